@@ -0,0 +1,21 @@
+package loggenie
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// adminHandler serves the metrics and, if enabled, the Go profiling
+// endpoints under /debug/pprof/
+func adminHandler(metrics http.Handler, profiling bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	if profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
@@ -0,0 +1,90 @@
+package loggenie
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+// Sinks a benchmark can run against
+const (
+	benchSinkNull    = logger.OutputNull
+	benchSinkDiscard = logger.OutputDiscard
+	benchSinkStdout  = logger.OutputStdout
+	benchSinkOTLP    = "otlp"
+)
+
+// benchSinks lists the sinks a benchmark can run against
+var benchSinks = []string{benchSinkNull, benchSinkDiscard, benchSinkStdout, benchSinkOTLP}
+
+// benchReport is the machine-readable result of a benchmark
+type benchReport struct {
+	Sink            string  `json:"sink"`
+	Format          string  `json:"format"`
+	Workers         int     `json:"workers"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Logs            int64   `json:"logs"`      // logs generated
+	Delivered       int64   `json:"delivered"` // logs the sink accepted
+	Bytes           int64   `json:"bytes"`
+	GeneratedPerSec float64 `json:"generated_per_sec"`
+	EventsPerSec    float64 `json:"events_per_sec"` // logs the sink accepted per second, the sustainable rate
+	MBPerSec        float64 `json:"mb_per_sec"`     // bytes generated per second, scaled by the share delivered
+	Lost            float64 `json:"lost"`           // share of logs the sink failed to deliver or dropped
+	TargetCPU       float64 `json:"target_cpu,omitempty"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	CPUUtilization  float64 `json:"cpu_utilization"` // share of the gomaxprocs cores used
+	EventsPerCore   float64 `json:"events_per_core"` // logs the sink accepted per second per core used
+	CPUs            int     `json:"cpus"`
+	GoMaxProcs      int     `json:"gomaxprocs"`
+	Version         string  `json:"version"`
+	Platform        string  `json:"platform"`
+}
+
+// newBenchReport derives the benchmark result from the summary of the run
+// and the CPU time it used
+func newBenchReport(summary runSummary, sink, format string, workers int, cpu time.Duration) benchReport {
+	build := version.Get()
+	report := benchReport{
+		Sink:            sink,
+		Format:          format,
+		Workers:         workers,
+		DurationSeconds: summary.DurationSeconds,
+		Logs:            summary.Logs,
+		Bytes:           summary.Bytes,
+		GeneratedPerSec: summary.LogsPerSec,
+		CPUSeconds:      round(cpu.Seconds()),
+		CPUs:            runtime.NumCPU(),
+		GoMaxProcs:      runtime.GOMAXPROCS(0),
+		Version:         build.Version,
+		Platform:        build.Platform,
+	}
+	for _, s := range summary.Sinks {
+		if s.Name == sink {
+			report.Delivered = s.Sent
+			report.Lost = math.Round(s.Lost()*1e4) / 1e4
+		}
+	}
+
+	if elapsed := summary.End.Sub(summary.Start).Seconds(); elapsed > 0 && summary.Logs > 0 {
+		delivered := float64(report.Delivered) / float64(summary.Logs)
+		report.EventsPerSec = round(float64(report.Delivered) / elapsed)
+		report.MBPerSec = round(float64(summary.Bytes) * delivered / 1e6 / elapsed)
+		report.CPUUtilization = math.Round(cpu.Seconds()/elapsed/float64(report.GoMaxProcs)*1e4) / 1e4
+	}
+	if cpu > 0 {
+		report.EventsPerCore = round(float64(report.Delivered) / cpu.Seconds())
+	}
+	return report
+}
+
+// write prints the report as JSON
+func (r benchReport) write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
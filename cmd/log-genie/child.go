@@ -0,0 +1,104 @@
+package loggenie
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// Streams of the child process logs are written through
+const (
+	childStdout = "stdout"
+	childStderr = "stderr"
+)
+
+// childProcess writes logs through the stdout or stderr of a child process,
+// so a container's logging driver takes them as it takes a workload's
+type childProcess struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+}
+
+// startChild runs log-genie again as a relay writing what it is given to
+// stream, which it shares with this process
+func startChild(stream string) (*childProcess, error) {
+	if stream != childStdout && stream != childStderr {
+		return nil, fmt.Errorf("unknown child stream %q (use %s or %s)", stream, childStdout, childStderr)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, "relay", "--stream="+stream)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &childProcess{cmd: cmd, in: in}, nil
+}
+
+// Write hands logs to the child
+func (c *childProcess) Write(p []byte) (int, error) {
+	return c.in.Write(p)
+}
+
+// Pid returns the process ID of the child
+func (c *childProcess) Pid() int {
+	return c.cmd.Process.Pid
+}
+
+// Close ends the input of the child and waits for it to write out the rest
+// and exit, killing it after timeout
+func (c *childProcess) Close(timeout time.Duration) error {
+	_ = c.in.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.cmd.Wait()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		_ = c.cmd.Process.Kill()
+		return errors.New("child did not exit before the drain timeout")
+	}
+}
+
+// runRelay implements the relay subcommand, the child of --output=child: it
+// copies its input to stdout or stderr write by write, as the process of a
+// workload writing its logs would, until the input ends
+func runRelay(args []string) int {
+	flags := flag.NewFlagSet("relay", flag.ExitOnError)
+	stream := flags.String("stream", childStdout, "Stream to write to: stdout or stderr")
+	flags.Parse(args)
+
+	out := os.Stdout
+	if *stream == childStderr {
+		out = os.Stderr
+	}
+	// The parent stops generating on a signal and ends the input once its
+	// logs are written; the relay must outlive it until then
+	signal.Ignore(os.Interrupt, syscall.SIGTERM)
+
+	// Hide the file types so each write is copied as it is, not spliced
+	buf := make([]byte, 64*1024)
+	if _, err := io.CopyBuffer(struct{ io.Writer }{out}, struct{ io.Reader }{os.Stdin}, buf); err != nil {
+		diag.Error("Failed to relay logs", "stream", *stream, "error", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,400 @@
+package loggenie
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logfile"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/netflow"
+	"github.com/rjonczy/log-genie/pkg/preset"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/statsd"
+	"github.com/rjonczy/log-genie/pkg/webhook"
+)
+
+// subcommands describes the subcommands of log-genie
+var subcommands = [][2]string{
+	{"preview", "Print sample logs locally without connecting to any sink"},
+	{"validate", "Check a config file against the generator flags"},
+	{"json-schema", "Print a JSON Schema of the records the generator flags produce"},
+	{"bench", "Measure the maximum sustainable rate of a sink"},
+	{"serve", "Stream generated logs to clients over Server-Sent Events and WebSocket"},
+	{"app", "Serve HTTP as a fake application logging every request"},
+	{"replay", "Send the records of a log file to the sinks, keeping their timing"},
+	{"verify", "Receive the generated logs back from a pipeline and check for loss"},
+	{"learn", "Infer a schema from sample logs"},
+	{"coordinate", "Share a total rate between worker instances"},
+	{"fleet", "Drive the control APIs of a set of instances and aggregate their stats"},
+	{"operate", "Reconcile LogLoad resources into generators on Kubernetes"},
+	{"service", "Install, remove, start or stop log-genie as a Windows service"},
+	{"list-formats", "List the output and level formats"},
+	{"list-profiles", "List the rate profiles and presets"},
+	{"list-sinks", "List where logs can be sent"},
+	{"version", "Print the version and build metadata"},
+	{"completion", "Print a shell completion script for bash, zsh or fish"},
+}
+
+// verifyFlags lists the flags verify adds to the generator flags
+var verifyFlags = []completionFlag{
+	{name: "receive", usage: "Address of the OTLP/HTTP receiver"},
+	{name: "receive-syslog", usage: "Address of the syslog receiver"},
+	{name: "receive-http", usage: "Address of the HTTP receiver"},
+	{name: "settle", usage: "How long to wait for logs in the pipeline"},
+	{name: "query-loki", usage: "Loki URL to read the logs back from"},
+	{name: "loki-query", usage: "LogQL stream selector of the logs"},
+	{name: "query-elasticsearch", usage: "Elasticsearch URL to read the logs back from"},
+	{name: "elasticsearch-index", usage: "Index pattern of the logs"},
+	{name: "elasticsearch-query", usage: "Query string selecting the logs"},
+}
+
+// completionShells lists the shells completion scripts are generated for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag is a flag offered by shell completion
+type completionFlag struct {
+	name    string
+	usage   string
+	boolean bool     // the flag takes no value
+	files   bool     // the value is a file name
+	values  []string // the possible values, if there are few
+}
+
+// subcommandFlags lists the flags of the subcommands that do not take the
+// generator flags
+var subcommandFlags = map[string][]completionFlag{
+	"learn": {
+		{name: "input", usage: "Sample NDJSON or logfmt file to learn from", files: true},
+		{name: "output", usage: "File to write the schema to", files: true},
+	},
+	"coordinate": {
+		{name: "listen", usage: "Address to serve the coordinator API on"},
+		{name: "rate", usage: "Total log rate shared by all workers"},
+		{name: "interval", usage: "How often workers report and stats are printed"},
+		{name: "assign", usage: "Extra flags for joining workers, handed out round-robin"},
+	},
+	"fleet": {
+		{name: "peers", usage: "Comma separated control API addresses of the peers"},
+		{name: "dns", usage: "Discover peers from DNS"},
+		{name: "split", usage: "Share the rate between the peers", boolean: true},
+		{name: "json", usage: "Print the results as JSON", boolean: true},
+		{name: "timeout", usage: "Bound on a request to a peer"},
+	},
+	"operate": {
+		{name: "server", usage: "URL of the Kubernetes API server"},
+		{name: "token", usage: "Bearer token for the API server"},
+		{name: "ca-file", usage: "CA certificate of the API server", files: true},
+		{name: "namespace", usage: "Namespace to watch LogLoads in"},
+		{name: "image", usage: "Image of the generators"},
+		{name: "resync", usage: "How often every LogLoad is reconciled again"},
+	},
+	"service": {
+		{name: "name", usage: "Name of the service"},
+		{name: "display-name", usage: "Name of the service shown by the Services console"},
+		{name: "description", usage: "Description of the service"},
+	},
+	"version": {
+		{name: "json", usage: "Print the build metadata as JSON", boolean: true},
+	},
+	"list-formats":  nil,
+	"list-profiles": nil,
+	"list-sinks":    nil,
+}
+
+// fileFlags name the generator flags whose value is a file
+var fileFlags = []string{"config", "env-file", "profile", "scenario", "schema", "message-corpus", "summary-file", "stats-file", "output-file"}
+
+// flagValues returns the possible values of the generator flags taking one
+// of a few values
+func flagValues() map[string][]string {
+	var formats []string
+	for _, format := range logger.Formats() {
+		formats = append(formats, format.Name)
+	}
+	var levelFormats []string
+	for _, format := range logger.LevelFormats() {
+		levelFormats = append(levelFormats, format.Name)
+	}
+	return map[string][]string{
+		"format":          formats,
+		"level-format":    levelFormats,
+		"nested-in":       {logger.NestedInFields, logger.NestedInBody},
+		"preset":          preset.Names(),
+		"verbosity":       {"debug", "info", "warn", "error"},
+		"messages":        {"catalog", "sentence"},
+		"arrival":         {"fixed", "poisson", "uniform"},
+		"pacing":          {"catch-up", "skip"},
+		"backpressure":    {"hold", "adapt"},
+		"wave":            {"sine", "diurnal"},
+		"ramp-shape":      {"linear", "exponential"},
+		"diag-level":      {"debug", "info", "warn", "error"},
+		"diag-format":     {diag.FormatText, diag.FormatJSON},
+		"report-format":   {stats.ReportText, stats.ReportJSON},
+		"output":          {logger.OutputStdout, logger.OutputNull, logger.OutputDiscard, logger.OutputChild, logger.OutputFile},
+		"child-stream":    {childStdout, childStderr},
+		"file-compress":   {logfile.CompressNone, logfile.CompressGzip, logfile.CompressZstd},
+		"netflow-version": {netflow.Version5, netflow.Version9, netflow.VersionIPFIX},
+		"statsd-flavor":   {statsd.FlavorStatsd, statsd.FlavorDogStatsD},
+		"http-preset":     webhook.Presets(),
+		"http-format":     {webhook.FormatNDJSON, webhook.FormatJSONArray},
+	}
+}
+
+// runCompletion implements the completion subcommand: it prints a completion
+// script for the given shell covering the generator flags defined so far
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "completion: expected one shell: %s\n", strings.Join(completionShells, ", "))
+		return 2
+	}
+
+	generator := generatorFlags()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(generator)
+	case "zsh":
+		writeZshCompletion(generator)
+	case "fish":
+		writeFishCompletion(generator)
+	default:
+		fmt.Fprintf(os.Stderr, "completion: unsupported shell %q: expected %s\n", args[0], strings.Join(completionShells, ", "))
+		return 2
+	}
+	return 0
+}
+
+// generatorFlags describes the flags defined on the command line
+func generatorFlags() []completionFlag {
+	values := flagValues()
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		boolean := false
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			boolean = b.IsBoolFlag()
+		}
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			usage:   f.Usage,
+			boolean: boolean,
+			files:   slices.Contains(fileFlags, f.Name),
+			values:  values[f.Name],
+		})
+	})
+	return flags
+}
+
+// subcommandNames returns the names of the subcommands
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for _, sub := range subcommands {
+		names = append(names, sub[0])
+	}
+	return names
+}
+
+// sortedSubcommands returns the subcommands with their own flags in a fixed
+// order
+func sortedSubcommands() []string {
+	names := make([]string, 0, len(subcommandFlags))
+	for name := range subcommandFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagNames returns the flags as --name words
+func flagNames(flags []completionFlag) string {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// writeBashCompletion prints the bash completion script
+func writeBashCompletion(generator []completionFlag) {
+	fmt.Print(`# bash completion for log-genie
+# Load with: source <(log-genie completion bash)
+_log_genie() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local flags
+
+    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+        COMPREPLY=($(compgen -W "` + strings.Join(subcommandNames(), " ") + `" -- "$cur"))
+        return
+    fi
+
+    case "$prev" in
+`)
+	for _, f := range generator {
+		switch {
+		case f.files:
+			fmt.Printf("        --%s|-%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.name, f.name)
+		case len(f.values) > 0:
+			fmt.Printf("        --%s|-%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", f.name, f.name, strings.Join(f.values, " "))
+		}
+	}
+	fmt.Printf("        --sink|-sink) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", strings.Join(benchSinks, " "))
+	fmt.Print(`        --input|-input|--output|-output|--file|-file) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+    esac
+
+    case "${COMP_WORDS[1]}" in
+`)
+	for _, name := range sortedSubcommands() {
+		fmt.Printf("        %s) flags=\"%s\" ;;\n", name, flagNames(subcommandFlags[name]))
+	}
+	fmt.Printf("        completion) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", strings.Join(completionShells, " "))
+	fmt.Printf("        validate) flags=\"--probe %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        preview) flags=\"-n %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        bench) flags=\"--sink --target-cpu %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        serve) flags=\"--listen %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        app) flags=\"--listen --logs-per-request --latency %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        verify) flags=\"%s %s\" ;;\n", flagNames(verifyFlags), flagNames(generator))
+	fmt.Printf("        replay) flags=\"--file --speed --rewrite-timestamps --loop --anonymize --anonymize-salt %s\" ;;\n", flagNames(generator))
+	fmt.Printf("        *) flags=\"%s\" ;;\n", flagNames(generator))
+	fmt.Print(`    esac
+    COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+}
+complete -F _log_genie log-genie
+`)
+}
+
+// zshDescribe returns a name:description word for _describe, escaping the
+// colons it splits on
+func zshDescribe(name, description string) string {
+	escape := strings.NewReplacer("'", `'\''`, ":", `\:`)
+	return "'" + escape.Replace(name) + ":" + escape.Replace(description) + "'"
+}
+
+// zshDescriptions returns the flags as name:description words
+func zshDescriptions(flags []completionFlag) string {
+	words := make([]string, 0, len(flags))
+	for _, f := range flags {
+		words = append(words, zshDescribe("--"+f.name, f.usage))
+	}
+	return strings.Join(words, " ")
+}
+
+// writeZshCompletion prints the zsh completion script
+func writeZshCompletion(generator []completionFlag) {
+	var subs []string
+	for _, sub := range subcommands {
+		subs = append(subs, zshDescribe(sub[0], sub[1]))
+	}
+
+	fmt.Print(`#compdef log-genie
+# Load with: source <(log-genie completion zsh)
+_log_genie() {
+    local -a subcommands flags
+    subcommands=(` + strings.Join(subs, " ") + `)
+
+    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then
+        _describe 'subcommand' subcommands
+        return
+    fi
+
+    case $words[CURRENT-1] in
+`)
+	for _, f := range generator {
+		switch {
+		case f.files:
+			fmt.Printf("        --%s|-%s) _files; return ;;\n", f.name, f.name)
+		case len(f.values) > 0:
+			fmt.Printf("        --%s|-%s) compadd %s; return ;;\n", f.name, f.name, strings.Join(f.values, " "))
+		}
+	}
+	fmt.Printf("        --sink|-sink) compadd %s; return ;;\n", strings.Join(benchSinks, " "))
+	fmt.Print(`        --input|-input|--output|-output|--file|-file) _files; return ;;
+    esac
+
+    case $words[2] in
+`)
+	for _, name := range sortedSubcommands() {
+		fmt.Printf("        %s) flags=(%s) ;;\n", name, zshDescriptions(subcommandFlags[name]))
+	}
+	fmt.Printf("        completion) compadd %s; return ;;\n", strings.Join(completionShells, " "))
+	fmt.Printf("        validate) flags=('--probe:Also check that configured endpoints accept connections' %s) ;;\n", zshDescriptions(generator))
+	fmt.Printf("        preview) flags=('-n:Number of sample logs to print' %s) ;;\n", zshDescriptions(generator))
+	fmt.Printf("        bench) flags=(%s %s %s) ;;\n", zshDescribe("--sink", "Sink to benchmark"), zshDescribe("--target-cpu", "Share of the cores to steer toward"), zshDescriptions(generator))
+	fmt.Printf("        serve) flags=(%s %s) ;;\n", zshDescribe("--listen", "Address to stream logs on"), zshDescriptions(generator))
+	fmt.Printf("        app) flags=(%s %s %s %s) ;;\n", zshDescribe("--listen", "Address to serve requests on"), zshDescribe("--logs-per-request", "Application logs per request"),
+		zshDescribe("--latency", "Longest simulated request time"), zshDescriptions(generator))
+	fmt.Printf("        verify) flags=(%s %s) ;;\n", zshDescriptions(verifyFlags), zshDescriptions(generator))
+	fmt.Printf("        replay) flags=(%s %s %s %s %s %s %s) ;;\n", zshDescribe("--file", "File to replay"), zshDescribe("--speed", "Replay speed, e.g. 2x"),
+		zshDescribe("--rewrite-timestamps", "Stamp records with the time they are sent"), zshDescribe("--loop", "Start over at the end of the file"),
+		zshDescribe("--anonymize", "Fields to replace consistently"), zshDescribe("--anonymize-salt", "Secret the replacements are derived from"), zshDescriptions(generator))
+	fmt.Printf("        *) flags=(%s) ;;\n", zshDescriptions(generator))
+	fmt.Print(`    esac
+    _describe 'flag' flags
+}
+compdef _log_genie log-genie
+`)
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// fishFlag prints the completion of one flag under a condition
+func fishFlag(condition string, f completionFlag) {
+	line := fmt.Sprintf("complete -c log-genie -n %s -l %s -d %s", fishQuote(condition), f.name, fishQuote(f.usage))
+	switch {
+	case f.boolean:
+	case f.files:
+		line += " -r -F"
+	case len(f.values) > 0:
+		line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+	default:
+		line += " -x"
+	}
+	fmt.Println(line)
+}
+
+// writeFishCompletion prints the fish completion script
+func writeFishCompletion(generator []completionFlag) {
+	fmt.Println("# fish completion for log-genie")
+	fmt.Println("# Load with: log-genie completion fish | source")
+	fmt.Println("complete -c log-genie -f")
+	for _, sub := range subcommands {
+		fmt.Printf("complete -c log-genie -n __fish_use_subcommand -a %s -d %s\n", sub[0], fishQuote(sub[1]))
+	}
+
+	// The generator flags apply to a run, a preview and a validation
+	own := sortedSubcommands()
+	generatorCondition := "not __fish_seen_subcommand_from completion " + strings.Join(own, " ")
+	for _, f := range generator {
+		fishFlag(generatorCondition, f)
+	}
+	fishFlag("__fish_seen_subcommand_from validate", completionFlag{name: "probe", usage: "Also check that configured endpoints accept connections", boolean: true})
+	fmt.Printf("complete -c log-genie -n %s -o n -d %s -x\n", fishQuote("__fish_seen_subcommand_from preview"), fishQuote("Number of sample logs to print"))
+	fishFlag("__fish_seen_subcommand_from bench", completionFlag{name: "sink", usage: "Sink to benchmark", values: benchSinks})
+	fishFlag("__fish_seen_subcommand_from bench", completionFlag{name: "target-cpu", usage: "Share of the cores to steer toward"})
+	fishFlag("__fish_seen_subcommand_from serve", completionFlag{name: "listen", usage: "Address to stream logs on"})
+	fishFlag("__fish_seen_subcommand_from app", completionFlag{name: "listen", usage: "Address to serve requests on"})
+	fishFlag("__fish_seen_subcommand_from app", completionFlag{name: "logs-per-request", usage: "Application logs per request"})
+	fishFlag("__fish_seen_subcommand_from app", completionFlag{name: "latency", usage: "Longest simulated request time"})
+	for _, f := range verifyFlags {
+		fishFlag("__fish_seen_subcommand_from verify", f)
+	}
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "file", usage: "File to replay", files: true})
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "speed", usage: "Replay speed, e.g. 2x"})
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "rewrite-timestamps", usage: "Stamp records with the time they are sent", boolean: true})
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "loop", usage: "Start over at the end of the file", boolean: true})
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "anonymize", usage: "Fields to replace consistently"})
+	fishFlag("__fish_seen_subcommand_from replay", completionFlag{name: "anonymize-salt", usage: "Secret the replacements are derived from"})
+
+	for _, name := range own {
+		for _, f := range subcommandFlags[name] {
+			fishFlag("__fish_seen_subcommand_from "+name, f)
+		}
+	}
+	fmt.Printf("complete -c log-genie -n %s -a %s\n", fishQuote("__fish_seen_subcommand_from completion"), fishQuote(strings.Join(completionShells, " ")))
+}
@@ -0,0 +1,100 @@
+package loggenie
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/cluster"
+	"github.com/rjonczy/log-genie/pkg/diag"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// defaultCoordinatorAddr is where the coordinator listens by default
+const defaultCoordinatorAddr = ":7070"
+
+// assignFlag collects repeated --assign values
+type assignFlag []string
+
+// String returns the assignments separated by semicolons
+func (a *assignFlag) String() string {
+	return strings.Join(*a, "; ")
+}
+
+// Set adds an assignment
+func (a *assignFlag) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// runCoordinate implements the coordinate subcommand: it shares a total rate
+// between worker instances started with --coordinator and prints their
+// aggregated stats
+func runCoordinate(args []string) int {
+	flags := flag.NewFlagSet("coordinate", flag.ExitOnError)
+	listen := flags.String("listen", defaultCoordinatorAddr, "Address to serve the coordinator API on")
+	rate := new(ratelimit.Flag)
+	*rate = defaultRate
+	flags.Var(rate, "rate", "Total log rate shared by all workers")
+	interval := flags.Duration("interval", 2*time.Second, "How often workers report and stats are printed")
+	var assign assignFlag
+	flags.Var(&assign, "assign", "Extra flags for joining workers, handed out round-robin (repeatable), e.g. \"--messages=sentence --sequence\"")
+	flags.Parse(args)
+
+	coordinator, err := cluster.New(cluster.Config{
+		Rate:     float64(*rate),
+		Assign:   assign,
+		Interval: *interval,
+	})
+	if err != nil {
+		diag.Error("Invalid coordinator settings", "error", err)
+		return 1
+	}
+
+	server := &http.Server{Addr: *listen, Handler: coordinator.Handler()}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	diag.Info("Coordinating workers", "rate", rate.String(), "address", *listen)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var lastLogs int64
+	last := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			stats := coordinator.Stats()
+			now := time.Now()
+			diag.Info("Worker stats", "workers", stats.Workers, "logs", stats.Logs,
+				"logs_per_sec", math.Round(float64(stats.Logs-lastLogs)/now.Sub(last).Seconds()*10)/10, "bytes", stats.Bytes)
+			lastLogs, last = stats.Logs, now
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				diag.Error("Coordinator API failed", "address", *listen, "error", err)
+				return 1
+			}
+			return 0
+		case <-sigs:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+
+			stats := coordinator.Stats()
+			diag.Info("Workers stopped", "logs", stats.Logs, "bytes", stats.Bytes)
+			return 0
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package loggenie
+
+import "github.com/rjonczy/log-genie/pkg/diag"
+
+// Exit codes of a generator run, so scripted benchmarks can detect failure.
+// Invalid configuration exits with 1 before generating.
+const (
+	exitOK          = 0
+	exitNeverSent   = 3 // a sink delivered none of the logs emitted to it
+	exitSinkFailing = 4 // a sink was still failing when the run ended
+	exitLoss        = 5 // a sink lost more logs than --max-loss allows
+	exitVerify      = 6 // verification found lost, duplicated or corrupted logs
+)
+
+// runExitCode checks the sinks of a finished run, reporting the problem that
+// decides the exit code
+func runExitCode(summary runSummary, maxLoss float64) int {
+	code := exitOK
+	fail := func(exitCode int, msg string, args ...interface{}) {
+		diag.Error(msg, args...)
+		if code == exitOK {
+			code = exitCode
+		}
+	}
+
+	for _, sink := range summary.Sinks {
+		switch {
+		case sink.Sent == 0 && sink.Failed+sink.Dropped > 0:
+			fail(exitNeverSent, "Sink never delivered a log", "sink", sink.Name, "failed", sink.Failed, "dropped", sink.Dropped)
+		case sink.Failing:
+			fail(exitSinkFailing, "Sink was failing when the run ended", "sink", sink.Name, "failed", sink.Failed)
+		case sink.Lost() > maxLoss:
+			fail(exitLoss, "Sink lost more logs than allowed", "sink", sink.Name, "lost", round(sink.Lost()*100)/100, "max_loss", maxLoss)
+		}
+	}
+	return code
+}
@@ -0,0 +1,396 @@
+package loggenie
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/identity"
+	"github.com/rjonczy/log-genie/pkg/logfile"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/netflow"
+	"github.com/rjonczy/log-genie/pkg/preset"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/statsd"
+	"github.com/rjonczy/log-genie/pkg/webhook"
+)
+
+// mode is what a subcommand running the configured generator does with it
+type mode struct {
+	preview      bool
+	describing   bool
+	validating   bool
+	completing   bool
+	benchmarking bool
+	serving      bool
+	faking       bool
+	replaying    bool
+	verifying    bool
+	// service is the name of the Windows service being run
+	service string
+}
+
+// settings holds the generator flags
+type settings struct {
+	rate                   *ratelimit.Flag
+	verbosity              *string
+	telemetryEnabled       *bool
+	telemetryEndpoint      *string
+	localLogs              *bool
+	output                 *string
+	childStream            *string
+	outputFile             *string
+	fileRotateSize         *string
+	fileKeep               *int
+	fileCompress           *string
+	showResponses          *bool
+	applicationID          *string
+	clockOffset            *time.Duration
+	timezone               *string
+	timestampField         *string
+	timestampFormat        *string
+	streamID               *string
+	services               *int
+	instances              *int
+	identitySeed           *string
+	tenants                *string
+	noisyTenant            *string
+	noisyEvery             *time.Duration
+	noisyDuration          *time.Duration
+	noisyMultiplier        *float64
+	sequence               *bool
+	checksum               *bool
+	messageCorpus          *string
+	markovOrder            *int
+	schemaFile             *string
+	messages               *string
+	repeatProbability      *float64
+	repeatMin              *int
+	repeatMax              *int
+	severityAttrs          *bool
+	throughput             *ratelimit.ByteFlag
+	messageSize            *int
+	nestedDepth            *int
+	nestedWidth            *int
+	nestedIn               *string
+	attributesMin          *int
+	attributesMax          *int
+	attributeKeys          *int
+	keyCollisions          *bool
+	burstEvery             *time.Duration
+	burstDuration          *time.Duration
+	burstMultiplier        *float64
+	burstOffset            *time.Duration
+	rampFrom               *ratelimit.Flag
+	rampTo                 *ratelimit.Flag
+	rampDuration           *time.Duration
+	rampHold               *time.Duration
+	rampDown               *bool
+	rampShape              *string
+	wave                   *string
+	wavePeriod             *time.Duration
+	waveAmplitude          *float64
+	arrivalProcess         *string
+	jitter                 *float64
+	pacing                 *string
+	maxLag                 *time.Duration
+	backpressure           *string
+	errorRatio             *float64
+	profileFile            *string
+	scenarioFile           *string
+	scheduleSpec           *string
+	drift                  *bool
+	driftMin               *ratelimit.Flag
+	driftMax               *ratelimit.Flag
+	driftStep              *float64
+	driftInterval          *time.Duration
+	workers                *int
+	startDelay             *time.Duration
+	stagger                *time.Duration
+	warmUpDuration         *time.Duration
+	pregenerate            *int
+	restamp                *bool
+	coordinatorURL         *string
+	workerID               *string
+	controlAddr            *string
+	metricsAddr            *string
+	pprofEnabled           *bool
+	selfMetrics            *bool
+	selfMetricsEndpoint    *string
+	selfMetricsInterval    *time.Duration
+	traces                 *bool
+	tracesEndpoint         *string
+	requestMetrics         *bool
+	requestMetricsEndpoint *string
+	requestMetricsInterval *time.Duration
+	statsdTarget           *string
+	statsdFlavor           *string
+	statsdPrefix           *string
+	snmpTraps              *string
+	snmpCommunity          *string
+	netflowTarget          *string
+	netflowVersion         *string
+	lumberjackTarget       *string
+	httpSinkURL            *string
+	httpPreset             *string
+	httpFormat             *string
+	httpBatch              *int
+	httpTenantHeader       *string
+	logplexURL             *string
+	logplexToken           *string
+	lumberjackBatch        *int
+	duration               *time.Duration
+	drainTimeout           *time.Duration
+	count                  *int64
+	levelWeights           *string
+	attributes             *string
+	presetName             *string
+	format                 *string
+	levelFormat            *string
+	buffer                 *string
+	flushInterval          *time.Duration
+	asyncQueue             *int
+	asyncWriters           *int
+	asyncOverflow          *string
+	plugins                *string
+	pluginSource           *string
+	pluginSinks            *string
+	pluginOptions          *string
+	transform              *string
+	spanEvents             *bool
+	process                *string
+
+	// Flags of the subcommands running the generator, left at their zero
+	// values by the others
+	previewCount       int
+	benchSink          string
+	targetCPU          float64
+	serveAddr          string
+	appAddr            string
+	logsPerRequest     int
+	appLatency         time.Duration
+	replayFile         string
+	replaySpeed        string
+	rewriteTimestamps  bool
+	loopReplay         bool
+	anonymize          string
+	anonymizeSalt      string
+	verifyAddr         string
+	verifySyslogAddr   string
+	verifyHTTPAddr     string
+	verifySettle       time.Duration
+	queryLoki          string
+	lokiQuery          string
+	queryElasticsearch string
+	elasticsearchIndex string
+	elasticsearchQuery string
+	probe              bool
+
+	configFile     *string
+	envFile        *string
+	showProgress   *bool
+	quiet          *bool
+	diagLevel      *string
+	diagFormat     *string
+	maxLoss        *float64
+	maxMemory      *string
+	reportInterval *time.Duration
+	reportFormat   *string
+	statsFile      *string
+	summaryFile    *string
+	showVersion    *bool
+}
+
+// defineFlags defines the generator flags, and those of the subcommand m
+// runs, on the command line flag set
+func defineFlags(m mode) *settings {
+	f := &settings{}
+	f.rate = new(ratelimit.Flag)
+	*f.rate = defaultRate
+	flag.Var(f.rate, "rate", "Log rate: logs per second, an expression like 500/m, 10k/s, 2/h, max for no limit, or 0 to start paused")
+	f.verbosity = flag.String("verbosity", defaultVerbosity, "Log verbosity level: debug, info, warn, error")
+	f.telemetryEnabled = flag.Bool("telemetry", false, "Enable OpenTelemetry logs export")
+	f.telemetryEndpoint = flag.String("telemetry-endpoint", defaultTelemetryEndpoint, "OpenTelemetry collector endpoint")
+	f.localLogs = flag.Bool("local-logs", false, "Enable local logs to stdout/stderr even when telemetry is enabled")
+	f.output = flag.String("output", logger.OutputStdout, "Local output: stdout, null (counts logs without serializing them), discard-after-serialize (serializes and discards them), child (writes through a child process) or file")
+	f.childStream = flag.String("child-stream", childStdout, "Stream of the child process --output=child writes through: stdout or stderr")
+	f.outputFile = flag.String("output-file", defaultOutputFile, "File --output=file writes to")
+	f.fileRotateSize = flag.String("file-rotate-size", "", "Rotate the output file once it reaches this size, e.g. 100MiB (default never)")
+	f.fileKeep = flag.Int("file-keep", 0, "Rotated output files kept, the oldest removed first (0 keeps all)")
+	f.fileCompress = flag.String("file-compress", logfile.CompressNone, "Compression of the output file: none, gzip or zstd (rotated files once closed, or the file as written, with a .gz or .zst suffix, if not rotated)")
+	f.showResponses = flag.Bool("show-responses", false, "Show responses from the OTEL collector")
+	f.applicationID = flag.String("application-id", defaultApplicationID, "Application ID for OTEL resource attributes")
+	f.clockOffset = flag.Duration("clock-offset", 0, "Skew added to generated timestamps, e.g. -90s or 2h")
+	f.timezone = flag.String("timezone", defaultTimezone, "Timezone of generated timestamps: IANA name, UTC, Local or offset like +05:30")
+	f.timestampField = flag.String("timestamp-field", defaultTimestampField, "Name of the generated timestamp field")
+	f.timestampFormat = flag.String("timestamp-format", defaultTimestampFormat, "Timestamp format: epoch_s, epoch_ms, epoch_us, epoch_ns, rfc3339, rfc3339nano, none, or a strftime/Go layout")
+	f.streamID = flag.String("stream-id", "", "Stream ID embedded with sequence numbers (defaults to the application ID)")
+	f.services = flag.Int("services", 0, "Generate the logs of a fixed fleet of this many services, each with hosts and pods, the same on every start (0 invents a service per log)")
+	f.instances = flag.Int("instances", identity.DefaultInstances, "Instances of each service of the fleet, each with a host and pod")
+	f.identitySeed = flag.String("identity-seed", "", "Seed the fleet of services is derived from (defaults to the application ID)")
+	f.tenants = flag.String("tenants", "", "Tag every log with a tenant_id: a number of tenants, or names with relative weights, e.g. acme=5,globex=2,initech")
+	f.noisyTenant = flag.String("noisy-tenant", "", "Make a tenant of --tenants a noisy neighbor whose logs periodically spike")
+	f.noisyEvery = flag.Duration("noisy-every", defaultNoisyEvery, "How often the noisy tenant spikes")
+	f.noisyDuration = flag.Duration("noisy-duration", defaultBurstDuration, "How long each spike of the noisy tenant lasts")
+	f.noisyMultiplier = flag.Float64("noisy-multiplier", defaultBurstMultiplier, "Multiplier of the noisy tenant's rate during a spike")
+	f.sequence = flag.Bool("sequence", false, "Embed per-stream sequence numbers in every log")
+	f.checksum = flag.Bool("checksum", false, "Embed a payload checksum in every log (implies --sequence)")
+	f.messageCorpus = flag.String("message-corpus", "", "Corpus file (plain or NDJSON) to train a Markov message generator on")
+	f.markovOrder = flag.Int("markov-order", defaultMarkovOrder, "Number of preceding words the Markov generator conditions on")
+	f.schemaFile = flag.String("schema", "", "Schema file produced by 'log-genie learn' to generate events from")
+	f.messages = flag.String("messages", defaultMessages, "Message source: catalog (level-appropriate messages) or sentence (random sentences)")
+	f.repeatProbability = flag.Float64("repeat-probability", 0, "Probability that a log starts a burst of identical messages (0 disables)")
+	f.repeatMin = flag.Int("repeat-min", defaultRepeatMin, "Minimum number of identical messages in a burst")
+	f.repeatMax = flag.Int("repeat-max", defaultRepeatMax, "Maximum number of identical messages in a burst")
+	f.severityAttrs = flag.Bool("severity-attributes", true, "Add attributes typical for each severity (stack traces on errors, retries on warnings...)")
+	f.throughput = new(ratelimit.ByteFlag)
+	flag.Var(f.throughput, "throughput", "Pace by emitted bytes instead of events, e.g. 5MB/s or 512KiB/s (overrides --rate)")
+	f.messageSize = flag.Int("message-size", 0, "Pad or truncate every message to this many bytes (0 keeps natural length)")
+	f.nestedDepth = flag.Int("nested-depth", 0, "Add objects and arrays nested this many levels deep to every generated log (0 disables nesting)")
+	f.nestedWidth = flag.Int("nested-width", logger.DefaultNestedWidth, "Members of each nested object and elements of each nested array")
+	f.nestedIn = flag.String("nested-in", logger.NestedInFields, "Where the nested structure goes: fields (under payload) or body (the message, encoded as JSON)")
+	f.attributesMin = flag.Int("attributes-min", 0, "Fewest generated attributes each log carries, drawn from a pool of keys")
+	f.attributesMax = flag.Int("attributes-max", 0, "Most generated attributes each log carries (0 disables them)")
+	f.attributeKeys = flag.Int("attribute-keys", 0, "Size of the pool of keys generated attributes are drawn from (default 200, at least --attributes-max)")
+	f.keyCollisions = flag.Bool("key-collisions", false, "Add field names that clash once normalized or expanded, e.g. http.status and http_status, error and error.code")
+	f.burstEvery = flag.Duration("burst-every", 0, "Start a burst of elevated rate this often, e.g. 5m (0 disables bursts)")
+	f.burstDuration = flag.Duration("burst-duration", defaultBurstDuration, "How long each burst lasts")
+	f.burstMultiplier = flag.Float64("burst-multiplier", defaultBurstMultiplier, "Rate multiplier applied during a burst")
+	f.burstOffset = flag.Duration("burst-offset", 0, "Delay before the first burst")
+	f.rampFrom = new(ratelimit.Flag)
+	flag.Var(f.rampFrom, "ramp-from", "Rate to start a ramp from (with --ramp-duration)")
+	f.rampTo = new(ratelimit.Flag)
+	flag.Var(f.rampTo, "ramp-to", "Rate to ramp to (defaults to --rate)")
+	f.rampDuration = flag.Duration("ramp-duration", 0, "Time to ramp from --ramp-from to --ramp-to (0 disables ramping)")
+	f.rampHold = flag.Duration("ramp-hold", 0, "Time to hold the target rate before ramping down")
+	f.rampDown = flag.Bool("ramp-down", false, "Ramp back down to --ramp-from after the hold")
+	f.rampShape = flag.String("ramp-shape", ratelimit.RampLinear, "Ramp curve: linear or exponential")
+	f.wave = flag.String("wave", "", "Modulate the rate on a wave: sine or diurnal (empty disables)")
+	f.wavePeriod = flag.Duration("wave-period", defaultWavePeriod, "Period of the rate wave (a full day for diurnal)")
+	f.waveAmplitude = flag.Float64("wave-amplitude", defaultWaveAmplitude, "Relative amplitude of the rate wave, 0 to 1")
+	f.arrivalProcess = flag.String("arrival", ratelimit.ArrivalFixed, "Inter-arrival timing: fixed, poisson or uniform")
+	f.jitter = flag.Float64("jitter", defaultJitter, "Relative spread of uniform arrivals, 0 to 1")
+	f.pacing = flag.String("pacing", ratelimit.PacingCatchUp, "What generation does with the logs it falls behind on, e.g. stalled by a slow sink: catch-up (emits them unpaced) or skip")
+	f.maxLag = flag.Duration("max-lag", defaultMaxLag, "How far behind the rate generation may fall and still catch up; the logs beyond are skipped")
+	f.backpressure = flag.String("backpressure", ratelimit.BackpressureHold, "What generation does when sinks cannot keep up: hold (the rate, sinks drop what they cannot take) or adapt (slows down until they keep up)")
+	f.errorRatio = flag.Float64("error-ratio", defaultErrorRatio, "Share of logs generated as dedicated error logs, 0 to 1")
+	f.profileFile = flag.String("profile", "", "Load profile file mapping elapsed time or time of day to rates and error ratios")
+	f.scenarioFile = flag.String("scenario", "", "Scenario file of streams, each generated at its own rate, bursts and start delay")
+	f.scheduleSpec = flag.String("schedule", "", "Cron expressions (separated by ';') of minutes when generation is active, e.g. '* 9-17 * * 1-5'")
+	f.drift = flag.Bool("drift", false, "Let the rate random-walk within --drift-min and --drift-max")
+	f.driftMin = new(ratelimit.Flag)
+	flag.Var(f.driftMin, "drift-min", "Lower bound of the rate random walk (defaults to half the rate)")
+	f.driftMax = new(ratelimit.Flag)
+	flag.Var(f.driftMax, "drift-max", "Upper bound of the rate random walk (defaults to double the rate)")
+	f.driftStep = flag.Float64("drift-step", defaultDriftStep, "Maximum relative change of the rate per drift interval")
+	f.driftInterval = flag.Duration("drift-interval", defaultDriftInterval, "How often the drifting rate takes a step")
+	f.workers = flag.Int("workers", defaultWorkers, "Number of concurrent generator goroutines sharing the rate")
+	f.startDelay = flag.Duration("start-delay", 0, "Wait this long before generating, e.g. for the pipeline to come up")
+	f.stagger = flag.Duration("stagger", 0, "Start every worker, of each stream, after a random delay up to this long, so instances started together do not emit in lockstep")
+	f.warmUpDuration = flag.Duration("warm-up", 0, "Leave the logs of this long after the start out of the run summary, e.g. while connections are established")
+	f.pregenerate = flag.Int("pregenerate", 0, "Pregenerate this many logs (and as many error logs) and cycle through them (0 generates every log)")
+	f.restamp = flag.Bool("restamp", true, "Give pregenerated logs a fresh timestamp when they are emitted")
+	f.coordinatorURL = flag.String("coordinator", "", "Join the coordinator at this address and generate the rate share it assigns")
+	f.workerID = flag.String("worker-id", "", "ID reported to the coordinator (defaults to hostname and PID)")
+	f.controlAddr = flag.String("control-addr", "", "Serve the runtime control API (e.g. changing the rate) on this address")
+	f.metricsAddr = flag.String("metrics-addr", "", "Serve Prometheus metrics of the generator on this address under /metrics")
+	f.pprofEnabled = flag.Bool("pprof", false, "Serve Go profiling endpoints under /debug/pprof/ on the metrics address")
+	f.selfMetrics = flag.Bool("self-metrics", false, "Export log-genie's own operational metrics as OTLP metrics")
+	f.selfMetricsEndpoint = flag.String("self-metrics-endpoint", "", "OTLP endpoint for self metrics (defaults to the host of --telemetry-endpoint)")
+	f.selfMetricsInterval = flag.Duration("self-metrics-interval", defaultSelfMetricsEvery, "How often self metrics are exported")
+	f.traces = flag.Bool("traces", false, "Export an OTLP trace for every log, correlated with it by trace and span IDs")
+	f.tracesEndpoint = flag.String("traces-endpoint", "", "OTLP endpoint for traces (defaults to the host of --telemetry-endpoint)")
+	f.requestMetrics = flag.Bool("request-metrics", false, "Export OTLP metrics of the requests the logs describe: counts, latency histograms and errors")
+	f.requestMetricsEndpoint = flag.String("request-metrics-endpoint", "", "OTLP endpoint for request metrics (defaults to the host of --telemetry-endpoint)")
+	f.requestMetricsInterval = flag.Duration("request-metrics-interval", defaultSelfMetricsEvery, "How often request metrics are exported")
+	f.statsdTarget = flag.String("statsd", "", "Emit statsd metrics of the logs and the requests they describe to this server, host or host:port (default port 8125)")
+	f.statsdFlavor = flag.String("statsd-flavor", statsd.FlavorDogStatsD, "Line protocol of the statsd metrics: statsd (tags in the name) or dogstatsd (tags appended)")
+	f.statsdPrefix = flag.String("statsd-prefix", defaultStatsdPrefix, "Prefix of the statsd metric names")
+	f.snmpTraps = flag.String("snmp-traps", "", "Send every log as an SNMPv2c trap to this receiver, host or host:port (default port 162)")
+	f.snmpCommunity = flag.String("snmp-community", defaultSNMPCommunity, "Community string of the SNMP traps")
+	f.netflowTarget = flag.String("netflow", "", "Export a flow record for every log to this collector over UDP, host or host:port (default port 2055)")
+	f.netflowVersion = flag.String("netflow-version", netflow.Version9, "Flow export version: 5, 9 or ipfix")
+	f.lumberjackTarget = flag.String("lumberjack", "", "Send the logs to this Logstash or Beats input over the Lumberjack v2 protocol, host or host:port (default port 5044)")
+	f.httpSinkURL = flag.String("http-sink", "", "Post the logs as JSON in batches to this URL (defaults to the agent's address with --http-preset)")
+	f.httpPreset = flag.String("http-preset", "", "Preconfigure the HTTP sink for an agent's HTTP input: "+strings.Join(webhook.Presets(), ", "))
+	f.httpFormat = flag.String("http-format", "", "Body of the HTTP sink's requests: ndjson or json-array (default ndjson, or the preset's)")
+	f.httpBatch = flag.Int("http-batch", 0, "Logs per request of the HTTP sink (default 500, or the preset's)")
+	f.httpTenantHeader = flag.String("http-tenant-header", "", "Batch the HTTP sink's logs per tenant, naming it in this header, e.g. X-Scope-OrgID")
+	f.logplexURL = flag.String("logplex", "", "Post the logs to this HTTPS log drain in Heroku's Logplex format")
+	f.logplexToken = flag.String("logplex-token", "", "Drain token sent with every Logplex batch (default a random d.<uuid>)")
+	f.lumberjackBatch = flag.Int("lumberjack-batch", defaultLumberjackBatch, "Events per Lumberjack window, each acknowledged before the next")
+	f.duration = flag.Duration("duration", 0, "Stop after this long, flush and exit (0 runs until interrupted)")
+	f.drainTimeout = flag.Duration("drain-timeout", defaultDrainTimeout, "How long each sink may take on shutdown to write or export the logs it holds")
+	f.count = flag.Int64("count", 0, "Stop after emitting exactly this many logs, flush and exit (0 for unlimited)")
+	f.levelWeights = flag.String("level-weights", "", "Relative weights of generated levels, e.g. debug=1,info=6,warn=2,error=1 (default uniform)")
+	f.attributes = flag.String("attributes", "", "Static attributes added to every log, e.g. env=prod,region=eu-west-1")
+	f.presetName = flag.String("preset", "", "Built-in preset of realistic settings: "+strings.Join(preset.Names(), ", "))
+	f.format = flag.String("format", defaultFormat, "Output format of local logs: json, logfmt or plain")
+	f.levelFormat = flag.String("level-format", "", "How local logs write levels: lower, upper, short, python, log4j, java, syslog or numeric (default lower, upper for plain)")
+	f.buffer = flag.String("buffer", "", "Buffer up to this much local output before writing it, e.g. 64KiB (default writes every log)")
+	f.flushInterval = flag.Duration("flush-interval", defaultFlushInterval, "Write out buffered local output at least this often (0 only when the buffer fills)")
+	f.asyncQueue = flag.Int("async-queue", 0, "Queue up to this many local logs for writer goroutines, decoupling generation from slow output (0 writes while generating)")
+	f.asyncWriters = flag.Int("async-writers", defaultAsyncWriters, "Number of goroutines writing queued local logs")
+	f.asyncOverflow = flag.String("async-overflow", logger.OverflowBlock, "What a full local queue does: block (slows generation), drop (the new log) or drop-oldest")
+	f.plugins = flag.String("plugin", "", "Comma-separated plugin files (.so) providing log sources and sinks")
+	f.pluginSource = flag.String("plugin-source", "", "Generate logs with this plugin source instead of the built-in generator")
+	f.pluginSinks = flag.String("plugin-sinks", "", "Comma-separated plugin sinks every log is sent to")
+	f.pluginOptions = flag.String("plugin-options", "", "Options given to plugin sources and sinks, e.g. topic=logs,brokers=kafka:9092")
+	f.transform = flag.String("transform", "", "Lua scripts rewriting or dropping logs before a sink takes them, as sink=file.lua pairs, e.g. otlp=enrich.lua,*=redact.lua")
+	f.spanEvents = flag.Bool("span-events", false, "Shape logs like span events: event.name on every log, exception.* attributes on errors")
+	f.process = flag.String("process", "", "Processors run on every log before the sinks, separated by ';': redact:fields, mask:regexp, sample:ratio, rate-limit:rate, enrich:key=value, route:level=sink|sink")
+	if m.describing {
+		flag.IntVar(&f.previewCount, "n", defaultSchemaSamples, "Number of sample logs to infer the schema from")
+	} else if m.preview {
+		flag.IntVar(&f.previewCount, "n", defaultPreviewCount, "Number of sample logs to print")
+	}
+	if m.benchmarking {
+		flag.StringVar(&f.benchSink, "sink", defaultBenchSink, "Sink to benchmark: "+strings.Join(benchSinks, ", "))
+		flag.Float64Var(&f.targetCPU, "target-cpu", 0, "Steer the rate so the process uses this share of the cores, 0 to 1, and report events/s per core (0 runs flat out)")
+	}
+	if m.serving {
+		flag.StringVar(&f.serveAddr, "listen", defaultServeAddr, "Address to stream logs on, over Server-Sent Events (/events) and WebSocket (/ws)")
+	}
+	if m.faking {
+		flag.StringVar(&f.appAddr, "listen", defaultAppAddr, "Address the fake application serves HTTP requests on")
+		flag.IntVar(&f.logsPerRequest, "logs-per-request", 0, "Application logs generated per request besides its access log")
+		flag.DurationVar(&f.appLatency, "latency", 0, "Requests take a random time up to this long")
+	}
+	if m.replaying {
+		flag.StringVar(&f.replayFile, "file", "", "NDJSON or logfmt file to replay")
+		flag.StringVar(&f.replaySpeed, "speed", defaultReplaySpeed, "Replay speed relative to the original timing, e.g. 2x or 0.5x, or max for no waiting")
+		flag.BoolVar(&f.rewriteTimestamps, "rewrite-timestamps", false, "Stamp replayed records with the time they are sent instead of their original time")
+		flag.BoolVar(&f.loopReplay, "loop", false, "Start over at the end of the file until stopped, rewriting timestamps")
+		flag.StringVar(&f.anonymize, "anonymize", "", "Fields to replace consistently, by a hash or a fake of the same kind, e.g. ip_address=fake,email=fake,user_id=hash")
+		flag.StringVar(&f.anonymizeSalt, "anonymize-salt", "", "Secret the replacements are derived from, for the same replacements across runs (default random)")
+	}
+	if m.verifying {
+		flag.StringVar(&f.verifyAddr, "receive", defaultVerifyAddr, "Address of the OTLP/HTTP receiver the pipeline delivers the logs back to, empty to disable")
+		flag.StringVar(&f.verifySyslogAddr, "receive-syslog", "", "Address of a syslog receiver (UDP and TCP) the pipeline delivers the logs back to")
+		flag.StringVar(&f.verifyHTTPAddr, "receive-http", "", "Address of an HTTP receiver taking NDJSON, JSON arrays or logfmt lines the pipeline delivers the logs back to")
+		flag.DurationVar(&f.verifySettle, "settle", defaultVerifySettle, "How long to wait for logs still in the pipeline after generating")
+		flag.StringVar(&f.queryLoki, "query-loki", "", "Read the logs back from this Loki URL after the run instead of receiving them")
+		flag.StringVar(&f.lokiQuery, "loki-query", defaultLokiQuery, "LogQL stream selector of the logs in Loki")
+		flag.StringVar(&f.queryElasticsearch, "query-elasticsearch", "", "Read the logs back from this Elasticsearch URL after the run instead of receiving them")
+		flag.StringVar(&f.elasticsearchIndex, "elasticsearch-index", defaultElasticsearchIndex, "Index pattern of the logs in Elasticsearch")
+		flag.StringVar(&f.elasticsearchQuery, "elasticsearch-query", "*", "Query string selecting the logs in Elasticsearch")
+	}
+	f.configFile = flag.String("config", "", "YAML config file with flag names as keys; reloaded on SIGHUP")
+	f.envFile = flag.String("env-file", defaultEnvFile, "File of KEY=value environment variables loaded before reading the environment")
+	f.showProgress = flag.Bool("progress", false, "Keep a live status line of logs sent, rate, queue depth and errors on stderr")
+	f.quiet = flag.Bool("quiet", false, "Suppress status messages on stderr; warnings and errors are still reported")
+	f.diagLevel = flag.String("diag-level", defaultDiagLevel, "Level of log-genie's own diagnostics on stderr: debug, info, warn, error")
+	f.diagFormat = flag.String("diag-format", defaultDiagFormat, "Format of log-genie's own diagnostics on stderr: text or json")
+	f.maxLoss = flag.Float64("max-loss", defaultMaxLoss, "Exit with code 5 if a sink fails to deliver or drops more than this share of logs, 0 to 1")
+	f.maxMemory = flag.String("max-memory", "", "Throttle generation while the process holds nearly this much memory, e.g. 512MiB (default unlimited)")
+	f.reportInterval = flag.Duration("report-interval", defaultReportInterval, "How often logs generated and sent per sink are reported on stderr, 0 to never")
+	f.reportFormat = flag.String("report-format", stats.ReportText, "Format of the periodic reports: text (through the diagnostics) or json (an object per line)")
+	f.statsFile = flag.String("stats-file", "", "Write the stats snapshot dumped on SIGUSR1 to this file instead of stderr")
+	f.summaryFile = flag.String("summary-file", "", "Write a JSON summary of the run to this file on shutdown")
+	f.showVersion = flag.Bool("version", false, "Print the version and build metadata and exit")
+	if m.validating {
+		flag.BoolVar(&f.probe, "probe", false, "Also check that configured endpoints accept connections")
+	}
+	return f
+}
@@ -0,0 +1,177 @@
+package loggenie
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/fleet"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// fleetActions describes what the fleet subcommand can do
+var fleetActions = [][2]string{
+	{"status", "Print the rate and stats of every peer and their totals"},
+	{"rate", "Change the base rate of every peer, e.g. rate 10k/s"},
+	{"set", "Change settings of every peer, e.g. set error-ratio=0.2 level-weights=info=6,error=1"},
+}
+
+// runFleet implements the fleet subcommand: it drives the control APIs of a
+// set of peers started with --control-addr, fanning out changes and
+// aggregating their stats
+func runFleet(args []string) int {
+	flags := flag.NewFlagSet("fleet", flag.ExitOnError)
+	peers := flags.String("peers", "", "Comma separated control API addresses of the peers")
+	dns := flags.String("dns", "", "Discover peers from DNS: host:port looks up addresses, a bare name looks up SRV records")
+	split := flags.Bool("split", false, "Share the rate given to the rate action between the peers instead of giving it to each")
+	jsonOutput := flags.Bool("json", false, "Print the results as JSON")
+	timeout := flags.Duration("timeout", 0, "Bound on a request to a peer (default 5s)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: log-genie fleet [flags] [action [arguments]]\n\nActions:")
+		for _, action := range fleetActions {
+			fmt.Fprintf(flags.Output(), "  %-8s %s\n", action[0], action[1])
+		}
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	ctx := context.Background()
+	var static []string
+	if *peers != "" {
+		static = strings.Split(*peers, ",")
+	}
+	client, err := fleet.New(ctx, fleet.Config{Peers: static, DNS: *dns, Timeout: *timeout})
+	if err != nil {
+		diag.Error("Invalid fleet", "error", err)
+		return 2
+	}
+
+	action, rest := "status", []string(nil)
+	if flags.NArg() > 0 {
+		action, rest = flags.Arg(0), flags.Args()[1:]
+	}
+
+	switch action {
+	case "status":
+		peerStats, totals := client.Stats(ctx)
+		if *jsonOutput {
+			return printFleetJSON(map[string]interface{}{"peers": peerStats, "totals": totals})
+		}
+		for _, p := range peerStats {
+			if p.Error != "" {
+				fmt.Printf("%-28s error: %s\n", p.Peer, p.Error)
+				continue
+			}
+			state := ""
+			if p.Paused {
+				state = " (paused)"
+			}
+			fmt.Printf("%-28s rate %-10s logs %d (%.1f logs/sec), bytes %d, queue %d%s\n",
+				p.Peer, p.Rate, p.Logs, p.LogsPerSec, p.Bytes, p.QueueDepth, state)
+		}
+		fmt.Printf("Total: %d/%d peers reachable, logs %d (%.1f logs/sec), bytes %d, queue %d\n",
+			totals.Reachable, totals.Peers, totals.Logs, totals.LogsPerSec, totals.Bytes, totals.QueueDepth)
+		for _, sink := range totals.Sinks {
+			fmt.Printf("  %-8s sent %d, failed %d, dropped %d\n", sink.Name, sink.Sent, sink.Failed, sink.Dropped)
+		}
+		return fleetExitCode(totals.Reachable < totals.Peers)
+
+	case "rate":
+		if len(rest) != 1 {
+			diag.Error("Invalid arguments: rate expects one rate expression, e.g. rate 10k/s")
+			return 2
+		}
+		expr := rest[0]
+		if *split {
+			expr, err = splitRate(expr, len(client.Peers()))
+			if err != nil {
+				diag.Error("Invalid rate", "error", err)
+				return 2
+			}
+		}
+		rates := client.SetRate(ctx, func(int) string { return expr })
+		if *jsonOutput {
+			return printFleetJSON(rates)
+		}
+		failed := false
+		for _, r := range rates {
+			if r.Error != "" {
+				failed = true
+				fmt.Printf("%-28s error: %s\n", r.Peer, r.Error)
+			} else {
+				fmt.Printf("%-28s rate %s\n", r.Peer, r.Formatted)
+			}
+		}
+		return fleetExitCode(failed)
+
+	case "set":
+		if len(rest) == 0 {
+			diag.Error("Invalid arguments: set expects name=value settings, e.g. set error-ratio=0.2")
+			return 2
+		}
+		settings := map[string]string{}
+		for _, arg := range rest {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok || name == "" {
+				diag.Error("Invalid setting: expected name=value", "setting", arg)
+				return 2
+			}
+			settings[name] = value
+		}
+		results := client.Apply(ctx, settings)
+		if *jsonOutput {
+			return printFleetJSON(results)
+		}
+		failed := false
+		for _, r := range results {
+			if r.Error != "" {
+				failed = true
+				fmt.Printf("%-28s error: %s\n", r.Peer, r.Error)
+			} else {
+				fmt.Printf("%-28s OK\n", r.Peer)
+			}
+		}
+		return fleetExitCode(failed)
+
+	default:
+		diag.Error("Unknown fleet action", "action", action)
+		flags.Usage()
+		return 2
+	}
+}
+
+// splitRate divides a rate expression between n peers
+func splitRate(expr string, n int) (string, error) {
+	if r, err := ratelimit.Parse(expr); err == nil {
+		return ratelimit.Format(r / float64(n)), nil
+	}
+	r, err := ratelimit.ParseBytes(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid rate %q", expr)
+	}
+	return ratelimit.FormatBytes(r / float64(n)), nil
+}
+
+// printFleetJSON prints results as JSON
+func printFleetJSON(v interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		diag.Error("Failed to write results", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// fleetExitCode returns 1 if a peer failed
+func fleetExitCode(failed bool) int {
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package loggenie
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/schema"
+)
+
+// runJSONSchema implements the json-schema subcommand: it infers the shape
+// of the sample logs generated with the configured flags and prints it as a
+// JSON Schema, the data contract downstream parsers can be built against
+func runJSONSchema(sample io.Reader, format string) int {
+	if strings.EqualFold(format, logger.FormatPlain) {
+		fmt.Fprintln(os.Stderr, "json-schema: the plain format has no record structure, use json or logfmt")
+		return 2
+	}
+
+	inferred, err := schema.Infer(sample)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "json-schema: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(inferred.JSONSchema(fmt.Sprintf("log-genie %s record", strings.ToLower(format)))); err != nil {
+		fmt.Fprintf(os.Stderr, "json-schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package loggenie
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rjonczy/log-genie/pkg/schema"
+)
+
+// runLearn implements the learn subcommand: it infers a schema from a sample
+// NDJSON or logfmt file and writes it as JSON
+func runLearn(args []string) int {
+	flags := flag.NewFlagSet("learn", flag.ExitOnError)
+	input := flags.String("input", "", "Sample NDJSON or logfmt file to learn from")
+	output := flags.String("output", "", "File to write the schema to (default stdout)")
+	flags.Parse(args)
+
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "learn: --input is required")
+		flags.Usage()
+		return 2
+	}
+
+	learned, err := schema.InferFile(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "learn: %v\n", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "learn: %v\n", err)
+			return 1
+		}
+		defer out.Close()
+	}
+
+	if err := learned.Save(out); err != nil {
+		fmt.Fprintf(os.Stderr, "learn: failed to write schema: %v\n", err)
+		return 1
+	}
+
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Learned %d fields from %d records into %s\n", len(learned.Fields), learned.Samples, *output)
+	}
+	return 0
+}
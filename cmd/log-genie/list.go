@@ -0,0 +1,82 @@
+package loggenie
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/preset"
+)
+
+// rateProfiles describes the ways the rate can be shaped over time
+var rateProfiles = [][2]string{
+	{"ramp", "Ramp linearly or exponentially between two rates (--ramp-*)"},
+	{"sine", "Oscillate around the rate (--wave=sine)"},
+	{"diurnal", "Follow a day/night cycle of the wall clock (--wave=diurnal)"},
+	{"drift", "Random-walk the rate within bounds (--drift)"},
+	{"burst", "Multiply the rate periodically (--burst-*)"},
+	{"file", "Follow rates and error ratios from a load profile file (--profile)"},
+	{"schedule", "Generate only within cron-style windows (--schedule)"},
+	{"poisson", "Exponentially distributed inter-arrival times (--arrival=poisson)"},
+	{"uniform", "Uniformly jittered inter-arrival times (--arrival=uniform)"},
+}
+
+// sinks describes where generated logs can be sent
+var sinks = [][2]string{
+	{"stdout", "Local logs on standard output in the selected --format (default, or with --local-logs)"},
+	{"file", "Local logs in a file, rotated by size and compressed with gzip or zstd (--output=file, --output-file, --file-rotate-size)"},
+	{"otlp", "OpenTelemetry logs over OTLP/HTTP (--telemetry, --telemetry-endpoint)"},
+	{"http", "Batches of JSON logs over HTTP, preconfigured for Vector or Fluent Bit (--http-sink, --http-preset)"},
+	{"logplex", "HTTPS log drains in Heroku's Logplex format (--logplex, --logplex-token)"},
+	{"lumberjack", "Logstash or Beats inputs over the Lumberjack v2 protocol (--lumberjack, --lumberjack-batch)"},
+	{"netflow", "NetFlow v5, v9 or IPFIX flow records over UDP (--netflow, --netflow-version)"},
+	{"snmp", "SNMPv2c traps over UDP with standard trap OIDs (--snmp-traps, --snmp-community)"},
+}
+
+// runListFormats implements the list-formats subcommand
+func runListFormats(args []string) int {
+	var rows [][2]string
+	for _, format := range logger.Formats() {
+		rows = append(rows, [2]string{format.Name, format.Description})
+	}
+	printList(rows)
+
+	fmt.Println("\nLevel formats (--level-format):")
+	rows = nil
+	for _, format := range logger.LevelFormats() {
+		rows = append(rows, [2]string{format.Name, format.Description})
+	}
+	printList(rows)
+	return 0
+}
+
+// runListProfiles implements the list-profiles subcommand: it lists the rate
+// profiles and the presets bundling complete settings
+func runListProfiles(args []string) int {
+	fmt.Println("Rate profiles:")
+	printList(rateProfiles)
+
+	fmt.Println("\nPresets (--preset):")
+	var rows [][2]string
+	for _, name := range preset.Names() {
+		rows = append(rows, [2]string{name, preset.Description(name)})
+	}
+	printList(rows)
+	return 0
+}
+
+// runListSinks implements the list-sinks subcommand
+func runListSinks(args []string) int {
+	printList(sinks)
+	return 0
+}
+
+// printList prints names and descriptions as aligned columns
+func printList(rows [][2]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %s\t%s\n", row[0], row[1])
+	}
+	w.Flush()
+}
@@ -1,128 +1,1036 @@
 package loggenie
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/rjonczy/log-genie/pkg/cluster"
+	"github.com/rjonczy/log-genie/pkg/config"
+	"github.com/rjonczy/log-genie/pkg/control"
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/generator"
 	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/metrics"
+	"github.com/rjonczy/log-genie/pkg/preset"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/replay"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/systemd"
+	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/verify"
+	"github.com/rjonczy/log-genie/pkg/webapp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
 const (
-	defaultRate              = 10
-	defaultVerbosity         = "info"
-	defaultTelemetryEndpoint = "collector:4318"
-	defaultApplicationID     = "log-genie" // Default application ID
+	defaultRate               = 10
+	defaultVerbosity          = "info"
+	defaultTelemetryEndpoint  = "collector:4318"
+	defaultApplicationID      = "log-genie" // Default application ID
+	defaultTimezone           = "Local"
+	defaultTimestampField     = "timestamp"
+	defaultTimestampFormat    = "epoch_ns"
+	defaultMarkovOrder        = 2
+	defaultMessages           = "catalog"
+	defaultRepeatMin          = 5
+	defaultRepeatMax          = 50
+	defaultBurstDuration      = 30 * time.Second
+	defaultBurstMultiplier    = 10
+	defaultNoisyEvery         = 5 * time.Minute
+	defaultOutputFile         = "log-genie.log"
+	defaultWavePeriod         = 24 * time.Hour
+	defaultWaveAmplitude      = 0.5
+	defaultJitter             = 0.5
+	defaultMaxLag             = time.Second
+	defaultErrorRatio         = 0.05
+	defaultDriftStep          = 0.1
+	defaultDriftInterval      = 10 * time.Second
+	defaultWorkers            = 1
+	defaultFormat             = "json"
+	defaultFlushInterval      = time.Second
+	defaultAsyncWriters       = 1
+	defaultDrainTimeout       = 5 * time.Second
+	defaultPreviewCount       = 10
+	defaultSchemaSamples      = 1000
+	defaultEnvFile            = ".env"
+	defaultDiagLevel          = "info"
+	defaultMaxLoss            = 1.0
+	defaultSelfMetricsEvery   = 10 * time.Second
+	defaultReportInterval     = time.Minute
+	defaultDiagFormat         = diag.FormatText
+	defaultBenchSink          = benchSinkNull
+	defaultBenchDuration      = 10 * time.Second
+	defaultServeAddr          = ":8080"
+	defaultReplaySpeed        = "1x"
+	defaultAppAddr            = ":8080"
+	defaultSNMPCommunity      = "public"
+	defaultLumberjackBatch    = 512
+	defaultStatsdPrefix       = "log_genie"
+	defaultVerifyAddr         = ":4319"
+	defaultVerifySettle       = 10 * time.Second
+	defaultLokiQuery          = `{service_name="log-genie"}`
+	defaultElasticsearchIndex = "logs-*"
 )
 
+// maxPreviewAttempts bounds the logs generated per requested sample log
+const maxPreviewAttempts = 1000
+
+// commands are the subcommands that run on their own, with flags of their own
+var commands = map[string]func(args []string) int{
+	"learn":      runLearn,
+	"coordinate": runCoordinate,
+	"fleet":      runFleet,
+	"operate":    runOperate,
+	// The child of --output=child
+	"relay":         runRelay,
+	"list-formats":  runListFormats,
+	"list-profiles": runListProfiles,
+	"list-sinks":    runListSinks,
+	"version":       runVersion,
+}
+
+// modes are the subcommands that run the configured generator, taking its
+// flags
+var modes = map[string]mode{
+	"preview": {preview: true},
+	// The JSON Schema is inferred from a preview of the configured generator
+	"json-schema": {preview: true, describing: true},
+	// A benchmark is a run of the configured generator at full speed against
+	// one sink
+	"bench": {benchmarking: true},
+	// Serving streams the configured generator to clients instead of stdout
+	"serve": {serving: true},
+	// The fake application logs the requests it serves, on top of what the
+	// configured generator emits
+	"app": {faking: true},
+	// A replay sends the records of a file to the configured sinks instead
+	// of generated ones
+	"replay": {replaying: true},
+	// Verification runs the configured generator and receives its logs back
+	// at the end of the pipeline
+	"verify": {verifying: true},
+	// Validation checks config files against the generator flags
+	"validate": {validating: true},
+	// Completion scripts cover the generator flags, so they are printed once
+	// those are defined
+	"completion": {completing: true},
+}
+
+// dispatch runs the subcommand args start with if it runs on its own, and
+// otherwise returns the mode of the generator and the args left to parse
+func dispatch(args []string) (mode, []string) {
+	if len(args) == 0 {
+		return mode{}, args
+	}
+	if run, ok := commands[args[0]]; ok {
+		os.Exit(run(args[1:]))
+	}
+	if args[0] == "service" {
+		// The service control manager runs the generator with the flags the
+		// service was installed with
+		if len(args) > 2 && args[1] == "run" {
+			if name, ok := strings.CutPrefix(args[2], "--name="); ok {
+				return mode{service: name}, args[3:]
+			}
+		}
+		os.Exit(runService(args[1:]))
+	}
+	if m, ok := modes[args[0]]; ok {
+		return m, args[1:]
+	}
+	return mode{}, args
+}
+
 // Main is the entry point for the application
 func Main() {
-	// Parse command line flags
-	rate := flag.Int("rate", defaultRate, "Number of logs per second")
-	verbosity := flag.String("verbosity", defaultVerbosity, "Log verbosity level: debug, info, warn, error")
-	telemetryEnabled := flag.Bool("telemetry", false, "Enable OpenTelemetry logs export")
-	telemetryEndpoint := flag.String("telemetry-endpoint", defaultTelemetryEndpoint, "OpenTelemetry collector endpoint")
-	localLogs := flag.Bool("local-logs", false, "Enable local logs to stdout/stderr even when telemetry is enabled")
-	showResponses := flag.Bool("show-responses", false, "Show responses from the OTEL collector")
-	applicationID := flag.String("application-id", defaultApplicationID, "Application ID for OTEL resource attributes")
-	flag.Parse()
+	m, args := dispatch(os.Args[1:])
+	f := defineFlags(m)
+	if m.completing {
+		os.Exit(runCompletion(args))
+	}
+	// The command line flag set exits on errors
+	_ = flag.CommandLine.Parse(args)
+
+	if m.validating {
+		os.Exit(runValidate(*f.configFile, f.probe))
+	}
+	if *f.showVersion {
+		os.Exit(runVersion(nil))
+	}
+
+	// Flags given on the command line take precedence over everything else
+	explicit := explicitFlags()
+
+	// Load the .env file; variables set in the real environment win
+	if envEnvFile := os.Getenv("LOG_GENIE_ENV_FILE"); envEnvFile != "" && !explicit["env-file"] {
+		*f.envFile = envEnvFile
+	}
+	if *f.envFile != "" {
+		if err := config.LoadEnv(*f.envFile); err != nil && !(errors.Is(err, os.ErrNotExist) && *f.envFile == defaultEnvFile) {
+			diag.Error("Invalid env file", "path", *f.envFile, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Every other flag can be set from its LOG_GENIE_<NAME> variable
+	if err := applyEnv(explicit); err != nil {
+		diag.Error("Invalid environment variable", "error", err)
+		os.Exit(1)
+	}
+	explicit = explicitFlags()
+
+	// Apply the config file and preset below command line flags and
+	// environment variables; the config file takes precedence over the preset
+	var loaded map[string]string
+	if *f.configFile != "" {
+		var err error
+		if loaded, err = config.Load(*f.configFile); err != nil {
+			diag.Error("Invalid config file", "path", *f.configFile, "error", err)
+			os.Exit(1)
+		}
+		if name, ok := loaded["preset"]; ok && !explicit["preset"] {
+			*f.presetName = name
+		}
+	}
+	if *f.presetName != "" {
+		values, err := preset.Load(*f.presetName)
+		if err == nil {
+			err = applySettings(values, explicit)
+		}
+		if err != nil {
+			diag.Error("Invalid preset", "preset", *f.presetName, "error", err)
+			os.Exit(1)
+		}
+	}
+	if err := applySettings(loaded, explicit); err != nil {
+		diag.Error("Invalid config file", "path", *f.configFile, "error", err)
+		os.Exit(1)
+	}
+
+	// Set up log-genie's own diagnostics on stderr, above the progress line
+	// if there is one
+	var bar *progress
+	var diagOutput io.Writer = os.Stderr
+	if *f.showProgress && !m.preview {
+		bar = &progress{out: os.Stderr}
+		diagOutput = bar
+	}
+	if err := diag.Configure(diagOutput, *f.diagFormat); err != nil {
+		diag.Error("Invalid diagnostics format", "error", err)
+		os.Exit(1)
+	}
+	minLevel, err := diag.ParseLevel(*f.diagLevel)
+	if err != nil {
+		diag.Error("Invalid diagnostics level", "error", err)
+		os.Exit(1)
+	}
+	if *f.quiet && minLevel < slog.LevelWarn {
+		minLevel = slog.LevelWarn
+	}
+	diag.SetLevel(minLevel)
+
+	// Run as a Windows service, with diagnostics in the event log
+	var service *serviceRun
+	if m.service != "" {
+		if service, err = startService(m.service); err != nil {
+			diag.Error("Failed to run as a service", "name", m.service, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// A preview prints sample logs locally without connecting to any sink
+	if m.preview {
+		*f.telemetryEnabled = false
+		*f.coordinatorURL = ""
+		*f.controlAddr = ""
+		*f.metricsAddr = ""
+		*f.pprofEnabled = false
+		*f.selfMetrics = false
+		*f.traces = false
+		*f.requestMetrics = false
+		*f.statsdTarget = ""
+		*f.snmpTraps = ""
+		*f.netflowTarget = ""
+		*f.lumberjackTarget = ""
+		*f.logplexURL = ""
+		*f.httpSinkURL, *f.httpPreset = "", ""
+		*f.count = int64(f.previewCount)
+		*f.pregenerate = 0
+		*f.buffer = ""
+		*f.asyncQueue = 0
+		*f.pluginSinks = ""
+		*f.output = logger.OutputStdout
+		if *f.count <= 0 {
+			diag.Error("Invalid number of sample logs: must be at least 1", "n", f.previewCount)
+			os.Exit(1)
+		}
+	}
+
+	// The fake application logs as its traffic calls for, so nothing is
+	// generated on a clock unless asked for
+	if m.faking {
+		if f.logsPerRequest < 0 || f.appLatency < 0 {
+			diag.Error("Invalid fake application: logs per request and latency must not be negative")
+			os.Exit(1)
+		}
+		if !explicit["rate"] && !explicit["throughput"] {
+			*f.rate = 0
+		}
+	}
 
-	// Check environment variables (override command line flags if present)
-	if envRate := os.Getenv("LOG_GENIE_RATE"); envRate != "" {
-		if r, err := strconv.Atoi(envRate); err == nil {
-			*rate = r
+	// A benchmark generates as fast as the sink takes logs, for a fixed time,
+	// unless told otherwise
+	if m.benchmarking {
+		if f.targetCPU < 0 || f.targetCPU > 1 {
+			diag.Error("Invalid target CPU: must be between 0 and 1", "target_cpu", f.targetCPU)
+			os.Exit(1)
+		}
+		if f.targetCPU > 0 && explicit["throughput"] {
+			diag.Error("A target CPU steers the rate in logs, not throughput")
+			os.Exit(1)
+		}
+		if !explicit["rate"] && !explicit["throughput"] {
+			*f.rate = ratelimit.Flag(ratelimit.Unlimited)
+			if f.targetCPU > 0 {
+				// Saturation starts at a modest rate and rises toward the target
+				*f.rate = ratelimit.Flag(saturateStartRate)
+			}
+		}
+		if *f.duration == 0 && *f.count == 0 {
+			*f.duration = defaultBenchDuration
+		}
+		*f.coordinatorURL = ""
+		switch f.benchSink {
+		case benchSinkNull, benchSinkDiscard, benchSinkStdout:
+			*f.telemetryEnabled = false
+			*f.output = f.benchSink
+		case benchSinkOTLP:
+			*f.telemetryEnabled = true
+			*f.localLogs = false
+		default:
+			diag.Error("Invalid benchmark sink", "sink", f.benchSink, "expected", strings.Join(benchSinks, ", "))
+			os.Exit(1)
 		}
 	}
 
-	if envVerbosity := os.Getenv("LOG_GENIE_VERBOSITY"); envVerbosity != "" {
-		*verbosity = envVerbosity
+	// Verification checks the sequence numbers and checksums of what comes
+	// back, so every log must carry them
+	if m.verifying {
+		*f.sequence, *f.checksum = true, true
 	}
 
-	if envTelemetry := os.Getenv("LOG_GENIE_TELEMETRY"); envTelemetry != "" {
-		*telemetryEnabled = strings.ToLower(envTelemetry) == "true" || envTelemetry == "1"
+	// A replay paces itself by the timestamps of the file, so there is no
+	// rate to be assigned and nothing to pregenerate
+	var player *replay.Player
+	if m.replaying {
+		speed, err := replay.ParseSpeed(f.replaySpeed)
+		if err != nil {
+			diag.Error("Invalid replay speed", "error", err)
+			os.Exit(1)
+		}
+		rules, err := replay.ParseAnonymize(f.anonymize)
+		if err != nil {
+			diag.Error("Invalid anonymization", "error", err)
+			os.Exit(1)
+		}
+		var anonymizer *replay.Anonymizer
+		if len(rules) > 0 {
+			anonymizer, err = replay.NewAnonymizer(rules, f.anonymizeSalt)
+			if err != nil {
+				diag.Error("Failed to set up anonymization", "error", err)
+				os.Exit(1)
+			}
+			diag.Info("Anonymizing replayed fields", "fields", strings.Join(anonymizer.Fields(), ","))
+		}
+		player, err = replay.New(replay.Config{
+			File:           f.replayFile,
+			Speed:          speed,
+			TimestampField: *f.timestampField,
+			Loop:           f.loopReplay,
+			Anonymizer:     anonymizer,
+		})
+		if err != nil {
+			diag.Error("Invalid replay", "file", f.replayFile, "error", err)
+			os.Exit(1)
+		}
+		*f.coordinatorURL = ""
+		*f.pregenerate = 0
 	}
 
-	if envTelemetryEndpoint := os.Getenv("LOG_GENIE_TELEMETRY_ENDPOINT"); envTelemetryEndpoint != "" {
-		*telemetryEndpoint = envTelemetryEndpoint
+	// Join the coordinator, which assigns the rate share, the stream ID and
+	// possibly extra flags
+	var member *cluster.Client
+	var assignment *cluster.Assignment
+	if *f.coordinatorURL != "" {
+		if *f.workerID == "" {
+			hostname, _ := os.Hostname()
+			*f.workerID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		}
+		member = cluster.NewClient(*f.coordinatorURL, *f.workerID)
+		var err error
+		assignment, err = member.Heartbeat(context.Background(), 0, 0, false)
+		if err != nil {
+			diag.Error("Failed to join coordinator", "coordinator", *f.coordinatorURL, "error", err)
+			os.Exit(1)
+		}
+		if err := flag.CommandLine.Parse(assignment.Args); err != nil {
+			diag.Error("Invalid flags assigned by coordinator", "args", strings.Join(assignment.Args, " "), "error", err)
+			os.Exit(1)
+		}
+		if *f.throughput > 0 {
+			diag.Error("Coordinated workers pace by events: --throughput is not supported")
+			os.Exit(1)
+		}
+		*f.rate = ratelimit.Flag(assignment.Rate)
+		if *f.streamID == "" {
+			*f.streamID = assignment.StreamID
+		}
+		diag.Info("Joined coordinator", "coordinator", *f.coordinatorURL, "worker_id", *f.workerID, "stream_id", assignment.StreamID)
 	}
 
-	if envLocalLogs := os.Getenv("LOG_GENIE_LOCAL_LOGS"); envLocalLogs != "" {
-		*localLogs = strings.ToLower(envLocalLogs) == "true" || envLocalLogs == "1"
+	if *f.workers < 1 {
+		diag.Error("Invalid number of workers: must be at least 1", "workers", *f.workers)
+		os.Exit(1)
 	}
 
-	if envShowResponses := os.Getenv("LOG_GENIE_SHOW_RESPONSES"); envShowResponses != "" {
-		*showResponses = strings.ToLower(envShowResponses) == "true" || envShowResponses == "1"
+	if *f.maxLoss < 0 || *f.maxLoss > 1 {
+		diag.Error("Invalid max loss: must be between 0 and 1", "max_loss", *f.maxLoss)
+		os.Exit(1)
 	}
 
-	if envApplicationID := os.Getenv("LOG_GENIE_APPLICATION_ID"); envApplicationID != "" {
-		*applicationID = envApplicationID
+	if *f.count < 0 {
+		diag.Error("Invalid count: must not be negative", "count", *f.count)
+		os.Exit(1)
 	}
 
-	// Create logger
-	config := logger.Config{
-		Verbosity:         *verbosity,
-		Rate:              *rate,
-		TelemetryEnabled:  *telemetryEnabled,
-		TelemetryEndpoint: *telemetryEndpoint,
-		LocalLogEnabled:   *localLogs,
-		ShowResponses:     *showResponses,
-		ApplicationID:     *applicationID,
+	// Create logger, with the streams and the pipeline of every log
+	loggerConfig, err := f.loggerConfig()
+	exitOnError(err)
+	out, err := f.openOutput(m, &loggerConfig)
+	exitOnError(err)
+	streams, err := f.streams(explicit, &loggerConfig)
+	exitOnError(err)
+	pipeline, err := f.pipelineOptions(&loggerConfig)
+	exitOnError(err)
+	sinks, err := f.sinkOptions()
+	exitOnError(err)
+	pacing, err := f.pacingOptions(streams)
+	exitOnError(err)
+	options := append([]generator.Option{generator.WithConfig(loggerConfig)}, pacing...)
+
+	reportFormatName, err := stats.ParseReportFormat(*f.reportFormat)
+	if err != nil {
+		diag.Error("Invalid report format", "format", *f.reportFormat, "error", err)
+		os.Exit(1)
+	}
+	if *f.reportInterval < 0 {
+		diag.Error("Invalid report interval: must not be negative", "interval", f.reportInterval.String())
+		os.Exit(1)
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	log, err := logger.New(config)
+	// Throttle instead of running out of memory when sinks fall behind, and
+	// pause on SIGUSR2
+	var guard *memoryGuard
+	if *f.maxMemory != "" {
+		limit, err := ratelimit.ParseSize(*f.maxMemory)
+		if err == nil && limit < 1<<20 {
+			err = fmt.Errorf("must be at least 1MiB")
+		}
+		if err != nil {
+			diag.Error("Invalid memory limit", "max_memory", *f.maxMemory, "error", err)
+			os.Exit(1)
+		}
+		guard = newMemoryGuard(uint64(limit))
+		go guard.Run(ctx)
+	}
+	pause := &pauser{}
+	options = append(options, generator.WithGate(pause.Wait), generator.WithGate(guard.Wait))
+
+	if player != nil {
+		// Original timestamps would repeat on every pass of a loop
+		options = append(options, generator.WithReplay(player, f.rewriteTimestamps || f.loopReplay))
+	}
+
+	options = append(options, pipeline...)
+	options = append(options, sinks...)
+
+	gen, err := generator.New(options...)
 	if err != nil {
-		fmt.Printf("Error initializing logger: %v\n", err)
+		diag.Error("Error initializing generator", "error", err)
+		if gen == nil {
+			os.Exit(1)
+		}
 		// Continue with local logging
 	}
-	defer log.Shutdown()
+	defer gen.Shutdown()
+	log, base := gen.Logger(), gen.Target()
+
+	if m.preview {
+		// Give up if the verbosity filters out (nearly) everything generated
+		for attempts := 0; !log.Exhausted() && attempts < maxPreviewAttempts*f.previewCount; attempts++ {
+			gen.Generate()
+		}
+		if m.describing {
+			os.Exit(runJSONSchema(&out.sample, *f.format))
+		}
+		return
+	}
+
+	if *f.pprofEnabled && *f.metricsAddr == "" {
+		diag.Error("Invalid flags: --pprof requires --metrics-addr")
+		os.Exit(1)
+	}
+	if out.hub != nil {
+		listener, err := net.Listen("tcp", f.serveAddr)
+		if err != nil {
+			diag.Error("Failed to start streaming", "address", f.serveAddr, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			_ = http.Serve(listener, out.hub.Handler())
+		}()
+		diag.Info("Streaming logs", "address", listener.Addr().String(), "events", "/events", "websocket", "/ws")
+	}
+	var appServer *http.Server
+	if m.faking {
+		listener, err := net.Listen("tcp", f.appAddr)
+		if err != nil {
+			diag.Error("Failed to start the fake application", "address", f.appAddr, "error", err)
+			os.Exit(1)
+		}
+		appServer = &http.Server{Handler: webapp.New(webapp.Config{
+			Logger:         log,
+			ErrorRatio:     gen.ErrorRatio,
+			LogsPerRequest: f.logsPerRequest,
+			Latency:        f.appLatency,
+		})}
+		go func() {
+			_ = appServer.Serve(listener)
+		}()
+		diag.Info("Serving the fake application", "address", listener.Addr().String(), "logs_per_request", f.logsPerRequest, "latency", f.appLatency.String())
+	}
+	var verifier *verify.Verifier
+	var verifyBackend string
+	var verifyQuery verify.QueryConfig
+	if m.verifying {
+		verifier = verify.New(log.Stream(), func() uint64 { return uint64(log.LogsEmitted()) })
+		// A backend is queried instead of receiving the logs, which would
+		// count them twice
+		switch {
+		case f.queryLoki != "" && f.queryElasticsearch != "":
+			diag.Error("Invalid flags: give --query-loki or --query-elasticsearch, not both")
+			os.Exit(1)
+		case f.queryLoki != "":
+			verifyBackend, verifyQuery = verify.BackendLoki, verify.QueryConfig{URL: f.queryLoki, Query: f.lokiQuery}
+		case f.queryElasticsearch != "":
+			verifyBackend = verify.BackendElasticsearch
+			verifyQuery = verify.QueryConfig{URL: f.queryElasticsearch, Index: f.elasticsearchIndex, Query: f.elasticsearchQuery}
+		}
+		if verifyBackend != "" && !explicit["receive"] {
+			f.verifyAddr = ""
+		}
+		receiving := f.verifyAddr != "" || f.verifySyslogAddr != "" || f.verifyHTTPAddr != ""
+		if verifyBackend != "" && receiving {
+			diag.Error("Invalid flags: verify receives the logs or queries a backend, not both")
+			os.Exit(1)
+		}
+		if verifyBackend == "" && !receiving {
+			diag.Error("Invalid flags: verify needs --receive, --receive-syslog, --receive-http or a backend to query")
+			os.Exit(1)
+		}
+		receivers := []struct {
+			protocol, address string
+			handler           http.Handler
+		}{
+			{"otlp", f.verifyAddr, verifier.OTLPHandler()},
+			{"http", f.verifyHTTPAddr, verifier.HTTPHandler()},
+		}
+		for _, receiver := range receivers {
+			if receiver.address == "" {
+				continue
+			}
+			listener, err := net.Listen("tcp", receiver.address)
+			if err != nil {
+				diag.Error("Failed to start verify receiver", "protocol", receiver.protocol, "address", receiver.address, "error", err)
+				os.Exit(1)
+			}
+			go func(handler http.Handler) {
+				_ = http.Serve(listener, handler)
+			}(receiver.handler)
+			diag.Info("Verify receiver listening", "protocol", receiver.protocol, "address", listener.Addr().String(), "stream", verifier.Stream())
+		}
+		if f.verifySyslogAddr != "" {
+			addr, err := verifier.ListenSyslog(f.verifySyslogAddr)
+			if err != nil {
+				diag.Error("Failed to start verify receiver", "protocol", "syslog", "address", f.verifySyslogAddr, "error", err)
+				os.Exit(1)
+			}
+			diag.Info("Verify receiver listening", "protocol", "syslog", "address", addr.String(), "stream", verifier.Stream())
+		}
+	}
+	var meterProvider *sdkmetric.MeterProvider
+	if *f.metricsAddr != "" || *f.selfMetrics {
+		profileName := *f.presetName
+		if profileName == "" && *f.profileFile != "" {
+			profileName = strings.TrimSuffix(filepath.Base(*f.profileFile), filepath.Ext(*f.profileFile))
+		}
+		exporter := metrics.New(metrics.Config{
+			Logger:     log,
+			Profile:    profileName,
+			Rate:       base.Get,
+			Throughput: *f.throughput > 0,
+		})
+		go exporter.Run(ctx)
 
-	// Calculate the interval between log emissions
-	interval := time.Second / time.Duration(*rate)
+		if *f.metricsAddr != "" {
+			listener, err := net.Listen("tcp", *f.metricsAddr)
+			if err != nil {
+				diag.Error("Failed to start metrics listener", "address", *f.metricsAddr, "error", err)
+				os.Exit(1)
+			}
+			go func() {
+				_ = http.Serve(listener, adminHandler(exporter.Handler(), *f.pprofEnabled))
+			}()
+			diag.Info("Metrics listening", "address", listener.Addr().String(), "pprof", *f.pprofEnabled)
+		}
 
-	// Setup ticker for regular logs
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+		if *f.selfMetrics {
+			endpoint := *f.selfMetricsEndpoint
+			if endpoint == "" {
+				// Share the collector, but not the logs path
+				endpoint = telemetry.Collector(*f.telemetryEndpoint)
+			}
+			if *f.selfMetricsInterval <= 0 {
+				diag.Error("Invalid self metrics interval: must be positive", "interval", f.selfMetricsInterval.String())
+				os.Exit(1)
+			}
+			meterProvider, err = exporter.StartOTLP(ctx, metrics.OTLPConfig{
+				Endpoint:      endpoint,
+				Interval:      *f.selfMetricsInterval,
+				ApplicationID: *f.applicationID,
+			})
+			if err != nil {
+				diag.Error("Failed to start self metrics", "endpoint", endpoint, "error", err)
+				os.Exit(1)
+			}
+			diag.Info("Exporting self metrics", "endpoint", endpoint, "interval", f.selfMetricsInterval.String())
+		}
+	}
+	if member != nil {
+		go func() {
+			ticker := time.NewTicker(assignment.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+				a, err := member.Heartbeat(ctx, log.LogsEmitted(), log.BytesEmitted(), false)
+				if err != nil {
+					if ctx.Err() == nil {
+						diag.Warn("Failed to report to coordinator", "coordinator", *f.coordinatorURL, "error", err)
+					}
+					continue
+				}
+				base.Set(a.Rate)
+			}
+		}()
+		// Take a new share as soon as workers join or leave
+		go func() {
+			epoch := assignment.Epoch
+			for ctx.Err() == nil {
+				a, err := member.Watch(ctx, epoch, assignment.Interval)
+				if err != nil {
+					select {
+					case <-time.After(assignment.Interval):
+					case <-ctx.Done():
+					}
+					continue
+				}
+				if a.Epoch != epoch {
+					epoch = a.Epoch
+					base.Set(a.Rate)
+				}
+			}
+		}()
+	}
 
 	// Setup signal catching
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	// Pause and resume generation on SIGUSR2
+	if len(pauseSignals) > 0 {
+		pauseSigs := make(chan os.Signal, 1)
+		signal.Notify(pauseSigs, pauseSignals...)
+		go func() {
+			for range pauseSigs {
+				if pause.Toggle() {
+					// Nothing generated before the pause lingers in buffers
+					if err := gen.Flush(); err != nil {
+						diag.Warn("Failed to flush sinks", "error", err)
+					}
+					diag.Info("Paused log generation")
+				} else {
+					diag.Info("Resumed log generation")
+				}
+			}
+		}()
+	}
+
+	// Settings that can change while running, from config reloads and the
+	// control API
+	runtimeSettings := map[string]func(string) error{
+		"error-ratio": func(value string) error {
+			r, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("must be between 0 and 1")
+			}
+			return gen.SetErrorRatio(r)
+		},
+		"level-weights": func(value string) error {
+			weights, err := logger.ParseLevelWeights(value)
+			if err == nil {
+				log.SetLevelWeights(weights)
+			}
+			return err
+		},
+		"attributes": func(value string) error {
+			attributes, err := logger.ParseAttributes(value)
+			if err == nil {
+				log.SetAttributes(attributes)
+			}
+			return err
+		},
+	}
+	if *f.throughput > 0 {
+		runtimeSettings["throughput"] = func(value string) error {
+			r, err := ratelimit.ParseBytes(value)
+			if err == nil {
+				err = ratelimit.ValidateBytes(r)
+			}
+			if err == nil {
+				base.Set(r)
+			}
+			return err
+		}
+	} else {
+		runtimeSettings["rate"] = func(value string) error {
+			r, err := ratelimit.Parse(value)
+			if err == nil {
+				err = ratelimit.Validate(r)
+			}
+			if err == nil {
+				base.Set(r)
+			}
+			return err
+		}
+	}
+
+	// Reload the config file on SIGHUP
+	if *f.configFile != "" && len(reloadSignals) > 0 {
+		reload := &reloader{
+			path:     *f.configFile,
+			explicit: explicit,
+			loaded:   loaded,
+			apply:    runtimeSettings,
+		}
+		reloadSigs := make(chan os.Signal, 1)
+		signal.Notify(reloadSigs, reloadSignals...)
+		go func() {
+			for range reloadSigs {
+				reload.Reload()
+			}
+		}()
+	}
+
 	// Log startup message
-	telemetryStatus := "disabled"
-	if *telemetryEnabled {
-		telemetryStatus = "enabled, endpoint: " + *telemetryEndpoint
+	pace := f.rate.String()
+	if *f.throughput > 0 {
+		pace = f.throughput.String()
+	}
+	if player != nil {
+		pace = "replay at " + f.replaySpeed
+	}
+	if m.faking && *f.rate == 0 && *f.throughput == 0 {
+		pace = "per request"
 	}
-	localLogsStatus := "enabled"
-	if !*localLogs {
-		localLogsStatus = "disabled"
+	endpoint := ""
+	if *f.telemetryEnabled {
+		endpoint = *f.telemetryEndpoint
 	}
-	showResponsesStatus := "disabled"
-	if *showResponses {
-		showResponsesStatus = "enabled"
+
+	diag.Info("Starting log generation",
+		"rate", pace,
+		"verbosity", *f.verbosity,
+		"telemetry", *f.telemetryEnabled,
+		"telemetry_endpoint", endpoint,
+		"local_logs", *f.localLogs,
+		"show_responses", *f.showResponses,
+		"application_id", *f.applicationID,
+		"clock_offset", f.clockOffset.String(),
+		"timezone", *f.timezone)
+	if *f.rate == 0 && *f.throughput == 0 && player == nil && !m.faking {
+		// Rate 0 pauses generation until the control API, a config reload
+		// or the coordinator raises the rate
+		if *f.controlAddr == "" && *f.configFile == "" && *f.coordinatorURL == "" {
+			diag.Warn("Generation is paused at rate 0 and nothing can raise the rate: set --control-addr")
+		} else {
+			diag.Info("Generation is paused at rate 0 until the rate is raised")
+		}
 	}
 
-	startupLog := log.WithField("app", "log-genie")
-	startupLog.Info(fmt.Sprintf("Starting log generation at %d logs per second with %s verbosity. OpenTelemetry: %s. Local logs: %s. Show responses: %s. Application ID: %s",
-		*rate, *verbosity, telemetryStatus, localLogsStatus, showResponsesStatus, *applicationID))
+	start, startCPU := time.Now(), processCPU()
 
-	// Run the log generator
-	go func() {
-		for range ticker.C {
-			// Occasionally generate an error log (about 5% of the time)
-			if time.Now().UnixNano()%20 == 0 {
-				log.GenerateRandomErrorLog()
-			} else {
-				log.GenerateRandomLog()
+	snapshot := func() statsSnapshot {
+		current := ratelimit.Format(base.Get())
+		if *f.throughput > 0 {
+			current = ratelimit.FormatBytes(base.Get())
+		}
+		return newSnapshot(log, start, current, pause.Paused())
+	}
+
+	// Dump a stats snapshot on SIGUSR1 without interrupting generation
+	if len(dumpSignals) > 0 {
+		dumpSigs := make(chan os.Signal, 1)
+		signal.Notify(dumpSigs, dumpSignals...)
+		go func() {
+			for range dumpSigs {
+				if err := snapshot().dump(*f.statsFile, diagOutput); err != nil {
+					diag.Warn("Failed to dump stats", "path", *f.statsFile, "error", err)
+				} else if *f.statsFile != "" {
+					diag.Info("Dumped stats", "path", *f.statsFile)
+				}
 			}
+		}()
+	}
+	// The runner generates in the background until stopped, or until the
+	// run ends by itself
+	runner := generator.NewRunner(gen)
+	if *f.controlAddr != "" {
+		api := control.New(control.Config{
+			Target:     base,
+			Throughput: *f.throughput > 0,
+			Settings:   runtimeSettings,
+			Stats:      func() interface{} { return snapshot() },
+			Runner:     runner,
+			Context:    ctx,
+		})
+		listener, err := net.Listen("tcp", *f.controlAddr)
+		if err != nil {
+			diag.Error("Failed to start control API", "address", *f.controlAddr, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			_ = http.Serve(listener, api.Handler())
+		}()
+		diag.Info("Control API listening", "address", listener.Addr().String())
+	}
+
+	// Report what was generated and sent every interval
+	reporter := &stats.Reporter{Registry: log.Stats(), Interval: *f.reportInterval, Format: reportFormatName, Output: diagOutput}
+	go reporter.Run(ctx)
+
+	// A saturation benchmark steers the rate toward the target CPU
+	var governor *cpuGovernor
+	if f.targetCPU > 0 {
+		governor = newCPUGovernor(f.targetCPU, base, func() float64 { return gen.Progress().Taken })
+		go governor.Run(ctx)
+		diag.Info("Steering the rate toward the target CPU", "target_cpu", f.targetCPU, "cores", runtime.GOMAXPROCS(0))
+	}
+
+	// Run the log generators, or the replay
+	_ = runner.Start(ctx)
+
+	// Take the totals once warmed up, so the summary describes the steady
+	// state
+	var warm atomic.Pointer[warmUp]
+	if *f.warmUpDuration > 0 {
+		warmUpTimer := time.AfterFunc(*f.startDelay+*f.warmUpDuration, func() {
+			warm.Store(endWarmUp(log))
+			diag.Info("Warm-up over", "warm_up", f.warmUpDuration.String())
+		})
+		defer warmUpTimer.Stop()
+	}
+
+	// Tell systemd the service is up and keep its watchdog fed until the
+	// process exits, draining included
+	if _, err := systemd.Notify("READY=1\nSTATUS=Generating logs at " + pace); err != nil {
+		diag.Warn("Failed to notify the service manager", "error", err)
+	}
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go systemd.Watchdog(watchdogCtx)
+
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		if bar != nil {
+			bar.run(ctx, log, start)
 		}
 	}()
 
-	// Wait for termination signal
-	<-sigs
-	fmt.Println("Shutting down log generator")
+	// Wait for termination signal or the end of the run
+	var deadline <-chan time.Time
+	if *f.duration > 0 {
+		// The duration counts from the end of the start delay
+		deadline = time.After(*f.startDelay + *f.duration)
+	}
+	var reason string
+	select {
+	case <-sigs:
+		reason = "signal"
+		diag.Info("Shutting down log generator", "reason", reason)
+	case <-service.Stopped():
+		reason = "service"
+		diag.Info("Shutting down log generator", "reason", reason, "name", m.service)
+	case <-deadline:
+		reason = "duration"
+		diag.Info("Shutting down log generator", "reason", reason, "duration", f.duration.String())
+	case <-runner.Finished():
+		if player != nil && !log.Exhausted() {
+			reason = "replayed"
+			diag.Info("Shutting down log generator", "reason", reason, "file", f.replayFile, "passes", player.Passes())
+			break
+		}
+		reason = "count"
+		diag.Info("Shutting down log generator", "reason", reason, "count", *f.count)
+	}
+
+	if _, err := systemd.Notify("STOPPING=1"); err != nil {
+		diag.Warn("Failed to notify the service manager", "error", err)
+	}
+
+	// Let requests in flight log before the sinks drain
+	if appServer != nil {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), *f.drainTimeout)
+		if err := appServer.Shutdown(stopCtx); err != nil {
+			diag.Warn("Failed to stop the fake application", "error", err)
+		}
+		stopCancel()
+	}
+
+	// Stop generating before the shutdown flushes the exporter; runs
+	// started through the control API end with ctx too
+	cancel()
+	<-runner.Done()
+	end := time.Now()
+	if err := runner.Err(); err != nil {
+		diag.Error("Failed to replay", "file", f.replayFile, "error", err)
+	}
+	if player != nil {
+		if skipped := player.Skipped(); skipped > 0 {
+			diag.Warn("Skipped unparseable lines", "file", f.replayFile, "lines", skipped)
+		}
+	}
+	<-progressDone
+	if bar != nil {
+		bar.finish()
+	}
+
+	// Tell the coordinator this worker is done so its share is handed out
+	if member != nil {
+		reportCtx, reportCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if _, err := member.Heartbeat(reportCtx, log.LogsEmitted(), log.BytesEmitted(), true); err != nil {
+			diag.Warn("Failed to report to coordinator", "coordinator", *f.coordinatorURL, "error", err)
+		}
+		reportCancel()
+	}
+
+	// Drain the sinks so their counts are final, then summarize the run
+	held := log.Sinks()
+	drainStart := time.Now()
+	gen.Shutdown()
+	out.close(*f.drainTimeout)
+	drainDuration := time.Since(drainStart)
+	if meterProvider != nil {
+		// Export the final values
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := meterProvider.Shutdown(flushCtx); err != nil {
+			diag.Warn("Failed to flush self metrics", "error", err)
+		}
+		flushCancel()
+	}
+	var verification verify.Report
+	if verifier != nil {
+		// Allow for skewed timestamps when querying by time
+		margin := time.Minute + f.clockOffset.Abs()
+		verifyQuery.Start, verifyQuery.End = start.Add(-margin), time.Now().Add(f.verifySettle+margin)
+		verification = settle(verifier, uint64(log.LogsEmitted()), f.verifySettle, sigs, verifyBackend, verifyQuery)
+	}
+	summary := newSummary(log, reason, start, end)
+	summary.MemoryThrottles = guard.Throttles()
+	if *f.throughput == 0 {
+		// Pacing by throughput skips bytes rather than logs
+		summary.Skipped = int64(gen.Progress().Skipped)
+	}
+	if adaptive := gen.Backpressure(); adaptive != nil {
+		summary.Backoffs, summary.LowestRateShare = adaptive.Backoffs()
+	}
+	summary.drain(held, drainDuration)
+	cpu := processCPU() - startCPU
+	if w := warm.Load(); w != nil {
+		summary.exclude(w)
+		cpu = processCPU() - w.cpu
+	} else if *f.warmUpDuration > 0 {
+		diag.Warn("The run ended during the warm-up, the summary covers all of it", "warm_up", f.warmUpDuration.String())
+	}
+	if m.benchmarking {
+		// Keep stdout for the report unless the logs went there
+		var out io.Writer = os.Stdout
+		if f.benchSink == benchSinkStdout {
+			out = os.Stderr
+		}
+		report := newBenchReport(summary, f.benchSink, *f.format, *f.workers, cpu)
+		if governor != nil {
+			report.TargetCPU = f.targetCPU
+			if !governor.Reached() {
+				diag.Warn("CPU stayed below the target, generation is held up elsewhere", "cpu_utilization", report.CPUUtilization, "target_cpu", f.targetCPU)
+			}
+		}
+		if err := report.write(out); err != nil {
+			diag.Error("Failed to write benchmark report", "error", err)
+		}
+	} else {
+		summary.report()
+	}
+	if *f.summaryFile != "" {
+		if err := summary.write(*f.summaryFile); err != nil {
+			diag.Error("Failed to write summary", "path", *f.summaryFile, "error", err)
+		}
+	}
+	exitCode := runExitCode(summary, *f.maxLoss)
+	if verifier != nil {
+		// Keep stdout for the report unless the logs went there
+		var out io.Writer = os.Stdout
+		if !*f.telemetryEnabled || *f.localLogs {
+			out = os.Stderr
+		}
+		if err := writeVerifyReport(verification, out); err != nil {
+			diag.Error("Failed to write verification report", "error", err)
+		}
+		if code := verifyExitCode(verification); exitCode == exitOK {
+			exitCode = code
+		}
+	}
+	service.Exit(exitCode)
+	os.Exit(exitCode)
 }
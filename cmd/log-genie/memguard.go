@@ -0,0 +1,130 @@
+package loggenie
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// Watermarks of the memory guard, as shares of the ceiling: generation is
+// throttled above the high one until memory falls below the low one
+const (
+	memoryHighWatermark = 0.9
+	memoryLowWatermark  = 0.75
+	memoryCheckInterval = 100 * time.Millisecond
+)
+
+// memoryGuard throttles generation while the memory held by the process
+// approaches a ceiling, so a soak test slows down instead of being killed
+// when a sink falls behind
+type memoryGuard struct {
+	limit     uint64
+	mutex     sync.Mutex
+	resumed   chan struct{} // closed when throttling ends, nil while not throttled
+	throttles atomic.Int64
+	sample    []metrics.Sample
+}
+
+// newMemoryGuard creates a guard for a ceiling in bytes, and sets the Go
+// runtime's soft memory limit to it so the collector works harder first
+func newMemoryGuard(limit uint64) *memoryGuard {
+	debug.SetMemoryLimit(int64(limit))
+	return &memoryGuard{
+		limit: limit,
+		sample: []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		},
+	}
+}
+
+// used returns the memory held by the process, as counted against the
+// runtime's memory limit
+func (g *memoryGuard) used() uint64 {
+	metrics.Read(g.sample)
+	return g.sample[0].Value.Uint64() - g.sample[1].Value.Uint64()
+}
+
+// Run checks memory until ctx is done, throttling and releasing generation
+func (g *memoryGuard) Run(ctx context.Context) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	var since time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			g.release()
+			return
+		case <-ticker.C:
+		}
+
+		used := g.used()
+		switch {
+		case since.IsZero() && float64(used) >= memoryHighWatermark*float64(g.limit):
+			since = time.Now()
+			g.throttle()
+			diag.Warn("Throttling generation: memory near limit", "used_bytes", used, "limit_bytes", g.limit)
+		case !since.IsZero() && float64(used) <= memoryLowWatermark*float64(g.limit):
+			g.release()
+			diag.Info("Resumed generation after memory throttling", "used_bytes", used, "throttled_for", time.Since(since).Round(time.Millisecond).String())
+			since = time.Time{}
+		case !since.IsZero():
+			// Give back what the collector freed while generation waits
+			debug.FreeOSMemory()
+		}
+	}
+}
+
+// throttle makes Wait block
+func (g *memoryGuard) throttle() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+		g.throttles.Add(1)
+	}
+}
+
+// release unblocks Wait
+func (g *memoryGuard) release() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// Wait blocks while generation is throttled, reporting false if the
+// context is done first
+func (g *memoryGuard) Wait(ctx context.Context) bool {
+	if g == nil {
+		return ctx.Err() == nil
+	}
+	g.mutex.Lock()
+	resumed := g.resumed
+	g.mutex.Unlock()
+
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// Throttles returns how often generation was throttled
+func (g *memoryGuard) Throttles() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.throttles.Load()
+}
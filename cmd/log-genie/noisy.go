@@ -0,0 +1,43 @@
+package loggenie
+
+import (
+	"fmt"
+
+	"github.com/rjonczy/log-genie/pkg/generator"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+)
+
+// noisyNeighbor splits generation at rate into the streams of the quiet
+// tenants and of the noisy one, which takes its share of the rate and
+// multiplies it during spikes. It returns the streams, the noisy one last,
+// and the pool of the quiet tenants.
+func noisyNeighbor(pool *tenant.Pool, name string, rate float64, spike ratelimit.Burst, scenario []generator.Stream) ([]generator.Stream, *tenant.Pool, error) {
+	switch {
+	case pool == nil:
+		return nil, nil, fmt.Errorf("a noisy neighbor needs --tenants")
+	case len(scenario) > 0:
+		return nil, nil, fmt.Errorf("a noisy neighbor cannot be combined with a scenario")
+	case rate == ratelimit.Unlimited:
+		return nil, nil, fmt.Errorf("a noisy neighbor needs a rate, not max")
+	case spike.Every <= 0 || spike.Duration <= 0 || spike.Multiplier <= 0:
+		return nil, nil, fmt.Errorf("the interval, duration and multiplier of spikes must be positive")
+	}
+	quiet, share, err := pool.Without(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var streams []generator.Stream
+	if quiet != nil {
+		streams = append(streams, generator.Stream{Name: "tenants", Rate: rate * (1 - share), ErrorRatio: -1})
+	}
+	streams = append(streams, generator.Stream{
+		Name:       "noisy:" + name,
+		Rate:       rate * share,
+		ErrorRatio: -1,
+		Attributes: map[string]string{tenant.Field: name},
+		Profile:    ratelimit.Chain{spike},
+	})
+	return streams, quiet, nil
+}
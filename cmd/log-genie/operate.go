@@ -0,0 +1,47 @@
+package loggenie
+
+import (
+	"context"
+	"flag"
+	"os/signal"
+	"syscall"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/operator"
+)
+
+// runOperate implements the operate subcommand: it reconciles LogLoad
+// resources into Deployments of generators until interrupted
+func runOperate(args []string) int {
+	flags := flag.NewFlagSet("operate", flag.ExitOnError)
+	server := flags.String("server", "", "URL of the Kubernetes API server, e.g. http://127.0.0.1:8001 for kubectl proxy (default the cluster the pod runs in)")
+	token := flags.String("token", "", "Bearer token for the API server (default the service account's)")
+	caFile := flags.String("ca-file", "", "CA certificate of the API server (default the service account's)")
+	namespace := flags.String("namespace", "", "Namespace to watch LogLoads in (default all)")
+	image := flags.String("image", "log-genie:latest", "Image of the generators unless a LogLoad names one")
+	resync := flags.Duration("resync", 0, "How often every LogLoad is reconciled again, refreshing its status (default 30s)")
+	flags.Parse(args)
+
+	op, err := operator.New(operator.Config{
+		Server:    *server,
+		Token:     *token,
+		CAFile:    *caFile,
+		Namespace: *namespace,
+		Image:     *image,
+		Resync:    *resync,
+	})
+	if err != nil {
+		diag.Error("Failed to start the operator", "error", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	scope := *namespace
+	if scope == "" {
+		scope = "all namespaces"
+	}
+	diag.Info("Reconciling LogLoads", "namespace", scope, "image", *image)
+	op.Run(ctx)
+	return 0
+}
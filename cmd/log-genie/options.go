@@ -0,0 +1,581 @@
+package loggenie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/generator"
+	"github.com/rjonczy/log-genie/pkg/identity"
+	"github.com/rjonczy/log-genie/pkg/logfile"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/logplex"
+	"github.com/rjonczy/log-genie/pkg/lumberjack"
+	"github.com/rjonczy/log-genie/pkg/netflow"
+	"github.com/rjonczy/log-genie/pkg/plugin"
+	"github.com/rjonczy/log-genie/pkg/processor"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/scenario"
+	"github.com/rjonczy/log-genie/pkg/schedule"
+	"github.com/rjonczy/log-genie/pkg/script"
+	"github.com/rjonczy/log-genie/pkg/snmp"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/statsd"
+	"github.com/rjonczy/log-genie/pkg/stream"
+	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+	"github.com/rjonczy/log-genie/pkg/tracing"
+	"github.com/rjonczy/log-genie/pkg/traffic"
+	"github.com/rjonczy/log-genie/pkg/webhook"
+)
+
+// setupError is a setting the generator cannot be set up with, reported
+// through the diagnostics with the key-value pairs describing it
+type setupError struct {
+	msg  string
+	args []interface{}
+}
+
+// failed returns a setupError reporting msg with the key-value pairs args
+func failed(msg string, args ...interface{}) error {
+	return &setupError{msg: msg, args: args}
+}
+
+func (e *setupError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.msg)
+	for i := 0; i+1 < len(e.args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", e.args[i], e.args[i+1])
+	}
+	return b.String()
+}
+
+// exitOnError reports err and exits if there is one
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	var setup *setupError
+	if errors.As(err, &setup) {
+		diag.Error(setup.msg, setup.args...)
+	} else {
+		diag.Error("Invalid settings", "error", err)
+	}
+	os.Exit(1)
+}
+
+// loggerConfig returns the logger configuration of the flags
+func (f *settings) loggerConfig() (logger.Config, error) {
+	var weights map[logger.LogLevel]float64
+	if *f.levelWeights != "" {
+		var err error
+		if weights, err = logger.ParseLevelWeights(*f.levelWeights); err != nil {
+			return logger.Config{}, failed("Invalid level weights", "error", err)
+		}
+	}
+	staticAttributes, err := logger.ParseAttributes(*f.attributes)
+	if err != nil {
+		return logger.Config{}, failed("Invalid attributes", "error", err)
+	}
+
+	// The same seed gives the same fleet, so restarts continue it
+	var identities *identity.Pool
+	if *f.services != 0 {
+		seed := *f.identitySeed
+		if seed == "" {
+			seed = *f.applicationID
+		}
+		identities, err = identity.New(identity.Config{Seed: seed, Services: *f.services, Instances: *f.instances})
+		if err != nil {
+			return logger.Config{}, failed("Invalid fleet of services", "error", err)
+		}
+	}
+
+	var tenantPool *tenant.Pool
+	if *f.tenants != "" {
+		tenantPool, err = tenant.Parse(*f.tenants)
+		if err != nil {
+			return logger.Config{}, failed("Invalid tenants", "error", err)
+		}
+	}
+
+	cfg := logger.Config{
+		Verbosity:         *f.verbosity,
+		Rate:              float64(*f.rate),
+		TelemetryEnabled:  *f.telemetryEnabled,
+		TelemetryEndpoint: *f.telemetryEndpoint,
+		LocalLogEnabled:   *f.localLogs,
+		ShowResponses:     *f.showResponses,
+		ApplicationID:     *f.applicationID,
+		ClockOffset:       *f.clockOffset,
+		Timezone:          *f.timezone,
+		TimestampField:    *f.timestampField,
+		TimestampFormat:   *f.timestampFormat,
+		StreamID:          *f.streamID,
+		Sequence:          *f.sequence,
+		Checksum:          *f.checksum,
+		MessageCorpus:     *f.messageCorpus,
+		MarkovOrder:       *f.markovOrder,
+		SchemaFile:        *f.schemaFile,
+		MessageSource:     *f.messages,
+		Repeat: logger.RepeatConfig{
+			Probability: *f.repeatProbability,
+			Min:         *f.repeatMin,
+			Max:         *f.repeatMax,
+		},
+		SeverityAttrs: *f.severityAttrs,
+		Nested: logger.NestedConfig{
+			Depth: *f.nestedDepth,
+			Width: *f.nestedWidth,
+			In:    *f.nestedIn,
+		},
+		KeyCollisions: *f.keyCollisions,
+		AttributeCount: logger.AttributeCountConfig{
+			Min:  *f.attributesMin,
+			Max:  *f.attributesMax,
+			Keys: *f.attributeKeys,
+		},
+		MessageSize:  *f.messageSize,
+		Limit:        *f.count,
+		Pregenerate:  *f.pregenerate,
+		Restamp:      *f.restamp,
+		LevelWeights: weights,
+		Attributes:   staticAttributes,
+		Identities:   identities,
+		Tenants:      tenantPool,
+		Format:       *f.format,
+		LevelFormat:  *f.levelFormat,
+	}
+	if *f.buffer != "" {
+		size, err := ratelimit.ParseSize(*f.buffer)
+		if err == nil && (size < 0 || size > 1<<30) {
+			err = fmt.Errorf("must be between 0 and 1GiB")
+		}
+		if err != nil {
+			return logger.Config{}, failed("Invalid buffer size", "buffer", *f.buffer, "error", err)
+		}
+		cfg.BufferSize = int(size)
+		cfg.FlushInterval = *f.flushInterval
+	}
+	if *f.asyncQueue < 0 || *f.asyncWriters < 1 {
+		return logger.Config{}, failed("Invalid local output queue: the size must not be negative and there must be at least one writer", "async_queue", *f.asyncQueue, "async_writers", *f.asyncWriters)
+	}
+	if _, err := logger.ParseOverflow(*f.asyncOverflow); err != nil {
+		return logger.Config{}, failed("Invalid overflow policy", "async_overflow", *f.asyncOverflow, "error", err)
+	}
+	if *f.drainTimeout <= 0 {
+		return logger.Config{}, failed("Invalid drain timeout: must be positive", "drain_timeout", f.drainTimeout.String())
+	}
+	cfg.DrainTimeout = *f.drainTimeout
+	cfg.Queue = *f.asyncQueue
+	cfg.QueueWriters = *f.asyncWriters
+	cfg.Overflow = *f.asyncOverflow
+	return cfg, nil
+}
+
+// localOutput holds what local logs are written to besides stdout
+type localOutput struct {
+	child *childProcess
+	file  *logfile.File
+	// sample holds the preview a JSON Schema is inferred from
+	sample bytes.Buffer
+	hub    *stream.Hub
+}
+
+// close waits for the child process and closes the output file, once the
+// generator has drained
+func (o *localOutput) close(timeout time.Duration) {
+	if o.child != nil {
+		if err := o.child.Close(timeout); err != nil {
+			diag.Warn("Child process failed", "error", err)
+		}
+	}
+	if o.file != nil {
+		if err := o.file.Close(); err != nil {
+			diag.Warn("Failed to close output file", "path", o.file.Path(), "error", err)
+		}
+	}
+}
+
+// openOutput sets cfg up to write local logs to the output of the flags,
+// or to what the subcommand m writes them to
+func (f *settings) openOutput(m mode, cfg *logger.Config) (*localOutput, error) {
+	out := &localOutput{}
+	outputName, err := logger.ParseOutput(*f.output)
+	if err != nil {
+		return nil, failed("Invalid output", "output", *f.output, "error", err)
+	}
+	if outputName != logger.OutputStdout {
+		cfg.OutputSink = outputName
+	}
+	switch outputName {
+	case logger.OutputNull:
+		cfg.CountOnly = true
+	case logger.OutputDiscard:
+		cfg.Output = io.Discard
+	case logger.OutputChild:
+		out.child, err = startChild(*f.childStream)
+		if err != nil {
+			return nil, failed("Failed to start child process", "error", err)
+		}
+		cfg.Output = out.child
+		diag.Info("Writing logs through a child process", "pid", out.child.Pid(), "stream", *f.childStream)
+	case logger.OutputFile:
+		var rotateSize float64
+		if *f.fileRotateSize != "" {
+			rotateSize, err = ratelimit.ParseSize(*f.fileRotateSize)
+			if err == nil && rotateSize < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+			if err != nil {
+				return nil, failed("Invalid rotation size", "file_rotate_size", *f.fileRotateSize, "error", err)
+			}
+		}
+		out.file, err = logfile.New(logfile.Config{Path: *f.outputFile, MaxSize: int64(rotateSize), Keep: *f.fileKeep, Compress: *f.fileCompress})
+		if err != nil {
+			return nil, failed("Failed to open output file", "path", *f.outputFile, "error", err)
+		}
+		cfg.Output = out.file
+		diag.Info("Writing logs to a file", "path", out.file.Path(), "rotate_size", *f.fileRotateSize, "compress", *f.fileCompress)
+	}
+	if m.describing {
+		cfg.Output = &out.sample
+	}
+	if m.serving {
+		out.hub = stream.New(stream.Config{})
+		cfg.LocalLogEnabled = true
+		cfg.Output = out.hub
+		// Clients get every record as it is written
+		cfg.BufferSize = 0
+		cfg.Queue = 0
+		cfg.OutputSink = "stream"
+		cfg.CountOnly = false
+	}
+	return out, nil
+}
+
+// streams returns the streams of the scenario and the noisy tenant, which
+// sets the rate unless given and the tenants of cfg sharing the rest
+func (f *settings) streams(explicit map[string]bool, cfg *logger.Config) ([]generator.Stream, error) {
+	// A scenario splits generation into streams at their own rates, unless
+	// --rate scales them
+	var streams []generator.Stream
+	var err error
+	if *f.scenarioFile != "" {
+		streams, err = scenario.Load(*f.scenarioFile)
+		if err != nil {
+			return nil, failed("Invalid scenario", "path", *f.scenarioFile, "error", err)
+		}
+		if !explicit["rate"] {
+			*f.rate = ratelimit.Flag(scenario.Total(streams))
+		}
+	}
+
+	// A noisy neighbor is a stream of its own, spiking on top of its share
+	// of the rate while the other tenants share the rest
+	if *f.noisyTenant != "" {
+		spike := ratelimit.Burst{Every: *f.noisyEvery, Duration: *f.noisyDuration, Multiplier: *f.noisyMultiplier}
+		streams, cfg.Tenants, err = noisyNeighbor(cfg.Tenants, *f.noisyTenant, float64(*f.rate), spike, streams)
+		if err != nil {
+			return nil, failed("Invalid noisy tenant", "tenant", *f.noisyTenant, "error", err)
+		}
+		noisy := streams[len(streams)-1]
+		diag.Info("Simulating a noisy neighbor", "tenant", *f.noisyTenant, "rate", ratelimit.Format(noisy.Rate), "spike_rate", ratelimit.Format(noisy.Rate*spike.Multiplier), "every", spike.Every.String())
+	}
+	return streams, nil
+}
+
+// pacingOptions paces the generator by events or by bytes, modulating the
+// base rate over time
+func (f *settings) pacingOptions(streams []generator.Stream) ([]generator.Option, error) {
+	options := []generator.Option{
+		generator.WithRate(float64(*f.rate)),
+		generator.WithThroughput(float64(*f.throughput)),
+		generator.WithWorkers(*f.workers),
+		generator.WithErrorRatio(*f.errorRatio),
+		generator.WithStreams(streams...),
+		generator.WithStart(*f.startDelay, *f.stagger),
+	}
+	if *f.scheduleSpec != "" {
+		activeWindows, err := schedule.Parse(*f.scheduleSpec)
+		if err != nil {
+			return nil, failed("Invalid schedule", "error", err)
+		}
+		options = append(options, generator.WithSchedule(activeWindows))
+	}
+
+	arrival, err := ratelimit.NewArrival(*f.arrivalProcess, *f.jitter)
+	if err != nil {
+		return nil, failed("Invalid arrival process", "error", err)
+	}
+	options = append(options, generator.WithArrival(arrival))
+
+	pacingPolicy, err := ratelimit.ParsePacing(*f.pacing)
+	if err != nil {
+		return nil, failed("Invalid pacing policy", "pacing", *f.pacing, "error", err)
+	}
+	if *f.maxLag < 0 {
+		return nil, failed("Invalid maximum lag: must not be negative", "max_lag", *f.maxLag)
+	}
+	if pacingPolicy == ratelimit.PacingSkip {
+		*f.maxLag = 0
+	}
+	options = append(options, generator.WithCatchUp(*f.maxLag))
+
+	backpressurePolicy, err := ratelimit.ParseBackpressure(*f.backpressure)
+	if err != nil {
+		return nil, failed("Invalid backpressure policy", "backpressure", *f.backpressure, "error", err)
+	}
+	options = append(options, generator.WithBackpressure(backpressurePolicy))
+
+	// Modulate the base rate over time
+	if *f.profileFile != "" {
+		loadProfile, err := ratelimit.LoadProfile(*f.profileFile)
+		if err != nil {
+			return nil, failed("Invalid load profile", "path", *f.profileFile, "error", err)
+		}
+		options = append(options, generator.WithProfile(loadProfile))
+	}
+	if *f.rampDuration > 0 {
+		if *f.rampShape != ratelimit.RampLinear && *f.rampShape != ratelimit.RampExponential {
+			return nil, failed("Invalid ramp shape: use linear or exponential", "ramp_shape", *f.rampShape)
+		}
+		options = append(options, generator.WithProfile(ratelimit.Ramp{
+			From:     float64(*f.rampFrom),
+			To:       float64(*f.rampTo),
+			Duration: *f.rampDuration,
+			Hold:     *f.rampHold,
+			Down:     *f.rampDown,
+			Shape:    *f.rampShape,
+		}))
+	}
+	if *f.wave != "" {
+		if *f.wave != ratelimit.WaveSine && *f.wave != ratelimit.WaveDiurnal {
+			return nil, failed("Invalid wave: use sine or diurnal", "wave", *f.wave)
+		}
+		options = append(options, generator.WithProfile(ratelimit.Wave{
+			Shape:     *f.wave,
+			Period:    *f.wavePeriod,
+			Amplitude: *f.waveAmplitude,
+		}))
+	}
+	if *f.drift {
+		options = append(options, generator.WithProfile(&ratelimit.RandomWalk{
+			Min:      float64(*f.driftMin),
+			Max:      float64(*f.driftMax),
+			Step:     *f.driftStep,
+			Interval: *f.driftInterval,
+		}))
+	}
+	if *f.burstEvery > 0 {
+		options = append(options, generator.WithProfile(ratelimit.Burst{
+			Every:      *f.burstEvery,
+			Duration:   *f.burstDuration,
+			Multiplier: *f.burstMultiplier,
+			Offset:     *f.burstOffset,
+		}))
+	}
+	return options, nil
+}
+
+// pipelineOptions sets up what every log goes through before the sinks:
+// transforms, telemetry derived from it and processors; the stats of the
+// traces go into cfg
+func (f *settings) pipelineOptions(cfg *logger.Config) ([]generator.Option, error) {
+	var options []generator.Option
+	var err error
+
+	// Scripts rewrite the logs each sink takes
+	if *f.transform != "" {
+		transforms, err := script.Parse(*f.transform)
+		if err != nil {
+			return nil, failed("Invalid transform", "error", err)
+		}
+		for sink, t := range transforms {
+			options = append(options, generator.WithTransform(sink, t))
+		}
+	}
+
+	// Traces are exported to the collector, counted with the logs
+	var tracer *tracing.Provider
+	if *f.traces {
+		endpoint := *f.tracesEndpoint
+		if endpoint == "" {
+			// Share the collector, but not the logs path
+			endpoint = telemetry.Collector(*f.telemetryEndpoint)
+		}
+		cfg.Stats = stats.New()
+		tracer, err = tracing.New(context.Background(), tracing.Config{
+			Endpoint:      endpoint,
+			ApplicationID: *f.applicationID,
+			Stats:         cfg.Stats.Sink("traces"),
+		})
+		if err != nil {
+			return nil, failed("Failed to start traces", "endpoint", endpoint, "error", err)
+		}
+		diag.Info("Exporting traces", "endpoint", endpoint)
+	}
+	var requests *traffic.Provider
+	if *f.requestMetrics {
+		endpoint := *f.requestMetricsEndpoint
+		if endpoint == "" {
+			endpoint = telemetry.Collector(*f.telemetryEndpoint)
+		}
+		if *f.requestMetricsInterval <= 0 {
+			return nil, failed("Invalid request metrics interval: must be positive", "interval", f.requestMetricsInterval.String())
+		}
+		requests, err = traffic.New(context.Background(), traffic.Config{
+			Endpoint:      endpoint,
+			Interval:      *f.requestMetricsInterval,
+			ApplicationID: *f.applicationID,
+		})
+		if err != nil {
+			return nil, failed("Failed to start request metrics", "endpoint", endpoint, "error", err)
+		}
+		diag.Info("Exporting request metrics", "endpoint", endpoint, "interval", f.requestMetricsInterval.String())
+	}
+	var statsdMetrics *statsd.Emitter
+	if *f.statsdTarget != "" {
+		statsdMetrics, err = statsd.New(statsd.Config{Target: *f.statsdTarget, Flavor: *f.statsdFlavor, Prefix: *f.statsdPrefix})
+		if err != nil {
+			return nil, failed("Failed to start statsd metrics", "target", *f.statsdTarget, "error", err)
+		}
+		diag.Info("Emitting statsd metrics", "target", *f.statsdTarget, "flavor", *f.statsdFlavor)
+	}
+
+	// Traces are recorded first, so every log carries the IDs of its span,
+	// and request and statsd metrics count the traffic before it is sampled
+	if tracer != nil {
+		options = append(options, generator.WithProcessor(tracer))
+	}
+	if requests != nil {
+		options = append(options, generator.WithProcessor(requests))
+	}
+	if statsdMetrics != nil {
+		options = append(options, generator.WithProcessor(statsdMetrics))
+	}
+
+	if *f.spanEvents {
+		options = append(options, generator.WithProcessor(processor.SpanEvents()))
+	}
+
+	// Processors handle every log before any sink takes it
+	if *f.process != "" {
+		chain, routes, err := processor.Parse(*f.process)
+		if err != nil {
+			return nil, failed("Invalid processors", "error", err)
+		}
+		options = append(options, generator.WithProcessor(chain...))
+		for level, sinks := range routes {
+			options = append(options, generator.WithRoute(level, sinks...))
+		}
+	}
+	return options, nil
+}
+
+// sinkOptions sets up the sinks of the flags besides local output and
+// OpenTelemetry
+func (f *settings) sinkOptions() ([]generator.Option, error) {
+	var options []generator.Option
+
+	// Plugins provide sources and sinks of their own
+	if *f.plugins != "" {
+		registry := plugin.New()
+		for _, path := range strings.Split(*f.plugins, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			if err := registry.Load(path); err != nil {
+				return nil, failed("Failed to load plugin", "path", path, "error", err)
+			}
+		}
+		pluginConfig, err := logger.ParseAttributes(*f.pluginOptions)
+		if err != nil {
+			return nil, failed("Invalid plugin options", "error", err)
+		}
+		if *f.pluginSource != "" {
+			source, err := registry.NewSource(*f.pluginSource, pluginConfig)
+			if err != nil {
+				return nil, failed("Failed to create plugin source", "source", *f.pluginSource, "error", err)
+			}
+			options = append(options, generator.WithSource(source))
+		}
+		for _, name := range strings.Split(*f.pluginSinks, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			sink, err := registry.NewSink(name, pluginConfig)
+			if err != nil {
+				return nil, failed("Failed to create plugin sink", "sink", name, "error", err)
+			}
+			options = append(options, generator.WithSink(name, sink))
+		}
+		diag.Info("Loaded plugins", "sources", strings.Join(registry.Sources(), ","), "sinks", strings.Join(registry.Sinks(), ","))
+	} else if *f.pluginSource != "" || *f.pluginSinks != "" {
+		return nil, failed("Invalid flags: --plugin-source and --plugin-sinks require --plugin")
+	}
+
+	// Traps stand for the logs on SNMP receivers
+	if *f.snmpTraps != "" {
+		traps, err := snmp.New(snmp.Config{Target: *f.snmpTraps, Community: *f.snmpCommunity})
+		if err != nil {
+			return nil, failed("Failed to start SNMP traps", "receiver", *f.snmpTraps, "error", err)
+		}
+		options = append(options, generator.WithSink("snmp", traps))
+		diag.Info("Sending SNMP traps", "receiver", *f.snmpTraps)
+	}
+
+	// Flow records stand for the logs on flow collectors
+	if *f.netflowTarget != "" {
+		flows, err := netflow.New(netflow.Config{Target: *f.netflowTarget, Version: *f.netflowVersion})
+		if err != nil {
+			return nil, failed("Failed to start flow export", "collector", *f.netflowTarget, "error", err)
+		}
+		options = append(options, generator.WithSink("netflow", flows))
+		diag.Info("Exporting flow records", "collector", *f.netflowTarget, "version", *f.netflowVersion)
+	}
+
+	// Beats inputs take the logs as events shipped by Filebeat would be
+	if *f.lumberjackTarget != "" {
+		if *f.lumberjackBatch < 1 {
+			return nil, failed("Invalid Lumberjack batch: must be at least 1", "lumberjack_batch", *f.lumberjackBatch)
+		}
+		beats, err := lumberjack.New(lumberjack.Config{Target: *f.lumberjackTarget, Batch: *f.lumberjackBatch})
+		if err != nil {
+			return nil, failed("Failed to start Lumberjack output", "target", *f.lumberjackTarget, "error", err)
+		}
+		options = append(options, generator.WithSink("lumberjack", beats))
+		diag.Info("Sending logs over Lumberjack", "target", *f.lumberjackTarget, "batch", *f.lumberjackBatch)
+	}
+
+	// Agents' HTTP inputs take batches of JSON logs
+	if *f.httpSinkURL != "" || *f.httpPreset != "" {
+		if *f.httpBatch < 0 {
+			return nil, failed("Invalid HTTP batch: must not be negative", "http_batch", *f.httpBatch)
+		}
+		poster, err := webhook.New(webhook.Config{Preset: *f.httpPreset, URL: *f.httpSinkURL, Format: *f.httpFormat, Batch: *f.httpBatch, TenantHeader: *f.httpTenantHeader})
+		if err != nil {
+			return nil, failed("Failed to start HTTP sink", "error", err)
+		}
+		options = append(options, generator.WithSink("http", poster))
+		diag.Info("Posting logs over HTTP", "url", poster.URL(), "preset", *f.httpPreset)
+	}
+
+	// Drains take the logs as Heroku's Logplex posts them, from the app named
+	// by the application ID
+	if *f.logplexURL != "" {
+		drain, err := logplex.New(logplex.Config{URL: *f.logplexURL, Token: *f.logplexToken, App: *f.applicationID})
+		if err != nil {
+			return nil, failed("Failed to start Logplex drain", "error", err)
+		}
+		options = append(options, generator.WithSink("logplex", drain))
+		diag.Info("Posting logs to Logplex drain", "app", *f.applicationID)
+	}
+	return options, nil
+}
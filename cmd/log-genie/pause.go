@@ -0,0 +1,52 @@
+package loggenie
+
+import (
+	"context"
+	"sync"
+)
+
+// pauser pauses and resumes generation without tearing anything down, so
+// exporter connections survive the gap
+type pauser struct {
+	mutex   sync.Mutex
+	resumed chan struct{} // closed on resume, nil while running
+}
+
+// Toggle pauses a running generator or resumes a paused one, reporting
+// whether it is paused now
+func (p *pauser) Toggle() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+		return true
+	}
+	close(p.resumed)
+	p.resumed = nil
+	return false
+}
+
+// Wait blocks while generation is paused, reporting false if the context
+// is done first
+func (p *pauser) Wait(ctx context.Context) bool {
+	p.mutex.Lock()
+	resumed := p.resumed
+	p.mutex.Unlock()
+
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// Paused reports whether generation is paused
+func (p *pauser) Paused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.resumed != nil
+}
@@ -0,0 +1,86 @@
+package loggenie
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// progressInterval is how often the progress line is updated
+const progressInterval = time.Second
+
+// clearLine returns the cursor to the start of the line and clears it
+const clearLine = "\r\033[K"
+
+// progress keeps a single status line updated on a terminal. Diagnostics
+// are written through it so they scroll by above the line.
+type progress struct {
+	mutex sync.Mutex
+	out   io.Writer
+	line  string
+}
+
+// Write writes diagnostics above the status line
+func (p *progress) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.line != "" {
+		io.WriteString(p.out, clearLine)
+	}
+	n, err := p.out.Write(b)
+	if p.line != "" {
+		io.WriteString(p.out, p.line)
+	}
+	return n, err
+}
+
+// update replaces the status line
+func (p *progress) update(line string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.line = line
+	io.WriteString(p.out, clearLine+line)
+}
+
+// finish ends the status line so later output starts on a line of its own
+func (p *progress) finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.line != "" {
+		io.WriteString(p.out, "\n")
+		p.line = ""
+	}
+}
+
+// run updates the status line with the logs emitted, the current rate, the
+// export queue depth and the delivery errors until ctx is done
+func (p *progress) run(ctx context.Context, log *logger.Logger, start time.Time) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	last, lastTime := log.LogsEmitted(), start
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			logs := log.LogsEmitted()
+			rate := float64(logs-last) / now.Sub(lastTime).Seconds()
+			last, lastTime = logs, now
+
+			errors := int64(0)
+			for _, sink := range log.Sinks() {
+				errors += sink.Failed
+			}
+			p.update(fmt.Sprintf("%s  logs %d  rate %.1f/s  bytes %d  queue %d  errors %d",
+				now.Sub(start).Round(time.Second), logs, rate, log.BytesEmitted(), log.QueueDepth(), errors))
+		}
+	}
+}
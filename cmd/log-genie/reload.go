@@ -0,0 +1,95 @@
+package loggenie
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/config"
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// envName returns the environment variable overriding a flag
+func envName(flagName string) string {
+	return "LOG_GENIE_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// explicitFlags returns the flags set so far, on the command line or from
+// the environment, which take precedence over the config file and preset
+func explicitFlags() map[string]bool {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+// applyEnv sets every flag not in skip from its environment variable, so
+// each flag can be configured as LOG_GENIE_<NAME>
+func applyEnv(skip map[string]bool) error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		value := os.Getenv(name)
+		if err != nil || skip[f.Name] || value == "" {
+			return
+		}
+		if setErr := flag.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s=%q: %w", name, value, setErr)
+		}
+	})
+	return err
+}
+
+// applySettings sets flags from config file or preset values, skipping
+// explicit ones
+func applySettings(values map[string]string, explicit map[string]bool) error {
+	for name, value := range values {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// reloader re-applies the config file to a running generator
+type reloader struct {
+	path     string
+	explicit map[string]bool                     // settings the config file must not override
+	loaded   map[string]string                   // values applied so far
+	apply    map[string]func(value string) error // settings that can change while running
+}
+
+// Reload reads the config file again and applies the changed settings that
+// can change while running, reporting the others
+func (r *reloader) Reload() {
+	values, err := config.Load(r.path)
+	if err != nil {
+		diag.Warn("Failed to reload config", "path", r.path, "error", err)
+		return
+	}
+
+	for name, value := range values {
+		if r.explicit[name] || r.loaded[name] == value {
+			continue
+		}
+		apply, ok := r.apply[name]
+		if !ok {
+			diag.Warn("Ignoring change that requires a restart", "setting", name)
+			continue
+		}
+		if err := apply(value); err != nil {
+			diag.Warn("Ignoring invalid setting", "setting", name, "value", value, "error", err)
+			continue
+		}
+		r.loaded[name] = value
+		diag.Info("Reloaded setting", "setting", name, "value", value)
+	}
+}
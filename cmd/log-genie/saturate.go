@@ -0,0 +1,80 @@
+package loggenie
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// Steering of a saturation benchmark
+const (
+	saturateInterval  = time.Second
+	saturateStartRate = 1000 // logs/s a saturation benchmark starts at unless --rate is given
+	saturateMaxStep   = 2.0  // the rate changes at most by this factor per interval
+	saturateDamping   = 0.5  // share of the gap to the target closed per interval
+	saturateShortfall = 0.9  // generation below this share of the rate is held up elsewhere
+)
+
+// cpuGovernor steers the base rate so the process uses a target share of the
+// cores it may run on, for a benchmark to measure what a core sustains
+type cpuGovernor struct {
+	target  float64 // share of the cores, 0 to 1
+	cores   int
+	base    *ratelimit.Target
+	taken   func() float64 // logs the rate let through so far
+	reached atomic.Bool    // the process came close to the target
+}
+
+// newCPUGovernor creates a governor steering base toward a share of the
+// cores the Go runtime may use
+func newCPUGovernor(target float64, base *ratelimit.Target, taken func() float64) *cpuGovernor {
+	return &cpuGovernor{target: target, cores: runtime.GOMAXPROCS(0), base: base, taken: taken}
+}
+
+// Run adjusts the rate every interval until ctx is done
+func (g *cpuGovernor) Run(ctx context.Context) {
+	ticker := time.NewTicker(saturateInterval)
+	defer ticker.Stop()
+
+	last, lastCPU, lastLogs := time.Now(), processCPU(), g.taken()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now, cpu, logs := time.Now(), processCPU(), g.taken()
+		elapsed := now.Sub(last).Seconds()
+		used := (cpu - lastCPU).Seconds() / (elapsed * float64(g.cores))
+		achieved := (logs - lastLogs) / elapsed
+		last, lastCPU, lastLogs = now, cpu, logs
+		if used >= g.target*saturateShortfall {
+			g.reached.Store(true)
+		}
+
+		rate := g.base.Get()
+		step := saturateMaxStep
+		if used > 0 {
+			step = 1 + (g.target/used-1)*saturateDamping
+		}
+		step = math.Max(1/saturateMaxStep, math.Min(saturateMaxStep, step))
+		// A sink holding generation up keeps the CPU down whatever the rate,
+		// so the rate only rises while generation keeps up with it
+		if step > 1 && achieved < rate*saturateShortfall {
+			step = 1
+		}
+		next := math.Max(ratelimit.MinRate, rate*step)
+		g.base.Set(next)
+		diag.Debug("Steering toward the target CPU", "cpu", math.Round(used*100)/100, "target", g.target, "achieved", round(achieved), "rate", round(next))
+	}
+}
+
+// Reached reports whether the process came close to the target CPU
+func (g *cpuGovernor) Reached() bool {
+	return g.reached.Load()
+}
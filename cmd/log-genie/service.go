@@ -0,0 +1,46 @@
+package loggenie
+
+import "sync"
+
+// defaultServiceName is the name log-genie is installed as a Windows service under
+const defaultServiceName = "log-genie"
+
+// serviceActions describes what the service subcommand can do
+var serviceActions = [][2]string{
+	{"install", "Install the service, running the generator flags given after --"},
+	{"uninstall", "Remove the service"},
+	{"start", "Start the service"},
+	{"stop", "Stop the service"},
+}
+
+// serviceRun is a run of the generator as a Windows service. A nil run, when
+// not running as a service, is never stopped.
+type serviceRun struct {
+	stopped chan struct{} // closed when the service control manager asks the service to stop
+	stop    sync.Once
+	exited  chan int      // receives the exit code of the run
+	done    chan struct{} // closed once the service control manager knows the service stopped
+}
+
+// newServiceRun creates a run of the generator as a service
+func newServiceRun() *serviceRun {
+	return &serviceRun{stopped: make(chan struct{}), exited: make(chan int, 1), done: make(chan struct{})}
+}
+
+// Stopped returns a channel closed when the service is asked to stop
+func (s *serviceRun) Stopped() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.stopped
+}
+
+// Exit reports the exit code of the run to the service control manager and
+// waits until it has taken note
+func (s *serviceRun) Exit(code int) {
+	if s == nil {
+		return
+	}
+	s.exited <- code
+	<-s.done
+}
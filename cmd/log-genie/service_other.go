@@ -0,0 +1,23 @@
+//go:build !windows
+
+package loggenie
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errNoServices is returned where Windows services are not supported
+var errNoServices = errors.New("Windows services are only supported on Windows")
+
+// runService implements the service subcommand, which needs Windows
+func runService(args []string) int {
+	fmt.Fprintf(os.Stderr, "service: %v\n", errNoServices)
+	return 2
+}
+
+// startService reports that Windows services are not supported
+func startService(name string) (*serviceRun, error) {
+	return nil, errNoServices
+}
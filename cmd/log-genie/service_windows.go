@@ -0,0 +1,250 @@
+//go:build windows
+
+package loggenie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Event IDs of the diagnostics written to the event log
+const (
+	eventInfo    = 1
+	eventWarning = 2
+	eventError   = 3
+)
+
+// serviceStopTimeout is how long the stop action waits for the service to stop
+const serviceStopTimeout = 30 * time.Second
+
+// runService installs, removes, starts or stops the Windows service
+func runService(args []string) int {
+	flags := flag.NewFlagSet("service", flag.ExitOnError)
+	name := flags.String("name", defaultServiceName, "Name of the service")
+	displayName := flags.String("display-name", "log-genie", "Name of the service shown by the Services console, when installing")
+	description := flags.String("description", "Generates synthetic logs", "Description of the service, when installing")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: log-genie service <action> [flags] [-- generator flags]\n\nActions:\n")
+		for _, action := range serviceActions {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", action[0], action[1])
+		}
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flags.Usage()
+		return 2
+	}
+	action := args[0]
+	flags.Parse(args[1:])
+
+	var err error
+	switch action {
+	case "install":
+		err = installService(*name, *displayName, *description, flags.Args())
+	case "uninstall":
+		err = uninstallService(*name)
+	case "start":
+		err = controlService(*name, func(s *mgr.Service) error { return s.Start() })
+	case "stop":
+		err = controlService(*name, stopService)
+	default:
+		fmt.Fprintf(os.Stderr, "service: unknown action %q\n", action)
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %s: %v\n", action, err)
+		return 1
+	}
+	fmt.Printf("Service %s: %s done\n", *name, action)
+	return 0
+}
+
+// installService installs the service, starting automatically and running
+// the generator with args, and registers its event log source
+func installService(name, displayName, description string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	config := mgr.Config{DisplayName: displayName, Description: description, StartType: mgr.StartAutomatic}
+	s, err := m.CreateService(name, exe, config, append([]string{"service", "run", "--name=" + name}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("registering the event log source: %w", err)
+	}
+	return nil
+}
+
+// uninstallService removes the service and its event log source
+func uninstallService(name string) error {
+	if err := controlService(name, func(s *mgr.Service) error { return s.Delete() }); err != nil {
+		return err
+	}
+	return eventlog.Remove(name)
+}
+
+// controlService opens the service to act on it
+func controlService(name string, act func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("opening service %s: %w", name, err)
+	}
+	defer s.Close()
+	return act(s)
+}
+
+// stopService asks the service to stop and waits until it has
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startService reports to the service control manager that the generator
+// runs as the service name, and writes diagnostics to the event log
+func startService(name string) (*serviceRun, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return nil, err
+	}
+	if !isService {
+		return nil, errors.New("not started by the service control manager")
+	}
+	events, err := eventlog.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening the event log: %w", err)
+	}
+	diag.Use(newEventLogHandler(events))
+
+	run := newServiceRun()
+	go func() {
+		defer close(run.done)
+		if err := svc.Run(name, serviceHandler{run}); err != nil {
+			diag.Error("Service failed", "error", err)
+		}
+	}()
+	return run, nil
+}
+
+// serviceHandler answers the requests of the service control manager
+type serviceHandler struct {
+	run *serviceRun
+}
+
+// Execute reports the service running until the run exits, closing the
+// stopped channel when asked to stop
+func (h serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case code := <-h.run.exited:
+			status <- svc.Status{State: svc.StopPending}
+			return code != 0, uint32(code)
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(serviceStopTimeout / time.Millisecond)}
+				h.run.stop.Do(func() { close(h.run.stopped) })
+			}
+		}
+	}
+}
+
+// eventLogHandler writes diagnostics to the event log, formatted as text,
+// at the event type of their level
+type eventLogHandler struct {
+	text   slog.Handler
+	output *eventLogOutput
+}
+
+// eventLogOutput is the event log and the buffer records are formatted into,
+// shared by the handlers derived from one another
+type eventLogOutput struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	events *eventlog.Log
+}
+
+// newEventLogHandler creates a handler writing to events
+func newEventLogHandler(events *eventlog.Log) *eventLogHandler {
+	output := &eventLogOutput{events: events}
+	return &eventLogHandler{text: slog.NewTextHandler(&output.buffer, diag.Options()), output: output}
+}
+
+func (h *eventLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.text.Enabled(ctx, level)
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	o := h.output
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.buffer.Reset()
+	if err := h.text.Handle(ctx, record); err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(o.buffer.String(), "\n")
+	switch {
+	case record.Level >= slog.LevelError:
+		return o.events.Error(eventError, msg)
+	case record.Level >= slog.LevelWarn:
+		return o.events.Warning(eventWarning, msg)
+	}
+	return o.events.Info(eventInfo, msg)
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{text: h.text.WithAttrs(attrs), output: h.output}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{text: h.text.WithGroup(name), output: h.output}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package loggenie
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals toggle between pausing and resuming generation
+var pauseSignals = []os.Signal{syscall.SIGUSR2}
+
+// reloadSignals reload the config file
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// dumpSignals dump a stats snapshot
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
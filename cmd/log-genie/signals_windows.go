@@ -0,0 +1,15 @@
+//go:build windows
+
+package loggenie
+
+import "os"
+
+// pauseSignals toggle between pausing and resuming generation; Windows has
+// no user-defined signals
+var pauseSignals []os.Signal
+
+// reloadSignals reload the config file; Windows has no SIGHUP
+var reloadSignals []os.Signal
+
+// dumpSignals dump a stats snapshot; Windows has no user-defined signals
+var dumpSignals []os.Signal
@@ -0,0 +1,51 @@
+package loggenie
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// statsSnapshot is a point-in-time view of a running generator
+type statsSnapshot struct {
+	runSummary
+	QueueDepth int64  `json:"queue_depth"`
+	Rate       string `json:"rate"` // current target rate
+	Paused     bool   `json:"paused"`
+}
+
+// newSnapshot collects the counts of a running generator without
+// interrupting it
+func newSnapshot(log *logger.Logger, start time.Time, rate string, paused bool) statsSnapshot {
+	return statsSnapshot{
+		runSummary: newSummary(log, "snapshot", start, time.Now()),
+		QueueDepth: log.QueueDepth(),
+		Rate:       rate,
+		Paused:     paused,
+	}
+}
+
+// dump writes the snapshot as JSON to a file, replacing it, or to w if path
+// is empty
+func (s statsSnapshot) dump(path string, w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = w.Write(data)
+		return err
+	}
+
+	// Write next to the file and rename it, so a reader never sees a
+	// partial snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,205 @@
+package loggenie
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// runSummary describes a finished run
+type runSummary struct {
+	Reason          string            `json:"reason"` // signal, service, duration, count or replayed
+	Start           time.Time         `json:"start"`
+	End             time.Time         `json:"end"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	WarmUpSeconds   float64           `json:"warm_up_seconds,omitempty"` // time before the start left out of the counts
+	Logs            int64             `json:"logs"`
+	Bytes           int64             `json:"bytes"`
+	Levels          map[string]int64  `json:"levels"`
+	LogsPerSec      float64           `json:"logs_per_sec"`
+	BytesPerSec     float64           `json:"bytes_per_sec"`
+	Filtered        int64             `json:"filtered,omitempty"` // logs dropped by processors before any sink
+	Sinks           []stats.SinkStats `json:"sinks"`
+	MemoryThrottles int64             `json:"memory_throttles,omitempty"`  // times the memory guard throttled generation
+	Skipped         int64             `json:"skipped,omitempty"`           // logs the rate called for that generation skipped while behind
+	Backoffs        int64             `json:"backoffs,omitempty"`          // times backpressure cut the rate
+	LowestRateShare float64           `json:"lowest_rate_share,omitempty"` // lowest share of the rate backpressure cut it to
+	DrainSeconds    float64           `json:"drain_seconds"`               // time the sinks took to drain on shutdown
+	Drained         []drainStats      `json:"drained,omitempty"`           // what the sinks delivered while draining
+}
+
+// drainStats counts what a sink did with the logs it held on shutdown
+type drainStats struct {
+	Sink    string `json:"sink"`
+	Flushed int64  `json:"flushed"` // delivered or failed while draining
+	Dropped int64  `json:"dropped"` // still undelivered when the drain ended
+}
+
+// drain records how the sinks drained on shutdown, given their counts
+// before
+func (s *runSummary) drain(before []stats.SinkStats, took time.Duration) {
+	s.DrainSeconds = math.Round(took.Seconds()*1000) / 1000
+	s.Drained = drained(before, s.Sinks)
+}
+
+// drained compares the counts of the sinks before and after draining them,
+// leaving out those that held no logs
+func drained(before, after []stats.SinkStats) []drainStats {
+	done := make(map[string]int64, len(before))
+	for _, sink := range before {
+		done[sink.Name] = sink.Sent + sink.Failed
+	}
+	var drains []drainStats
+	for _, sink := range after {
+		d := drainStats{Sink: sink.Name, Flushed: sink.Sent + sink.Failed - done[sink.Name], Dropped: sink.Dropped}
+		if d.Flushed > 0 || d.Dropped > 0 {
+			drains = append(drains, d)
+		}
+	}
+	return drains
+}
+
+// newSummary collects the totals of a run once the logger has shut down
+func newSummary(log *logger.Logger, reason string, start, end time.Time) runSummary {
+	elapsed := end.Sub(start).Seconds()
+	s := runSummary{
+		Reason:          reason,
+		Start:           start,
+		End:             end,
+		DurationSeconds: round(elapsed),
+		Logs:            log.LogsEmitted(),
+		Bytes:           log.BytesEmitted(),
+		Levels:          map[string]int64{},
+		Filtered:        log.LogsFiltered(),
+		Sinks:           log.Sinks(),
+	}
+	for level, n := range log.LevelsEmitted() {
+		s.Levels[string(level)] = n
+	}
+	if elapsed > 0 {
+		s.LogsPerSec = round(float64(s.Logs) / elapsed)
+		s.BytesPerSec = round(float64(s.Bytes) / elapsed)
+	}
+	return s
+}
+
+// warmUp holds the totals at the end of the warm-up, which the summary
+// leaves out
+type warmUp struct {
+	end      time.Time
+	cpu      time.Duration // CPU time the process used by then
+	logs     int64
+	bytes    int64
+	filtered int64
+	levels   map[logger.LogLevel]int64
+	sinks    []stats.SinkStats
+}
+
+// endWarmUp takes the totals of the logger at the end of the warm-up
+func endWarmUp(log *logger.Logger) *warmUp {
+	return &warmUp{
+		end:      time.Now(),
+		cpu:      processCPU(),
+		logs:     log.LogsEmitted(),
+		bytes:    log.BytesEmitted(),
+		filtered: log.LogsFiltered(),
+		levels:   log.LevelsEmitted(),
+		sinks:    log.Sinks(),
+	}
+}
+
+// exclude leaves the logs of the warm-up out of the summary, which starts
+// when it ended. Logs the sinks dropped, or still held at the end, count
+// whenever they were generated.
+func (s *runSummary) exclude(w *warmUp) {
+	s.WarmUpSeconds = round(w.end.Sub(s.Start).Seconds())
+	s.Start = w.end
+	s.Logs -= w.logs
+	s.Bytes -= w.bytes
+	s.Filtered -= w.filtered
+	for level, n := range w.levels {
+		s.Levels[string(level)] -= n
+	}
+	before := make(map[string]stats.SinkStats, len(w.sinks))
+	for _, sink := range w.sinks {
+		before[sink.Name] = sink
+	}
+	for i, sink := range s.Sinks {
+		s.Sinks[i].Sent -= before[sink.Name].Sent
+		s.Sinks[i].Failed -= before[sink.Name].Failed
+	}
+
+	elapsed := s.End.Sub(s.Start).Seconds()
+	s.DurationSeconds = round(elapsed)
+	s.LogsPerSec, s.BytesPerSec = 0, 0
+	if elapsed > 0 {
+		s.LogsPerSec = round(float64(s.Logs) / elapsed)
+		s.BytesPerSec = round(float64(s.Bytes) / elapsed)
+	}
+}
+
+// round rounds to a tenth, enough for reporting rates
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+// report writes the summary to the diagnostics
+func (s runSummary) report() {
+	var levels []interface{}
+	for _, level := range []logger.LogLevel{logger.Debug, logger.Info, logger.Warn, logger.Error} {
+		levels = append(levels, slog.Int64(string(level), s.Levels[string(level)]))
+	}
+	diag.Info("Run summary",
+		"reason", s.Reason,
+		"duration", s.End.Sub(s.Start).Round(time.Millisecond).String(),
+		"logs", s.Logs,
+		"bytes", s.Bytes,
+		"logs_per_sec", s.LogsPerSec,
+		"bytes_per_sec", s.BytesPerSec,
+		slog.Group("levels", levels...))
+	if s.WarmUpSeconds > 0 {
+		diag.Info("Warm-up left out of the summary", "warm_up", seconds(s.WarmUpSeconds))
+	}
+	if s.MemoryThrottles > 0 {
+		diag.Warn("Generation was throttled to stay below the memory limit", "throttles", s.MemoryThrottles)
+	}
+	if s.Backoffs > 0 {
+		diag.Warn("Sinks pushed back, generation slowed down", "backoffs", s.Backoffs, "lowest_rate_share", math.Round(s.LowestRateShare*100)/100)
+	}
+	if s.Skipped > 0 {
+		diag.Warn("Generation fell behind the rate and skipped logs", "skipped", s.Skipped)
+	}
+	if s.Filtered > 0 {
+		diag.Info("Processors dropped logs before the sinks", "filtered", s.Filtered)
+	}
+	for _, d := range s.Drained {
+		if d.Dropped > 0 {
+			diag.Warn("Sink drained on shutdown, dropping logs it still held", "sink", d.Sink, "flushed", d.Flushed, "dropped", d.Dropped, "drain", seconds(s.DrainSeconds))
+		} else {
+			diag.Info("Sink drained on shutdown", "sink", d.Sink, "flushed", d.Flushed, "drain", seconds(s.DrainSeconds))
+		}
+	}
+	for _, sink := range s.Sinks {
+		diag.Info("Sink summary", "sink", sink.Name, "sent", sink.Sent, "failed", sink.Failed, "dropped", sink.Dropped)
+	}
+}
+
+// seconds formats a duration in seconds for diagnostics
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// write writes the summary as JSON to a file, for CI to consume
+func (s runSummary) write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
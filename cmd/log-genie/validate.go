@@ -0,0 +1,298 @@
+package loggenie
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/clock"
+	"github.com/rjonczy/log-genie/pkg/config"
+	"github.com/rjonczy/log-genie/pkg/logfile"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/netflow"
+	"github.com/rjonczy/log-genie/pkg/preset"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/scenario"
+	"github.com/rjonczy/log-genie/pkg/schedule"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/statsd"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+	"github.com/rjonczy/log-genie/pkg/webhook"
+)
+
+// probeTimeout bounds connecting to an endpoint when probing
+const probeTimeout = 3 * time.Second
+
+// settingChecks validate the values of settings beyond what parsing the
+// flag checks, mirroring the checks of a run
+var settingChecks = map[string]func(value string) error{
+	"rate": func(value string) error {
+		r, err := ratelimit.Parse(value)
+		if err != nil {
+			return err
+		}
+		return ratelimit.Validate(r)
+	},
+	"throughput": func(value string) error {
+		r, err := ratelimit.ParseBytes(value)
+		if err != nil {
+			return err
+		}
+		return ratelimit.ValidateBytes(r)
+	},
+	"error-ratio": func(value string) error {
+		return checkRatio(value)
+	},
+	"repeat-probability": func(value string) error {
+		return checkRatio(value)
+	},
+	"max-loss": func(value string) error {
+		return checkRatio(value)
+	},
+	"level-weights": func(value string) error {
+		_, err := logger.ParseLevelWeights(value)
+		return err
+	},
+	"attributes": func(value string) error {
+		_, err := logger.ParseAttributes(value)
+		return err
+	},
+	"format": func(value string) error {
+		for _, format := range logger.Formats() {
+			if strings.EqualFold(value, format.Name) {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown format %q", value)
+	},
+	"tenants": func(value string) error {
+		_, err := tenant.Parse(value)
+		return err
+	},
+	"nested-in": func(value string) error {
+		switch strings.ToLower(value) {
+		case logger.NestedInFields, logger.NestedInBody:
+			return nil
+		}
+		return fmt.Errorf("unknown nesting target %q", value)
+	},
+	"level-format": func(value string) error {
+		for _, format := range logger.LevelFormats() {
+			if strings.EqualFold(value, format.Name) {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown level format %q", value)
+	},
+	"preset": func(value string) error {
+		_, err := preset.Load(value)
+		return err
+	},
+	"timezone": func(value string) error {
+		_, err := clock.ParseLocation(value)
+		return err
+	},
+	"timestamp-format": func(value string) error {
+		_, err := clock.ParseFormat(value)
+		return err
+	},
+	"schedule": func(value string) error {
+		_, err := schedule.Parse(value)
+		return err
+	},
+	"profile": func(value string) error {
+		_, err := ratelimit.LoadProfile(value)
+		return err
+	},
+	"scenario": func(value string) error {
+		_, err := scenario.Load(value)
+		return err
+	},
+	"arrival": func(value string) error {
+		_, err := ratelimit.NewArrival(value, defaultJitter)
+		return err
+	},
+	"backpressure": func(value string) error {
+		_, err := ratelimit.ParseBackpressure(value)
+		return err
+	},
+	"output": func(value string) error {
+		_, err := logger.ParseOutput(value)
+		return err
+	},
+	"child-stream": func(value string) error {
+		if value != childStdout && value != childStderr {
+			return fmt.Errorf("unknown child stream %q (use %s or %s)", value, childStdout, childStderr)
+		}
+		return nil
+	},
+	"file-compress": func(value string) error {
+		_, err := logfile.ParseCompression(value)
+		return err
+	},
+	"file-rotate-size": func(value string) error {
+		size, err := ratelimit.ParseSize(value)
+		if err == nil && size < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+		return err
+	},
+	"report-format": func(value string) error {
+		_, err := stats.ParseReportFormat(value)
+		return err
+	},
+	"netflow-version": func(value string) error {
+		_, err := netflow.ParseVersion(value)
+		return err
+	},
+	"statsd-flavor": func(value string) error {
+		_, err := statsd.ParseFlavor(value)
+		return err
+	},
+	"http-preset": func(value string) error {
+		_, err := webhook.LookupPreset(value)
+		return err
+	},
+	"http-format": func(value string) error {
+		_, err := webhook.ParseFormat(value)
+		return err
+	},
+	"pacing": func(value string) error {
+		_, err := ratelimit.ParsePacing(value)
+		return err
+	},
+	"buffer": func(value string) error {
+		size, err := ratelimit.ParseSize(value)
+		if err == nil && (size < 0 || size > 1<<30) {
+			err = fmt.Errorf("must be between 0 and 1GiB")
+		}
+		return err
+	},
+	"async-overflow": func(value string) error {
+		_, err := logger.ParseOverflow(value)
+		return err
+	},
+	"max-memory": func(value string) error {
+		limit, err := ratelimit.ParseSize(value)
+		if err == nil && limit < 1<<20 {
+			err = fmt.Errorf("must be at least 1MiB")
+		}
+		return err
+	},
+	"async-writers": func(value string) error {
+		if n, _ := strconv.Atoi(value); n < 1 {
+			return fmt.Errorf("must be at least 1")
+		}
+		return nil
+	},
+	"workers": func(value string) error {
+		if n, _ := strconv.Atoi(value); n < 1 {
+			return fmt.Errorf("must be at least 1")
+		}
+		return nil
+	},
+	"count": func(value string) error {
+		if n, _ := strconv.ParseInt(value, 10, 64); n < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		return nil
+	},
+}
+
+// probedSettings name the settings holding endpoints --probe connects to
+var probedSettings = []string{"telemetry-endpoint", "coordinator"}
+
+// runValidate checks a config file against the generator flags, printing
+// every problem with its position, and returns the exit code
+func runValidate(path string, probe bool) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "validate: --config is required")
+		return 2
+	}
+
+	settings, err := config.LoadSettings(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	problems := 0
+	report := func(s config.Setting, format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s: %s\n", path, s.Line, s.Column, s.Name, fmt.Sprintf(format, args...))
+		problems++
+	}
+
+	seen := map[string]bool{}
+	for _, s := range settings {
+		if seen[s.Name] {
+			report(s, "duplicate setting")
+			continue
+		}
+		seen[s.Name] = true
+
+		if flag.Lookup(s.Name) == nil || s.Name == "config" {
+			report(s, "unknown setting")
+			continue
+		}
+		if err := flag.Set(s.Name, s.Value); err != nil {
+			report(s, "invalid value %q: %v", s.Value, err)
+			continue
+		}
+		if check, ok := settingChecks[s.Name]; ok {
+			if err := check(s.Value); err != nil {
+				report(s, "invalid value %q: %v", s.Value, err)
+				continue
+			}
+		}
+
+		if probe && slices.Contains(probedSettings, s.Name) && s.Value != "" {
+			if err := probeEndpoint(s.Value); err != nil {
+				report(s, "endpoint %s is unreachable: %v", s.Value, err)
+			}
+		}
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", path, problems)
+		return 1
+	}
+	fmt.Printf("%s: OK (%d settings)\n", path, len(settings))
+	return 0
+}
+
+// checkRatio checks that a value is a number between 0 and 1
+func checkRatio(value string) error {
+	r, err := strconv.ParseFloat(value, 64)
+	if err != nil || r < 0 || r > 1 {
+		return fmt.Errorf("must be between 0 and 1")
+	}
+	return nil
+}
+
+// probeEndpoint opens (and closes) a TCP connection to an endpoint given as
+// host:port or URL
+func probeEndpoint(endpoint string) error {
+	address := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		address = u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", address, probeTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
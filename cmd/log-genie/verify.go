@@ -0,0 +1,73 @@
+package loggenie
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/verify"
+)
+
+// settle waits for the logs still in the pipeline to arrive, up to the
+// settle time or a signal, and returns the verification. With a backend,
+// the logs are read back from it once the settle time has passed.
+func settle(verifier *verify.Verifier, sent uint64, timeout time.Duration, sigs <-chan os.Signal, backend string, query verify.QueryConfig) verify.Report {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if backend == "" {
+		diag.Info("Waiting for logs in the pipeline", "sent", sent, "settle", timeout.String())
+		verifier.Wait(ctx, sent, timeout)
+		report := verifier.Report(sent)
+		report.Source = "receivers"
+		return report
+	}
+
+	diag.Info("Waiting for the backend to ingest the logs", "backend", backend, "sent", sent, "settle", timeout.String())
+	select {
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+	// A signal cuts the wait short, not the query
+	read, err := verifier.Query(context.Background(), backend, query)
+	if err != nil {
+		diag.Error("Failed to query the backend", "backend", backend, "url", query.URL, "read", read, "error", err)
+	} else {
+		diag.Info("Queried the backend", "backend", backend, "read", read)
+	}
+	report := verifier.Report(sent)
+	report.Source = backend
+	return report
+}
+
+// writeVerifyReport writes the verification as indented JSON
+func writeVerifyReport(report verify.Report, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// verifyExitCode reports the problems a verification found, returning the
+// exit code they decide
+func verifyExitCode(report verify.Report) int {
+	if report.Passed {
+		diag.Info("Verification passed", "received", report.Received, "reordered", report.Reordered)
+		return exitOK
+	}
+	diag.Error("Verification failed",
+		"lost", report.Lost,
+		"duplicated", report.Duplicated,
+		"corrupted", report.Corrupted,
+		"unexpected", report.Unexpected)
+	return exitVerify
+}
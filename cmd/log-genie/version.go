@@ -0,0 +1,31 @@
+package loggenie
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+// runVersion implements the version subcommand: it prints the version,
+// commit, build date and Go version of the binary
+func runVersion(args []string) int {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the build metadata as JSON")
+	flags.Parse(args)
+
+	info := version.Get()
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(info); err != nil {
+			fmt.Fprintf(os.Stderr, "version: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Println(info)
+	return 0
+}
@@ -0,0 +1,109 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// errorCodes are symbolic error codes attached to error logs, with whether
+// an operation failing with them is worth retrying
+var errorCodes = map[string]bool{
+	"ECONNREFUSED":         true,
+	"ETIMEDOUT":            true,
+	"ECONNRESET":           true,
+	"DEADLOCK_DETECTED":    true,
+	"SERVICE_UNAVAILABLE":  true,
+	"NULL_POINTER":         false,
+	"INVALID_ARGUMENT":     false,
+	"PERMISSION_DENIED":    false,
+	"DISK_FULL":            false,
+	"CERTIFICATE_EXPIRED":  false,
+	"RESOURCE_EXHAUSTED":   true,
+	"UNIQUE_CONSTRAINT":    false,
+	"UPSTREAM_BAD_GATEWAY": true,
+}
+
+// Attributes returns attributes typical for logs of the given level: errors
+// carry stack_trace/error_kind/retryable, warnings retry_count/backoff_ms and
+// debug logs a dump of internal state. Info logs get no extra attributes.
+func Attributes(level string) map[string]interface{} {
+	fields := map[string]interface{}{}
+	AddAttributes(level, fields)
+	return fields
+}
+
+// AddAttributes adds the attributes of Attributes to fields, sparing a map
+func AddAttributes(level string, fields map[string]interface{}) {
+	switch level {
+	case Error:
+		// The symbolic code goes beside the numeric error_code of error logs
+		kind := gofakeit.RandomMapKey(errorCodes).(string)
+		fields["error_kind"] = kind
+		fields["error_type"] = placeholders["exception"]()
+		fields["retryable"] = errorCodes[kind]
+		fields["stack_trace"] = StackTrace()
+	case Warn:
+		fields["retry_count"] = gofakeit.Number(1, 5)
+		fields["backoff_ms"] = 100 << gofakeit.Number(0, 6)
+	case Debug:
+		fields["thread"] = fmt.Sprintf("worker-%d", gofakeit.Number(1, 32))
+		fields["state_dump"] = stateDump()
+	}
+}
+
+// StackTrace returns a realistic multi-line stack trace in a random language style
+func StackTrace() string {
+	frames := gofakeit.Number(3, 8)
+	var b strings.Builder
+
+	switch gofakeit.Number(0, 2) {
+	case 0: // Java
+		fmt.Fprintf(&b, "java.lang.%s: %s\n", gofakeit.RandomString([]string{"NullPointerException", "IllegalStateException", "IllegalArgumentException"}), gofakeit.HackerPhrase())
+		for i := 0; i < frames; i++ {
+			class := gofakeit.RandomString([]string{"OrderService", "UserRepository", "PaymentClient", "RequestHandler", "CacheManager"})
+			fmt.Fprintf(&b, "\tat com.example.%s.%s(%s.java:%d)\n", class, placeholders["method"](), class, gofakeit.Number(10, 900))
+		}
+	case 1: // Go
+		fmt.Fprintf(&b, "goroutine %d [running]:\n", gofakeit.Number(1, 5000))
+		for i := 0; i < frames; i++ {
+			pkg := gofakeit.RandomString([]string{"server", "store", "handlers", "client", "cache"})
+			fmt.Fprintf(&b, "example.com/app/%s.%s(...)\n\t/app/%s/%s.go:%d +0x%x\n", pkg, exported(placeholders["method"]()), pkg, pkg, gofakeit.Number(10, 900), gofakeit.Number(16, 4095))
+		}
+	default: // Python
+		b.WriteString("Traceback (most recent call last):\n")
+		for i := 0; i < frames; i++ {
+			module := gofakeit.RandomString([]string{"views", "models", "tasks", "client", "utils"})
+			fmt.Fprintf(&b, "  File \"/app/%s.py\", line %d, in %s\n", module, gofakeit.Number(10, 900), strings.ToLower(placeholders["method"]()))
+		}
+		fmt.Fprintf(&b, "%s: %s\n", gofakeit.RandomString([]string{"KeyError", "ValueError", "TimeoutError"}), gofakeit.HackerPhrase())
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// exported upper-cases the first letter of a Go identifier
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// stateDump returns a JSON encoded snapshot of made-up internal state
+func stateDump() string {
+	state := map[string]interface{}{
+		"goroutines":   gofakeit.Number(8, 400),
+		"heap_mb":      gofakeit.Number(20, 2048),
+		"cache_size":   gofakeit.Number(0, 100000),
+		"pending_jobs": gofakeit.Number(0, 50),
+		"conn_pool": map[string]int{
+			"idle":   gofakeit.Number(0, 10),
+			"active": gofakeit.Number(0, 20),
+		},
+	}
+	encoded, _ := json.Marshal(state)
+	return string(encoded)
+}
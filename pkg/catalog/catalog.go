@@ -0,0 +1,278 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Level names understood by the catalog
+const (
+	Debug = "debug"
+	Info  = "info"
+	Warn  = "warn"
+	Error = "error"
+)
+
+// messages holds message templates per level. Placeholders in braces are
+// replaced with fake values, or the fields of the log, by Message.
+var messages = map[string][]string{
+	Debug: {
+		"Cache lookup for key {key} returned {hit}",
+		"Acquired connection from pool {pool} (idle={small}, active={small})",
+		"Resolved {host} to {ip} in {ms}ms",
+		"Entering {method} with {small} arguments",
+		"Feature flag {flag} evaluated to {bool} for user {user}",
+		"Parsed request body of {bytes} bytes for {path}",
+		"Scheduling job {id} on worker {small}",
+		"Retry policy for {service}: max_attempts={small} backoff={ms}ms",
+		"Loaded {count} rows from table {table} in {ms}ms",
+		"Span {hex} finished with status OK",
+	},
+	Info: {
+		"{http} {path} completed with status {status} in {ms}ms",
+		"User {user} logged in from {ip}",
+		"Order {id} created for customer {user} ({count} items)",
+		"Started {service} version {version} on port {port}",
+		"Processed batch of {count} messages from topic {topic}",
+		"Scheduled job {job} completed in {ms}ms",
+		"Health check passed for {service}",
+		"Uploaded {bytes} bytes to bucket {bucket}",
+		"Configuration reloaded from {file}",
+		"Connected to {db} at {host}:{port}",
+	},
+	Warn: {
+		"Retrying request to {service} (attempt {small}/5) after {ms}ms",
+		"Slow query on {table} took {slowms}ms",
+		"Deprecated API {path} called by client {user}",
+		"Connection pool {pool} is at {pct}% capacity",
+		"Disk usage on {mount} is at {pct}%",
+		"Rate limit approaching for API key {hex}: {count} requests in the last minute",
+		"Certificate for {host} expires in {small} days",
+		"Response from {service} exceeded SLO: {slowms}ms",
+		"Falling back to cached value for {key} after upstream timeout",
+		"Message {id} redelivered from topic {topic} ({small} times)",
+	},
+	Error: {
+		"Request to {service} timed out after {slowms}ms",
+		"dial tcp {ip}:{port}: connect: connection refused",
+		"java.lang.NullPointerException: Cannot invoke \"{class}.{method}()\" because \"{var}\" is null",
+		"Failed to execute query on {table}: deadlock detected",
+		"Unhandled exception processing order {id}: {exception}",
+		"{http} {path} failed with status {errstatus}",
+		"Could not connect to {db} at {host}:{port}: too many connections",
+		"Payment for order {id} declined by provider: {reason}",
+		"panic: runtime error: index out of range [{small}] with length {small}",
+		"Write to {file} failed: no space left on device",
+		"TLS handshake with {host} failed: certificate has expired",
+		"Consumer for topic {topic} crashed: {exception}",
+	},
+}
+
+// placeholders generates the fake value for each supported placeholder
+var placeholders = map[string]func() string{
+	"key":    func() string { return fmt.Sprintf("%s:%d", gofakeit.Noun(), gofakeit.Number(1, 99999)) },
+	"hit":    func() string { return gofakeit.RandomString([]string{"hit", "miss"}) },
+	"pool":   func() string { return gofakeit.RandomString([]string{"primary", "replica", "analytics"}) },
+	"small":  func() string { return fmt.Sprint(gofakeit.Number(1, 20)) },
+	"host":   func() string { return gofakeit.DomainName() },
+	"ip":     func() string { return gofakeit.IPv4Address() },
+	"ms":     func() string { return fmt.Sprint(gofakeit.Number(1, 500)) },
+	"slowms": func() string { return fmt.Sprint(gofakeit.Number(1000, 30000)) },
+	"method": func() string {
+		return gofakeit.RandomString([]string{"getUser", "saveOrder", "validateToken", "computeTotals"})
+	},
+	"flag":  func() string { return strings.ToLower(gofakeit.Adjective()) + "_" + strings.ToLower(gofakeit.Noun()) },
+	"bool":  func() string { return fmt.Sprint(gofakeit.Bool()) },
+	"user":  func() string { return gofakeit.Username() },
+	"bytes": func() string { return fmt.Sprint(gofakeit.Number(128, 5_000_000)) },
+	"path": func() string {
+		return "/api/v" + fmt.Sprint(gofakeit.Number(1, 3)) + "/" + strings.ToLower(gofakeit.Noun())
+	},
+	"id":      func() string { return gofakeit.UUID() },
+	"service": func() string { return strings.ToLower(gofakeit.AppName()) + "-service" },
+	"count":   func() string { return fmt.Sprint(gofakeit.Number(1, 1000)) },
+	"table": func() string {
+		return gofakeit.RandomString([]string{"users", "orders", "payments", "sessions", "inventory"})
+	},
+	"hex":       func() string { return gofakeit.HexUint64()[2:] },
+	"http":      func() string { return gofakeit.HTTPMethod() },
+	"status":    func() string { return fmt.Sprint(Status(Info)) },
+	"errstatus": func() string { return fmt.Sprint(Status(Error)) },
+	"version":   func() string { return gofakeit.AppVersion() },
+	"port":      func() string { return fmt.Sprint(gofakeit.Number(1024, 65535)) },
+	"topic":     func() string { return gofakeit.RandomString([]string{"orders", "events", "payments", "audit"}) },
+	"job":       func() string { return gofakeit.RandomString([]string{"cleanup", "reindex", "billing-run", "report"}) },
+	"bucket":    func() string { return strings.ToLower(gofakeit.Word()) + "-assets" },
+	"file": func() string {
+		return gofakeit.RandomString([]string{"/etc/app/config.yaml", "/var/lib/app/state.db", "/var/log/app/app.log", "/data/export.csv"})
+	},
+	"db":    func() string { return gofakeit.RandomString([]string{"postgres", "mysql", "redis", "mongodb"}) },
+	"pct":   func() string { return fmt.Sprint(gofakeit.Number(80, 99)) },
+	"mount": func() string { return gofakeit.RandomString([]string{"/", "/var", "/data", "/tmp"}) },
+	"class": func() string { return gofakeit.RandomString([]string{"User", "Order", "Session", "Account"}) },
+	"var":   func() string { return gofakeit.RandomString([]string{"user", "order", "session", "this.account"}) },
+	"exception": func() string {
+		return gofakeit.RandomString([]string{"IllegalStateException", "TimeoutError", "ValueError", "context deadline exceeded"})
+	},
+	"reason": func() string {
+		return gofakeit.RandomString([]string{"insufficient funds", "card expired", "fraud suspected"})
+	},
+}
+
+// fieldPlaceholders maps the placeholders that stand for an attribute of the
+// request a log is about to the field holding it
+var fieldPlaceholders = map[string]string{
+	"http":      "http_method",
+	"status":    "status_code",
+	"errstatus": "status_code",
+	"ms":        "latency_ms",
+	"slowms":    "latency_ms",
+	"user":      "user_id",
+	"ip":        "ip_address",
+}
+
+// statusCodes are the HTTP status codes befitting each level
+var statusCodes = map[string][]int{
+	Debug: {200, 201, 204, 301, 302, 304},
+	Info:  {200, 201, 204, 301, 302, 304},
+	Warn:  {400, 401, 403, 404, 409, 429},
+	Error: {500, 502, 503, 504},
+}
+
+// Status returns an HTTP status code befitting a log of the given level:
+// success for debug and info, a client error for warn and a server error
+// for error
+func Status(level string) int {
+	codes, ok := statusCodes[level]
+	if !ok {
+		codes = statusCodes[Info]
+	}
+	return gofakeit.RandomInt(codes)
+}
+
+// registered holds the placeholders added by Register. The map is replaced,
+// never changed, so rendering reads it without locking.
+var (
+	registered    atomic.Pointer[map[string]func() string]
+	registerMutex sync.Mutex
+)
+
+// Register adds a placeholder generating a domain-specific fake, e.g. an
+// internal ticket ID or SKU, for message templates and schemas to reference
+// by name. A later registration replaces an earlier one; built-in
+// placeholders cannot be replaced.
+func Register(name string, generate func() string) error {
+	if name == "" || strings.ContainsAny(name, "{}") {
+		return fmt.Errorf("invalid placeholder name %q", name)
+	}
+	if _, ok := placeholders[name]; ok {
+		return fmt.Errorf("placeholder %q is built in", name)
+	}
+
+	registerMutex.Lock()
+	defer registerMutex.Unlock()
+	fakes := map[string]func() string{name: generate}
+	if current := registered.Load(); current != nil {
+		for k, v := range *current {
+			if k != name {
+				fakes[k] = v
+			}
+		}
+	}
+	registered.Store(&fakes)
+	return nil
+}
+
+// Fake returns the generator of a placeholder, built in or registered
+func Fake(name string) (func() string, bool) {
+	if generate, ok := placeholders[name]; ok {
+		return generate, true
+	}
+	if fakes := registered.Load(); fakes != nil {
+		generate, ok := (*fakes)[name]
+		return generate, ok
+	}
+	return nil, false
+}
+
+// Fakes returns the names of all placeholders, sorted
+func Fakes() []string {
+	names := make([]string, 0, len(placeholders))
+	for name := range placeholders {
+		names = append(names, name)
+	}
+	if fakes := registered.Load(); fakes != nil {
+		for name := range *fakes {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Levels returns the levels the catalog has messages for
+func Levels() []string {
+	return []string{Debug, Info, Warn, Error}
+}
+
+// Message returns a random message appropriate for the given level,
+// rendered like Render
+func Message(level string, fields map[string]interface{}) string {
+	templates, ok := messages[level]
+	if !ok {
+		templates = messages[Info]
+	}
+	return Render(templates[gofakeit.Number(0, len(templates)-1)], fields)
+}
+
+// Render replaces {placeholder} markers in a template with fake values.
+// Unknown placeholders are left untouched. A placeholder standing for a field
+// of the log, e.g. {status} for status_code, quotes the field when fields
+// has it and otherwise sets it to the fake value, so the message and fields
+// of a log agree. Fields may be nil.
+func Render(template string, fields map[string]interface{}) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(template[:start])
+		name := template[start+1 : end]
+		field := fieldPlaceholders[name]
+		if value, ok := fields[field]; ok && field != "" {
+			fmt.Fprint(&b, value)
+		} else if generate, ok := Fake(name); ok {
+			value := generate()
+			b.WriteString(value)
+			if field != "" && fields != nil {
+				fields[field] = fieldValue(value)
+			}
+		} else {
+			b.WriteString(template[start : end+1])
+		}
+		template = template[end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// fieldValue returns a fake value as a field, numbers as integers
+func fieldValue(value string) interface{} {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderQuotesFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		fields   map[string]interface{}
+		want     string
+	}{
+		{"quotes fields", "{http} /orders completed with status {status} in {ms}ms",
+			map[string]interface{}{"http_method": "PUT", "status_code": 201, "latency_ms": 42},
+			"PUT /orders completed with status 201 in 42ms"},
+		{"repeated placeholder", "{user} logged in as {user}",
+			map[string]interface{}{"user_id": "u-1"}, "u-1 logged in as u-1"},
+		{"unknown placeholder", "{nope} {http}",
+			map[string]interface{}{"http_method": "GET"}, "{nope} GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.template, tt.fields); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSetsMissingFields(t *testing.T) {
+	fields := map[string]interface{}{}
+	message := Render("Slow query took {slowms}ms", fields)
+	latency, ok := fields["latency_ms"].(int)
+	if !ok || message != fmt.Sprintf("Slow query took %dms", latency) {
+		t.Errorf("rendered %q with latency_ms = %v", message, fields["latency_ms"])
+	}
+
+	if message := Render("{http} {table}", nil); strings.ContainsAny(message, "{}") {
+		t.Errorf("rendered %q without fields", message)
+	}
+}
+
+func TestMessageAgreesWithFields(t *testing.T) {
+	for _, level := range Levels() {
+		for i := 0; i < 200; i++ {
+			fields := map[string]interface{}{}
+			message := Message(level, fields)
+			for _, field := range []string{"http_method", "status_code", "latency_ms", "user_id", "ip_address"} {
+				if value, ok := fields[field]; ok && !strings.Contains(message, fmt.Sprint(value)) {
+					t.Errorf("%s message %q does not quote %s = %v", level, message, field, value)
+				}
+			}
+			if status, ok := fields["status_code"].(int); ok && (level == Info) != (status < 400) {
+				t.Errorf("%s message %q reports status %d", level, message, status)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock produces the timestamps stamped on generated logs
+type Clock struct {
+	offset   time.Duration
+	location *time.Location
+}
+
+// Config holds the configuration for the clock
+type Config struct {
+	Offset   time.Duration // Skew added to every generated timestamp (may be negative)
+	Timezone string        // IANA zone name, "UTC", "Local" or a fixed offset such as "+05:30"
+}
+
+// New creates a new clock with the given configuration
+func New(config Config) (*Clock, error) {
+	location, err := ParseLocation(config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clock{
+		offset:   config.Offset,
+		location: location,
+	}, nil
+}
+
+// Now returns the current time shifted by the configured offset and
+// expressed in the configured timezone
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.offset).In(c.location)
+}
+
+// Offset returns the configured clock skew
+func (c *Clock) Offset() time.Duration {
+	return c.offset
+}
+
+// Location returns the configured timezone
+func (c *Clock) Location() *time.Location {
+	return c.location
+}
+
+// ParseLocation resolves a timezone specification. IANA names (which carry
+// their DST rules), "UTC", "Local" and fixed offsets ("+02:00", "-0530",
+// "UTC+3") are supported. An empty string means UTC.
+func ParseLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+
+	switch strings.ToLower(name) {
+	case "", "utc", "z":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	}
+
+	// Fixed offsets, optionally prefixed with UTC or GMT
+	offset := name
+	for _, prefix := range []string{"UTC", "GMT"} {
+		if strings.HasPrefix(strings.ToUpper(offset), prefix) && len(offset) > len(prefix) {
+			offset = offset[len(prefix):]
+			break
+		}
+	}
+	if strings.HasPrefix(offset, "+") || strings.HasPrefix(offset, "-") {
+		seconds, err := parseOffset(offset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone offset %q: %w", name, err)
+		}
+		return time.FixedZone(name, seconds), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// parseOffset converts "+HH", "+HHMM" or "+HH:MM" into seconds east of UTC
+func parseOffset(offset string) (int, error) {
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+	digits := strings.ReplaceAll(offset[1:], ":", "")
+
+	var hours, minutes int
+	var err error
+	switch len(digits) {
+	case 1, 2:
+		hours, err = strconv.Atoi(digits)
+	case 3, 4:
+		hours, err = strconv.Atoi(digits[:len(digits)-2])
+		if err == nil {
+			minutes, err = strconv.Atoi(digits[len(digits)-2:])
+		}
+	default:
+		return 0, fmt.Errorf("expected +HH, +HHMM or +HH:MM")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if hours > 14 || minutes > 59 {
+		return 0, fmt.Errorf("offset out of range")
+	}
+
+	return sign * (hours*3600 + minutes*60), nil
+}
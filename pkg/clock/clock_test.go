@@ -0,0 +1,39 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		in      string
+		offset  int // seconds east of UTC, in January
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"UTC", 0, false},
+		{"+05:30", 5*3600 + 30*60, false},
+		{"-0800", -8 * 3600, false},
+		{"UTC+3", 3 * 3600, false},
+		{"GMT-2", -2 * 3600, false},
+		{"Asia/Tokyo", 9 * 3600, false},
+		{"+15", 0, true},
+		{"+05:75", 0, true},
+		{"Mars/Olympus", 0, true},
+	}
+	january := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		location, err := ParseLocation(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLocation(%q) error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, offset := january.In(location).Zone(); offset != tt.offset {
+			t.Errorf("ParseLocation(%q) offset %d, want %d", tt.in, offset, tt.offset)
+		}
+	}
+}
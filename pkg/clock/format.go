@@ -0,0 +1,140 @@
+package clock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format describes how the generated timestamp field is rendered
+type Format struct {
+	kind   string
+	layout string
+}
+
+// Supported timestamp format names
+const (
+	FormatNone        = "none"
+	FormatEpochS      = "epoch_s"
+	FormatEpochMillis = "epoch_ms"
+	FormatEpochMicros = "epoch_us"
+	FormatEpochNanos  = "epoch_ns"
+	FormatRFC3339     = "rfc3339"
+	FormatRFC3339Nano = "rfc3339nano"
+	formatLayout      = "layout"
+)
+
+// ParseFormat parses a timestamp format specification. Besides the named
+// formats, a strftime-like pattern ("%Y-%m-%d %H:%M:%S.%f") or a Go
+// reference-time layout ("2006-01-02 15:04:05") may be given.
+func ParseFormat(spec string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case FormatNone, "absent", "":
+		return Format{kind: FormatNone}, nil
+	case FormatEpochS, "epoch", "unix":
+		return Format{kind: FormatEpochS}, nil
+	case FormatEpochMillis, "unix_ms":
+		return Format{kind: FormatEpochMillis}, nil
+	case FormatEpochMicros, "unix_us":
+		return Format{kind: FormatEpochMicros}, nil
+	case FormatEpochNanos, "unix_ns":
+		return Format{kind: FormatEpochNanos}, nil
+	case FormatRFC3339:
+		return Format{kind: formatLayout, layout: time.RFC3339}, nil
+	case FormatRFC3339Nano, "iso8601":
+		return Format{kind: formatLayout, layout: time.RFC3339Nano}, nil
+	}
+
+	if strings.Contains(spec, "%") {
+		layout, err := strftimeLayout(spec)
+		if err != nil {
+			return Format{}, err
+		}
+		return Format{kind: formatLayout, layout: layout}, nil
+	}
+
+	// Anything mentioning the reference year is treated as a Go layout
+	if strings.Contains(spec, "2006") || strings.Contains(spec, "15:04") {
+		return Format{kind: formatLayout, layout: spec}, nil
+	}
+
+	return Format{}, fmt.Errorf("unknown timestamp format %q", spec)
+}
+
+// Absent reports whether the timestamp field should be omitted
+func (f Format) Absent() bool {
+	return f.kind == FormatNone
+}
+
+// Value renders the timestamp according to the format. Epoch formats
+// produce integers, layouts produce strings.
+func (f Format) Value(t time.Time) interface{} {
+	switch f.kind {
+	case FormatEpochS:
+		return t.Unix()
+	case FormatEpochMillis:
+		return t.UnixMilli()
+	case FormatEpochMicros:
+		return t.UnixMicro()
+	case FormatEpochNanos:
+		return t.UnixNano()
+	case formatLayout:
+		return t.Format(f.layout)
+	}
+	return nil
+}
+
+// strftimeDirectives maps strftime directives to Go layout elements
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'f': "000000",
+	'L': "000",
+	'N': "000000000",
+	'p': "PM",
+	'z': "-0700",
+	'Z': "MST",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'j': "002",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'%': "%",
+}
+
+// strftimeLayout converts a strftime-like pattern into a Go time layout
+func strftimeLayout(pattern string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		if i+1 >= len(pattern) {
+			return "", fmt.Errorf("timestamp format %q ends with a lone %%", pattern)
+		}
+		i++
+		directive, ok := strftimeDirectives[pattern[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in timestamp format %q", pattern[i], pattern)
+		}
+		// Go only recognises fractional seconds right after a '.' or ','
+		if pattern[i] == 'f' || pattern[i] == 'L' || pattern[i] == 'N' {
+			if i < 2 || (pattern[i-2] != '.' && pattern[i-2] != ',') {
+				return "", fmt.Errorf("fractional seconds in %q must follow a '.' or ','", pattern)
+			}
+		}
+		b.WriteString(directive)
+	}
+	return b.String(), nil
+}
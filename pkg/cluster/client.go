@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds a single heartbeat request
+const requestTimeout = 5 * time.Second
+
+// Client reports a worker's progress to a coordinator
+type Client struct {
+	url   string
+	id    string
+	http  *http.Client
+	watch *http.Client // without a timeout, bounded by the request's context
+}
+
+// NewClient creates a client for the coordinator at url, identifying the
+// worker as id
+func NewClient(url, id string) *Client {
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	return &Client{
+		url:   strings.TrimSuffix(url, "/"),
+		id:    id,
+		http:  &http.Client{Timeout: requestTimeout},
+		watch: &http.Client{},
+	}
+}
+
+// ID returns the worker ID
+func (c *Client) ID() string {
+	return c.id
+}
+
+// Heartbeat sends the worker's totals and returns its current assignment.
+// The first heartbeat joins the worker to the coordinator.
+func (c *Client) Heartbeat(ctx context.Context, logs, bytesEmitted int64, done bool) (*Assignment, error) {
+	body, err := json.Marshal(Report{ID: c.id, Logs: logs, Bytes: bytesEmitted, Done: done})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/v1/heartbeat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.assignment(c.http, req)
+}
+
+// Watch waits for the shares to change since the epoch of an earlier
+// assignment and returns the new assignment, or the unchanged one after an
+// interval
+func (c *Client) Watch(ctx context.Context, epoch uint64, interval time.Duration) (*Assignment, error) {
+	ctx, cancel := context.WithTimeout(ctx, interval+requestTimeout)
+	defer cancel()
+	query := url.Values{"id": {c.id}, "epoch": {strconv.FormatUint(epoch, 10)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/v1/assignment?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.assignment(c.watch, req)
+}
+
+// assignment sends a request answered with an assignment
+func (c *Client) assignment(client *http.Client, req *http.Request) (*Assignment, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coordinator returned %s", resp.Status)
+	}
+
+	var assignment Assignment
+	if err := json.NewDecoder(resp.Body).Decode(&assignment); err != nil {
+		return nil, fmt.Errorf("invalid assignment: %w", err)
+	}
+	if assignment.Interval <= 0 {
+		assignment.Interval = defaultInterval
+	}
+	return &assignment, nil
+}
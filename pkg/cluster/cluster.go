@@ -0,0 +1,296 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// defaultInterval is how often workers report when not configured
+const defaultInterval = 2 * time.Second
+
+// missedReports is how many reports a worker may miss before its rate share
+// is handed to the others
+const missedReports = 3
+
+// Report is what a worker sends the coordinator on every heartbeat
+type Report struct {
+	ID    string `json:"id"`
+	Logs  int64  `json:"logs"`
+	Bytes int64  `json:"bytes"`
+	Done  bool   `json:"done,omitempty"` // the worker has stopped generating
+}
+
+// Assignment is the coordinator's answer to a heartbeat
+type Assignment struct {
+	Rate     float64       `json:"rate"`      // Events per second this worker should generate
+	StreamID string        `json:"stream_id"` // Stream ID for sequence numbers
+	Args     []string      `json:"args"`      // Extra command line flags for this worker
+	Interval time.Duration `json:"interval"`  // How often to report
+	Epoch    uint64        `json:"epoch"`     // Changes whenever the shares do
+}
+
+// Stats aggregates the reports of all workers
+type Stats struct {
+	Workers int           `json:"workers"` // workers currently generating
+	Logs    int64         `json:"logs"`
+	Bytes   int64         `json:"bytes"`
+	Members []MemberStats `json:"members"`
+}
+
+// MemberStats is the last report of a single worker
+type MemberStats struct {
+	ID       string    `json:"id"`
+	StreamID string    `json:"stream_id"`
+	Logs     int64     `json:"logs"`
+	Bytes    int64     `json:"bytes"`
+	Active   bool      `json:"active"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Config holds the configuration for the coordinator
+type Config struct {
+	Rate     float64       // Total rate shared by all active workers
+	Assign   []string      // Flag sets handed out to joining workers round-robin
+	Interval time.Duration // How often workers report
+}
+
+// Coordinator distributes rate shares and flag assignments to worker
+// instances and aggregates their stats
+type Coordinator struct {
+	config     Config
+	mutex      sync.Mutex
+	members    map[string]*member
+	joined     int
+	lastActive int           // active workers when the epoch last changed
+	epoch      uint64        // counts the changes of the active workers
+	changed    chan struct{} // closed when the epoch changes
+}
+
+// member is a worker known to the coordinator
+type member struct {
+	streamID string
+	args     []string
+	report   Report
+	lastSeen time.Time
+}
+
+// New creates a new coordinator with the given configuration
+func New(config Config) (*Coordinator, error) {
+	if err := rate.Validate(config.Rate); err != nil {
+		return nil, err
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	return &Coordinator{config: config, members: map[string]*member{}, changed: make(chan struct{})}, nil
+}
+
+// Handler returns the HTTP API of the coordinator: workers POST reports to
+// /v1/heartbeat and wait for new shares on GET /v1/assignment, and GET
+// /v1/stats returns the aggregated stats
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/heartbeat", c.handleHeartbeat)
+	mux.HandleFunc("/v1/assignment", c.handleAssignment)
+	mux.HandleFunc("/v1/stats", c.handleStats)
+	return mux
+}
+
+// handleHeartbeat records a worker report and answers with its assignment
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var report Report
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil || report.ID == "" {
+		http.Error(w, "invalid report", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, c.Heartbeat(report))
+}
+
+// handleAssignment answers a worker with its assignment once the epoch it
+// knows is outdated, or after an interval
+func (c *Coordinator) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	epoch, err := strconv.ParseUint(r.URL.Query().Get("epoch"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid epoch", http.StatusBadRequest)
+		return
+	}
+	assignment, ok := c.Watch(r.Context(), r.URL.Query().Get("id"), epoch)
+	if !ok {
+		http.Error(w, "unknown worker", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, assignment)
+}
+
+// handleStats returns the aggregated stats
+func (c *Coordinator) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.Stats())
+}
+
+// Heartbeat records a worker report and returns the worker's assignment.
+// Workers join on their first report.
+func (c *Coordinator) Heartbeat(report Report) Assignment {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	m, ok := c.members[report.ID]
+	if !ok {
+		m = &member{streamID: fmt.Sprintf("worker-%d", c.joined+1)}
+		if len(c.config.Assign) > 0 {
+			m.args = strings.Fields(c.config.Assign[c.joined%len(c.config.Assign)])
+		}
+		c.members[report.ID] = m
+		c.joined++
+	}
+	m.report = report
+	m.lastSeen = now
+	c.observe(now)
+	return c.assignment(m, now)
+}
+
+// Watch returns the assignment of a worker once the shares changed since
+// epoch, or after an interval if they did not, so workers take their new
+// share as soon as others join or leave rather than on their next
+// heartbeat. It reports false for workers that never reported.
+func (c *Coordinator) Watch(ctx context.Context, id string, epoch uint64) (Assignment, bool) {
+	timeout := time.NewTimer(c.config.Interval)
+	defer timeout.Stop()
+	for waited := false; ; {
+		c.mutex.Lock()
+		now := time.Now()
+		c.observe(now)
+		m, ok := c.members[id]
+		if !ok || c.epoch != epoch || waited {
+			var a Assignment
+			if ok {
+				a = c.assignment(m, now)
+			}
+			c.mutex.Unlock()
+			return a, ok
+		}
+		changed := c.changed
+		// Workers missing reports leave without a request to notice it
+		expiry := c.nextExpiry(now)
+		c.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-expiry:
+		case <-timeout.C:
+			waited = true
+		case <-ctx.Done():
+			return Assignment{}, false
+		}
+	}
+}
+
+// assignment returns the assignment of a worker; callers hold the mutex
+func (c *Coordinator) assignment(m *member, now time.Time) Assignment {
+	share := 0.0
+	if c.isActive(m, now) {
+		share = c.config.Rate / float64(c.active(now))
+	}
+	return Assignment{
+		Rate:     share,
+		StreamID: m.streamID,
+		Args:     m.args,
+		Interval: c.config.Interval,
+		Epoch:    c.epoch,
+	}
+}
+
+// observe starts a new epoch when the number of active workers, and so
+// their shares, changed, waking the workers waiting for it; callers hold
+// the mutex
+func (c *Coordinator) observe(now time.Time) {
+	if n := c.active(now); n != c.lastActive {
+		c.lastActive = n
+		c.epoch++
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}
+}
+
+// nextExpiry returns a channel firing when the next active worker misses
+// its reports, nil if none is active; callers hold the mutex
+func (c *Coordinator) nextExpiry(now time.Time) <-chan time.Time {
+	var next time.Duration = -1
+	for _, m := range c.members {
+		if !c.isActive(m, now) {
+			continue
+		}
+		if d := m.lastSeen.Add(missedReports * c.config.Interval).Sub(now); next < 0 || d < next {
+			next = d
+		}
+	}
+	if next < 0 {
+		return nil
+	}
+	return time.After(next + time.Millisecond)
+}
+
+// Stats returns the stats aggregated from the last report of every worker
+func (c *Coordinator) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.observe(now)
+	stats := Stats{Workers: c.active(now)}
+	for id, m := range c.members {
+		stats.Logs += m.report.Logs
+		stats.Bytes += m.report.Bytes
+		stats.Members = append(stats.Members, MemberStats{
+			ID:       id,
+			StreamID: m.streamID,
+			Logs:     m.report.Logs,
+			Bytes:    m.report.Bytes,
+			Active:   c.isActive(m, now),
+			LastSeen: m.lastSeen,
+		})
+	}
+	sort.Slice(stats.Members, func(i, j int) bool { return stats.Members[i].StreamID < stats.Members[j].StreamID })
+	return stats
+}
+
+// active counts the workers currently generating; callers hold the mutex
+func (c *Coordinator) active(now time.Time) int {
+	n := 0
+	for _, m := range c.members {
+		if c.isActive(m, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// isActive reports whether a worker is generating and reported recently
+func (c *Coordinator) isActive(m *member, now time.Time) bool {
+	return !m.report.Done && now.Sub(m.lastSeen) <= missedReports*c.config.Interval
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
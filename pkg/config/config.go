@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads a YAML config file whose keys are flag names and returns the
+// values as strings flags can be set from. Lists are joined with commas and
+// maps are flattened to comma separated key=value pairs, so
+//
+//	level-weights:
+//	  info: 6
+//	  error: 1
+//
+// is equivalent to --level-weights=error=1,info=6.
+func Load(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses the contents of a config file, see Load
+func Parse(data []byte) (map[string]string, error) {
+	settings, err := ParseSettings(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Name] = setting.Value
+	}
+	return values, nil
+}
+
+// Setting is a config file entry with its position in the file
+type Setting struct {
+	Name   string
+	Value  string
+	Line   int
+	Column int
+}
+
+// LoadSettings reads a config file like Load but keeps the order and
+// positions of the settings, for precise error reporting
+func LoadSettings(path string) ([]Setting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSettings(data)
+}
+
+// ParseSettings parses the contents of a config file, see LoadSettings
+func ParseSettings(data []byte) ([]Setting, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid config: line %d: expected a mapping of settings", root.Line)
+	}
+
+	settings := make([]Setting, 0, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid config: line %d: %w", node.Line, err)
+		}
+		s, err := flatten(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config: line %d: invalid value for %s: %w", node.Line, key.Value, err)
+		}
+		settings = append(settings, Setting{Name: key.Value, Value: s, Line: key.Line, Column: key.Column})
+	}
+	return settings, nil
+}
+
+// flatten converts a YAML value into the string form of a flag value
+func flatten(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := flatten(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			s, err := flatten(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(s, ",") {
+				return "", fmt.Errorf("nested value for %s must not contain commas", key)
+			}
+			pairs = append(pairs, key+"="+s)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
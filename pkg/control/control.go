@@ -0,0 +1,235 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// maxBody bounds the size of a request body
+const maxBody = 1 << 10
+
+// Config holds the configuration for the control API
+type Config struct {
+	Target     *rate.Target                        // Base rate steered by the API
+	Throughput bool                                // The target is a byte rate rather than an event rate
+	Settings   map[string]func(value string) error // Settings that can change while running, by flag name
+	Stats      func() interface{}                  // Point-in-time stats of the generator
+	Runner     Runner                              // Starts and stops generation (nil disables /v1/run)
+	Context    context.Context                     // Runs started through the API end with it (background if nil)
+}
+
+// Runner starts and stops generation, see generator.Runner
+type Runner interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Running() bool
+}
+
+// Server exposes a control API for changing the rate of a running generator
+type Server struct {
+	target     *rate.Target
+	throughput bool
+	settings   map[string]func(value string) error
+	stats      func() interface{}
+	runner     Runner
+	ctx        context.Context
+}
+
+// rateResponse describes the current base rate
+type rateResponse struct {
+	Rate      *float64 `json:"rate,omitempty"` // absent when unlimited
+	Formatted string   `json:"formatted"`
+}
+
+// New creates a new control API with the given configuration
+func New(config Config) *Server {
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Server{
+		target:     config.Target,
+		throughput: config.Throughput,
+		settings:   config.Settings,
+		stats:      config.Stats,
+		runner:     config.Runner,
+		ctx:        ctx,
+	}
+}
+
+// Handler returns the HTTP API: GET /v1/rate returns the base rate, PUT or
+// POST /v1/rate with a rate expression ("500/m", "10k/s", or "5MB/s" in
+// throughput mode) in the body or the rate query parameter changes it.
+// GET /v1/settings lists the settings that can change while running, and
+// PUT or POST /v1/settings with a JSON object of flag names and values
+// changes them. GET /v1/stats returns a stats snapshot. GET /v1/run reports
+// whether generation is running, and POST /v1/run/start, /v1/run/stop and
+// /v1/run/restart start, stop and restart it.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/rate", s.handleRate)
+	mux.HandleFunc("/v1/settings", s.handleSettings)
+	if s.stats != nil {
+		mux.HandleFunc("GET /v1/stats", s.handleStats)
+	}
+	if s.runner != nil {
+		mux.HandleFunc("GET /v1/run", s.handleRun)
+		mux.HandleFunc("POST /v1/run/{action}", s.handleRun)
+	}
+	return mux
+}
+
+// runResponse reports whether generation is running
+type runResponse struct {
+	Running bool `json:"running"`
+}
+
+// handleRun reports whether generation is running, or starts, stops or
+// restarts it. Stopping waits for the run to end, as long as the request
+// lasts.
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if action == "stop" || action == "restart" {
+		if err := s.runner.Stop(r.Context()); err != nil {
+			http.Error(w, "failed to stop: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	switch action {
+	case "", "stop":
+	case "start", "restart":
+		if err := s.runner.Start(s.ctx); err != nil {
+			// Already running
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q (use start, stop or restart)", action), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(runResponse{Running: s.runner.Running()})
+}
+
+// settingsResponse lists the settings that can change while running
+type settingsResponse struct {
+	Settings []string `json:"settings"`
+}
+
+// handleSettings lists or changes settings. Changes are applied in name
+// order and stop at the first invalid one.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		// Keep numbers as written, e.g. 1000000 rather than 1e+06
+		decoder := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		decoder.UseNumber()
+		var values map[string]interface{}
+		if err := decoder.Decode(&values); err != nil {
+			http.Error(w, "expected a JSON object of settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			if _, ok := s.settings[name]; !ok {
+				http.Error(w, fmt.Sprintf("setting %q cannot change while running", name), http.StatusBadRequest)
+				return
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := s.settings[name](fmt.Sprint(values[name])); err != nil {
+				http.Error(w, fmt.Sprintf("invalid value for %s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := settingsResponse{Settings: make([]string, 0, len(s.settings))}
+	for name := range s.settings {
+		response.Settings = append(response.Settings, name)
+	}
+	sort.Strings(response.Settings)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleStats returns a stats snapshot
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.stats())
+}
+
+// handleRate reads or changes the base rate
+func (s *Server) handleRate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		expr := r.URL.Query().Get("rate")
+		if expr == "" {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			expr = strings.TrimSpace(string(body))
+		}
+
+		value, err := s.parse(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.target.Set(value)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.current())
+}
+
+// parse parses and validates a rate expression
+func (s *Server) parse(expr string) (float64, error) {
+	if s.throughput {
+		value, err := rate.ParseBytes(expr)
+		if err != nil {
+			return 0, err
+		}
+		return value, rate.ValidateBytes(value)
+	}
+
+	value, err := rate.Parse(expr)
+	if err != nil {
+		return 0, err
+	}
+	return value, rate.Validate(value)
+}
+
+// current describes the current base rate
+func (s *Server) current() rateResponse {
+	value := s.target.Get()
+	formatted := rate.Format(value)
+	if s.throughput {
+		formatted = rate.FormatBytes(value)
+	}
+	response := rateResponse{Formatted: formatted}
+	if value != rate.Unlimited {
+		response.Rate = &value
+	}
+	return response
+}
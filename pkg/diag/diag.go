@@ -0,0 +1,88 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Formats of diagnostics
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Diagnostics are log-genie's own structured health messages. They go to
+// stderr so that stdout carries only the generated logs.
+var (
+	level  = new(slog.LevelVar)
+	logger atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	logger.Store(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
+// Configure changes where diagnostics are written and their format: text
+// (key=value pairs) or json (one object per line)
+func Configure(w io.Writer, format string) error {
+	options := Options()
+	switch strings.ToLower(format) {
+	case FormatText:
+		logger.Store(slog.New(slog.NewTextHandler(w, options)))
+	case FormatJSON:
+		logger.Store(slog.New(slog.NewJSONHandler(w, options)))
+	default:
+		return fmt.Errorf("unknown format %q: use text or json", format)
+	}
+	return nil
+}
+
+// Use hands diagnostics to a handler of their own, e.g. one writing to the
+// system's event log, created with Options to keep the level set with
+// SetLevel
+func Use(handler slog.Handler) {
+	logger.Store(slog.New(handler))
+}
+
+// Options returns the options diagnostics are handled with
+func Options() *slog.HandlerOptions {
+	return &slog.HandlerOptions{Level: level}
+}
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown level %q: use debug, info, warn or error", name)
+	}
+	return l, nil
+}
+
+// SetLevel changes the minimum level of diagnostics written
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Debug writes a diagnostic only useful when troubleshooting log-genie
+func Debug(msg string, args ...interface{}) {
+	logger.Load().Debug(msg, args...)
+}
+
+// Info writes a status message
+func Info(msg string, args ...interface{}) {
+	logger.Load().Info(msg, args...)
+}
+
+// Warn writes a message about a problem log-genie recovers from
+func Warn(msg string, args ...interface{}) {
+	logger.Load().Warn(msg, args...)
+}
+
+// Error writes a message about a problem log-genie cannot recover from
+func Error(msg string, args ...interface{}) {
+	logger.Load().Error(msg, args...)
+}
@@ -0,0 +1,264 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// defaultTimeout bounds a single request to a peer
+const defaultTimeout = 5 * time.Second
+
+// Config holds the configuration for the fleet client
+type Config struct {
+	Peers   []string      // Control API addresses of the peers, host:port or URL
+	DNS     string        // Name to discover peers from: host:port looks up addresses, a bare name looks up SRV records
+	Timeout time.Duration // Bound on a single request to a peer
+}
+
+// Client drives the control APIs of a set of log-genie instances
+type Client struct {
+	peers []string
+	http  *http.Client
+}
+
+// New creates a fleet client, discovering the peers named by DNS in
+// addition to the static ones
+func New(ctx context.Context, config Config) (*Client, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	peers := append([]string(nil), config.Peers...)
+	if config.DNS != "" {
+		discovered, err := discover(ctx, config.DNS)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, discovered...)
+	}
+
+	seen := map[string]bool{}
+	c := &Client{http: &http.Client{Timeout: config.Timeout}}
+	for _, peer := range peers {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+		if !strings.Contains(peer, "://") {
+			peer = "http://" + peer
+		}
+		peer = strings.TrimSuffix(peer, "/")
+		if !seen[peer] {
+			seen[peer] = true
+			c.peers = append(c.peers, peer)
+		}
+	}
+	if len(c.peers) == 0 {
+		return nil, fmt.Errorf("no peers: give --peers or --dns")
+	}
+	sort.Strings(c.peers)
+	return c, nil
+}
+
+// discover resolves a DNS name to peer addresses
+func discover(ctx context.Context, name string) ([]string, error) {
+	var resolver net.Resolver
+	if host, port, err := net.SplitHostPort(name); err == nil {
+		addrs, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover peers: %w", err)
+		}
+		peers := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			peers = append(peers, net.JoinHostPort(addr, port))
+		}
+		return peers, nil
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover peers: %w", err)
+	}
+	peers := make([]string, 0, len(records))
+	for _, srv := range records {
+		peers = append(peers, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+	}
+	return peers, nil
+}
+
+// Peers returns the base URLs of the peers
+func (c *Client) Peers() []string {
+	return c.peers
+}
+
+// Result is the outcome of a request to one peer
+type Result struct {
+	Peer  string `json:"peer"`
+	Error string `json:"error,omitempty"`
+}
+
+// Rate is the base rate of one peer
+type Rate struct {
+	Result
+	Rate      *float64 `json:"rate,omitempty"` // absent when unlimited or unknown
+	Formatted string   `json:"formatted,omitempty"`
+}
+
+// Rates returns the base rate of every peer
+func (c *Client) Rates(ctx context.Context) []Rate {
+	rates := make([]Rate, len(c.peers))
+	c.fanOut(func(i int, peer string) {
+		rates[i].Peer = peer
+		if err := c.do(ctx, http.MethodGet, peer+"/v1/rate", nil, &rates[i]); err != nil {
+			rates[i].Error = err.Error()
+		}
+	})
+	return rates
+}
+
+// SetRate changes the base rate of every peer, to the rate expression
+// returned for its index
+func (c *Client) SetRate(ctx context.Context, expr func(i int) string) []Rate {
+	rates := make([]Rate, len(c.peers))
+	c.fanOut(func(i int, peer string) {
+		rates[i].Peer = peer
+		body := strings.NewReader(expr(i))
+		if err := c.do(ctx, http.MethodPut, peer+"/v1/rate", body, &rates[i]); err != nil {
+			rates[i].Error = err.Error()
+		}
+	})
+	return rates
+}
+
+// Apply changes settings that can change while running (e.g. error-ratio,
+// level-weights or attributes) on every peer
+func (c *Client) Apply(ctx context.Context, settings map[string]string) []Result {
+	body, _ := json.Marshal(settings)
+	results := make([]Result, len(c.peers))
+	c.fanOut(func(i int, peer string) {
+		results[i].Peer = peer
+		if err := c.do(ctx, http.MethodPut, peer+"/v1/settings", bytes.NewReader(body), nil); err != nil {
+			results[i].Error = err.Error()
+		}
+	})
+	return results
+}
+
+// PeerStats is the stats snapshot of one peer
+type PeerStats struct {
+	Result
+	Logs        int64             `json:"logs"`
+	Bytes       int64             `json:"bytes"`
+	LogsPerSec  float64           `json:"logs_per_sec"`
+	BytesPerSec float64           `json:"bytes_per_sec"`
+	QueueDepth  int64             `json:"queue_depth"`
+	Rate        string            `json:"rate,omitempty"`
+	Paused      bool              `json:"paused"`
+	Sinks       []stats.SinkStats `json:"sinks,omitempty"`
+}
+
+// Totals aggregates the stats of the peers that answered
+type Totals struct {
+	Peers       int               `json:"peers"`
+	Reachable   int               `json:"reachable"`
+	Paused      int               `json:"paused"`
+	Logs        int64             `json:"logs"`
+	Bytes       int64             `json:"bytes"`
+	LogsPerSec  float64           `json:"logs_per_sec"`
+	BytesPerSec float64           `json:"bytes_per_sec"`
+	QueueDepth  int64             `json:"queue_depth"`
+	Sinks       []stats.SinkStats `json:"sinks"`
+}
+
+// Stats returns the stats of every peer and their totals
+func (c *Client) Stats(ctx context.Context) ([]PeerStats, Totals) {
+	peers := make([]PeerStats, len(c.peers))
+	c.fanOut(func(i int, peer string) {
+		peers[i].Peer = peer
+		if err := c.do(ctx, http.MethodGet, peer+"/v1/stats", nil, &peers[i]); err != nil {
+			peers[i].Error = err.Error()
+		}
+	})
+
+	totals := Totals{Peers: len(peers), Sinks: []stats.SinkStats{}}
+	sinks := map[string]int{}
+	for _, p := range peers {
+		if p.Error != "" {
+			continue
+		}
+		totals.Reachable++
+		if p.Paused {
+			totals.Paused++
+		}
+		totals.Logs += p.Logs
+		totals.Bytes += p.Bytes
+		totals.LogsPerSec += p.LogsPerSec
+		totals.BytesPerSec += p.BytesPerSec
+		totals.QueueDepth += p.QueueDepth
+		for _, sink := range p.Sinks {
+			i, ok := sinks[sink.Name]
+			if !ok {
+				i = len(totals.Sinks)
+				sinks[sink.Name] = i
+				totals.Sinks = append(totals.Sinks, stats.SinkStats{Name: sink.Name})
+			}
+			totals.Sinks[i].Sent += sink.Sent
+			totals.Sinks[i].Failed += sink.Failed
+			totals.Sinks[i].Dropped += sink.Dropped
+			totals.Sinks[i].Failing = totals.Sinks[i].Failing || sink.Failing
+		}
+	}
+	return peers, totals
+}
+
+// fanOut calls f for every peer concurrently and waits for all of them
+func (c *Client) fanOut(f func(i int, peer string)) {
+	var wg sync.WaitGroup
+	for i, peer := range c.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i, peer)
+		}()
+	}
+	wg.Wait()
+}
+
+// do sends a request to a peer and decodes the JSON response into out,
+// unless out is nil
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
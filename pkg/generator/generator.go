@@ -0,0 +1,476 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rjonczy/log-genie/pkg/catalog"
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/processor"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/replay"
+	"github.com/rjonczy/log-genie/pkg/schedule"
+)
+
+// heldBack is how long a gate must block for generation to count as held
+// back rather than merely stalled
+const heldBack = 10 * time.Millisecond
+
+// Generator emits logs paced by a rate, or replays a file, to the sinks of
+// its logger and pipeline
+type Generator struct {
+	log        *logger.Logger
+	pipeline   *Pipeline
+	target     *ratelimit.Target
+	pool       *ratelimit.Pool
+	mux        *ratelimit.Mux // nil unless generating streams
+	streams    []*stream
+	profile    ratelimit.Chain
+	adaptive   *ratelimit.Backpressure // nil unless adapting to backpressure
+	loadFile   *ratelimit.FileProfile  // nil unless a load profile sets error ratios
+	arrival    ratelimit.Arrival
+	throughput bool
+	schedule   *schedule.Schedule
+	gates      []Gate
+	processors processor.Chain
+	player     *replay.Player
+	rewrite    bool
+	startDelay time.Duration
+	stagger    time.Duration
+	errorRatio atomic.Uint64 // bits of a float64
+	paid       atomic.Int64  // bytes paid for in throughput mode
+	sinkFailed sync.Once     // reports the first log a sink refused
+	shutdown   sync.Once
+	start      time.Time
+}
+
+// stream is a stream of the generator with the pool pacing its workers
+type stream struct {
+	pool       *ratelimit.Pool
+	errorRatio float64 // negative for the generator's
+	variant    *logger.Variant
+}
+
+// New creates a generator with the given options. Like logger.New, if the
+// telemetry provider fails to start it returns the generator, falling back
+// to local logs, along with the error.
+func New(options ...Option) (*Generator, error) {
+	s := settings{rate: DefaultRate, workers: 1, maxLag: DefaultMaxLag, errorRatio: DefaultErrorRatio}
+	for _, option := range options {
+		option(&s)
+	}
+
+	if s.workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d: must be at least 1", s.workers)
+	}
+	if s.errorRatio < 0 || s.errorRatio > 1 {
+		return nil, fmt.Errorf("invalid error ratio %g: must be between 0 and 1", s.errorRatio)
+	}
+	if s.maxLag < 0 {
+		return nil, fmt.Errorf("invalid maximum lag %s: must not be negative", s.maxLag)
+	}
+	if s.startDelay < 0 || s.stagger < 0 {
+		return nil, fmt.Errorf("invalid start: delay and stagger must not be negative")
+	}
+	if s.count < 0 {
+		return nil, fmt.Errorf("invalid count %d: must not be negative", s.count)
+	}
+	if len(s.profile) > 0 && s.throughput == 0 && s.rate == ratelimit.Unlimited {
+		return nil, fmt.Errorf("invalid rate: max cannot be combined with rate profiles")
+	}
+	if s.adapt && s.throughput == 0 && s.rate == ratelimit.Unlimited {
+		return nil, fmt.Errorf("invalid rate: max cannot adapt to backpressure")
+	}
+	if len(s.streams) > 0 {
+		switch {
+		case s.throughput > 0:
+			return nil, fmt.Errorf("invalid streams: cannot be combined with throughput")
+		case s.player != nil:
+			return nil, fmt.Errorf("invalid streams: cannot be combined with a replay")
+		case s.config.Pregenerate > 0:
+			return nil, fmt.Errorf("invalid streams: cannot be combined with pregenerated logs")
+		case s.rate == ratelimit.Unlimited:
+			return nil, fmt.Errorf("invalid rate: max cannot be combined with streams")
+		}
+	}
+	for _, fake := range s.fakes {
+		if err := catalog.Register(fake.name, fake.generate); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pace by events or by bytes, giving every worker an equal share
+	var limiter *ratelimit.Limiter
+	var err error
+	base := s.rate
+	if s.throughput > 0 {
+		base = s.throughput
+		limiter, err = ratelimit.NewByteLimiter(s.throughput)
+	} else {
+		limiter, err = ratelimit.NewLimiter(s.rate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	limiter.SetCatchUp(s.maxLag)
+	if s.arrival == nil {
+		s.arrival, _ = ratelimit.NewArrival(ratelimit.ArrivalFixed, 0)
+	}
+
+	g := &Generator{
+		target:     ratelimit.NewTarget(base),
+		pool:       limiter.Split(s.workers),
+		profile:    s.profile,
+		arrival:    s.arrival,
+		throughput: s.throughput > 0,
+		schedule:   s.schedule,
+		gates:      s.gates,
+		processors: s.processors,
+		player:     s.player,
+		rewrite:    s.rewrite,
+		startDelay: s.startDelay,
+		stagger:    s.stagger,
+		start:      time.Now(),
+	}
+	g.SetErrorRatio(s.errorRatio)
+	if len(s.streams) > 0 {
+		g.mux = ratelimit.NewMux(s.workers, s.maxLag)
+		for _, st := range s.streams {
+			if st.ErrorRatio > 1 {
+				return nil, fmt.Errorf("invalid error ratio %g of stream %s: must be between 0 and 1", st.ErrorRatio, st.Name)
+			}
+			var profile ratelimit.Profile
+			if len(st.Profile) > 0 {
+				profile = st.Profile
+			}
+			pool, err := g.mux.Add(st.Rate, profile, st.StartDelay)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate of stream %s: %w", st.Name, err)
+			}
+			g.streams = append(g.streams, &stream{
+				pool:       pool,
+				errorRatio: st.ErrorRatio,
+				variant:    &logger.Variant{LevelWeights: st.LevelWeights, Attributes: st.Attributes, Location: st.Location},
+			})
+		}
+	}
+	if s.adapt {
+		// The sinks count into the logger's registry
+		g.adaptive = &ratelimit.Backpressure{Signal: func() float64 { return g.log.Stats().Pressure() }}
+		g.profile = append(g.profile[:len(g.profile):len(g.profile)], g.adaptive)
+	}
+	for _, p := range s.profile {
+		if file, ok := p.(*ratelimit.FileProfile); ok {
+			g.loadFile = file
+		}
+	}
+
+	config := s.config
+	config.Rate = s.rate
+	if s.count > 0 {
+		config.Limit = s.count
+	}
+	if len(s.sinks) > 0 {
+		config.Emit = g.emit
+	}
+	if len(s.transforms) > 0 {
+		config.Transforms = s.transforms
+	}
+	config.Transforms = s.routes.Apply(config.Transforms)
+	if len(s.processors) > 0 {
+		config.Process = s.processors.Process
+	}
+	if len(s.messages) > 0 {
+		messages := make(map[logger.LogLevel][]string, len(config.Messages)+len(s.messages))
+		for level, templates := range config.Messages {
+			messages[level] = templates
+		}
+		for level, templates := range s.messages {
+			messages[level] = append(append([]string(nil), messages[level]...), templates...)
+		}
+		config.Messages = messages
+	}
+	if s.source != nil {
+		config.Source = func(errorLog bool) (time.Time, logger.LogLevel, string, map[string]interface{}) {
+			e := s.source(errorLog)
+			return e.Time, e.Level, e.Message, e.Fields
+		}
+	}
+	log, err := logger.New(config)
+	if log == nil {
+		return nil, err
+	}
+	g.log = log
+	g.pipeline = NewPipeline(log.Stats())
+	g.pipeline.SetTransforms(config.Transforms)
+	for _, sink := range s.sinks {
+		g.pipeline.Add(sink.name, sink.sink)
+	}
+	return g, err
+}
+
+// emit hands a log the logger emitted to the pipeline, reporting the first
+// one refused
+func (g *Generator) emit(timestamp time.Time, level logger.LogLevel, message string, fields map[string]interface{}) {
+	if err := g.pipeline.Write(Event{Time: timestamp, Level: level, Message: message, Fields: fields}); err != nil {
+		g.sinkFailed.Do(func() {
+			diag.Warn("Failed to send log to sink", "error", err)
+		})
+	}
+}
+
+// Logger returns the logger generating the logs, which holds their counts
+func (g *Generator) Logger() *logger.Logger {
+	return g.log
+}
+
+// Target returns the base rate, which may be changed while running
+func (g *Generator) Target() *ratelimit.Target {
+	return g.target
+}
+
+// Backpressure returns the profile slowing the rate down while sinks push
+// back, nil if the rate is held
+func (g *Generator) Backpressure() *ratelimit.Backpressure {
+	return g.adaptive
+}
+
+// Progress compares the logs the rate called for so far with those
+// generated and skipped (bytes when pacing by throughput). Only levels the
+// verbosity lets through are generated, so the logs taken are those emitted.
+func (g *Generator) Progress() ratelimit.Progress {
+	if g.mux != nil {
+		return g.mux.Progress()
+	}
+	return g.pool.Progress()
+}
+
+// ErrorRatio returns the share of logs generated as dedicated error logs
+func (g *Generator) ErrorRatio() float64 {
+	return math.Float64frombits(g.errorRatio.Load())
+}
+
+// SetErrorRatio changes the share of logs generated as dedicated error logs
+func (g *Generator) SetErrorRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("must be between 0 and 1")
+	}
+	g.errorRatio.Store(math.Float64bits(ratio))
+	return nil
+}
+
+// Shutdown drains the sinks of the logger (see logger.Logger.Shutdown) and
+// closes those of the pipeline, then the processors implementing io.Closer;
+// later calls do nothing. Stop Run first, so no log races the drain.
+func (g *Generator) Shutdown() {
+	g.shutdown.Do(func() {
+		g.log.Shutdown()
+		if err := g.pipeline.Close(); err != nil {
+			diag.Warn("Failed to close sink", "error", err)
+		}
+		for _, p := range g.processors {
+			if closer, ok := p.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					diag.Warn("Failed to close processor", "error", err)
+				}
+			}
+		}
+	})
+}
+
+// Flush writes out the logs buffered by the local output and by the sinks
+// of the pipeline implementing Flusher, e.g. before pausing
+func (g *Generator) Flush() error {
+	return errors.Join(g.log.Flush(), g.pipeline.Flush())
+}
+
+// Generate emits one log right away, an error log as often as the error
+// ratio says
+func (g *Generator) Generate() {
+	g.generate(nil)
+}
+
+// generate emits one log of a stream, or of none if nil
+func (g *Generator) generate(s *stream) {
+	ratio := g.ErrorRatio()
+	if g.loadFile != nil {
+		if r, ok := g.loadFile.ErrorRatio(time.Since(g.start)); ok {
+			ratio = r
+		}
+	}
+	var variant *logger.Variant
+	if s != nil {
+		variant = s.variant
+		if s.errorRatio >= 0 {
+			ratio = s.errorRatio
+		}
+	}
+	if gofakeit.Float64Range(0, 1) < ratio {
+		g.log.GenerateVariantErrorLog(variant)
+	} else {
+		g.log.GenerateVariantLog(variant)
+	}
+}
+
+// Run generates logs until ctx is done, the count is reached or the replay
+// ends. Profiles and changes of the target steer the rate while it runs,
+// timed from the end of the start delay.
+func (g *Generator) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if !sleep(ctx, g.startDelay) {
+		return nil
+	}
+	g.start = time.Now()
+
+	if g.player != nil {
+		return g.player.Run(ctx, func() bool { return g.wait(ctx, nil) && !g.log.Exhausted() }, func(r replay.Record) {
+			if g.rewrite {
+				r.Time = time.Time{}
+			}
+			g.log.Replay(r.Time, r.Level, r.Message, r.Fields)
+		})
+	}
+
+	var wg sync.WaitGroup
+	if g.mux != nil {
+		// Every stream is paced by workers of its own
+		g.mux.Start()
+		go ratelimit.Apply(ctx, g.mux, g.target, g.profile)
+		for _, s := range g.streams {
+			for i := 0; i < s.pool.Workers(); i++ {
+				wg.Add(1)
+				go func(limiter *ratelimit.Limiter, s *stream) {
+					defer wg.Done()
+					g.work(ctx, limiter, s)
+				}(s.pool.Worker(i), s)
+			}
+		}
+		wg.Wait()
+		return nil
+	}
+
+	go ratelimit.Apply(ctx, g.pool, g.target, g.profile)
+	for i := 0; i < g.pool.Workers(); i++ {
+		wg.Add(1)
+		go func(limiter *ratelimit.Limiter) {
+			defer wg.Done()
+			g.work(ctx, limiter, nil)
+		}(g.pool.Worker(i))
+	}
+	wg.Wait()
+	return nil
+}
+
+// work generates logs of a stream, or of none if nil, paced by a worker's
+// share of the rate
+func (g *Generator) work(ctx context.Context, limiter *ratelimit.Limiter, s *stream) {
+	// Workers start spread over the stagger, so they do not emit in lockstep,
+	// and do not catch up on the logs due while they waited
+	if g.stagger > 0 && !sleep(ctx, time.Duration(gofakeit.Float64Range(0, 1)*float64(g.stagger))) {
+		return
+	}
+	if g.startDelay > 0 || g.stagger > 0 {
+		limiter.Resync()
+	}
+
+	if g.throughput {
+		// Pay for the logs' bytes after emitting them
+		for g.wait(ctx, limiter) && !g.log.Exhausted() {
+			g.Generate()
+			if limiter.WaitN(ctx, float64(g.unpaid())*g.arrival()) != nil {
+				return
+			}
+		}
+		return
+	}
+
+	for g.wait(ctx, limiter) && !g.log.Exhausted() && limiter.WaitN(ctx, g.arrival()) == nil {
+		// Generation may have been held back while waiting for the limiter
+		if !g.hold(ctx, limiter) {
+			return
+		}
+		g.generate(s)
+	}
+}
+
+// wait blocks outside the scheduled generation windows and while a gate
+// holds generation back, reporting false once ctx is done. The limiter does
+// not catch up on the logs due while blocked.
+func (g *Generator) wait(ctx context.Context, limiter *ratelimit.Limiter) bool {
+	if !g.hold(ctx, limiter) {
+		return false
+	}
+	if g.schedule == nil {
+		return ctx.Err() == nil
+	}
+	waited := false
+	for now := time.Now(); !g.schedule.Active(now); now = time.Now() {
+		next := g.schedule.Next(now)
+		if next.IsZero() {
+			next = now.Add(time.Hour)
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return false
+		}
+		waited = true
+	}
+	if waited && limiter != nil {
+		limiter.Resync()
+	}
+	return ctx.Err() == nil
+}
+
+// hold blocks while a gate holds generation back, resyncing the limiter if
+// it did, so resuming does not catch up on the logs due meanwhile
+func (g *Generator) hold(ctx context.Context, limiter *ratelimit.Limiter) bool {
+	start := time.Now()
+	for _, gate := range g.gates {
+		if !gate(ctx) {
+			return false
+		}
+	}
+	if limiter != nil && time.Since(start) > heldBack {
+		limiter.Resync()
+	}
+	return true
+}
+
+// unpaid returns the bytes emitted by all workers that no worker has paid
+// for yet, claiming them for the caller
+func (g *Generator) unpaid() int64 {
+	for {
+		before, emitted := g.paid.Load(), g.log.BytesEmitted()
+		if emitted <= before {
+			return 0
+		}
+		if g.paid.CompareAndSwap(before, emitted) {
+			return emitted - before
+		}
+	}
+}
+
+// sleep waits for d, reporting false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// run generates for a fixed window and returns the generator once it stopped
+func run(t *testing.T, window time.Duration, options ...Option) *Generator {
+	t.Helper()
+	g, err := New(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g.Shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+	if err := g.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestRateCountsEmittedLogs(t *testing.T) {
+	const rate = 1000
+	g := run(t, 300*time.Millisecond,
+		WithConfig(logger.Config{Verbosity: "info", LocalLogEnabled: true, Output: io.Discard}),
+		WithRate(rate),
+		WithErrorRatio(0),
+	)
+
+	// Every log the rate let through is emitted, none dropped below the
+	// verbosity; a run cut short may hold one token it had no time to use
+	emitted, taken := g.Logger().LogsEmitted(), g.Progress().Taken
+	if emitted == 0 || math.Abs(taken-float64(emitted)) > 1 {
+		t.Errorf("emitted %d logs for %.0f taken at %d/s", emitted, taken, rate)
+	}
+	if debug := g.Logger().LevelsEmitted()[logger.Debug]; debug != 0 {
+		t.Errorf("emitted %d debug logs at info verbosity", debug)
+	}
+}
+
+func TestProgressCountsLogsPassingVerbosity(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logger.Config
+	}{
+		{"generated", logger.Config{Verbosity: "warn", LocalLogEnabled: true, Output: io.Discard}},
+		{"pregenerated", logger.Config{Verbosity: "warn", LocalLogEnabled: true, Output: io.Discard, Pregenerate: 100}},
+		{"weighted", logger.Config{Verbosity: "warn", LocalLogEnabled: true, Output: io.Discard,
+			LevelWeights: map[logger.LogLevel]float64{logger.Debug: 5, logger.Info: 5, logger.Warn: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := run(t, 200*time.Millisecond, WithConfig(tt.config), WithRate(1000), WithErrorRatio(0.1))
+
+			// The logs taken are those emitted, so a shortfall shows as behind
+			progress, emitted := g.Progress(), g.Logger().LogsEmitted()
+			if emitted == 0 || math.Abs(progress.Taken-float64(emitted)) > 1 {
+				t.Errorf("progress %+v for %d logs emitted", progress, emitted)
+			}
+			levels := g.Logger().LevelsEmitted()
+			if levels[logger.Debug]+levels[logger.Info] != 0 {
+				t.Errorf("emitted %v at warn verbosity", levels)
+			}
+		})
+	}
+}
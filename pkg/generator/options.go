@@ -0,0 +1,273 @@
+package generator
+
+import (
+	"context"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/processor"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/replay"
+	"github.com/rjonczy/log-genie/pkg/schedule"
+)
+
+// Defaults of a generator created without options
+const (
+	DefaultRate       = 10
+	DefaultErrorRatio = 0.05
+	DefaultMaxLag     = time.Second
+)
+
+// Gate blocks while generation is held back, e.g. paused, reporting false
+// if ctx is done first
+type Gate func(ctx context.Context) bool
+
+// Option configures a generator
+type Option func(*settings)
+
+// settings collects the options of a generator
+type settings struct {
+	config     logger.Config
+	rate       float64
+	throughput float64 // bytes per second, 0 to pace by events
+	workers    int
+	maxLag     time.Duration
+	arrival    ratelimit.Arrival
+	errorRatio float64
+	count      int64
+	profile    ratelimit.Chain
+	adapt      bool
+	schedule   *schedule.Schedule
+	gates      []Gate
+	sinks      []namedSink
+	source     func(errorLog bool) Event
+	transforms logger.Transforms
+	processors processor.Chain
+	routes     processor.Routes
+	fakes      []namedFake
+	messages   map[logger.LogLevel][]string
+	player     *replay.Player
+	rewrite    bool
+	streams    []Stream
+	startDelay time.Duration
+	stagger    time.Duration
+}
+
+// namedSink is a sink with the name its deliveries are counted under
+type namedSink struct {
+	name string
+	sink Sink
+}
+
+// Stream is a part of the logs paced independently of the others, e.g. a
+// chatty debug service next to a quiet, error-prone one
+type Stream struct {
+	Name         string
+	Rate         float64                     // logs per second, its share of the generator's rate
+	ErrorRatio   float64                     // share of error logs, negative for the generator's
+	LevelWeights map[logger.LogLevel]float64 // relative weights of its levels, nil for the generator's
+	Attributes   map[string]string           // added to its logs, taking precedence over the generator's
+	Profile      ratelimit.Chain             // modulates its rate, e.g. with bursts, timed from its start
+	StartDelay   time.Duration               // before it starts generating
+	Location     *time.Location              // of its timestamps, nil for the generator's
+}
+
+// namedFake is a fake-data function with the placeholder it is registered as
+type namedFake struct {
+	name     string
+	generate func() string
+}
+
+// WithConfig sets what is generated and where it goes besides the sinks:
+// messages, fields, timestamps, local logs and telemetry. Without it logs
+// are written to stdout as JSON.
+func WithConfig(config logger.Config) Option {
+	return func(s *settings) {
+		s.config = config
+	}
+}
+
+// WithRate paces the generator at rate logs per second, or as fast as
+// possible with ratelimit.Unlimited
+func WithRate(rate float64) Option {
+	return func(s *settings) {
+		s.rate = rate
+	}
+}
+
+// WithThroughput paces the generator by the bytes emitted instead of the
+// number of logs
+func WithThroughput(bytesPerSecond float64) Option {
+	return func(s *settings) {
+		s.throughput = bytesPerSecond
+	}
+}
+
+// WithWorkers generates on n goroutines, each pacing an equal share of the
+// rate
+func WithWorkers(n int) Option {
+	return func(s *settings) {
+		s.workers = n
+	}
+}
+
+// WithCatchUp lets generation that fell behind the rate, e.g. stalled by a
+// slow sink, catch up on up to lag worth of logs it missed by emitting them
+// without pacing; the ones beyond are skipped. With 0 it skips them all.
+func WithCatchUp(lag time.Duration) Option {
+	return func(s *settings) {
+		s.maxLag = lag
+	}
+}
+
+// WithStart delays generation by delay once the generator runs, and every
+// worker, of each stream, by a random share of stagger on top, so instances
+// and workers started together do not emit in lockstep
+func WithStart(delay, stagger time.Duration) Option {
+	return func(s *settings) {
+		s.startDelay, s.stagger = delay, stagger
+	}
+}
+
+// WithArrival shapes the inter-arrival times of logs (fixed by default)
+func WithArrival(arrival ratelimit.Arrival) Option {
+	return func(s *settings) {
+		s.arrival = arrival
+	}
+}
+
+// WithStreams splits generation into streams, each paced by workers of its
+// own. A stream takes a share of the rate in proportion to its own rate, so
+// with the sum of their rates each generates at its own; profiles and
+// changes of the rate scale all of them. Streams cannot be combined with
+// throughput, replays or pregenerated logs.
+func WithStreams(streams ...Stream) Option {
+	return func(s *settings) {
+		s.streams = append(s.streams, streams...)
+	}
+}
+
+// WithErrorRatio sets the share of logs generated as dedicated error logs
+func WithErrorRatio(ratio float64) Option {
+	return func(s *settings) {
+		s.errorRatio = ratio
+	}
+}
+
+// WithCount stops the generator after exactly n logs
+func WithCount(n int64) Option {
+	return func(s *settings) {
+		s.count = n
+	}
+}
+
+// WithProfile modulates the rate over time with the profiles, in order. A
+// load profile file also sets the error ratio where it gives one.
+func WithProfile(profiles ...ratelimit.Profile) Option {
+	return func(s *settings) {
+		s.profile = append(s.profile, profiles...)
+	}
+}
+
+// WithBackpressure sets what generation does when sinks cannot keep up:
+// ratelimit.BackpressureHold keeps the rate, the sinks dropping what they
+// cannot take, and ratelimit.BackpressureAdapt slows the rate down until
+// they keep up, like a profile applied after the others
+func WithBackpressure(policy string) Option {
+	return func(s *settings) {
+		s.adapt = policy == ratelimit.BackpressureAdapt
+	}
+}
+
+// WithSchedule generates only within the active windows of the schedule
+func WithSchedule(windows *schedule.Schedule) Option {
+	return func(s *settings) {
+		s.schedule = windows
+	}
+}
+
+// WithGate holds generation back while gate blocks
+func WithGate(gate Gate) Option {
+	return func(s *settings) {
+		s.gates = append(s.gates, gate)
+	}
+}
+
+// WithSink sends every log to sink, counting its deliveries under name. With
+// sinks, logs are written locally only if the config asks for local logs.
+func WithSink(name string, sink Sink) Option {
+	return func(s *settings) {
+		s.sinks = append(s.sinks, namedSink{name: name, sink: sink})
+	}
+}
+
+// WithSource generates logs with source instead of the built-in generators.
+// errorLog asks for a dedicated error log; a zero time is replaced by the
+// current time.
+func WithSource(source func(errorLog bool) Event) Option {
+	return func(s *settings) {
+		s.source = source
+	}
+}
+
+// WithTransform rewrites logs before the named sink takes them, the sinks of
+// the pipeline as well as the local output and telemetry (named as in the
+// stats, e.g. stdout and otlp). logger.AllSinks names every sink without a
+// transform of its own.
+func WithTransform(sink string, transform logger.Transform) Option {
+	return func(s *settings) {
+		if s.transforms == nil {
+			s.transforms = logger.Transforms{}
+		}
+		s.transforms[sink] = transform
+	}
+}
+
+// WithProcessor runs processors on every log, in order, between generation
+// and all sinks: they may redact, enrich or drop it, e.g. by sampling.
+// Processors implementing io.Closer are closed when the generator shuts down.
+func WithProcessor(processors ...processor.Processor) Option {
+	return func(s *settings) {
+		s.processors = append(s.processors, processors...)
+	}
+}
+
+// WithRoute sends the logs of level only to the named sinks, the sinks of
+// the pipeline as well as the local output and telemetry
+func WithRoute(level logger.LogLevel, sinks ...string) Option {
+	return func(s *settings) {
+		if s.routes == nil {
+			s.routes = processor.Routes{}
+		}
+		s.routes[level] = append(s.routes[level], sinks...)
+	}
+}
+
+// WithFake registers generate as the {name} placeholder of message templates
+// and the fake of schema fields naming it, e.g. for internal ticket IDs or
+// SKU formats. Placeholders are shared by the whole process; built-in ones
+// cannot be replaced.
+func WithFake(name string, generate func() string) Option {
+	return func(s *settings) {
+		s.fakes = append(s.fakes, namedFake{name: name, generate: generate})
+	}
+}
+
+// WithMessages adds message templates for level, used in place of the
+// catalog's. Templates may reference built-in and registered placeholders.
+func WithMessages(level logger.LogLevel, templates ...string) Option {
+	return func(s *settings) {
+		if s.messages == nil {
+			s.messages = map[logger.LogLevel][]string{}
+		}
+		s.messages[level] = append(s.messages[level], templates...)
+	}
+}
+
+// WithReplay emits the records of player instead of generated logs, stamped
+// with the time they are sent if rewrite is set
+func WithReplay(player *replay.Player, rewrite bool) Option {
+	return func(s *settings) {
+		s.player, s.rewrite = player, rewrite
+	}
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"errors"
+	"io"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// Event is a log emitted by a generator, stamped and sequenced like the logs
+// written locally or exported. Its fields are shared by all sinks, which
+// must not modify them.
+type Event = logger.Entry
+
+// Sink receives the events of a generator. Write is called concurrently when
+// several workers generate, and should not block for long as it paces them.
+// Sinks implementing io.Closer are closed when the generator shuts down.
+type Sink interface {
+	Write(Event) error
+}
+
+// Flusher is a sink buffering events, written out by Flush
+type Flusher interface {
+	Flush() error
+}
+
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(Event) error
+
+// Write calls f
+func (f SinkFunc) Write(e Event) error {
+	return f(e)
+}
+
+// stage is a sink of a pipeline with the counts of its deliveries
+type stage struct {
+	name  string
+	sink  Sink
+	stats *stats.Sink
+}
+
+// Pipeline fans events out to sinks, counting the deliveries of each in the
+// registry the run summary and metrics read
+type Pipeline struct {
+	registry   *stats.Registry
+	stages     []stage
+	transforms logger.Transforms
+}
+
+// NewPipeline creates an empty pipeline counting into registry (a private
+// one if nil)
+func NewPipeline(registry *stats.Registry) *Pipeline {
+	if registry == nil {
+		registry = stats.New()
+	}
+	return &Pipeline{registry: registry}
+}
+
+// SetTransforms rewrites the events before the named sinks take them
+func (p *Pipeline) SetTransforms(transforms logger.Transforms) {
+	p.transforms = transforms
+}
+
+// Add appends a sink, counted under name. Sinks must be added before events
+// are written.
+func (p *Pipeline) Add(name string, sink Sink) {
+	p.stages = append(p.stages, stage{name: name, sink: sink, stats: p.registry.Sink(name)})
+}
+
+// Len returns the number of sinks
+func (p *Pipeline) Len() int {
+	return len(p.stages)
+}
+
+// Write hands the event to every sink, returning their errors. A failing
+// sink does not keep the event from the others.
+func (p *Pipeline) Write(e Event) error {
+	var errs []error
+	for _, s := range p.stages {
+		e := e
+		if !p.transforms.Apply(s.name, &e) {
+			continue
+		}
+		err := s.sink.Write(e)
+		s.stats.Deliver(1, err)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes the sinks implementing Flusher, returning their errors
+func (p *Pipeline) Flush() error {
+	var errs []error
+	for _, s := range p.stages {
+		if flusher, ok := s.sink.(Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the sinks implementing io.Closer, returning their errors
+func (p *Pipeline) Close() error {
+	var errs []error
+	for _, s := range p.stages {
+		if closer, ok := s.sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
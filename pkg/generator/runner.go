@@ -0,0 +1,109 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrRunning is returned when starting a runner whose run is in progress
+var ErrRunning = errors.New("generation is already running")
+
+// Runner starts and stops runs of a generator, one at a time. A run ends
+// when stopped, when the context it was started with is done, or by itself
+// once the count is reached or the replay is over; it may then be started
+// again.
+type Runner struct {
+	gen      *Generator
+	mutex    sync.Mutex
+	cancel   context.CancelFunc // cancels the run in progress, nil if none
+	done     chan struct{}      // closed when the last run started ends
+	err      error              // of the last run that ended
+	stopping bool               // the run in progress is being stopped
+	finished chan struct{}      // closed when a run ends by itself
+	finish   sync.Once
+}
+
+// NewRunner creates a runner of the generator
+func NewRunner(g *Generator) *Runner {
+	done := make(chan struct{})
+	close(done)
+	return &Runner{gen: g, done: done, finished: make(chan struct{})}
+}
+
+// Start starts a run in the background, which ends at the latest when ctx
+// is done, or returns ErrRunning if one is in progress
+func (r *Runner) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cancel != nil {
+		return ErrRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	r.cancel, r.done, r.stopping = cancel, done, false
+	go func() {
+		err := r.gen.Run(ctx)
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		stopped := r.stopping || ctx.Err() != nil
+		cancel()
+		r.cancel, r.err = nil, err
+		close(done)
+		if !stopped {
+			r.finish.Do(func() { close(r.finished) })
+		}
+	}()
+	return nil
+}
+
+// Stop ends the run in progress and waits until it has, or until ctx is
+// done, then writes out the logs buffered by the sinks (see
+// Generator.Flush). Stopping a runner that is not running does nothing.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	cancel, done := r.cancel, r.done
+	if cancel != nil {
+		r.stopping = true
+		cancel()
+	}
+	r.mutex.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.gen.Flush()
+}
+
+// Running reports whether a run is in progress
+func (r *Runner) Running() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.cancel != nil
+}
+
+// Done returns a channel closed when the last run started ends
+func (r *Runner) Done() <-chan struct{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.done
+}
+
+// Finished returns a channel closed the first time a run ends by itself,
+// having reached the count or replayed the file, rather than being stopped
+func (r *Runner) Finished() <-chan struct{} {
+	return r.finished
+}
+
+// Err returns the error the last run ended with, e.g. a replay failing
+func (r *Runner) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
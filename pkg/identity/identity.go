@@ -0,0 +1,113 @@
+package identity
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// DefaultInstances is the number of instances of each service unless
+// configured
+const DefaultInstances = 3
+
+// podAlphabet holds the characters Kubernetes uses in generated pod names
+const podAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// Identity is an instance of a service, naming where a log comes from
+type Identity struct {
+	Service string
+	Host    string
+	Pod     string
+}
+
+// Config holds the configuration for an identity pool
+type Config struct {
+	Seed      string // Derives the identities; the same seed and sizes give the same pool
+	Services  int    // Number of services
+	Instances int    // Instances of each service, each with a host and pod (default 3)
+}
+
+// Pool is a fixed fleet of service instances derived from a seed, so a
+// restarted generator continues with the same services, hosts and pods
+type Pool struct {
+	identities []Identity
+}
+
+// New derives the identities of a pool from its seed
+func New(config Config) (*Pool, error) {
+	if config.Services < 1 {
+		return nil, fmt.Errorf("invalid number of services %d: must be at least 1", config.Services)
+	}
+	if config.Instances == 0 {
+		config.Instances = DefaultInstances
+	}
+	if config.Instances < 1 {
+		return nil, fmt.Errorf("invalid number of instances %d: must be at least 1", config.Instances)
+	}
+
+	faker := gofakeit.New(seed(config.Seed))
+	p := &Pool{}
+	taken := map[string]bool{}
+	for i := 0; i < config.Services; i++ {
+		base := serviceName(faker)
+		service := base
+		for n := 2; taken[service]; n++ {
+			service = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[service] = true
+
+		replicaSet := randomString(faker, 10)
+		for j := 0; j < config.Instances; j++ {
+			p.identities = append(p.identities, Identity{
+				Service: service,
+				Host:    fmt.Sprintf("ip-10-%d-%d-%d.ec2.internal", faker.Number(0, 255), faker.Number(0, 255), faker.Number(1, 254)),
+				Pod:     service + "-" + replicaSet + "-" + randomString(faker, 5),
+			})
+		}
+	}
+	return p, nil
+}
+
+// Pick returns an identity of the pool at random
+func (p *Pool) Pick() Identity {
+	return p.identities[gofakeit.Number(0, len(p.identities)-1)]
+}
+
+// Identities returns every identity of the pool, in the order derived
+func (p *Pool) Identities() []Identity {
+	return p.identities
+}
+
+// seed derives the seed of the faker from a string, never 0, which would
+// seed it randomly
+func seed(s string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	if n := int64(h.Sum64() >> 1); n != 0 {
+		return n
+	}
+	return 1
+}
+
+// serviceName returns a service name in the form of DNS labels
+func serviceName(faker *gofakeit.Faker) string {
+	name := strings.ToLower(faker.AppName())
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "-")
+	if name == "" {
+		name = "service"
+	}
+	return name
+}
+
+// randomString returns n characters of the pod name alphabet
+func randomString(faker *gofakeit.Faker, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = podAlphabet[faker.Number(0, len(podAlphabet)-1)]
+	}
+	return string(b)
+}
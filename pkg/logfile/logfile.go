@@ -0,0 +1,288 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// Compression of the files
+const (
+	// CompressNone writes plain files
+	CompressNone = "none"
+	// CompressGzip gzips rotated files, or the file itself if it is not rotated
+	CompressGzip = "gzip"
+	// CompressZstd compresses rotated files with zstd, or the file itself if
+	// it is not rotated
+	CompressZstd = "zstd"
+)
+
+// suffixes are the file name suffixes of the compressions
+var suffixes = map[string]string{
+	CompressNone: "",
+	CompressGzip: ".gz",
+	CompressZstd: ".zst",
+}
+
+// rotatedLayout stamps the names of rotated files, sorting by time
+const rotatedLayout = "20060102T150405.000000"
+
+// rotatedStamp matches the stamp of rotatedLayout
+const rotatedStamp = `[0-9]{8}T[0-9]{6}\.[0-9]{6}`
+
+// ParseCompression validates a compression, none if empty
+func ParseCompression(compression string) (string, error) {
+	switch c := strings.ToLower(strings.TrimSpace(compression)); c {
+	case "":
+		return CompressNone, nil
+	case CompressNone, CompressGzip, CompressZstd:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown compression %q (use %s, %s or %s)", compression, CompressNone, CompressGzip, CompressZstd)
+}
+
+// Config holds the configuration of a log file
+type Config struct {
+	Path     string // File the logs are written to
+	MaxSize  int64  // Rotate the file once it reaches this many bytes (0 never rotates)
+	Keep     int    // Rotated files kept, the oldest removed first (0 keeps all)
+	Compress string // none, gzip or zstd
+}
+
+// File writes logs to a file, rotating it by size. Rotated files are renamed
+// with the time of their rotation, e.g. app-20240102T150405.000000.log, and
+// compressed in the background once closed, so the active file stays plain
+// for agents to tail. A file that is not rotated is compressed as written,
+// under its path with the suffix of the compression, e.g. app.log.gz.
+type File struct {
+	config  Config
+	path    string // of the active file
+	rotated *regexp.Regexp
+	mutex   sync.Mutex
+	file    *os.File
+	stream  io.WriteCloser // nil unless the active file is compressed
+	size    int64          // bytes written to the active file, before compression
+	pending sync.WaitGroup
+	pruning sync.Mutex
+}
+
+// New opens a log file, appending to it unless compressed as written. A
+// compressed stream cannot be appended to, so New refuses to start over an
+// existing one.
+func New(config Config) (*File, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("no file path")
+	}
+	compression, err := ParseCompression(config.Compress)
+	if err != nil {
+		return nil, err
+	}
+	config.Compress = compression
+	if config.MaxSize < 0 || config.Keep < 0 {
+		return nil, fmt.Errorf("invalid rotation: size and kept files must not be negative")
+	}
+	f := &File{config: config, path: config.Path}
+	if f.streamed() && !strings.HasSuffix(f.path, suffixes[compression]) {
+		f.path += suffixes[compression]
+	}
+	ext := filepath.Ext(config.Path)
+	f.rotated = regexp.MustCompile("^" + regexp.QuoteMeta(filepath.Base(strings.TrimSuffix(config.Path, ext))) +
+		"-" + rotatedStamp + regexp.QuoteMeta(ext) + `(\.gz|\.zst)?$`)
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the active file
+func (f *File) Path() string {
+	return f.path
+}
+
+// open opens the active file
+func (f *File) open() error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if f.streamed() {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_EXCL
+	}
+	file, err := os.OpenFile(f.path, flags, 0o644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s exists and a compressed stream cannot be appended to: move it or write to another file", f.path)
+	}
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	if f.streamed() {
+		if f.stream, err = newCompressor(f.config.Compress, file); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// streamed tells whether the active file is compressed as written
+func (f *File) streamed() bool {
+	return f.config.Compress != CompressNone && f.config.MaxSize == 0
+}
+
+// newCompressor returns a writer compressing into w
+func newCompressor(compression string, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// Write writes p to the active file, rotating it first if p would take it
+// past the maximum size. Writes are never split between files.
+func (f *File) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.config.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	var w io.Writer = f.file
+	if f.stream != nil {
+		w = f.stream
+	}
+	n, err := w.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the active file with the current time and opens a new
+// one; the mutex must be held
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	ext := filepath.Ext(f.path)
+	rotated := strings.TrimSuffix(f.path, ext) + "-" + time.Now().UTC().Format(rotatedLayout) + ext
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		if f.config.Compress != CompressNone {
+			if err := compress(rotated, f.config.Compress); err != nil {
+				diag.Warn("Failed to compress rotated log file", "path", rotated, "error", err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// prune removes the oldest rotated files beyond those kept. Only files named
+// as rotated ones count, so other files next to the log are left alone.
+func (f *File) prune() {
+	if f.config.Keep == 0 {
+		return
+	}
+	f.pruning.Lock()
+	defer f.pruning.Unlock()
+	dir := filepath.Dir(f.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	suffix := suffixes[f.config.Compress]
+	var rotated []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !f.rotated.MatchString(name) {
+			continue
+		}
+		// Files being compressed are counted by their compressed copy
+		if suffix != "" && !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		rotated = append(rotated, name)
+	}
+	sort.Strings(rotated)
+	for len(rotated) > f.config.Keep {
+		path := filepath.Join(dir, rotated[0])
+		if err := os.Remove(path); err != nil {
+			diag.Warn("Failed to remove rotated log file", "path", path, "error", err)
+		}
+		rotated = rotated[1:]
+	}
+}
+
+// compress compresses a closed file next to it and removes it
+func compress(path, compression string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	target := path + suffixes[compression]
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w, err := newCompressor(compression, out)
+	if err == nil {
+		_, err = io.Copy(w, in)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close closes the active file, completing its compressed stream, and waits
+// for rotated files to be compressed
+func (f *File) Close() error {
+	f.mutex.Lock()
+	var err error
+	if f.file != nil {
+		if f.stream != nil {
+			err = f.stream.Close()
+		}
+		if closeErr := f.file.Close(); err == nil {
+			err = closeErr
+		}
+		f.file = nil
+	}
+	f.mutex.Unlock()
+	f.pending.Wait()
+	return err
+}
@@ -0,0 +1,170 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", CompressNone, false},
+		{"none", CompressNone, false},
+		{" GZIP ", CompressGzip, false},
+		{"zstd", CompressZstd, false},
+		{"brotli", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCompression(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCompression(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStreamedSuffixAndRefusal(t *testing.T) {
+	tests := []struct {
+		compression string
+		path        string
+		want        string
+	}{
+		{CompressGzip, "app.log", "app.log.gz"},
+		{CompressZstd, "app.log", "app.log.zst"},
+		{CompressGzip, "app.log.gz", "app.log.gz"},
+		{CompressNone, "app.log", "app.log"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		f, err := New(Config{Path: filepath.Join(dir, tt.path), Compress: tt.compression})
+		if err != nil {
+			t.Fatalf("New(%s, %s): %v", tt.path, tt.compression, err)
+		}
+		if _, err := f.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := filepath.Base(f.Path()); got != tt.want {
+			t.Errorf("%s with %s: path %s, want %s", tt.path, tt.compression, got, tt.want)
+		}
+		if got := readAll(t, f.Path()); got != "line\n" {
+			t.Errorf("%s: read %q", f.Path(), got)
+		}
+
+		// A second run must not overwrite a compressed stream
+		_, err = New(Config{Path: filepath.Join(dir, tt.path), Compress: tt.compression})
+		if streamed := tt.compression != CompressNone; streamed != (err != nil) {
+			t.Errorf("reopening %s with %s: error %v", tt.path, tt.compression, err)
+		}
+	}
+}
+
+func TestRotationAndPrune(t *testing.T) {
+	for _, compression := range []string{CompressNone, CompressGzip, CompressZstd} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			// Files log-genie did not write must survive pruning
+			foreign := []string{"app-old.log.bak", "app-notes.log", "app-20240102T150405.000000.txt"}
+			for _, name := range foreign {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f, err := New(Config{Path: filepath.Join(dir, "app.log"), MaxSize: 10, Keep: 2, Compress: compression})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 6; i++ {
+				// Each write takes the file past its size, rotating the last
+				if _, err := f.Write([]byte("0123456789\n")); err != nil {
+					t.Fatal(err)
+				}
+				// Rotations within the same microsecond would share a name
+				f.pending.Wait()
+				waitNextStamp()
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var rotated []string
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				if f.rotated.MatchString(e.Name()) {
+					rotated = append(rotated, e.Name())
+				}
+			}
+			sort.Strings(rotated)
+			if len(rotated) != 2 {
+				t.Fatalf("rotated files %v, want 2", rotated)
+			}
+			for _, name := range rotated {
+				if !strings.HasSuffix(name, ".log"+suffixes[compression]) {
+					t.Errorf("rotated file %s lacks the suffix of %s", name, compression)
+				}
+				if got := readAll(t, filepath.Join(dir, name)); got != "0123456789\n" {
+					t.Errorf("%s: read %q", name, got)
+				}
+			}
+			for _, name := range foreign {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("pruning removed %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+// waitNextStamp waits until the stamp of rotated files changes
+func waitNextStamp() {
+	for stamp := stampNow(); stampNow() == stamp; {
+	}
+}
+
+func stampNow() string {
+	return time.Now().UTC().Format(rotatedLayout)
+}
+
+// readAll reads a file, decompressing it by its suffix
+func readAll(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
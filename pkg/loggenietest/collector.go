@@ -0,0 +1,178 @@
+package loggenietest
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rjonczy/log-genie/pkg/generator"
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// Collector is an in-process OTLP/HTTP logs receiver keeping the records it
+// is sent as events, for tests of what reaches a collector
+type Collector struct {
+	*Memory
+	server *httptest.Server
+}
+
+// NewCollector starts a collector, closed when the test ends
+func NewCollector(t testing.TB) *Collector {
+	t.Helper()
+	c := &Collector{Memory: NewMemory()}
+	c.server = httptest.NewServer(http.HandlerFunc(c.receive))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+// Endpoint returns the address to export logs to, as the telemetry endpoint
+// takes it
+func (c *Collector) Endpoint() string {
+	return strings.TrimPrefix(c.server.URL, "http://")
+}
+
+// Config returns config exporting logs to the collector
+func (c *Collector) Config(config logger.Config) logger.Config {
+	config.TelemetryEnabled = true
+	config.TelemetryEndpoint = c.Endpoint()
+	return config
+}
+
+// Export generates n logs as fast as possible, exporting them to the
+// collector, and returns the events it received
+func (c *Collector) Export(t testing.TB, n int, config logger.Config, options ...generator.Option) []generator.Event {
+	t.Helper()
+	options = append([]generator.Option{generator.WithConfig(c.Config(config))}, options...)
+	gen, memory := run(t, n, options...)
+	// Shutting down flushes the logs batched for export
+	gen.Shutdown()
+	return c.WaitFor(t, memory.Len(), DefaultTimeout)
+}
+
+// receive handles an export request (POST /v1/logs, protobuf or JSON,
+// optionally gzipped)
+func (c *Collector) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/logs" {
+		http.NotFound(w, r)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonEncoded := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	request := &collogs.ExportLogsServiceRequest{}
+	if jsonEncoded {
+		err = protojson.Unmarshal(data, request)
+	} else {
+		err = proto.Unmarshal(data, request)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events []generator.Event
+	for _, resourceLogs := range request.GetResourceLogs() {
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				events = append(events, fromRecord(record))
+			}
+		}
+	}
+	c.add(events...)
+
+	var out []byte
+	if jsonEncoded {
+		w.Header().Set("Content-Type", "application/json")
+		out, _ = protojson.Marshal(&collogs.ExportLogsServiceResponse{})
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		out, _ = proto.Marshal(&collogs.ExportLogsServiceResponse{})
+	}
+	_, _ = w.Write(out)
+}
+
+// fromRecord converts an OTLP log record to an event
+func fromRecord(record *logs.LogRecord) generator.Event {
+	e := generator.Event{
+		Time:    time.Unix(0, int64(record.GetTimeUnixNano())),
+		Message: record.GetBody().GetStringValue(),
+		Fields:  make(map[string]interface{}, len(record.GetAttributes())),
+	}
+	if level, ok := logger.ParseLevel(record.GetSeverityText()); ok {
+		e.Level = level
+	} else {
+		e.Level = levelOf(record.GetSeverityNumber())
+	}
+	for _, kv := range record.GetAttributes() {
+		e.Fields[kv.GetKey()] = fromAny(kv.GetValue())
+	}
+	return e
+}
+
+// levelOf maps an OTLP severity number to a level
+func levelOf(severity logs.SeverityNumber) logger.LogLevel {
+	switch {
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return logger.Error
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return logger.Warn
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return logger.Info
+	default:
+		return logger.Debug
+	}
+}
+
+// fromAny converts an OTLP attribute value to a field value
+func fromAny(value *common.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_IntValue:
+		return v.IntValue
+	case *common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *common.AnyValue_BytesValue:
+		return v.BytesValue
+	case *common.AnyValue_ArrayValue:
+		items := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			items = append(items, fromAny(item))
+		}
+		return items
+	case *common.AnyValue_KvlistValue:
+		m := make(map[string]interface{}, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			m[kv.GetKey()] = fromAny(kv.GetValue())
+		}
+		return m
+	default:
+		return nil
+	}
+}
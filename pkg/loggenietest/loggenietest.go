@@ -0,0 +1,235 @@
+package loggenietest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/generator"
+	"github.com/rjonczy/log-genie/pkg/integrity"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// MemorySink is the name the deliveries of the memory sink are counted under
+const MemorySink = "memory"
+
+// DefaultTimeout bounds how long helpers wait for logs
+const DefaultTimeout = 30 * time.Second
+
+// Memory is a sink keeping every event it receives, for tests to inspect
+type Memory struct {
+	mutex  sync.Mutex
+	events []generator.Event
+	added  chan struct{} // closed and replaced whenever events are added
+}
+
+// NewMemory creates an empty memory sink
+func NewMemory() *Memory {
+	return &Memory{added: make(chan struct{})}
+}
+
+// Write keeps a copy of the event
+func (m *Memory) Write(e generator.Event) error {
+	fields := make(map[string]interface{}, len(e.Fields))
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	e.Fields = fields
+	m.add(e)
+	return nil
+}
+
+// add appends events and wakes up the waiters
+func (m *Memory) add(events ...generator.Event) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = append(m.events, events...)
+	close(m.added)
+	m.added = make(chan struct{})
+}
+
+// Events returns the events received so far
+func (m *Memory) Events() []generator.Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]generator.Event(nil), m.events...)
+}
+
+// Len returns the number of events received so far
+func (m *Memory) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.events)
+}
+
+// Reset forgets the events received so far
+func (m *Memory) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = nil
+}
+
+// WaitFor waits until at least n events were received and returns them,
+// failing the test if they do not arrive within timeout
+func (m *Memory) WaitFor(t testing.TB, n int, timeout time.Duration) []generator.Event {
+	t.Helper()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		m.mutex.Lock()
+		received, added := len(m.events), m.added
+		m.mutex.Unlock()
+		if received >= n {
+			return m.Events()
+		}
+		select {
+		case <-added:
+		case <-deadline.C:
+			t.Fatalf("received %d events within %s, expected %d", received, timeout, n)
+			return nil
+		}
+	}
+}
+
+// Start creates a generator sending every log to a new memory sink, shut
+// down when the test ends. Local logs are off unless the config of the
+// options enables them. Run the generator, or call Generate, to emit logs.
+func Start(t testing.TB, options ...generator.Option) (*generator.Generator, *Memory) {
+	t.Helper()
+	memory := NewMemory()
+	gen, err := generator.New(append(options, generator.WithSink(MemorySink, memory))...)
+	if err != nil {
+		if gen != nil {
+			gen.Shutdown()
+		}
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	t.Cleanup(gen.Shutdown)
+	return gen, memory
+}
+
+// Generate emits n logs as fast as possible, or at the rate of the options,
+// and returns them
+func Generate(t testing.TB, n int, options ...generator.Option) []generator.Event {
+	t.Helper()
+	_, memory := run(t, n, options...)
+	return memory.Events()
+}
+
+// run emits n logs to a memory sink, returning once it received them
+func run(t testing.TB, n int, options ...generator.Option) (*generator.Generator, *Memory) {
+	t.Helper()
+	options = append([]generator.Option{generator.WithRate(ratelimit.Unlimited)}, options...)
+	gen, memory := Start(t, append(options, generator.WithCount(int64(n)))...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := gen.Run(ctx); err != nil {
+		t.Fatalf("generator failed: %v", err)
+	}
+	// Sinks take the logs processors keep
+	memory.WaitFor(t, n-int(gen.Logger().LogsFiltered()), DefaultTimeout)
+	return gen, memory
+}
+
+// Filter returns the events match reports true for
+func Filter(events []generator.Event, match func(generator.Event) bool) []generator.Event {
+	var matched []generator.Event
+	for _, e := range events {
+		if match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// AssertCount checks that there are exactly n events
+func AssertCount(t testing.TB, events []generator.Event, n int) {
+	t.Helper()
+	if len(events) != n {
+		t.Errorf("got %d events, expected %d", len(events), n)
+	}
+}
+
+// AssertLevels checks that every event has one of the levels
+func AssertLevels(t testing.TB, events []generator.Event, levels ...logger.LogLevel) {
+	t.Helper()
+	for i, e := range events {
+		if !containsLevel(levels, e.Level) {
+			t.Errorf("event %d has level %s, expected one of %v", i, e.Level, levels)
+			return
+		}
+	}
+}
+
+// containsLevel reports whether level is among levels
+func containsLevel(levels []logger.LogLevel, level logger.LogLevel) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertFields checks that every event has the fields
+func AssertFields(t testing.TB, events []generator.Event, names ...string) {
+	t.Helper()
+	for i, e := range events {
+		for _, name := range names {
+			if _, ok := e.Fields[name]; !ok {
+				t.Errorf("event %d lacks field %s: %v", i, name, e.Fields)
+				return
+			}
+		}
+	}
+}
+
+// AssertSequence checks that the events of each stream carry the sequence
+// numbers 1 to n, in any order, without gaps or duplicates. The generator
+// must embed them (logger.Config.Sequence).
+func AssertSequence(t testing.TB, events []generator.Event) {
+	t.Helper()
+	seen := map[string]map[int64]bool{}
+	for i, e := range events {
+		stream := fmt.Sprint(e.Fields[integrity.StreamField])
+		seq, ok := sequenceOf(e.Fields[integrity.SequenceField])
+		if !ok {
+			t.Errorf("event %d has no sequence number", i)
+			return
+		}
+		if seen[stream] == nil {
+			seen[stream] = map[int64]bool{}
+		}
+		if seen[stream][seq] {
+			t.Errorf("stream %s has sequence number %d twice", stream, seq)
+		}
+		seen[stream][seq] = true
+	}
+	for stream, numbers := range seen {
+		for seq := int64(1); seq <= int64(len(numbers)); seq++ {
+			if !numbers[seq] {
+				t.Errorf("stream %s lacks sequence number %d", stream, seq)
+				break
+			}
+		}
+	}
+}
+
+// sequenceOf reads a sequence number, as generated or decoded from JSON
+func sequenceOf(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
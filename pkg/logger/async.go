@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// Overflow policies of the local output queue
+const (
+	// OverflowBlock makes generation wait for room in the queue
+	OverflowBlock = "block"
+	// OverflowDrop drops the log that does not fit
+	OverflowDrop = "drop"
+	// OverflowDropOldest drops the oldest queued log to make room
+	OverflowDropOldest = "drop-oldest"
+)
+
+// ParseOverflow validates an overflow policy, block if empty
+func ParseOverflow(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return OverflowBlock, nil
+	case OverflowBlock, OverflowDrop, OverflowDropOldest:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q (use %s, %s or %s)", policy, OverflowBlock, OverflowDrop, OverflowDropOldest)
+}
+
+// line is a queued local log, counted into its sink once written
+type line struct {
+	data *[]byte
+	sink *stats.Sink // nil while local logs are disabled
+}
+
+// linePool holds the buffers of queued lines
+var linePool = sync.Pool{
+	New: func() any {
+		data := make([]byte, 0, 1024)
+		return &data
+	},
+}
+
+// asyncWriter hands local writes to writer goroutines through a bounded
+// queue, so a slow terminal or disk does not pace generation. Queued logs
+// are counted when written; the ones the overflow policy drops stay queued
+// in the stats, which report them as dropped.
+type asyncWriter struct {
+	w        io.Writer
+	queue    chan line
+	overflow string
+	pending  atomic.Int64 // lines queued and not yet written or dropped
+	writers  sync.WaitGroup
+	mutex    sync.RWMutex // held for reading while queueing, for writing while closing
+	closed   bool         // lines are written synchronously once closed
+	full     sync.Once    // reports the first time the queue is full
+}
+
+// newAsyncWriter queues up to size lines for writer goroutines writing to w
+func newAsyncWriter(w io.Writer, size, writers int, overflow string) *asyncWriter {
+	a := &asyncWriter{w: w, queue: make(chan line, size), overflow: overflow}
+	if writers < 1 {
+		writers = 1
+	}
+	a.writers.Add(writers)
+	for i := 0; i < writers; i++ {
+		go a.run()
+	}
+	return a
+}
+
+// run writes queued lines until the queue is closed and empty
+func (a *asyncWriter) run() {
+	defer a.writers.Done()
+	for l := range a.queue {
+		_, err := a.w.Write(*l.data)
+		if l.sink != nil {
+			l.sink.Done(1, err)
+		}
+		a.release(l)
+	}
+}
+
+// enqueue queues a copy of p, counted into sink, applying the overflow
+// policy when the queue is full
+func (a *asyncWriter) enqueue(p []byte, sink *stats.Sink) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		_, err := a.w.Write(p)
+		if sink != nil {
+			sink.Deliver(1, err)
+		}
+		return
+	}
+
+	data := linePool.Get().(*[]byte)
+	*data = append((*data)[:0], p...)
+	l := line{data: data, sink: sink}
+	if sink != nil {
+		sink.Queue(1)
+	}
+	a.pending.Add(1)
+
+	select {
+	case a.queue <- l:
+		return
+	default:
+	}
+	switch a.overflow {
+	case OverflowDrop:
+		a.reportFull("dropping new logs")
+		a.drop(l)
+	case OverflowDropOldest:
+		a.reportFull("dropping the oldest logs")
+		for {
+			select {
+			case a.queue <- l:
+				return
+			default:
+			}
+			select {
+			case oldest := <-a.queue:
+				a.drop(oldest)
+			default:
+			}
+		}
+	default:
+		a.reportFull("generation waits for it")
+		a.queue <- l
+	}
+}
+
+// reportFull reports the first time the queue overflows
+func (a *asyncWriter) reportFull(consequence string) {
+	a.full.Do(func() {
+		diag.Warn("Local output cannot keep up, its queue is full", "queue", cap(a.queue), "consequence", consequence)
+	})
+}
+
+// release returns the buffer of a line written or dropped
+func (a *asyncWriter) release(l line) {
+	linePool.Put(l.data)
+	a.pending.Add(-1)
+}
+
+// drop releases a line dropped by the overflow policy, which stays counted
+// as dropped
+func (a *asyncWriter) drop(l line) {
+	if l.sink != nil {
+		l.sink.Drop(1)
+	}
+	a.release(l)
+}
+
+// Len returns the number of lines queued and not yet written
+func (a *asyncWriter) Len() int64 {
+	return a.pending.Load()
+}
+
+// Drain waits until the lines queued so far are written, at most timeout
+func (a *asyncWriter) Drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for a.pending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Close writes the queued lines and stops the writer goroutines, reporting
+// false if they are still writing after timeout; later lines are written
+// right away
+func (a *asyncWriter) Close(timeout time.Duration) bool {
+	a.mutex.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		a.writers.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
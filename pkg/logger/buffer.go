@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// bufferedWriter buffers local writes, writing them out when the buffer
+// fills, every interval and on Flush, so a log costs one write per buffer
+// instead of one per line
+type bufferedWriter struct {
+	mutex sync.Mutex
+	w     *bufio.Writer
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// newBufferedWriter buffers up to size bytes for w, flushing every interval
+// (only when full if interval is 0)
+func newBufferedWriter(w io.Writer, size int, interval time.Duration) *bufferedWriter {
+	b := &bufferedWriter{w: bufio.NewWriterSize(w, size), stop: make(chan struct{}), done: make(chan struct{})}
+	if interval <= 0 {
+		close(b.done)
+		return b
+	}
+	go b.run(interval)
+	return b
+}
+
+// run flushes the buffer every interval until Close
+func (b *bufferedWriter) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Flush()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Write buffers p, writing out the buffer first if p does not fit
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Keep lines whole: a line that does not fit starts the next buffer
+	if b.w.Buffered() > 0 && len(p) > b.w.Available() {
+		if err := b.w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return b.w.Write(p)
+}
+
+// Flush writes out the buffered logs
+func (b *bufferedWriter) Flush() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.w.Flush()
+}
+
+// Close stops the periodic flushes and writes out the buffered logs
+func (b *bufferedWriter) Close() error {
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	<-b.done
+	return b.Flush()
+}
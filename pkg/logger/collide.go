@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"strconv"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// collision is a group of field names that clash once a backend maps or
+// normalizes them
+type collision struct {
+	keys  []string
+	value func(i int) interface{} // value of the i-th key
+}
+
+// collisions are the groups of clashing field names of the collision mode:
+// spellings normalizing to the same name, dotted keys expanding into objects
+// over scalars of the same name, and values changing type between logs
+var collisions = []collision{
+	{
+		// Normalize to http_status when dots and slashes become underscores
+		keys:  []string{"http.status", "http_status", "http/status", "HTTP_STATUS"},
+		value: func(int) interface{} { return gofakeit.HTTPStatusCode() },
+	},
+	{
+		keys:  []string{"user.id", "user_id", "userId", "user-id"},
+		value: func(int) interface{} { return gofakeit.UUID() },
+	},
+	{
+		keys:  []string{"k8s.pod.name", "k8s_pod_name", "k8s/pod/name", "k8s.pod_name"},
+		value: func(int) interface{} { return gofakeit.Word() + "-" + strconv.Itoa(gofakeit.Number(0, 9)) },
+	},
+	{
+		// A scalar whose name a dotted key expands into an object
+		keys: []string{"error", "error.code", "error.message"},
+		value: func(i int) interface{} {
+			if i == 1 {
+				return gofakeit.Number(400, 599)
+			}
+			return gofakeit.HackerPhrase()
+		},
+	},
+	{
+		// Expands the generated service name into an object
+		keys: []string{"service.name", "service.version"},
+		value: func(i int) interface{} {
+			if i == 0 {
+				return gofakeit.AppName()
+			}
+			return gofakeit.AppVersion()
+		},
+	},
+	{
+		// Names with empty segments, rejected by some backends
+		keys:  []string{".leading", "trailing.", "double..dot"},
+		value: func(int) interface{} { return gofakeit.Word() },
+	},
+	{
+		// Numbers in some logs, strings in others
+		keys: []string{"duration"},
+		value: func(int) interface{} {
+			if gofakeit.Bool() {
+				return gofakeit.Number(1, 5000)
+			}
+			return strconv.Itoa(gofakeit.Number(1, 5000)) + "ms"
+		},
+	},
+}
+
+// collide adds the clashing field names of one to three collision groups to
+// the fields when the collision mode is enabled
+func (l *Logger) collide(fields map[string]interface{}) {
+	if !l.keyCollisions {
+		return
+	}
+	for n := gofakeit.Number(1, 3); n > 0; n-- {
+		c := collisions[gofakeit.Number(0, len(collisions)-1)]
+		for i, key := range c.keys {
+			fields[key] = c.value(i)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxPooledFields bounds the fields maps kept for reuse, so one huge log
+// does not pin its memory
+const maxPooledFields = 64
+
+// fieldsPool holds the fields maps of generated logs for reuse
+var fieldsPool = sync.Pool{
+	New: func() any { return make(map[string]interface{}, 16) },
+}
+
+// newFields returns an empty fields map, reused if one is free
+func newFields() map[string]interface{} {
+	return fieldsPool.Get().(map[string]interface{})
+}
+
+// releaseFields makes the fields of an emitted log available for reuse,
+// unless a sink may still hold them: logs handed to other sinks, or
+// captured while pregenerating, keep their fields
+func (l *Logger) releaseFields(fields map[string]interface{}) {
+	if l.hook != nil || l.capture != nil || len(fields) > maxPooledFields {
+		return
+	}
+	clear(fields)
+	fieldsPool.Put(fields)
+}
+
+// encoder renders logs as logrus' JSON formatter does, reusing its buffers
+type encoder struct {
+	buf  []byte
+	keys []string
+}
+
+// encoderPool holds the encoders of local JSON writes
+var encoderPool = sync.Pool{
+	New: func() any { return &encoder{buf: make([]byte, 0, 1024), keys: make([]string, 0, 16)} },
+}
+
+// jsonKeys are the keys a JSON formatter writes besides the fields
+type jsonKeys struct {
+	time, msg, level, logrusError string
+	timeFormat                    string
+	escapeHTML                    bool
+	levels                        *levelFormat // nil writes logrus' names
+}
+
+// newJSONKeys returns the keys of a JSON formatter, false if it renders logs
+// in a way the encoder does not (nested data or indentation)
+func newJSONKeys(formatter logrus.Formatter) (*jsonKeys, bool) {
+	f, ok := formatter.(*logrus.JSONFormatter)
+	if !ok || f.DataKey != "" || f.PrettyPrint || f.CallerPrettyfier != nil {
+		return nil, false
+	}
+	resolve := func(key string) string { return fieldKey(f.FieldMap, key) }
+	keys := &jsonKeys{
+		msg:         resolve(logrus.FieldKeyMsg),
+		level:       resolve(logrus.FieldKeyLevel),
+		logrusError: resolve(logrus.FieldKeyLogrusError),
+		timeFormat:  f.TimestampFormat,
+		escapeHTML:  !f.DisableHTMLEscape,
+	}
+	if keys.timeFormat == "" {
+		keys.timeFormat = time.RFC3339
+	}
+	// Only the key a field clashes with when the formatter omits the time
+	keys.time = resolve(logrus.FieldKeyTime)
+	if f.DisableTimestamp {
+		keys.timeFormat = ""
+	}
+	return keys, true
+}
+
+// writeJSON writes a log to the local output without going through logrus,
+// reporting false if the log needs logrus' own rendering: fields clashing
+// with the keys it writes, or values it cannot encode
+func (l *Logger) writeJSON(e Entry) bool {
+	keys := l.jsonKeys
+	for _, key := range [...]string{keys.time, keys.msg, keys.level, keys.logrusError} {
+		if _, ok := e.Fields[key]; ok {
+			return false
+		}
+	}
+
+	enc := encoderPool.Get().(*encoder)
+	defer encoderPool.Put(enc)
+	enc.keys = enc.keys[:0]
+	for k := range e.Fields {
+		enc.keys = append(enc.keys, k)
+	}
+	enc.keys = append(enc.keys, keys.msg, keys.level)
+	if keys.timeFormat != "" {
+		enc.keys = append(enc.keys, keys.time)
+	}
+	slices.Sort(enc.keys)
+
+	buf := append(enc.buf[:0], '{')
+	for i, k := range enc.keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendString(buf, k, keys.escapeHTML)
+		buf = append(buf, ':')
+		switch k {
+		case keys.msg:
+			buf = appendString(buf, e.Message, keys.escapeHTML)
+			continue
+		case keys.level:
+			switch {
+			case keys.levels == nil:
+				buf = appendString(buf, logrusLevel(e.Level).String(), keys.escapeHTML)
+			case keys.levels.numeric:
+				buf = append(buf, keys.levels.text(e.Level)...)
+			default:
+				buf = appendString(buf, keys.levels.text(e.Level), keys.escapeHTML)
+			}
+			continue
+		case keys.time:
+			if keys.timeFormat != "" {
+				buf = append(buf, '"')
+				buf = e.Time.AppendFormat(buf, keys.timeFormat)
+				buf = append(buf, '"')
+				continue
+			}
+		}
+		var ok bool
+		if buf, ok = appendValue(buf, e.Fields[k], keys.escapeHTML); !ok {
+			enc.buf = buf
+			return false
+		}
+	}
+	buf = append(buf, '}', '\n')
+	enc.buf = buf
+	_, _ = l.Out.Write(buf)
+	return true
+}
+
+// appendValue appends a JSON encoded field value as encoding/json would,
+// reporting false if it cannot be encoded
+func appendValue(buf []byte, value interface{}, escapeHTML bool) ([]byte, bool) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...), true
+	case string:
+		return appendString(buf, v, escapeHTML), true
+	case bool:
+		return strconv.AppendBool(buf, v), true
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), true
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), true
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), true
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), true
+	case int64:
+		return strconv.AppendInt(buf, v, 10), true
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), true
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), true
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), true
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), true
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), true
+	case float64:
+		return appendFloat(buf, v, 64)
+	case float32:
+		return appendFloat(buf, float64(v), 32)
+	case error:
+		// logrus writes errors as their message
+		return appendString(buf, v.Error(), escapeHTML), true
+	}
+	encoded, err := marshal(value, escapeHTML)
+	if err != nil {
+		return buf, false
+	}
+	return append(buf, encoded...), true
+}
+
+// marshal encodes a value of another type with encoding/json
+func marshal(value interface{}, escapeHTML bool) ([]byte, error) {
+	if escapeHTML {
+		return json.Marshal(value)
+	}
+	var b jsonBuffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return b[:len(b)-1], nil
+}
+
+// jsonBuffer collects the output of a JSON encoder
+type jsonBuffer []byte
+
+// Write appends p
+func (b *jsonBuffer) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+// appendFloat appends a float as encoding/json would, reporting false for
+// the values JSON cannot represent
+func appendFloat(buf []byte, f float64, bits int) ([]byte, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return buf, false
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(buf) - start
+		if n >= 4 && buf[len(buf)-4] == 'e' && buf[len(buf)-3] == '-' && buf[len(buf)-2] == '0' {
+			buf[len(buf)-2] = buf[len(buf)-1]
+			buf = buf[:len(buf)-1]
+		}
+	}
+	return buf, true
+}
+
+// hex are the digits of escaped characters
+const hex = "0123456789abcdef"
+
+// appendString appends a JSON string as encoding/json would: control
+// characters, invalid UTF-8, U+2028 and U+2029 escaped, and <, > and & too
+// if escapeHTML
+func appendString(buf []byte, s string, escapeHTML bool) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && (!escapeHTML || (b != '<' && b != '>' && b != '&')) {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
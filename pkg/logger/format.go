@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of local logs
+const (
+	// FormatJSON writes one JSON object per line
+	FormatJSON = "json"
+	// FormatLogfmt writes key=value pairs
+	FormatLogfmt = "logfmt"
+	// FormatPlain writes human readable lines: time, level, message, fields
+	FormatPlain = "plain"
+)
+
+// FormatInfo describes an output format
+type FormatInfo struct {
+	Name        string
+	Description string
+}
+
+// Formats returns the supported output formats
+func Formats() []FormatInfo {
+	return []FormatInfo{
+		{FormatJSON, "One JSON object per line (default)"},
+		{FormatLogfmt, "logfmt key=value pairs"},
+		{FormatPlain, "Human readable lines: time, level, message, then the fields"},
+	}
+}
+
+// Level formats of local logs
+const (
+	// LevelLower writes debug, info, warning and error
+	LevelLower = "lower"
+	// LevelUpper writes DEBUG, INFO, WARNING and ERROR
+	LevelUpper = "upper"
+	// LevelShort writes debug, info, warn and error
+	LevelShort = "short"
+	// LevelPython writes the names of Python's logging levels
+	LevelPython = "python"
+	// LevelLog4j writes DEBUG, INFO, WARN and ERROR
+	LevelLog4j = "log4j"
+	// LevelJava writes the names of java.util.logging's levels
+	LevelJava = "java"
+	// LevelSyslog writes the numeric syslog severities
+	LevelSyslog = "syslog"
+	// LevelNumeric writes the numeric levels of pino and bunyan
+	LevelNumeric = "numeric"
+)
+
+// levelFormat renders the levels of local logs
+type levelFormat struct {
+	names   [4]string // in the order of levels
+	numeric bool      // written as JSON numbers
+}
+
+// levelFormats holds the level formats by name
+var levelFormats = map[string]levelFormat{
+	LevelLower:   {names: [4]string{"debug", "info", "warning", "error"}},
+	LevelUpper:   {names: [4]string{"DEBUG", "INFO", "WARNING", "ERROR"}},
+	LevelShort:   {names: [4]string{"debug", "info", "warn", "error"}},
+	LevelPython:  {names: [4]string{"DEBUG", "INFO", "WARNING", "ERROR"}},
+	LevelLog4j:   {names: [4]string{"DEBUG", "INFO", "WARN", "ERROR"}},
+	LevelJava:    {names: [4]string{"FINE", "INFO", "WARNING", "SEVERE"}},
+	LevelSyslog:  {names: [4]string{"7", "6", "4", "3"}, numeric: true},
+	LevelNumeric: {names: [4]string{"20", "30", "40", "50"}, numeric: true},
+}
+
+// LevelFormats returns the supported level formats
+func LevelFormats() []FormatInfo {
+	return []FormatInfo{
+		{LevelLower, "debug, info, warning, error (default, but for plain)"},
+		{LevelUpper, "DEBUG, INFO, WARNING, ERROR"},
+		{LevelShort, "debug, info, warn, error, as zap and Go's slog spell them"},
+		{LevelPython, "Python's logging: DEBUG, INFO, WARNING, ERROR"},
+		{LevelLog4j, "Log4j and Logback: DEBUG, INFO, WARN, ERROR"},
+		{LevelJava, "java.util.logging: FINE, INFO, WARNING, SEVERE"},
+		{LevelSyslog, "Syslog severities: 7, 6, 4, 3"},
+		{LevelNumeric, "Numeric levels of pino and bunyan: 20, 30, 40, 50"},
+	}
+}
+
+// parseLevelFormat returns the level format of a name, nil if empty: the
+// formatters then write levels on their own
+func parseLevelFormat(name string) (*levelFormat, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return nil, nil
+	}
+	f, ok := levelFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown level format %q", name)
+	}
+	return &f, nil
+}
+
+// text returns the rendering of a level
+func (f *levelFormat) text(level LogLevel) string {
+	for i, lv := range levels {
+		if lv == level {
+			return f.names[i]
+		}
+	}
+	return string(level)
+}
+
+// logrusText returns the rendering of a logrus level, as its own name unless a
+// level format is set
+func (f *levelFormat) logrusText(level logrus.Level) string {
+	if f == nil {
+		return level.String()
+	}
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return f.text(Debug)
+	case logrus.InfoLevel:
+		return f.text(Info)
+	case logrus.WarnLevel:
+		return f.text(Warn)
+	}
+	return f.text(Error)
+}
+
+// newFormatter returns the formatter for an output format. ownsTime tells
+// whether the generated timestamp field takes the key of logrus' own.
+func newFormatter(format string, ownsTime bool) (logrus.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		formatter := &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}
+		// Let the generated field own the key if it collides with logrus' own
+		if ownsTime {
+			formatter.DisableTimestamp = true
+			formatter.FieldMap = logrus.FieldMap{logrus.FieldKeyTime: "@" + logrus.FieldKeyTime}
+		}
+		return formatter, nil
+	case FormatLogfmt:
+		formatter := &logrus.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  time.RFC3339Nano,
+			QuoteEmptyFields: true,
+		}
+		if ownsTime {
+			formatter.DisableTimestamp = true
+			formatter.FieldMap = logrus.FieldMap{logrus.FieldKeyTime: "@" + logrus.FieldKeyTime}
+		}
+		return formatter, nil
+	case FormatPlain:
+		return &plainFormatter{disableTimestamp: ownsTime}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (use json, logfmt or plain)", format)
+}
+
+// withLevels makes a formatter render levels in a level format, if not nil
+func withLevels(formatter logrus.Formatter, levels *levelFormat) logrus.Formatter {
+	if levels == nil {
+		return formatter
+	}
+	switch f := formatter.(type) {
+	case *plainFormatter:
+		f.levels = levels
+		return f
+	case *logrus.JSONFormatter:
+		return &levelFormatter{Formatter: f, levels: levels, key: fieldKey(f.FieldMap, logrus.FieldKeyLevel), json: true, escapeHTML: !f.DisableHTMLEscape}
+	case *logrus.TextFormatter:
+		return &levelFormatter{Formatter: f, levels: levels, key: fieldKey(f.FieldMap, logrus.FieldKeyLevel)}
+	}
+	return formatter
+}
+
+// fieldKey returns the key a field map renames a logrus key to
+func fieldKey(fieldMap logrus.FieldMap, key string) string {
+	for k, name := range fieldMap {
+		if string(k) == key {
+			return name
+		}
+	}
+	return key
+}
+
+// levelFormatter rewrites the level a logrus formatter writes by its own
+// name. It only formats the logs the encoder leaves to logrus.
+type levelFormatter struct {
+	logrus.Formatter
+	levels     *levelFormat
+	key        string
+	json       bool
+	escapeHTML bool
+}
+
+// Format renders an entry with the formatter, then replaces its level
+func (f *levelFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	line, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	level := f.levels.logrusText(entry.Level)
+	if f.json {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return nil, err
+		}
+		value := []byte(level)
+		if !f.levels.numeric {
+			value = appendString(nil, level, f.escapeHTML)
+		}
+		doc[f.key] = value
+		encoded, err := marshal(doc, f.escapeHTML)
+		if err != nil {
+			return nil, err
+		}
+		return append(encoded, '\n'), nil
+	}
+
+	// The level leads the pairs, after the time if any
+	pair := []byte(f.key + "=" + entry.Level.String())
+	i := bytes.Index(line, pair)
+	for i > 0 && line[i-1] != ' ' {
+		next := bytes.Index(line[i+1:], pair)
+		if next < 0 {
+			return line, nil
+		}
+		i += 1 + next
+	}
+	if i < 0 {
+		return line, nil
+	}
+	return slices.Concat(line[:i], []byte(f.key+"="+level), line[i+len(pair):]), nil
+}
+
+// nestingFormatter makes a logrus text formatter write the nested values of
+// fields as compact JSON rather than Go's map and slice syntax
+type nestingFormatter struct {
+	logrus.Formatter
+}
+
+// Format renders an entry with its nested values encoded
+func (f *nestingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var data logrus.Fields
+	for k, v := range entry.Data {
+		if text, nested := nestedText(v); nested {
+			if data == nil {
+				data = make(logrus.Fields, len(entry.Data))
+				for k, v := range entry.Data {
+					data[k] = v
+				}
+			}
+			data[k] = text
+		}
+	}
+	if data == nil {
+		return f.Formatter.Format(entry)
+	}
+	encoded := *entry
+	encoded.Data = data
+	return f.Formatter.Format(&encoded)
+}
+
+// nestedText encodes a nested value, a map or a slice, as compact JSON for
+// text formats, reporting whether it was one
+func nestedText(v interface{}) (string, bool) {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		if encoded, err := marshal(v, false); err == nil {
+			return string(encoded), true
+		}
+	}
+	return "", false
+}
+
+// plainFormatter renders human readable lines
+type plainFormatter struct {
+	disableTimestamp bool
+	levels           *levelFormat // nil writes logrus' names
+}
+
+// Format renders the time, level, message and the sorted fields of an entry
+func (f *plainFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	if !f.disableTimestamp {
+		b.WriteString(entry.Time.Format(time.RFC3339Nano))
+		b.WriteByte(' ')
+	}
+	level := strings.ToUpper(entry.Level.String())
+	if f.levels != nil {
+		level = f.levels.logrusText(entry.Level)
+	}
+	fmt.Fprintf(&b, "%-7s %s", level, entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, nested := nestedText(entry.Data[k])
+		if !nested {
+			value = fmt.Sprint(entry.Data[k])
+		}
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, value)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
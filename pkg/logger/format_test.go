@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNestedTextFormats(t *testing.T) {
+	nested := map[string]interface{}{"a": []interface{}{1, "x"}, "b": map[string]interface{}{"c": true}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatLogfmt, `payload="{\"a\":[1,\"x\"],\"b\":{\"c\":true}}"`},
+		{FormatPlain, `payload="{\"a\":[1,\"x\"],\"b\":{\"c\":true}}"`},
+		{FormatJSON, `"payload":{"a":[1,"x"],"b":{"c":true}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			formatter, err := newFormatter(tt.format, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, text := formatter.(*logrus.TextFormatter); text {
+				formatter = &nestingFormatter{formatter}
+			}
+			entry := &logrus.Entry{
+				Time:    time.Now(),
+				Level:   logrus.InfoLevel,
+				Message: "m",
+				Data:    logrus.Fields{"payload": nested, "n": 1},
+			}
+			line, err := formatter.Format(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(line), tt.want) {
+				t.Errorf("%s wrote %s, want %s in it", tt.format, line, tt.want)
+			}
+			if _, ok := entry.Data["payload"].(map[string]interface{}); !ok {
+				t.Errorf("%s changed the fields of the entry", tt.format)
+			}
+		})
+	}
+}
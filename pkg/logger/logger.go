@@ -1,12 +1,25 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rjonczy/log-genie/pkg/catalog"
+	"github.com/rjonczy/log-genie/pkg/clock"
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/identity"
+	"github.com/rjonczy/log-genie/pkg/integrity"
+	"github.com/rjonczy/log-genie/pkg/markov"
+	"github.com/rjonczy/log-genie/pkg/schema"
+	"github.com/rjonczy/log-genie/pkg/stats"
 	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/tenant"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,19 +29,116 @@ type Logger struct {
 	telemetryEnabled bool
 	telemetry        *telemetry.Provider
 	localLogEnabled  bool
+	countOnly        bool // local logs are counted, not serialized
+	clock            *clock.Clock
+	timestampField   string
+	timestampFormat  clock.Format
+	sequencer        *integrity.Sequencer // nil unless sequence numbers are enabled
+	hook             EmitFunc             // nil unless another sink receives the logs
+	transforms       Transforms           // by sink name
+	processor        Transform            // nil unless logs are processed before the sinks
+	outputSink       string               // name of the local output in the stats
+	source           Source               // nil unless the logs come from outside
+	checksum         bool
+	markov           *markov.Chain  // nil unless a message corpus is configured
+	schema           *schema.Schema // nil unless a learned schema is configured
+	messageSource    string
+	messages         map[LogLevel][]string
+	identities       *identity.Pool // nil unless logs come from a fixed fleet
+	tenants          *tenant.Pool   // nil unless logs are tagged with tenants
+	repeater         *repeater
+	severityAttrs    bool
+	nested           NestedConfig
+	keyCollisions    bool
+	wide             *attributePool // nil unless logs carry generated attributes
+	messageSize      int
+	limit            int64     // Maximum number of logs to emit, 0 for unlimited
+	pool             *pool     // nil unless events are pregenerated
+	capture          *[]*event // collects generated events instead of emitting them while pregenerating
+	levelWeights     atomic.Pointer[map[LogLevel]float64]
+	attributes       atomic.Pointer[map[string]string]
+	bytesEmitted     atomic.Int64
+	logsEmitted      atomic.Int64
+	logsFiltered     atomic.Int64 // dropped by the processor
+	stats            *stats.Registry
+	levelsEmitted    [4]*stats.Counter // counters of levels, in the order of levels
+	output           *countingWriter   // counts local writes
+	buffer           *bufferedWriter   // nil unless local writes are buffered
+	drainTimeout     time.Duration     // bounds each sink's drain on shutdown
+	jsonKeys         *jsonKeys         // nil unless local logs are JSON, written by the encoder
+	otlp             *stats.Sink       // nil unless telemetry is enabled
+	sendFailed       sync.Once         // reports the first log the telemetry provider refused
 }
 
 // Config holds the configuration for the logger
 type Config struct {
 	Verbosity         string
-	Rate              int
+	Rate              float64
 	TelemetryEnabled  bool
 	TelemetryEndpoint string
 	LocalLogEnabled   bool
 	ShowResponses     bool
-	ApplicationID     string // Application ID for OTEL resource attributes
+	ApplicationID     string                // Application ID for OTEL resource attributes
+	ClockOffset       time.Duration         // Skew applied to generated timestamps
+	Timezone          string                // Timezone of generated timestamps
+	TimestampField    string                // Name of the generated timestamp field
+	TimestampFormat   string                // Format of the generated timestamp field, "none" to omit it
+	StreamID          string                // Stream identifier embedded alongside sequence numbers
+	Sequence          bool                  // Embed per-stream sequence numbers
+	Checksum          bool                  // Embed a payload checksum
+	MessageCorpus     string                // Corpus file to train the Markov message generator on
+	MarkovOrder       int                   // Number of words the Markov chain conditions on
+	SchemaFile        string                // Learned schema to generate events from
+	MessageSource     string                // Message generator: catalog or sentence
+	Messages          map[LogLevel][]string // Message templates replacing the catalog's, with {placeholder} fakes
+	Identities        *identity.Pool        // Services, hosts and pods the logs come from (a random service per log if nil)
+	Tenants           *tenant.Pool          // Tenants the logs are tagged with (none if nil)
+	Repeat            RepeatConfig          // Bursts of identical messages
+	SeverityAttrs     bool                  // Add attributes typical for each severity
+	Nested            NestedConfig          // Nested objects and arrays in the fields or body
+	KeyCollisions     bool                  // Add field names that clash once normalized or expanded, such as http.status and http_status
+	AttributeCount    AttributeCountConfig  // Generated attributes of each log, drawn from a pool of keys
+	MessageSize       int                   // Pad or truncate messages to this many bytes (0 keeps them as generated)
+	Limit             int64                 // Stop emitting after this many logs (0 for unlimited)
+	Pregenerate       int                   // Cycle through this many pregenerated logs of each kind (0 generates every log)
+	Restamp           bool                  // Give pregenerated logs a fresh timestamp when emitted
+	LevelWeights      map[LogLevel]float64  // Relative weights of generated levels (nil picks uniformly)
+	Attributes        map[string]string     // Static attributes added to every log
+	Format            string                // Output format of local logs: json, logfmt or plain
+	LevelFormat       string                // How local logs write levels, e.g. upper or syslog (empty leaves it to the format)
+	Output            io.Writer             // Destination of local logs, stdout if nil
+	OutputSink        string                // Name local logs are counted under, "stdout" if empty
+	CountOnly         bool                  // Count local logs without serializing or writing them
+	BufferSize        int                   // Buffer up to this many bytes of local logs (0 writes every log)
+	FlushInterval     time.Duration         // Write out buffered local logs at least this often (0 only when the buffer fills)
+	Queue             int                   // Queue up to this many local logs for writer goroutines (0 writes them while generating)
+	QueueWriters      int                   // Number of goroutines writing queued local logs (default 1)
+	Overflow          string                // What a full queue does to a local log: block (default), drop or drop-oldest
+	DrainTimeout      time.Duration         // How long Shutdown waits for queued logs to be written and exported (default 5s)
+	Stats             *stats.Registry       // Registry to count logs into (a private one if nil)
+	Emit              EmitFunc              // Receives every emitted log besides telemetry and local logs
+	Source            Source                // Generates the logs in place of the built-in generators
+	Transforms        Transforms            // Rewrite logs before the named sinks take them
+	Process           Transform             // Runs on every log before all sinks, dropping it if false; must not keep the fields, which are reused
 }
 
+// Source generates logs in place of the built-in generators, e.g. from a
+// plugin. errorLog asks for a dedicated error log. A zero timestamp is
+// replaced by the current time and an unknown level by info.
+type Source func(errorLog bool) (timestamp time.Time, level LogLevel, message string, fields map[string]interface{})
+
+// EmitFunc receives a log as it is emitted, stamped and sequenced. The
+// fields are also sent to the other sinks, so they must not be modified.
+type EmitFunc func(timestamp time.Time, level LogLevel, message string, fields map[string]interface{})
+
+// Message sources
+const (
+	// MessagesCatalog draws level-appropriate messages from the built-in catalog
+	MessagesCatalog = "catalog"
+	// MessagesSentence generates random sentences regardless of level
+	MessagesSentence = "sentence"
+)
+
 // LogLevel represents the level of logging
 type LogLevel string
 
@@ -45,11 +155,79 @@ const (
 
 // New creates a new logger with the given configuration
 func New(config Config) (*Logger, error) {
-	logger := logrus.New()
-	logger.SetOutput(os.Stdout)
-	logger.SetFormatter(&logrus.JSONFormatter{
-		TimestampFormat: time.RFC3339Nano,
+	logClock, err := clock.New(clock.Config{
+		Offset:   config.ClockOffset,
+		Timezone: config.Timezone,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestampFormat, err := clock.ParseFormat(config.TimestampFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain *markov.Chain
+	if config.MessageCorpus != "" {
+		chain, err = markov.TrainFile(config.MessageCorpus, config.MarkovOrder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messageSource := strings.ToLower(config.MessageSource)
+	switch messageSource {
+	case "":
+		messageSource = MessagesCatalog
+	case MessagesCatalog, MessagesSentence:
+	default:
+		return nil, fmt.Errorf("unknown message source %q", config.MessageSource)
+	}
+
+	nested := config.Nested
+	if err := nested.validate(); err != nil {
+		return nil, err
+	}
+
+	wide, err := newAttributePool(config.AttributeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var learned *schema.Schema
+	if config.SchemaFile != "" {
+		learned, err = schema.Load(config.SchemaFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timestampField := config.TimestampField
+	if timestampField == "" {
+		timestampField = "timestamp"
+	}
+
+	formatter, err := newFormatter(config.Format, timestampField == logrus.FieldKeyTime && !timestampFormat.Absent())
+	if err != nil {
+		return nil, err
+	}
+
+	levelFormat, err := parseLevelFormat(config.LevelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logrus.New()
+	if _, text := formatter.(*logrus.TextFormatter); text {
+		logger.SetFormatter(&nestingFormatter{withLevels(formatter, levelFormat)})
+	} else {
+		logger.SetFormatter(withLevels(formatter, levelFormat))
+	}
+	jsonKeys, _ := newJSONKeys(formatter)
+	if jsonKeys != nil {
+		jsonKeys.levels = levelFormat
+	}
 
 	// Set log level
 	switch strings.ToLower(config.Verbosity) {
@@ -68,136 +246,564 @@ func New(config Config) (*Logger, error) {
 	l := &Logger{
 		Logger:           logger,
 		telemetryEnabled: config.TelemetryEnabled,
-		localLogEnabled:  config.LocalLogEnabled || !config.TelemetryEnabled, // If telemetry is disabled, local logs are always enabled
+		localLogEnabled:  config.LocalLogEnabled || (!config.TelemetryEnabled && config.Emit == nil), // Logs always go somewhere
+		countOnly:        config.CountOnly,
+		clock:            logClock,
+		timestampField:   timestampField,
+		timestampFormat:  timestampFormat,
+		checksum:         config.Checksum,
+		hook:             config.Emit,
+		transforms:       config.Transforms,
+		processor:        config.Process,
+		source:           config.Source,
+		markov:           chain,
+		schema:           learned,
+		messageSource:    messageSource,
+		messages:         config.Messages,
+		identities:       config.Identities,
+		tenants:          config.Tenants,
+		repeater:         &repeater{config: config.Repeat},
+		severityAttrs:    config.SeverityAttrs,
+		nested:           nested,
+		keyCollisions:    config.KeyCollisions,
+		wide:             wide,
+		messageSize:      config.MessageSize,
+		limit:            config.Limit,
+		jsonKeys:         jsonKeys,
+		drainTimeout:     config.DrainTimeout,
+	}
+	if l.drainTimeout <= 0 {
+		l.drainTimeout = defaultDrainTimeout
+	}
+	l.stats = config.Stats
+	if l.stats == nil {
+		l.stats = stats.New()
+	}
+	for i, level := range levels {
+		l.levelsEmitted[i] = l.stats.Level(string(level))
+	}
+	output := config.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	outputSink := config.OutputSink
+	if outputSink == "" {
+		outputSink = "stdout"
+	}
+	l.outputSink = outputSink
+	if config.BufferSize > 0 {
+		l.buffer = newBufferedWriter(output, config.BufferSize, config.FlushInterval)
+		output = l.buffer
+	}
+	l.output = &countingWriter{w: output, count: &l.bytesEmitted}
+	if config.Queue > 0 {
+		overflow, err := ParseOverflow(config.Overflow)
+		if err != nil {
+			return nil, err
+		}
+		l.output.async = newAsyncWriter(output, config.Queue, config.QueueWriters, overflow)
+	}
+	if l.localLogEnabled {
+		l.output.sink = l.stats.Sink(outputSink)
+		if l.output.async != nil {
+			l.output.sink.SetCapacity(int64(config.Queue))
+		}
+	}
+	logger.SetOutput(l.output)
+	l.SetLevelWeights(config.LevelWeights)
+	l.SetAttributes(config.Attributes)
+
+	if config.Sequence || config.Checksum {
+		streamID := config.StreamID
+		if streamID == "" {
+			streamID = config.ApplicationID
+		}
+		l.sequencer = integrity.NewSequencer(streamID)
 	}
 
 	// Initialize telemetry provider if enabled
 	if config.TelemetryEnabled {
+		l.otlp = l.stats.Sink("otlp")
 		telemetryProvider, err := telemetry.New(telemetry.Config{
 			Enabled:       true,
 			Endpoint:      config.TelemetryEndpoint,
 			ShowResponses: config.ShowResponses,
 			ApplicationID: config.ApplicationID,
+			Stats:         l.otlp,
+			DrainTimeout:  l.drainTimeout,
 		})
 		if err != nil {
-			logger.WithError(err).Error("Failed to initialize telemetry provider, falling back to local logging")
+			diag.Error("Failed to initialize telemetry provider, falling back to local logging", "error", err)
 			l.telemetryEnabled = false
 			l.localLogEnabled = true
+			l.output.sink = l.stats.Sink(outputSink)
+			if l.output.async != nil {
+				l.output.sink.SetCapacity(int64(config.Queue))
+			}
 			return l, err
 		}
 		l.telemetry = telemetryProvider
-		logger.Info("Telemetry provider initialized successfully")
+		diag.Info("Telemetry provider initialized")
+	}
+
+	if config.Pregenerate > 0 {
+		if err := l.pregenerate(config.Pregenerate, config.Restamp); err != nil {
+			return nil, err
+		}
 	}
 
 	return l, nil
 }
 
-// Shutdown gracefully shuts down the logger and its telemetry provider
+// defaultDrainTimeout bounds how long Shutdown waits for each sink to write
+// or export its queued logs, and Flush for the local queue
+const defaultDrainTimeout = 5 * time.Second
+
+// Shutdown drains the sinks of the logger: the local queue, buffer and the
+// telemetry provider write or export the logs they hold, each within the
+// drain timeout; the logs left over count as dropped. Generation should stop
+// first, as the telemetry provider refuses logs once draining.
 func (l *Logger) Shutdown() {
+	if l.output.async != nil && !l.output.async.Close(l.drainTimeout) {
+		diag.Warn("Local output did not drain before the timeout", "timeout", l.drainTimeout.String(), "queued", l.output.async.Len())
+	}
+	if l.buffer != nil {
+		if err := l.buffer.Close(); err != nil {
+			diag.Warn("Failed to write buffered logs", "error", err)
+		}
+	}
 	if l.telemetry != nil {
 		l.telemetry.Shutdown()
 	}
 }
 
+// Flush writes out the local logs queued and buffered so far, e.g. before
+// pausing
+func (l *Logger) Flush() error {
+	if l.output.async != nil {
+		l.output.async.Drain(l.drainTimeout)
+	}
+	if l.buffer == nil {
+		return nil
+	}
+	return l.buffer.Flush()
+}
+
 // GenerateRandomLog generates a random log entry
 func (l *Logger) GenerateRandomLog() {
-	// Generate a random log level
-	levels := []LogLevel{Debug, Info, Warn, Error}
-	level := levels[gofakeit.Number(0, len(levels)-1)]
-
-	// Generate fake data
-	message := gofakeit.Sentence(gofakeit.Number(5, 15))
-	service := gofakeit.AppName()
-	userID := gofakeit.UUID()
-	httpMethod := gofakeit.HTTPMethod()
-	statusCode := gofakeit.HTTPStatusCode()
-	latency := gofakeit.Number(1, 500)
-	ipAddress := gofakeit.IPv4Address()
-
-	// Create log fields map
-	fields := map[string]interface{}{
-		"service":     service,
-		"user_id":     userID,
-		"http_method": httpMethod,
-		"status_code": statusCode,
-		"latency_ms":  latency,
-		"ip_address":  ipAddress,
-		"timestamp":   time.Now().UnixNano(),
+	l.GenerateVariantLog(nil)
+}
+
+// GenerateVariantLog generates a random log entry with the level weights and
+// attributes of a variant. Pregenerated logs ignore the variant.
+func (l *Logger) GenerateVariantLog(v *Variant) {
+	if l.pool != nil {
+		l.emitPregenerated(l.pool.nextEvent(false))
+		return
 	}
 
-	// Send to telemetry if enabled
-	if l.telemetryEnabled && l.telemetry != nil {
-		var telemetryLevel telemetry.LogLevel
-		switch level {
-		case Debug:
-			telemetryLevel = telemetry.DebugLevel
-		case Info:
-			telemetryLevel = telemetry.InfoLevel
-		case Warn:
-			telemetryLevel = telemetry.WarnLevel
-		case Error:
-			telemetryLevel = telemetry.ErrorLevel
-		}
+	if l.emitRepeat(v) {
+		return
+	}
 
-		err := l.telemetry.SendLog(telemetryLevel, message, fields)
-		if err != nil {
-			// If telemetry fails, log the error locally
-			l.WithError(err).Error("Failed to send log to telemetry endpoint")
-		}
+	if l.source != nil {
+		l.generateFromSource(false, v)
+		return
 	}
 
-	// Log locally if enabled or if telemetry is not enabled
-	if l.localLogEnabled {
-		// Create log entry with random fields
-		logEntry := l.WithFields(logrus.Fields(fields))
-
-		// Log at the random level
-		switch level {
-		case Debug:
-			logEntry.Debug(message)
-		case Info:
-			logEntry.Info(message)
-		case Warn:
-			logEntry.Warn(message)
-		case Error:
-			logEntry.Error(message)
+	if l.schema != nil {
+		// A learned level below the verbosity would be dropped
+		level, ok := ParseLevel(l.schema.RandomLevel())
+		if !ok || !l.IsLevelEnabled(logrusLevel(level)) {
+			level = l.randomLevel(v)
 		}
+		l.generateFromSchema(level, v)
+		return
 	}
+
+	// Generate a random log level
+	level := l.randomLevel(v)
+
+	timestamp := l.clock.Now()
+
+	// Create log fields map, reusing the one of an earlier log
+	fields := newFields()
+	l.identify(fields)
+	fields["user_id"] = gofakeit.UUID()
+	fields["http_method"] = gofakeit.HTTPMethod()
+	fields["ip_address"] = gofakeit.IPv4Address()
+
+	// The message quotes the fields above and sets those it makes up, e.g.
+	// the latency of a slow query, so it cannot contradict them
+	message := l.newMessage(level, fields)
+	if _, ok := fields["status_code"]; !ok {
+		fields["status_code"] = catalog.Status(string(level))
+	}
+	if _, ok := fields["latency_ms"]; !ok {
+		fields["latency_ms"] = gofakeit.Number(1, 500)
+	}
+	l.enrich(level, fields)
+	l.collide(fields)
+	l.widen(fields)
+	message = l.nest(message, fields)
+
+	l.emit(v, timestamp, level, message, l.repeater.maybeStart(level, message, fields))
+	l.releaseFields(fields)
 }
 
 // GenerateRandomErrorLog generates a random error log entry
 func (l *Logger) GenerateRandomErrorLog() {
-	// Generate fake data
-	errorMessage := gofakeit.SentenceSimple()
-	service := gofakeit.AppName()
-	requestID := gofakeit.UUID()
-	errorCode := gofakeit.Number(400, 599)
-	stackTrace := gofakeit.LoremIpsumSentence(5)
-
-	// Create fields map
-	fields := map[string]interface{}{
-		"service":     service,
-		"request_id":  requestID,
-		"error_code":  errorCode,
-		"stack_trace": stackTrace,
-		"timestamp":   time.Now().UnixNano(),
+	l.GenerateVariantErrorLog(nil)
+}
+
+// GenerateVariantErrorLog generates a random error log entry with the
+// attributes of a variant. Pregenerated logs ignore the variant.
+func (l *Logger) GenerateVariantErrorLog(v *Variant) {
+	if l.pool != nil {
+		l.emitPregenerated(l.pool.nextEvent(true))
+		return
+	}
+
+	if l.emitRepeat(v) {
+		return
+	}
+
+	if l.source != nil {
+		l.generateFromSource(true, v)
+		return
+	}
+
+	if l.schema != nil {
+		l.generateFromSchema(Error, v)
+		return
+	}
+
+	timestamp := l.clock.Now()
+
+	// Create fields map, reusing the one of an earlier log
+	fields := newFields()
+	l.identify(fields)
+	fields["request_id"] = gofakeit.UUID()
+
+	// A status the message reports is the error code too
+	errorMessage := l.newMessage(Error, fields)
+	errorCode, ok := fields["status_code"].(int)
+	if !ok {
+		errorCode = gofakeit.Number(400, 599)
+	}
+	fields["error_code"] = errorCode
+	fields["stack_trace"] = gofakeit.LoremIpsumSentence(5)
+	l.enrich(Error, fields)
+	l.collide(fields)
+	l.widen(fields)
+	errorMessage = l.nest(errorMessage, fields)
+
+	l.emit(v, timestamp, Error, errorMessage, l.repeater.maybeStart(Error, errorMessage, fields))
+	l.releaseFields(fields)
+}
+
+// Replay emits a log read back from a file instead of a generated one. The
+// level is parsed like ParseLevel (info if unknown) and a zero timestamp
+// is replaced by the current time.
+func (l *Logger) Replay(timestamp time.Time, level string, message string, fields map[string]interface{}) {
+	logLevel, ok := ParseLevel(level)
+	if !ok {
+		logLevel = Info
+	}
+	if timestamp.IsZero() {
+		timestamp = l.clock.Now()
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	// Captured integrity fields would repeat on every replay; fresh ones are
+	// embedded if enabled
+	delete(fields, integrity.StreamField)
+	delete(fields, integrity.SequenceField)
+	delete(fields, integrity.ChecksumField)
+	l.emit(nil, timestamp, logLevel, message, fields)
+}
+
+// generateFromSchema generates a log entry following the learned schema
+func (l *Logger) generateFromSchema(level LogLevel, v *Variant) {
+	fields := l.schema.Generate()
+	// Generated timestamps and integrity fields take precedence over learned ones
+	for _, name := range l.schema.TimestampFields() {
+		delete(fields, name)
+	}
+	delete(fields, l.timestampField)
+	delete(fields, integrity.StreamField)
+	delete(fields, integrity.SequenceField)
+	delete(fields, integrity.ChecksumField)
+
+	message := l.newMessage(level, nil)
+	l.emit(v, l.clock.Now(), level, message, l.repeater.maybeStart(level, message, fields))
+}
+
+// generateFromSource emits a log of the configured source
+func (l *Logger) generateFromSource(errorLog bool, v *Variant) {
+	timestamp, level, message, fields := l.source(errorLog)
+	level, ok := ParseLevel(string(level))
+	if !ok {
+		level = Info
+	}
+	if timestamp.IsZero() {
+		timestamp = l.clock.Now()
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	// Integrity fields are the generator's own
+	delete(fields, integrity.StreamField)
+	delete(fields, integrity.SequenceField)
+	delete(fields, integrity.ChecksumField)
+	l.emit(v, timestamp, level, message, l.repeater.maybeStart(level, message, fields))
+}
+
+// identify sets the service a log comes from, with its host and pod when
+// the logs come from an identity pool
+func (l *Logger) identify(fields map[string]interface{}) {
+	if l.identities == nil {
+		fields["service"] = gofakeit.AppName()
+		return
+	}
+	id := l.identities.Pick()
+	fields["service"] = id.Service
+	fields["host"] = id.Host
+	fields["pod"] = id.Pod
+}
+
+// enrich adds severity-correlated attributes to the fields when enabled
+func (l *Logger) enrich(level LogLevel, fields map[string]interface{}) {
+	if !l.severityAttrs {
+		return
+	}
+	catalog.AddAttributes(string(level), fields)
+}
+
+// emitRepeat emits the next log of an active repeat burst, reporting whether it did
+func (l *Logger) emitRepeat(v *Variant) bool {
+	level, message, fields, ok := l.repeater.next()
+	if !ok {
+		return false
+	}
+	l.emit(v, l.clock.Now(), level, message, fields)
+	return true
+}
+
+// newMessage generates a log message, from the Markov chain when one is
+// trained, otherwise from the configured message source. Templates quote
+// and set the fields, as catalog.Render does; fields may be nil.
+func (l *Logger) newMessage(level LogLevel, fields map[string]interface{}) string {
+	return fitMessage(l.generateMessage(level, fields), l.messageSize)
+}
+
+// generateMessage generates a log message of natural length
+func (l *Logger) generateMessage(level LogLevel, fields map[string]interface{}) string {
+	if l.markov != nil {
+		if message := l.markov.Generate(30); message != "" {
+			return message
+		}
+	}
+
+	if templates := l.messages[level]; len(templates) > 0 {
+		return catalog.Render(templates[gofakeit.Number(0, len(templates)-1)], fields)
+	}
+	if l.messageSource == MessagesSentence {
+		return gofakeit.Sentence(gofakeit.Number(5, 15))
+	}
+	return catalog.Message(string(level), fields)
+}
+
+// emit sends a generated log to telemetry and/or the local output, with the
+// attributes of the variant if not nil
+func (l *Logger) emit(v *Variant, timestamp time.Time, level LogLevel, message string, fields map[string]interface{}) {
+	if v != nil && v.Location != nil {
+		timestamp = timestamp.In(v.Location)
+	}
+
+	// Logs that come with a tenant keep it
+	if _, ok := fields[tenant.Field]; !ok && l.tenants != nil {
+		fields[tenant.Field] = l.tenants.Pick()
+	}
+
+	if l.capture != nil {
+		*l.capture = append(*l.capture, &event{timestamp: timestamp, level: level, message: message, fields: fields})
+		return
+	}
+
+	// Drop logs below the configured verbosity before they consume a sequence number
+	if !l.IsLevelEnabled(logrusLevel(level)) {
+		return
+	}
+
+	// Claim a slot before sequencing so a limited run emits exactly its limit
+	if !l.reserve() {
+		return
+	}
+	l.countLevel(level)
+
+	l.addAttributes(fields, v)
+
+	if !l.timestampFormat.Absent() {
+		fields[l.timestampField] = l.timestampFormat.Value(timestamp)
+	}
+
+	// Embed stream ID, sequence number and checksum for loss/duplication detection
+	if l.sequencer != nil {
+		seq := l.sequencer.Next()
+		fields[integrity.StreamField] = l.sequencer.Stream()
+		fields[integrity.SequenceField] = int64(seq)
+		if l.checksum {
+			fields[integrity.ChecksumField] = integrity.Checksum(l.sequencer.Stream(), seq, message)
+		}
+	}
+
+	e := Entry{Time: timestamp, Level: level, Message: message, Fields: fields}
+	if l.process(&e) {
+		l.deliver(e)
+	}
+}
+
+// deliver hands an emitted log to every sink
+func (l *Logger) deliver(e Entry) {
+	if l.hook != nil {
+		l.hook(e.Time, e.Level, e.Message, e.Fields)
 	}
 
 	// Send to telemetry if enabled
 	if l.telemetryEnabled && l.telemetry != nil {
-		err := l.telemetry.SendLog(telemetry.ErrorLevel, errorMessage, fields)
-		if err != nil {
-			// If telemetry fails, log the error locally
-			l.WithError(err).Error("Failed to send error log to telemetry endpoint")
-		}
+		l.sendTelemetry(e)
+	}
+
+	// Local writes are counted by the output writer; estimate the rest
+	if !l.localLogEnabled {
+		l.bytesEmitted.Add(estimateSize(e.Message, e.Fields))
 	}
 
 	// Log locally if enabled or if telemetry is not enabled
 	if l.localLogEnabled {
-		// Create log entry with random fields
-		logEntry := l.WithFields(logrus.Fields(fields))
-		logEntry.Error(errorMessage)
+		l.writeLocal(e)
+	}
+}
+
+// sendTelemetry hands a log to the telemetry provider, transformed for it
+func (l *Logger) sendTelemetry(e Entry) {
+	if !l.transforms.Apply("otlp", &e) {
+		return
 	}
+	if err := l.telemetry.SendLogAt(e.Time, telemetryLevel(e.Level), e.Message, e.Fields); err != nil {
+		l.refused(err)
+	}
+}
+
+// writeLocal writes a log to the local output, transformed for it
+func (l *Logger) writeLocal(e Entry) {
+	if !l.transforms.Apply(l.outputSink, &e) {
+		return
+	}
+	if l.countOnly {
+		l.countLocal(estimateSize(e.Message, e.Fields))
+		return
+	}
+	if l.jsonKeys != nil && l.writeJSON(e) {
+		return
+	}
+	l.WithFields(logrus.Fields(e.Fields)).WithTime(e.Time).Log(logrusLevel(e.Level), e.Message)
+}
+
+// countLocal counts a local log of about size bytes as written, without
+// serializing it
+func (l *Logger) countLocal(size int64) {
+	l.bytesEmitted.Add(size)
+	l.output.sink.Deliver(1, nil)
+}
+
+// reserve counts a log about to be emitted, refusing once the limit is reached
+func (l *Logger) reserve() bool {
+	for {
+		emitted := l.logsEmitted.Load()
+		if l.limit > 0 && emitted >= l.limit {
+			return false
+		}
+		if l.logsEmitted.CompareAndSwap(emitted, emitted+1) {
+			return true
+		}
+	}
+}
+
+// ParseLevel maps common level spellings (WARNING, err, fatal, trace...) to a LogLevel
+func ParseLevel(level string) (LogLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "trace", "debug", "dbg", "verbose":
+		return Debug, true
+	case "info", "information", "notice", "inf":
+		return Info, true
+	case "warn", "warning", "wrn":
+		return Warn, true
+	case "error", "err", "fatal", "critical", "crit", "panic", "alert", "emerg", "emergency":
+		return Error, true
+	}
+	return "", false
+}
+
+// logrusLevel converts a LogLevel to the matching logrus level
+func logrusLevel(level LogLevel) logrus.Level {
+	switch level {
+	case Debug:
+		return logrus.DebugLevel
+	case Warn:
+		return logrus.WarnLevel
+	case Error:
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// telemetryLevel converts a LogLevel to the matching telemetry level
+func telemetryLevel(level LogLevel) telemetry.LogLevel {
+	switch level {
+	case Debug:
+		return telemetry.DebugLevel
+	case Warn:
+		return telemetry.WarnLevel
+	case Error:
+		return telemetry.ErrorLevel
+	default:
+		return telemetry.InfoLevel
+	}
+}
+
+// LogsEmitted returns the total number of generated logs emitted
+func (l *Logger) LogsEmitted() int64 {
+	return l.logsEmitted.Load()
+}
+
+// Stream returns the stream ID embedded with sequence numbers, empty unless
+// they are enabled
+func (l *Logger) Stream() string {
+	if l.sequencer == nil {
+		return ""
+	}
+	return l.sequencer.Stream()
+}
+
+// Exhausted reports whether the configured log limit has been reached
+func (l *Logger) Exhausted() bool {
+	return l.limit > 0 && l.logsEmitted.Load() >= l.limit
+}
+
+// LogsFiltered returns the number of emitted logs the processor dropped
+// before they reached any sink
+func (l *Logger) LogsFiltered() int64 {
+	return l.logsFiltered.Load()
+}
+
+// BytesEmitted returns the total number of bytes of generated logs written
+// locally (or, when only exporting telemetry, their estimated size)
+func (l *Logger) BytesEmitted() int64 {
+	return l.bytesEmitted.Load()
 }
 
 // WithField creates a new entry with the specified field
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Where nested structures go
+const (
+	// NestedInFields adds the structure under the nested field
+	NestedInFields = "fields"
+	// NestedInBody writes the structure as the message, encoded as JSON
+	NestedInBody = "body"
+)
+
+// NestedField is the field holding a nested structure added to the fields
+const NestedField = "payload"
+
+// Bounds of nested structures
+const (
+	DefaultNestedWidth = 3
+	maxNestedDepth     = 128
+	maxNestedWidth     = 100
+)
+
+// NestedConfig shapes nested objects and arrays added to generated logs
+type NestedConfig struct {
+	Depth int    // Levels of objects and arrays below the top (0 disables nesting)
+	Width int    // Members of each object and elements of each array (default 3)
+	In    string // Where the structure goes: fields (default) or body
+}
+
+// validate checks a nested config, filling in the defaults
+func (c *NestedConfig) validate() error {
+	if c.Depth < 0 || c.Depth > maxNestedDepth {
+		return fmt.Errorf("invalid nesting depth %d: must be between 0 and %d", c.Depth, maxNestedDepth)
+	}
+	if c.Width == 0 {
+		c.Width = DefaultNestedWidth
+	}
+	if c.Width < 1 || c.Width > maxNestedWidth {
+		return fmt.Errorf("invalid nesting width %d: must be between 1 and %d", c.Width, maxNestedWidth)
+	}
+	switch c.In = strings.ToLower(c.In); c.In {
+	case "":
+		c.In = NestedInFields
+	case NestedInFields, NestedInBody:
+	default:
+		return fmt.Errorf("unknown nesting target %q (use fields or body)", c.In)
+	}
+	return nil
+}
+
+// nest adds a nested structure to a generated log when enabled, returning
+// its message
+func (l *Logger) nest(message string, fields map[string]interface{}) string {
+	if l.nested.Depth == 0 {
+		return message
+	}
+	doc := nestedObject(l.nested.Depth, l.nested.Width)
+	if l.nested.In == NestedInFields {
+		fields[NestedField] = doc
+		return message
+	}
+	doc["message"] = message
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return message
+	}
+	return string(encoded)
+}
+
+// nestedObject returns an object depth levels deep: its first member
+// nests further, the others are scalars, so the size grows with the depth
+// rather than exponentially
+func nestedObject(depth, width int) map[string]interface{} {
+	object := make(map[string]interface{}, width)
+	for i := 0; i < width; i++ {
+		key := strings.ToLower(gofakeit.Noun())
+		if _, taken := object[key]; taken || key == "" {
+			key += "_" + strconv.Itoa(i)
+		}
+		if i == 0 && depth > 0 {
+			object[key] = nestedValue(depth-1, width)
+		} else {
+			object[key] = nestedScalar()
+		}
+	}
+	return object
+}
+
+// nestedArray returns an array depth levels deep, nesting in its first
+// element
+func nestedArray(depth, width int) []interface{} {
+	array := make([]interface{}, width)
+	for i := range array {
+		if i == 0 && depth > 0 {
+			array[i] = nestedValue(depth-1, width)
+		} else {
+			array[i] = nestedScalar()
+		}
+	}
+	return array
+}
+
+// nestedValue returns an object or, a third of the time, an array
+func nestedValue(depth, width int) interface{} {
+	if gofakeit.Number(0, 2) == 0 {
+		return nestedArray(depth, width)
+	}
+	return nestedObject(depth, width)
+}
+
+// nestedScalar returns a string, number, boolean or null
+func nestedScalar() interface{} {
+	switch gofakeit.Number(0, 9) {
+	case 0, 1, 2, 3:
+		return gofakeit.Word()
+	case 4, 5:
+		return gofakeit.Number(0, 10000)
+	case 6, 7:
+		return gofakeit.Float64Range(0, 1000)
+	case 8:
+		return gofakeit.Bool()
+	}
+	return nil
+}
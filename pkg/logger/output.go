@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// Local outputs
+const (
+	// OutputStdout writes local logs to stdout
+	OutputStdout = "stdout"
+	// OutputNull counts local logs without serializing them
+	OutputNull = "null"
+	// OutputDiscard serializes local logs and discards them
+	OutputDiscard = "discard-after-serialize"
+	// OutputChild writes local logs through a child process, as a
+	// container's workload does
+	OutputChild = "child"
+	// OutputFile writes local logs to a file, rotated and compressed if
+	// configured
+	OutputFile = "file"
+)
+
+// ParseOutput validates a local output, stdout if empty
+func ParseOutput(output string) (string, error) {
+	switch o := strings.ToLower(strings.TrimSpace(output)); o {
+	case "":
+		return OutputStdout, nil
+	case OutputStdout, OutputNull, OutputDiscard, OutputChild, OutputFile:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown output %q (use %s, %s, %s, %s or %s)", output, OutputStdout, OutputNull, OutputDiscard, OutputChild, OutputFile)
+}
+
+// countingWriter counts the bytes written through it, and the writes that
+// succeeded and failed
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+	sink  *stats.Sink  // nil while local logs are disabled
+	async *asyncWriter // nil unless writes are queued for writer goroutines
+}
+
+// Write writes p to the underlying writer, or queues it, and counts the
+// written bytes
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.async != nil {
+		c.count.Add(int64(len(p)))
+		c.async.enqueue(p, c.sink)
+		return len(p), nil
+	}
+	n, err := c.w.Write(p)
+	c.count.Add(int64(n))
+	if c.sink != nil {
+		c.sink.Deliver(1, err)
+	}
+	return n, err
+}
+
+// estimateSize approximates the encoded size of a log that is not written
+// locally (e.g. only exported over OTLP)
+func estimateSize(message string, fields map[string]interface{}) int64 {
+	size := len(message)
+	for k, v := range fields {
+		size += len(k) + valueSize(v)
+	}
+	return int64(size)
+}
+
+// valueSize returns the printed size of a field value, without printing the
+// common kinds
+func valueSize(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case int:
+		return intSize(int64(v))
+	case int64:
+		return intSize(v)
+	case bool:
+		if v {
+			return 4
+		}
+		return 5
+	}
+	return len(fmt.Sprint(v))
+}
+
+// intSize returns the number of characters of a printed integer
+func intSize(n int64) int {
+	size := 1
+	if n < 0 {
+		size++
+		n = -n
+	}
+	for n >= 10 {
+		n /= 10
+		size++
+	}
+	return size
+}
+
+// fitMessage pads (with filler words) or truncates a message to exactly size bytes
+func fitMessage(message string, size int) string {
+	if size <= 0 || len(message) == size {
+		return message
+	}
+	if len(message) > size {
+		// Cut at a rune boundary, padding the bytes of a split rune with spaces
+		cut := size
+		for cut > 0 && !utf8.RuneStart(message[cut]) {
+			cut--
+		}
+		return message[:cut] + strings.Repeat(" ", size-cut)
+	}
+
+	var b strings.Builder
+	b.Grow(size + 16)
+	b.WriteString(message)
+	for b.Len() < size {
+		b.WriteByte(' ')
+		b.WriteString(gofakeit.Word())
+	}
+	return b.String()[:size]
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		size    int
+		want    string // empty to only check the size and the prefix
+	}{
+		{"disabled", "hello", 0, "hello"},
+		{"exact", "hello", 5, "hello"},
+		{"truncated", "hello world", 5, "hello"},
+		{"rune kept whole", "héllo", 3, "hé"},
+		{"rune split", "héllo", 2, "h "},
+		{"wide rune split", "a€b", 3, "a  "},
+		{"padded", "hi", 40, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitMessage(tt.message, tt.size)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("fitMessage(%q, %d) = %q, want %q", tt.message, tt.size, got, tt.want)
+			}
+			if tt.size > 0 && len(got) != tt.size {
+				t.Errorf("fitMessage(%q, %d) is %d bytes", tt.message, tt.size, len(got))
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("fitMessage(%q, %d) = %q, not valid UTF-8", tt.message, tt.size, got)
+			}
+			if tt.size > len(tt.message) && !strings.HasPrefix(got, tt.message) {
+				t.Errorf("fitMessage(%q, %d) = %q, does not start with the message", tt.message, tt.size, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package logger
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/integrity"
+	"github.com/sirupsen/logrus"
+)
+
+// event is a generated log kept for replay
+type event struct {
+	timestamp time.Time
+	level     LogLevel
+	message   string
+	fields    map[string]interface{}
+	line      []byte // serialized log, without the leading '{' when fields are stamped in front
+}
+
+// pool is a set of events generated ahead of time and cycled through, which
+// takes fake data generation and serialization off the hot path
+type pool struct {
+	regular    []*event
+	errors     []*event
+	next       atomic.Uint64
+	nextError  atomic.Uint64
+	restamp    bool
+	static     bool   // whether lines are serialized ahead of time, which needs JSON output
+	stamped    bool   // whether per-emit fields are written in front of the line
+	timeKey    string // key of logrus' own timestamp when restamping it, "" if absent
+	timeLayout string // of logrus' own timestamp, the formatter's
+}
+
+// pregenerate fills the pool with size regular and size error events
+func (l *Logger) pregenerate(size int, restamp bool) error {
+	p := &pool{restamp: restamp}
+	formatter, static := l.Formatter.(*logrus.JSONFormatter)
+	p.static = static
+	if restamp && formatter != nil && !formatter.DisableTimestamp {
+		// Restamped as the formatter would write it
+		p.timeKey = fieldKey(formatter.FieldMap, logrus.FieldKeyTime)
+		p.timeLayout = formatter.TimestampFormat
+		if p.timeLayout == "" {
+			p.timeLayout = time.RFC3339
+		}
+	}
+	p.stamped = l.sequencer != nil || (restamp && (!l.timestampFormat.Absent() || p.timeKey != ""))
+
+	var captured []*event
+	l.capture = &captured
+	for i := 0; i < size; i++ {
+		l.GenerateRandomLog()
+	}
+	// Finish a repeat burst started by the regular logs before switching
+	for l.emitRepeat(nil) {
+	}
+	p.regular, captured = captured, nil
+	for i := 0; i < size; i++ {
+		l.GenerateRandomErrorLog()
+	}
+	for l.emitRepeat(nil) {
+	}
+	p.errors = captured
+	l.capture = nil
+
+	if p.static {
+		for _, events := range [][]*event{p.regular, p.errors} {
+			for _, e := range events {
+				if err := l.serialize(p, e, formatter); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	l.pool = p
+	return nil
+}
+
+// serialize renders the static part of an event for the local output
+func (l *Logger) serialize(p *pool, e *event, formatter *logrus.JSONFormatter) error {
+	fields := copyFields(e.fields)
+	l.addAttributes(fields, nil)
+	if !p.restamp && !l.timestampFormat.Absent() {
+		fields[l.timestampField] = l.timestampFormat.Value(e.timestamp)
+	}
+
+	entry := l.WithFields(logrus.Fields(fields)).WithTime(e.timestamp)
+	entry.Level = logrusLevel(e.level)
+	entry.Message = e.message
+
+	var line []byte
+	var err error
+	if p.timeKey != "" {
+		// The restamped time is written in front of the line instead
+		static := *formatter
+		static.DisableTimestamp = true
+		line, err = static.Format(entry)
+	} else {
+		line, err = l.Formatter.Format(entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.stamped {
+		line = line[1:]
+	}
+	e.line = line
+	return nil
+}
+
+// nextEvent returns the next pregenerated event of the requested kind
+func (p *pool) nextEvent(isError bool) *event {
+	if isError {
+		return p.errors[(p.nextError.Add(1)-1)%uint64(len(p.errors))]
+	}
+	return p.regular[(p.next.Add(1)-1)%uint64(len(p.regular))]
+}
+
+// emitPregenerated emits a pregenerated event, stamping it with a fresh
+// timestamp (when restamping) and integrity fields
+func (l *Logger) emitPregenerated(e *event) {
+	if !l.IsLevelEnabled(logrusLevel(e.level)) {
+		return
+	}
+	if !l.reserve() {
+		return
+	}
+	l.countLevel(e.level)
+
+	timestamp := e.timestamp
+	if l.pool.restamp {
+		timestamp = l.clock.Now()
+	}
+
+	var seq uint64
+	var checksum string
+	if l.sequencer != nil {
+		seq = l.sequencer.Next()
+		if l.checksum {
+			checksum = integrity.Checksum(l.sequencer.Stream(), seq, e.message)
+		}
+	}
+
+	// Telemetry, other sinks and formats that cannot be serialized ahead of
+	// time need the complete fields
+	var fields map[string]interface{}
+	if (l.telemetryEnabled && l.telemetry != nil) || l.hook != nil || len(l.transforms) > 0 || l.processor != nil || !l.pool.static {
+		fields = copyFields(e.fields)
+		l.addAttributes(fields, nil)
+		if !l.timestampFormat.Absent() {
+			fields[l.timestampField] = l.timestampFormat.Value(timestamp)
+		}
+		if l.sequencer != nil {
+			fields[integrity.StreamField] = l.sequencer.Stream()
+			fields[integrity.SequenceField] = int64(seq)
+			if l.checksum {
+				fields[integrity.ChecksumField] = checksum
+			}
+		}
+	}
+
+	// Processed logs may differ from the pregenerated line
+	if l.processor != nil {
+		processed := Entry{Time: timestamp, Level: e.level, Message: e.message, Fields: fields}
+		if l.process(&processed) {
+			l.deliver(processed)
+		}
+		return
+	}
+
+	if l.hook != nil {
+		l.hook(timestamp, e.level, e.message, fields)
+	}
+
+	if l.telemetryEnabled && l.telemetry != nil {
+		l.sendTelemetry(Entry{Time: timestamp, Level: e.level, Message: e.message, Fields: fields})
+	}
+
+	if !l.localLogEnabled {
+		l.bytesEmitted.Add(estimateSize(e.message, e.fields))
+		return
+	}
+
+	if !l.pool.static || len(l.transforms) > 0 {
+		l.writeLocal(Entry{Time: timestamp, Level: e.level, Message: e.message, Fields: fields})
+		return
+	}
+
+	if l.countOnly {
+		l.countLocal(int64(len(e.line)))
+		return
+	}
+
+	if !l.pool.stamped {
+		_, _ = l.Out.Write(e.line)
+		return
+	}
+
+	buf := make([]byte, 0, len(e.line)+128)
+	buf = append(buf, '{')
+	if l.pool.restamp {
+		if !l.timestampFormat.Absent() {
+			buf = appendField(buf, l.timestampField, l.timestampFormat.Value(timestamp))
+		}
+		if l.pool.timeKey != "" {
+			buf = appendField(buf, l.pool.timeKey, timestamp.Format(l.pool.timeLayout))
+		}
+	}
+	if l.sequencer != nil {
+		buf = appendField(buf, integrity.StreamField, l.sequencer.Stream())
+		buf = appendField(buf, integrity.SequenceField, int64(seq))
+		if l.checksum {
+			buf = appendField(buf, integrity.ChecksumField, checksum)
+		}
+	}
+	buf = append(buf, e.line...)
+	_, _ = l.Out.Write(buf)
+}
+
+// appendField appends a JSON encoded "key":value, pair
+func appendField(buf []byte, key string, value interface{}) []byte {
+	encodedKey, _ := json.Marshal(key)
+	buf = append(buf, encodedKey...)
+	buf = append(buf, ':')
+	switch v := value.(type) {
+	case int64:
+		buf = strconv.AppendInt(buf, v, 10)
+	default:
+		encoded, _ := json.Marshal(v)
+		buf = append(buf, encoded...)
+	}
+	return append(buf, ',')
+}
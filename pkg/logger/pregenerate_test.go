@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPregeneratedRestampFollowsFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		fieldMap logrus.FieldMap
+		layout   string
+		key      string
+		parse    string // layout the written time parses with
+	}{
+		{"defaults", nil, time.RFC3339Nano, "time", time.RFC3339Nano},
+		{"renamed key", logrus.FieldMap{logrus.FieldKeyTime: "ts"}, time.RFC3339Nano, "ts", time.RFC3339Nano},
+		{"custom layout", nil, time.RFC1123Z, "time", time.RFC1123Z},
+		{"logrus default layout", nil, "", "time", time.RFC3339},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			l, err := New(Config{Verbosity: "debug", LocalLogEnabled: true, Output: &out, TimestampFormat: "none", Timezone: "UTC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.Formatter = &logrus.JSONFormatter{FieldMap: tt.fieldMap, TimestampFormat: tt.layout}
+			if err := l.pregenerate(1, true); err != nil {
+				t.Fatal(err)
+			}
+			out.Reset()
+			before := time.Now().Truncate(time.Second)
+			l.GenerateRandomLog()
+			l.GenerateRandomLog()
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("wrote %d lines, want 2: %s", len(lines), out.String())
+			}
+			for _, line := range lines {
+				var doc map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &doc); err != nil {
+					t.Fatalf("invalid JSON %s: %v", line, err)
+				}
+				value, _ := doc[tt.key].(string)
+				stamp, err := time.Parse(tt.parse, value)
+				if err != nil {
+					t.Fatalf("time %q under %s does not parse as %s: %s", value, tt.key, tt.parse, line)
+				}
+				if stamp.Before(before) {
+					t.Errorf("time %s was not restamped", value)
+				}
+				if tt.key != "time" {
+					if _, ok := doc["time"]; ok {
+						t.Errorf("wrote the time under its default key too: %s", line)
+					}
+				}
+			}
+		})
+	}
+}
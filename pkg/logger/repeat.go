@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"sync"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Field names carrying repeat burst metadata
+const (
+	RepeatCountField = "repeat_count"
+	RepeatIndexField = "repeat_index"
+)
+
+// RepeatConfig controls bursts of identical messages
+type RepeatConfig struct {
+	Probability float64 // Chance that a generated log starts a burst (0 disables bursts)
+	Min         int     // Minimum number of logs in a burst
+	Max         int     // Maximum number of logs in a burst
+}
+
+// repeater replays the same log several times in a row, mimicking apps
+// that spam identical errors
+type repeater struct {
+	config  RepeatConfig
+	mutex   sync.Mutex
+	level   LogLevel
+	message string
+	fields  map[string]interface{}
+	total   int
+	emitted int
+}
+
+// next returns the next log of the active burst, if any
+func (r *repeater) next() (LogLevel, string, map[string]interface{}, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.emitted >= r.total {
+		return "", "", nil, false
+	}
+	r.emitted++
+	return r.level, r.message, r.annotate(copyFields(r.fields)), true
+}
+
+// maybeStart starts a burst with the given log with the configured
+// probability; the returned fields carry the burst metadata when it does
+func (r *repeater) maybeStart(level LogLevel, message string, fields map[string]interface{}) map[string]interface{} {
+	if r.config.Probability <= 0 || gofakeit.Float64Range(0, 1) >= r.config.Probability {
+		return fields
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Another goroutine may have started a burst in the meantime
+	if r.emitted < r.total {
+		return fields
+	}
+
+	minCount, maxCount := r.config.Min, r.config.Max
+	if minCount < 2 {
+		minCount = 2
+	}
+	if maxCount < minCount {
+		maxCount = minCount
+	}
+
+	r.level = level
+	r.message = message
+	r.fields = copyFields(fields)
+	r.total = gofakeit.Number(minCount, maxCount)
+	r.emitted = 1
+	return r.annotate(fields)
+}
+
+// annotate adds the burst metadata to the fields
+func (r *repeater) annotate(fields map[string]interface{}) map[string]interface{} {
+	fields[RepeatCountField] = r.total
+	fields[RepeatIndexField] = r.emitted
+	return fields
+}
+
+// copyFields returns a shallow copy of a fields map
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied
+}
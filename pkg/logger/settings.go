@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// levels lists the generated levels in a fixed order
+var levels = []LogLevel{Debug, Info, Warn, Error}
+
+// ParseLevelWeights parses relative level weights such as
+// "debug=1,info=6,warn=2,error=1". Levels left out get a weight of zero.
+func ParseLevelWeights(spec string) (map[LogLevel]float64, error) {
+	weights := map[LogLevel]float64{}
+	total := 0.0
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid level weight %q: expected level=weight", pair)
+		}
+		level, ok := ParseLevel(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid level weight %q: unknown level %q", pair, name)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid level weight %q: weight must be a non-negative number", pair)
+		}
+		weights[level] = weight
+		total += weight
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("invalid level weights %q: at least one weight must be positive", spec)
+	}
+	return weights, nil
+}
+
+// ParseAttributes parses static attributes such as "env=prod,region=eu-west-1"
+func ParseAttributes(spec string) (map[string]string, error) {
+	attributes := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid attribute %q: expected key=value", pair)
+		}
+		attributes[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return attributes, nil
+}
+
+// Variant overrides settings of the logger for some of the logs, e.g. those
+// of a stream of a scenario
+type Variant struct {
+	LevelWeights map[LogLevel]float64 // nil keeps the logger's
+	Attributes   map[string]string    // added after the logger's, taking precedence
+	Location     *time.Location       // of its timestamps, nil keeps the clock's
+}
+
+// SetLevelWeights changes the relative weights of generated levels; nil
+// picks levels uniformly
+func (l *Logger) SetLevelWeights(weights map[LogLevel]float64) {
+	l.levelWeights.Store(&weights)
+}
+
+// SetAttributes changes the static attributes added to every log
+func (l *Logger) SetAttributes(attributes map[string]string) {
+	l.attributes.Store(&attributes)
+}
+
+// randomLevel picks a level according to the weights of the variant, the
+// configured ones if it has none. Levels below the verbosity are not picked,
+// as their logs would be dropped after taking their turn of the rate.
+func (l *Logger) randomLevel(v *Variant) LogLevel {
+	weights := l.levelWeights.Load()
+	if v != nil && v.LevelWeights != nil {
+		weights = &v.LevelWeights
+	}
+	candidates := l.enabledLevels()
+	if weights == nil || *weights == nil {
+		return candidates[gofakeit.Number(0, len(candidates)-1)]
+	}
+
+	total := 0.0
+	for _, level := range candidates {
+		total += (*weights)[level]
+	}
+	if total <= 0 {
+		// Only levels below the verbosity are weighted: keep the weights,
+		// though every log is dropped
+		candidates, total = levels, 0
+		for _, level := range levels {
+			total += (*weights)[level]
+		}
+	}
+	pick := gofakeit.Float64Range(0, total)
+	for _, level := range candidates {
+		if pick < (*weights)[level] {
+			return level
+		}
+		pick -= (*weights)[level]
+	}
+	// Rounding left nothing to pick from: use the last weighted level
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if (*weights)[candidates[i]] > 0 {
+			return candidates[i]
+		}
+	}
+	return Info
+}
+
+// enabledLevels returns the generated levels the verbosity lets through, all
+// of them if it lets none through
+func (l *Logger) enabledLevels() []LogLevel {
+	for i, level := range levels {
+		if l.IsLevelEnabled(logrusLevel(level)) {
+			// Levels are ordered by severity
+			return levels[i:]
+		}
+	}
+	return levels
+}
+
+// addAttributes adds the static attributes to the fields, then those of the
+// variant
+func (l *Logger) addAttributes(fields map[string]interface{}, v *Variant) {
+	if attributes := l.attributes.Load(); attributes != nil {
+		for key, value := range *attributes {
+			fields[key] = value
+		}
+	}
+	if v != nil {
+		for key, value := range v.Attributes {
+			fields[key] = value
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// countLevel counts an emitted log of the given level
+func (l *Logger) countLevel(level LogLevel) {
+	for i, lv := range levels {
+		if lv == level {
+			l.levelsEmitted[i].Add(1)
+			return
+		}
+	}
+}
+
+// Stats returns the registry the logger counts into
+func (l *Logger) Stats() *stats.Registry {
+	return l.stats
+}
+
+// LevelsEmitted returns the number of logs emitted at each level
+func (l *Logger) LevelsEmitted() map[LogLevel]int64 {
+	counts := make(map[LogLevel]int64, len(levels))
+	for i, level := range levels {
+		counts[level] = l.levelsEmitted[i].Load()
+	}
+	return counts
+}
+
+// Sinks returns what every active sink delivered; after Shutdown the
+// counts are final
+func (l *Logger) Sinks() []stats.SinkStats {
+	return l.stats.Sinks()
+}
+
+// refused counts a log the telemetry provider refused as failed, reporting
+// the first one
+func (l *Logger) refused(err error) {
+	l.otlp.Deliver(1, err)
+	l.sendFailed.Do(func() {
+		diag.Warn("Failed to send log to telemetry endpoint", "error", err)
+	})
+}
+
+// QueueDepth returns the logs handed to the exporter that are not yet
+// exported or failed, and the local logs queued for writing
+func (l *Logger) QueueDepth() int64 {
+	var depth int64
+	if l.otlp != nil {
+		depth = l.otlp.Stats().Dropped
+	}
+	if l.output.async != nil {
+		depth += l.output.async.Len()
+	}
+	return depth
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AllSinks is the sink name of a transform applied to every sink without
+// one of its own
+const AllSinks = "*"
+
+// Entry is a log as a sink takes it
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Fields  map[string]interface{}
+}
+
+// IntField reads an integral field, as generated or decoded from JSON
+func IntField(fields map[string]interface{}, name string) (int, bool) {
+	switch v := fields[name].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	}
+	return 0, false
+}
+
+// Transform rewrites a log before a sink takes it, reporting false to drop
+// the log from that sink. The fields are the sink's own copy.
+type Transform func(e *Entry) bool
+
+// Transforms maps sink names, as counted in the stats, to their transforms
+type Transforms map[string]Transform
+
+// For returns the transform of a sink, nil if it has none
+func (t Transforms) For(sink string) Transform {
+	if transform, ok := t[sink]; ok {
+		return transform
+	}
+	return t[AllSinks]
+}
+
+// Apply runs the transform of a sink on a copy of the log, reporting false if
+// the sink should not take it
+func (t Transforms) Apply(sink string, e *Entry) bool {
+	transform := t.For(sink)
+	if transform == nil {
+		return true
+	}
+	e.Fields = copyFields(e.Fields)
+	return run(transform, e)
+}
+
+// run runs a transform on a log, keeping its level valid
+func run(transform Transform, e *Entry) bool {
+	level := e.Level
+	if !transform(e) {
+		return false
+	}
+	// A transform may spell the level in any common way
+	if parsed, ok := ParseLevel(string(e.Level)); ok {
+		e.Level = parsed
+	} else {
+		e.Level = level
+	}
+	return true
+}
+
+// process runs the processor on a log before any sink takes it, counting the
+// logs it drops
+func (l *Logger) process(e *Entry) bool {
+	if l.processor == nil {
+		return true
+	}
+	if !run(l.processor, e) {
+		l.logsFiltered.Add(1)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"fmt"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Bounds of the generated attributes of each log
+const (
+	DefaultAttributeKeys = 200
+	maxAttributeCount    = 10000
+)
+
+// AttributeCountConfig controls how many generated attributes each log
+// carries, testing per-event field limits and wide events
+type AttributeCountConfig struct {
+	Min  int // Fewest generated attributes of a log
+	Max  int // Most generated attributes of a log (0 disables them)
+	Keys int // Size of the pool of keys drawn from (default 200, at least Max)
+}
+
+// attributePool draws distinct keys of generated attributes
+type attributePool struct {
+	min, max int
+	keys     []string
+}
+
+// newAttributePool returns the pool of a config, nil if disabled
+func newAttributePool(config AttributeCountConfig) (*attributePool, error) {
+	if config.Min < 0 || config.Max < 0 || config.Max > maxAttributeCount {
+		return nil, fmt.Errorf("invalid attribute count %d-%d: must be between 0 and %d", config.Min, config.Max, maxAttributeCount)
+	}
+	if config.Min > config.Max {
+		return nil, fmt.Errorf("invalid attribute count: minimum %d is above the maximum %d", config.Min, config.Max)
+	}
+	if config.Max == 0 {
+		return nil, nil
+	}
+	if config.Keys == 0 {
+		config.Keys = max(DefaultAttributeKeys, config.Max)
+	}
+	if config.Keys < config.Max || config.Keys > maxAttributeCount {
+		return nil, fmt.Errorf("invalid number of attribute keys %d: must be between the maximum count %d and %d", config.Keys, config.Max, maxAttributeCount)
+	}
+	p := &attributePool{min: config.Min, max: config.Max, keys: make([]string, config.Keys)}
+	for i := range p.keys {
+		p.keys[i] = fmt.Sprintf("attr_%04d", i)
+	}
+	return p, nil
+}
+
+// add adds between the minimum and maximum number of attributes to the
+// fields, with distinct keys of the pool
+func (p *attributePool) add(fields map[string]interface{}) {
+	for n := gofakeit.Number(p.min, p.max); n > 0; {
+		key := p.keys[gofakeit.Number(0, len(p.keys)-1)]
+		if _, taken := fields[key]; taken {
+			continue
+		}
+		if gofakeit.Bool() {
+			fields[key] = gofakeit.Word()
+		} else {
+			fields[key] = gofakeit.Number(0, 100000)
+		}
+		n--
+	}
+}
+
+// widen adds generated attributes to the fields when enabled
+func (l *Logger) widen(fields map[string]interface{}) {
+	if l.wide != nil {
+		l.wide.add(fields)
+	}
+}
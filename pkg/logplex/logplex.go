@@ -0,0 +1,223 @@
+package logplex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+const (
+	defaultApp      = "app"
+	defaultBatch    = 100
+	defaultInterval = time.Second
+	defaultTimeout  = 30 * time.Second
+	contentType     = "application/logplex-1"
+	userAgent       = "Logplex/v72"
+	facilityLocal7  = 23 // facility of app logs on Heroku
+	dynos           = 4  // web dynos the logs are spread over
+)
+
+// Config holds the configuration of the drain sink
+type Config struct {
+	URL      string        // drain URL, with credentials as user info if it needs them
+	Token    string        // drain token sent in Logplex-Drain-Token (default a random d.<uuid>)
+	App      string        // app name of the syslog lines (default app)
+	Batch    int           // logs per request, sent once full (default 100)
+	Interval time.Duration // longest a log waits to be sent (default 1s)
+	Timeout  time.Duration // bound on a request (default 30s)
+}
+
+// Sink posts the logs to an HTTPS log drain the way Heroku's Logplex does:
+// batches of octet-counted RFC 5424 syslog lines, with the message count,
+// a frame ID and the drain token in headers. A batch fills up in Write,
+// which posts it and so paces generation by the drain; the rest is posted
+// every interval.
+type Sink struct {
+	url     string
+	token   string
+	app     string
+	batch   int
+	client  *http.Client
+	mutex   sync.Mutex
+	body    bytes.Buffer
+	pending int
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// New creates a drain sink
+func New(config Config) (*Sink, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid drain URL %q: use http(s)://host/path", config.URL)
+	}
+	if config.Token == "" {
+		config.Token = "d." + gofakeit.UUID()
+	}
+	if config.App == "" {
+		config.App = defaultApp
+	}
+	if config.Batch <= 0 {
+		config.Batch = defaultBatch
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	s := &Sink{
+		url:    config.URL,
+		token:  config.Token,
+		app:    config.App,
+		batch:  config.Batch,
+		client: &http.Client{Timeout: config.Timeout},
+		done:   make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go s.run(config.Interval)
+	return s, nil
+}
+
+// Write adds the syslog line of a log to the batch, posting the batch it
+// fills
+func (s *Sink) Write(e logger.Entry) error {
+	line := s.line(e)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.body.WriteString(strconv.Itoa(len(line)))
+	s.body.WriteByte(' ')
+	s.body.WriteString(line)
+	s.pending++
+	if s.pending < s.batch {
+		return nil
+	}
+	return s.post()
+}
+
+// Flush posts the logs batched so far
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.post()
+}
+
+// Close posts the batched logs
+func (s *Sink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	return s.Flush()
+}
+
+// run posts the batched logs every interval until closed
+func (s *Sink) run(interval time.Duration) {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				diag.Warn("Failed to post to log drain", "error", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// post posts the batch; the mutex must be held
+func (s *Sink) post() error {
+	if s.pending == 0 {
+		return nil
+	}
+	count := s.pending
+	defer func() {
+		s.body.Reset()
+		s.pending = 0
+	}()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(s.body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Logplex-Msg-Count", strconv.Itoa(count))
+	req.Header.Set("Logplex-Frame-Id", gofakeit.UUID())
+	req.Header.Set("Logplex-Drain-Token", s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("batch of %d logs: %w", count, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("batch of %d logs: drain answered %s", count, resp.Status)
+	}
+	return nil
+}
+
+// line renders a log as the syslog line of a web dyno: the message, then
+// the fields as sorted key=value pairs as Heroku's router logs them
+func (s *Sink) line(e logger.Entry) string {
+	timestamp := e.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s host %s %s - %s", facilityLocal7*8+severity(e.Level), timestamp.UTC().Format(time.RFC3339Nano), s.app, dyno(e), e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := fmt.Sprint(e.Fields[k])
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// severity returns the syslog severity of a level
+func severity(level logger.LogLevel) int {
+	switch level {
+	case logger.Error:
+		return 3
+	case logger.Warn:
+		return 4
+	case logger.Debug:
+		return 7
+	}
+	return 6
+}
+
+// dyno returns the process ID of the dyno a log comes from, derived from its
+// service so each service keeps to one dyno
+func dyno(e logger.Entry) string {
+	service, _ := e.Fields["service"].(string)
+	h := fnv.New32a()
+	h.Write([]byte(service))
+	return fmt.Sprintf("web.%d", h.Sum32()%dynos+1)
+}
@@ -0,0 +1,243 @@
+package lumberjack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+const (
+	defaultPort     = "5044"
+	defaultBatch    = 512
+	defaultInterval = time.Second
+	defaultTimeout  = 30 * time.Second
+)
+
+// Frame types of the Lumberjack v2 protocol
+const (
+	protocolVersion = '2'
+	frameWindow     = 'W'
+	frameCompressed = 'C'
+	frameJSON       = 'J'
+	frameACK        = 'A'
+)
+
+// Config holds the configuration of the Lumberjack sink
+type Config struct {
+	Target   string        // host:port of the Beats input, port 5044 if omitted
+	Batch    int           // Events per window, sent once full (default 512)
+	Interval time.Duration // Longest an event waits to be sent (default 1s)
+	Timeout  time.Duration // Bound on sending a window and waiting for its ACK (default 30s)
+}
+
+// Sink sends the logs to a Logstash or Beats input over the Lumberjack v2
+// protocol, as Filebeat does: JSON events in compressed windows, each
+// acknowledged before the next. A window fills up in Write, which sends it
+// and so paces generation by the input; the rest is sent every interval. A
+// failed window is dropped and the connection opened again for the next.
+type Sink struct {
+	target   string
+	batch    int
+	timeout  time.Duration
+	mutex    sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	pending  [][]byte
+	done     chan struct{}
+	stopped  sync.WaitGroup
+	compress bytes.Buffer
+}
+
+// New creates a Lumberjack sink, connecting to the input right away so a
+// wrong address shows at start
+func New(config Config) (*Sink, error) {
+	target := config.Target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultPort)
+	}
+	if config.Batch <= 0 {
+		config.Batch = defaultBatch
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	s := &Sink{target: target, batch: config.Batch, timeout: config.Timeout, done: make(chan struct{})}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	s.stopped.Add(1)
+	go s.run(config.Interval)
+	return s, nil
+}
+
+// Write queues the event of a log, sending the window it fills
+func (s *Sink) Write(e logger.Entry) error {
+	event, err := json.Marshal(document(e))
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, event)
+	if len(s.pending) < s.batch {
+		return nil
+	}
+	return s.send()
+}
+
+// Flush sends the events queued so far and waits for their ACK
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.send()
+}
+
+// Close sends the queued events and closes the connection
+func (s *Sink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	err := s.Flush()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// run sends the queued events every interval until closed
+func (s *Sink) run(interval time.Duration) {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				diag.Warn("Failed to send Lumberjack window", "target", s.target, "error", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// connect opens the connection to the input
+func (s *Sink) connect() error {
+	conn, err := net.DialTimeout("tcp", s.target, s.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to reach Beats input: %w", err)
+	}
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+	return nil
+}
+
+// send sends the queued events as one window and waits until the input
+// acknowledges all of them; the mutex must be held
+func (s *Sink) send() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	events := len(s.pending)
+	err := s.sendWindow()
+	s.pending = s.pending[:0]
+	if err != nil {
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return fmt.Errorf("window of %d events: %w", events, err)
+	}
+	return nil
+}
+
+// sendWindow writes the window of the queued events and reads ACKs up to
+// its last sequence number
+func (s *Sink) sendWindow() error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+
+	s.compress.Reset()
+	z := zlib.NewWriter(&s.compress)
+	header := make([]byte, 10)
+	for i, event := range s.pending {
+		header[0], header[1] = protocolVersion, frameJSON
+		binary.BigEndian.PutUint32(header[2:], uint32(i+1))
+		binary.BigEndian.PutUint32(header[6:], uint32(len(event)))
+		z.Write(header)
+		z.Write(event)
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+
+	frames := make([]byte, 0, 12+s.compress.Len())
+	frames = append(frames, protocolVersion, frameWindow)
+	frames = binary.BigEndian.AppendUint32(frames, uint32(len(s.pending)))
+	frames = append(frames, protocolVersion, frameCompressed)
+	frames = binary.BigEndian.AppendUint32(frames, uint32(s.compress.Len()))
+	frames = append(frames, s.compress.Bytes()...)
+	if _, err := s.conn.Write(frames); err != nil {
+		return err
+	}
+
+	// Inputs acknowledge partially while processing a large window
+	ack := make([]byte, 6)
+	for {
+		if _, err := io.ReadFull(s.reader, ack); err != nil {
+			return fmt.Errorf("waiting for ACK: %w", err)
+		}
+		if ack[1] != frameACK {
+			return fmt.Errorf("unexpected frame %q instead of an ACK", ack[:2])
+		}
+		if binary.BigEndian.Uint32(ack[2:]) >= uint32(len(s.pending)) {
+			return nil
+		}
+	}
+}
+
+// document returns the event of a log as Filebeat ships it: the fields of
+// the log alongside @timestamp, message and log.level, and the metadata
+// Logstash names indices by, with the tenant if any
+func document(e logger.Entry) map[string]interface{} {
+	doc := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		doc[k] = v
+	}
+	timestamp := e.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	doc["@timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	doc["message"] = e.Message
+	doc["log"] = map[string]interface{}{"level": string(e.Level)}
+	metadata := map[string]interface{}{"beat": "log-genie", "type": "_doc", "version": version.Get().Version}
+	if name := tenant.Of(e.Fields); name != "" {
+		// Lets Logstash route the tenants to indices of their own
+		metadata["tenant"] = name
+	}
+	doc["@metadata"] = metadata
+	return doc
+}
@@ -0,0 +1,166 @@
+package lumberjack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+)
+
+// readWindow reads a window as a Beats input does, checking its framing, and
+// returns its events
+func readWindow(r *bufio.Reader) ([]map[string]interface{}, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != protocolVersion || header[1] != frameWindow {
+		return nil, fmt.Errorf("frame %q instead of a window", header[:2])
+	}
+	count := binary.BigEndian.Uint32(header[2:])
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != protocolVersion || header[1] != frameCompressed {
+		return nil, fmt.Errorf("frame %q instead of a compressed one", header[:2])
+	}
+	compressed := make([]byte, binary.BigEndian.Uint32(header[2:]))
+	if _, err := io.ReadFull(r, compressed); err != nil {
+		return nil, err
+	}
+	z, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := io.ReadAll(z)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []map[string]interface{}
+	for seq := uint32(1); len(payload) > 0; seq++ {
+		if len(payload) < 10 || payload[0] != protocolVersion || payload[1] != frameJSON {
+			return nil, fmt.Errorf("invalid JSON frame %d", seq)
+		}
+		if got := binary.BigEndian.Uint32(payload[2:]); got != seq {
+			return nil, fmt.Errorf("sequence number %d, want %d", got, seq)
+		}
+		size := binary.BigEndian.Uint32(payload[6:])
+		var event map[string]interface{}
+		if err := json.Unmarshal(payload[10:10+size], &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+		payload = payload[10+size:]
+	}
+	if uint32(len(events)) != count {
+		return nil, fmt.Errorf("window of %d events holds %d", count, len(events))
+	}
+	return events, nil
+}
+
+// ack writes an ACK frame
+func ack(w io.Writer, frame byte, seq uint32) {
+	w.Write(binary.BigEndian.AppendUint32([]byte{protocolVersion, frame}, seq))
+}
+
+func TestWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  int
+		batch   int
+		acks    func(n int) []uint32 // ACKs of a window of n events
+		frame   byte                 // of the ACKs
+		windows []int                // events of the windows received
+		wantErr bool
+	}{
+		{"one window", 3, 10, func(n int) []uint32 { return []uint32{uint32(n)} }, frameACK, []int{3}, false},
+		{"full windows", 5, 2, func(n int) []uint32 { return []uint32{uint32(n)} }, frameACK, []int{2, 2, 1}, false},
+		{"partial ACKs", 4, 10, func(n int) []uint32 { return []uint32{1, 3, uint32(n)} }, frameACK, []int{4}, false},
+		{"not an ACK", 1, 10, func(n int) []uint32 { return []uint32{uint32(n)} }, 'X', []int{1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			received := make(chan []map[string]interface{}, 10)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					events, err := readWindow(r)
+					if err != nil {
+						if err != io.EOF {
+							t.Error(err)
+						}
+						close(received)
+						return
+					}
+					received <- events
+					for _, seq := range tt.acks(len(events)) {
+						ack(conn, tt.frame, seq)
+					}
+				}
+			}()
+
+			s, err := New(Config{Target: listener.Addr().String(), Batch: tt.batch, Interval: time.Hour, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sendErr error
+			for i := 0; i < tt.events; i++ {
+				fields := map[string]interface{}{"i": i, tenant.Field: "acme"}
+				e := logger.Entry{Time: time.Unix(0, 0), Level: logger.Warn, Message: fmt.Sprint("m", i), Fields: fields}
+				if err := s.Write(e); err != nil {
+					sendErr = err
+				}
+			}
+			if err := s.Close(); err != nil {
+				sendErr = err
+			}
+			if (sendErr != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", sendErr, tt.wantErr)
+			}
+
+			var windows []int
+			next := 0
+			for events := range received {
+				windows = append(windows, len(events))
+				for _, event := range events {
+					if event["message"] != fmt.Sprint("m", next) || event["i"] != float64(next) {
+						t.Errorf("event %d: %v", next, event)
+					}
+					if event["@timestamp"] != "1970-01-01T00:00:00Z" {
+						t.Errorf("event %d: @timestamp %v", next, event["@timestamp"])
+					}
+					if level := event["log"].(map[string]interface{})["level"]; level != string(logger.Warn) {
+						t.Errorf("event %d: level %v", next, level)
+					}
+					if metadata := event["@metadata"].(map[string]interface{}); metadata["tenant"] != "acme" {
+						t.Errorf("event %d: metadata %v", next, metadata)
+					}
+					next++
+				}
+			}
+			if fmt.Sprint(windows) != fmt.Sprint(tt.windows) {
+				t.Errorf("windows of %v events, want %v", windows, tt.windows)
+			}
+		})
+	}
+}
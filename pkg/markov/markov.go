@@ -0,0 +1,170 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Placeholders substituted for sensitive-looking tokens during training
+const (
+	emailToken = "\x00email"
+	endToken   = "\x00end"
+)
+
+// messageKeys are the JSON keys checked for a message when training on NDJSON
+var messageKeys = []string{"msg", "message", "body", "log"}
+
+// Chain is a word-level Markov chain trained on a corpus of log messages
+type Chain struct {
+	order       int
+	transitions map[string][]string
+	starts      [][]string
+}
+
+// New creates an empty chain using prefixes of the given number of words
+func New(order int) *Chain {
+	if order < 1 {
+		order = 1
+	}
+	return &Chain{
+		order:       order,
+		transitions: make(map[string][]string),
+	}
+}
+
+// TrainFile creates a chain from a corpus file. Each line is a message;
+// JSON lines contribute their msg/message/body/log field.
+func TrainFile(path string, order int) (*Chain, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus: %w", err)
+	}
+	defer file.Close()
+
+	chain := New(order)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		chain.Train(extractMessage(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+
+	if len(chain.starts) == 0 {
+		return nil, fmt.Errorf("corpus %s contains no messages", path)
+	}
+	return chain, nil
+}
+
+// Train adds a single message to the chain
+func (c *Chain) Train(message string) {
+	words := strings.Fields(message)
+	if len(words) == 0 {
+		return
+	}
+	for i, word := range words {
+		words[i] = scrub(word)
+	}
+
+	// Pad short messages so they still provide a start prefix
+	prefix := make([]string, c.order)
+	copy(prefix, words)
+	c.starts = append(c.starts, prefix)
+
+	words = append(words, endToken)
+	for i := 0; i+c.order < len(words); i++ {
+		key := strings.Join(words[i:i+c.order], " ")
+		c.transitions[key] = append(c.transitions[key], words[i+c.order])
+	}
+}
+
+// Generate produces a message of at most maxWords words
+func (c *Chain) Generate(maxWords int) string {
+	if len(c.starts) == 0 {
+		return ""
+	}
+
+	start := c.starts[gofakeit.Number(0, len(c.starts)-1)]
+	words := make([]string, 0, maxWords)
+	for _, word := range start {
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	for len(words) < maxWords && len(words) >= c.order {
+		candidates := c.transitions[strings.Join(words[len(words)-c.order:], " ")]
+		if len(candidates) == 0 {
+			break
+		}
+		next := candidates[gofakeit.Number(0, len(candidates)-1)]
+		if next == endToken {
+			break
+		}
+		words = append(words, next)
+	}
+
+	for i, word := range words {
+		words[i] = fill(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// extractMessage returns the message part of a corpus line
+func extractMessage(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return line
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return line
+	}
+	for _, key := range messageKeys {
+		if msg, ok := record[key].(string); ok {
+			return msg
+		}
+	}
+	return ""
+}
+
+// scrub replaces tokens that may carry real data with placeholders: emails
+// become a marker and every digit becomes '#', so IDs, IPs and numbers keep
+// their shape but not their value
+func scrub(word string) string {
+	if strings.Contains(word, "@") && strings.Contains(word, ".") {
+		return emailToken
+	}
+	if !strings.ContainsAny(word, "0123456789") {
+		return word
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '#'
+		}
+		return r
+	}, word)
+}
+
+// fill replaces placeholders left by scrub with fresh fake values
+func fill(word string) string {
+	if word == emailToken {
+		return gofakeit.Email()
+	}
+	if !strings.Contains(word, "#") {
+		return word
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '#' {
+			return rune('0' + gofakeit.Number(0, 9))
+		}
+		return r
+	}, word)
+}
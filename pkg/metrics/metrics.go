@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// sampleInterval is how often the achieved rate is measured
+const sampleInterval = time.Second
+
+// Config holds the configuration for the metrics exporter
+type Config struct {
+	Logger     *logger.Logger
+	Profile    string         // Value of the profile label of generated logs
+	Rate       func() float64 // Current target rate, in events or bytes per second
+	Throughput bool           // The target rate is a byte rate rather than an event rate
+}
+
+// Exporter exposes the counters of a running generator in the Prometheus
+// text format
+type Exporter struct {
+	config   Config
+	mutex    sync.Mutex
+	achieved float64 // logs per second over the last sample interval
+}
+
+// New creates a new metrics exporter with the given configuration
+func New(config Config) *Exporter {
+	if config.Profile == "" {
+		config.Profile = "default"
+	}
+	return &Exporter{config: config}
+}
+
+// Handler returns the HTTP handler serving GET /metrics
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.handleMetrics)
+	return mux
+}
+
+// Run measures the achieved rate until ctx is done
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	last, lastTime := e.config.Logger.LogsEmitted(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			logs := e.config.Logger.LogsEmitted()
+			e.mutex.Lock()
+			e.achieved = float64(logs-last) / now.Sub(lastTime).Seconds()
+			e.mutex.Unlock()
+			last, lastTime = logs, now
+		}
+	}
+}
+
+// handleMetrics writes the current metrics
+func (e *Exporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+// Write writes the current metrics in the Prometheus text format
+func (e *Exporter) Write(w io.Writer) {
+	log := e.config.Logger
+
+	levels := log.LevelsEmitted()
+	header(w, "loggenie_logs_generated_total", "counter", "Logs generated, by level and profile.")
+	for _, level := range []logger.LogLevel{logger.Debug, logger.Info, logger.Warn, logger.Error} {
+		sample(w, "loggenie_logs_generated_total", float64(levels[level]), "level", string(level), "profile", e.config.Profile)
+	}
+
+	header(w, "loggenie_bytes_emitted_total", "counter", "Bytes of generated logs emitted.")
+	sample(w, "loggenie_bytes_emitted_total", float64(log.BytesEmitted()))
+
+	sinks := log.Sinks()
+	header(w, "loggenie_export_failures_total", "counter", "Logs a sink failed to deliver.")
+	for _, sink := range sinks {
+		sample(w, "loggenie_export_failures_total", float64(sink.Failed), "sink", sink.Name)
+	}
+	header(w, "loggenie_logs_sent_total", "counter", "Logs a sink delivered.")
+	for _, sink := range sinks {
+		sample(w, "loggenie_logs_sent_total", float64(sink.Sent), "sink", sink.Name)
+	}
+
+	header(w, "loggenie_queue_depth", "gauge", "Logs waiting to be exported.")
+	sample(w, "loggenie_queue_depth", float64(log.QueueDepth()))
+
+	e.mutex.Lock()
+	achieved := e.achieved
+	e.mutex.Unlock()
+	header(w, "loggenie_achieved_rate", "gauge", "Logs generated per second over the last second.")
+	sample(w, "loggenie_achieved_rate", achieved)
+
+	if e.config.Rate != nil {
+		unit := "events"
+		if e.config.Throughput {
+			unit = "bytes"
+		}
+		header(w, "loggenie_target_rate", "gauge", "Target rate, in events or bytes per second.")
+		sample(w, "loggenie_target_rate", e.config.Rate(), "unit", unit)
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample of a metric with label name/value pairs
+func sample(w io.Writer, name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatValue(value))
+	b.WriteByte('\n')
+	io.WriteString(w, b.String())
+}
+
+// escapeLabel escapes a label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatValue formats a sample value, including infinite rates
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
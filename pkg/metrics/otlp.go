@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+// OTLPConfig holds the configuration for exporting self metrics over OTLP
+type OTLPConfig struct {
+	Endpoint      string        // Collector endpoint, host:port with an optional path (default /v1/metrics), over TLS with https://
+	Interval      time.Duration // How often metrics are exported
+	ApplicationID string        // Application ID for resource attributes
+}
+
+// StartOTLP exports the generator's own metrics to an OTLP collector
+// periodically and installs the meter provider globally, so export durations
+// recorded by the telemetry package are included. The returned provider must
+// be shut down to flush the last values.
+func (e *Exporter) StartOTLP(ctx context.Context, config OTLPConfig) (*sdkmetric.MeterProvider, error) {
+	hostPort, path, insecure := telemetry.ParseEndpoint(config.Endpoint)
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(hostPort)}
+	if insecure {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+	if path != "" {
+		options = append(options, otlpmetrichttp.WithURLPath(path))
+	}
+	exporter, err := otlpmetrichttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	resource, err := sdkresource.New(ctx,
+		sdkresource.WithAttributes(
+			semconv.ServiceName("log-genie"),
+			semconv.ServiceVersion(version.Get().Version),
+			attribute.String("application_id", config.ApplicationID),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(config.Interval))),
+		sdkmetric.WithResource(resource),
+	)
+	if err := e.register(provider.Meter("log-genie")); err != nil {
+		_ = provider.Shutdown(ctx)
+		return nil, err
+	}
+	otel.SetMeterProvider(provider)
+	return provider, nil
+}
+
+// register creates observable instruments reporting the same values as the
+// Prometheus endpoint
+func (e *Exporter) register(meter metric.Meter) error {
+	generated, err := meter.Int64ObservableCounter("loggenie.logs.generated",
+		metric.WithDescription("Logs generated, by level and profile"))
+	if err != nil {
+		return err
+	}
+	emitted, err := meter.Int64ObservableCounter("loggenie.bytes.emitted",
+		metric.WithDescription("Bytes of generated logs emitted"), metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	sent, err := meter.Int64ObservableCounter("loggenie.logs.sent",
+		metric.WithDescription("Logs a sink delivered"))
+	if err != nil {
+		return err
+	}
+	failures, err := meter.Int64ObservableCounter("loggenie.export.failures",
+		metric.WithDescription("Logs a sink failed to deliver"))
+	if err != nil {
+		return err
+	}
+	queue, err := meter.Int64ObservableGauge("loggenie.queue.depth",
+		metric.WithDescription("Logs waiting to be exported"))
+	if err != nil {
+		return err
+	}
+	achieved, err := meter.Float64ObservableGauge("loggenie.rate.achieved",
+		metric.WithDescription("Logs generated per second over the last second"))
+	if err != nil {
+		return err
+	}
+	target, err := meter.Float64ObservableGauge("loggenie.rate.target",
+		metric.WithDescription("Target rate, in events or bytes per second"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		log := e.config.Logger
+		profile := attribute.String("profile", e.config.Profile)
+
+		levels := log.LevelsEmitted()
+		for _, level := range []logger.LogLevel{logger.Debug, logger.Info, logger.Warn, logger.Error} {
+			o.ObserveInt64(generated, levels[level], metric.WithAttributes(attribute.String("level", string(level)), profile))
+		}
+		o.ObserveInt64(emitted, log.BytesEmitted())
+		for _, sink := range log.Sinks() {
+			o.ObserveInt64(sent, sink.Sent, metric.WithAttributes(attribute.String("sink", sink.Name)))
+			o.ObserveInt64(failures, sink.Failed, metric.WithAttributes(attribute.String("sink", sink.Name)))
+		}
+		o.ObserveInt64(queue, int64(log.QueueDepth()))
+
+		e.mutex.Lock()
+		o.ObserveFloat64(achieved, e.achieved)
+		e.mutex.Unlock()
+
+		if e.config.Rate != nil {
+			unit := "events"
+			if e.config.Throughput {
+				unit = "bytes"
+			}
+			o.ObserveFloat64(target, e.config.Rate(), metric.WithAttributes(attribute.String("unit", unit)))
+		}
+		return nil
+	}, generated, emitted, sent, failures, queue, achieved, target)
+	return err
+}
@@ -0,0 +1,200 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Information elements of the template, numbered alike in NetFlow v9 and
+// IPFIX, with their sizes in each (0 where a version goes without)
+var templateFields = []struct {
+	id, v9, ipfix uint16
+}{
+	{8, 4, 4},   // sourceIPv4Address
+	{12, 4, 4},  // destinationIPv4Address
+	{7, 2, 2},   // sourceTransportPort
+	{11, 2, 2},  // destinationTransportPort
+	{4, 1, 1},   // protocolIdentifier
+	{6, 1, 1},   // tcpControlBits
+	{5, 1, 1},   // ipClassOfService
+	{10, 2, 2},  // ingressInterface
+	{14, 2, 2},  // egressInterface
+	{2, 4, 8},   // packetDeltaCount
+	{1, 4, 8},   // octetDeltaCount
+	{22, 4, 0},  // FIRST_SWITCHED, uptime in ms
+	{21, 4, 0},  // LAST_SWITCHED, uptime in ms
+	{152, 0, 8}, // flowStartMilliseconds
+	{153, 0, 8}, // flowEndMilliseconds
+}
+
+// templateID identifies the only template, the first ID data sets may use
+const templateID = 256
+
+// Sizes of the fixed parts of the packets
+const (
+	v5HeaderSize    = 24
+	v5RecordSize    = 48
+	v9HeaderSize    = 20
+	ipfixHeaderSize = 16
+)
+
+// exporter encodes flows into export packets of one version, keeping the
+// sequence numbers and when to resend the template
+type exporter struct {
+	version  string
+	boot     time.Time // sysUptime counts from here
+	sequence uint32    // packets (v9) or records (v5, IPFIX) exported so far
+	packets  int       // packets since the template was last sent
+	domain   uint32    // source ID or observation domain
+}
+
+// uptime returns the milliseconds since boot at t, as the v5 and v9 clocks
+func (x *exporter) uptime(t time.Time) uint32 {
+	if t.Before(x.boot) {
+		return 0
+	}
+	return uint32(t.Sub(x.boot).Milliseconds())
+}
+
+// encode encodes flows into one packet, which must fit the version's limit
+func (x *exporter) encode(flows []flow, now time.Time) []byte {
+	switch x.version {
+	case Version5:
+		return x.encodeV5(flows, now)
+	case Version9:
+		return x.encodeV9(flows, now)
+	}
+	return x.encodeIPFIX(flows, now)
+}
+
+// encodeV5 encodes a NetFlow v5 packet
+func (x *exporter) encodeV5(flows []flow, now time.Time) []byte {
+	b := make([]byte, 0, v5HeaderSize+v5RecordSize*len(flows))
+	b = binary.BigEndian.AppendUint16(b, 5)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(flows)))
+	b = binary.BigEndian.AppendUint32(b, x.uptime(now))
+	b = binary.BigEndian.AppendUint32(b, uint32(now.Unix()))
+	b = binary.BigEndian.AppendUint32(b, uint32(now.Nanosecond()))
+	b = binary.BigEndian.AppendUint32(b, x.sequence)
+	b = append(b, 0, byte(x.domain))        // engine type and ID
+	b = binary.BigEndian.AppendUint16(b, 0) // not sampled
+	x.sequence += uint32(len(flows))
+
+	for _, f := range flows {
+		b = append(b, f.src[:]...)
+		b = append(b, f.dst[:]...)
+		b = append(b, 0, 0, 0, 0) // next hop
+		b = binary.BigEndian.AppendUint16(b, f.input)
+		b = binary.BigEndian.AppendUint16(b, f.output)
+		b = binary.BigEndian.AppendUint32(b, uint32(f.packets))
+		b = binary.BigEndian.AppendUint32(b, uint32(f.bytes))
+		b = binary.BigEndian.AppendUint32(b, x.uptime(f.start))
+		b = binary.BigEndian.AppendUint32(b, x.uptime(f.end))
+		b = binary.BigEndian.AppendUint16(b, f.srcPort)
+		b = binary.BigEndian.AppendUint16(b, f.dstPort)
+		b = append(b, 0, f.tcpFlags, f.proto, f.tos)
+		b = binary.BigEndian.AppendUint32(b, 0) // source and destination AS
+		b = append(b, 8, 0, 0, 0)               // masks and padding
+	}
+	return b
+}
+
+// encodeV9 encodes a NetFlow v9 packet, with the template every
+// templateEvery packets
+func (x *exporter) encodeV9(flows []flow, now time.Time) []byte {
+	b := make([]byte, v9HeaderSize, 512)
+	count := len(flows)
+	if x.packets%templateEvery == 0 {
+		b = x.appendTemplate(b, 0, false)
+		count++
+	}
+	b = x.appendData(b, flows, false)
+	x.packets++
+
+	binary.BigEndian.PutUint16(b[0:], 9)
+	binary.BigEndian.PutUint16(b[2:], uint16(count))
+	binary.BigEndian.PutUint32(b[4:], x.uptime(now))
+	binary.BigEndian.PutUint32(b[8:], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(b[12:], x.sequence)
+	binary.BigEndian.PutUint32(b[16:], x.domain)
+	x.sequence++
+	return b
+}
+
+// encodeIPFIX encodes an IPFIX message, with the template every
+// templateEvery messages
+func (x *exporter) encodeIPFIX(flows []flow, now time.Time) []byte {
+	b := make([]byte, ipfixHeaderSize, 512)
+	if x.packets%templateEvery == 0 {
+		b = x.appendTemplate(b, 2, true)
+	}
+	b = x.appendData(b, flows, true)
+	x.packets++
+
+	binary.BigEndian.PutUint16(b[0:], 10)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	binary.BigEndian.PutUint32(b[4:], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(b[8:], x.sequence)
+	binary.BigEndian.PutUint32(b[12:], x.domain)
+	x.sequence += uint32(len(flows))
+	return b
+}
+
+// appendTemplate appends the template set, whose set ID is 0 in v9 and 2
+// in IPFIX
+func (x *exporter) appendTemplate(b []byte, setID uint16, ipfix bool) []byte {
+	start := len(b)
+	b = binary.BigEndian.AppendUint16(b, setID)
+	b = binary.BigEndian.AppendUint16(b, 0) // length, set below
+	b = binary.BigEndian.AppendUint16(b, templateID)
+	b = binary.BigEndian.AppendUint16(b, 0) // field count, set below
+	fields := 0
+	for _, field := range templateFields {
+		size := field.v9
+		if ipfix {
+			size = field.ipfix
+		}
+		if size == 0 {
+			continue
+		}
+		b = binary.BigEndian.AppendUint16(b, field.id)
+		b = binary.BigEndian.AppendUint16(b, size)
+		fields++
+	}
+	binary.BigEndian.PutUint16(b[start+2:], uint16(len(b)-start))
+	binary.BigEndian.PutUint16(b[start+6:], uint16(fields))
+	return b
+}
+
+// appendData appends a data set of flows following the template, padded to
+// four bytes
+func (x *exporter) appendData(b []byte, flows []flow, ipfix bool) []byte {
+	start := len(b)
+	b = binary.BigEndian.AppendUint16(b, templateID)
+	b = binary.BigEndian.AppendUint16(b, 0) // length, set below
+	for _, f := range flows {
+		b = append(b, f.src[:]...)
+		b = append(b, f.dst[:]...)
+		b = binary.BigEndian.AppendUint16(b, f.srcPort)
+		b = binary.BigEndian.AppendUint16(b, f.dstPort)
+		b = append(b, f.proto, f.tcpFlags, f.tos)
+		b = binary.BigEndian.AppendUint16(b, f.input)
+		b = binary.BigEndian.AppendUint16(b, f.output)
+		if ipfix {
+			b = binary.BigEndian.AppendUint64(b, f.packets)
+			b = binary.BigEndian.AppendUint64(b, f.bytes)
+			b = binary.BigEndian.AppendUint64(b, uint64(f.start.UnixMilli()))
+			b = binary.BigEndian.AppendUint64(b, uint64(f.end.UnixMilli()))
+		} else {
+			b = binary.BigEndian.AppendUint32(b, uint32(f.packets))
+			b = binary.BigEndian.AppendUint32(b, uint32(f.bytes))
+			b = binary.BigEndian.AppendUint32(b, x.uptime(f.start))
+			b = binary.BigEndian.AppendUint32(b, x.uptime(f.end))
+		}
+	}
+	for (len(b)-start)%4 != 0 {
+		b = append(b, 0)
+	}
+	binary.BigEndian.PutUint16(b[start+2:], uint16(len(b)-start))
+	return b
+}
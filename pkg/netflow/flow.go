@@ -0,0 +1,149 @@
+package netflow
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// IP protocol numbers
+const (
+	protoICMP = 1
+	protoTCP  = 6
+	protoUDP  = 17
+)
+
+// TCP flags seen over a whole connection: SYN, ACK, PSH and FIN
+const tcpFlagsSession = 0x02 | 0x10 | 0x08 | 0x01
+
+// flow is a unidirectional flow record, common to all export versions
+type flow struct {
+	src, dst         [4]byte
+	srcPort, dstPort uint16
+	proto            uint8
+	tcpFlags         uint8
+	tos              uint8
+	input, output    uint16 // SNMP interface indexes
+	packets, bytes   uint64
+	start, end       time.Time
+}
+
+// service is a destination port with its protocol and share of flows
+type service struct {
+	port   uint16
+	proto  uint8
+	weight float64
+}
+
+// services approximates the mix of an enterprise edge: mostly HTTPS, then
+// DNS, HTTP and a tail of infrastructure protocols
+var services = []service{
+	{443, protoTCP, 45},
+	{53, protoUDP, 15},
+	{80, protoTCP, 10},
+	{443, protoUDP, 6}, // QUIC
+	{123, protoUDP, 3},
+	{22, protoTCP, 3},
+	{25, protoTCP, 2},
+	{3306, protoTCP, 2},
+	{5432, protoTCP, 2},
+	{389, protoTCP, 2},
+	{8080, protoTCP, 3},
+	{0, protoICMP, 3},
+	{514, protoUDP, 2},
+	{161, protoUDP, 2},
+}
+
+// totalWeight is the sum of the weights of the services
+var totalWeight = func() float64 {
+	var total float64
+	for _, s := range services {
+		total += s.weight
+	}
+	return total
+}()
+
+// flowOf derives a flow from a log: its client address is the source, its
+// latency the duration, and requests go to web ports. The rest follows the
+// distributions of real traffic: heavy-tailed sizes, mostly short flows.
+func flowOf(e logger.Entry) flow {
+	f := flow{end: e.Time, input: uint16(1 + rand.Intn(4)), output: uint16(5 + rand.Intn(4))}
+	if f.end.IsZero() {
+		f.end = time.Now()
+	}
+
+	s := pickService()
+	if _, ok := e.Fields["http_method"]; ok {
+		s = service{port: 443, proto: protoTCP}
+		if rand.Intn(5) == 0 {
+			s.port = 80
+		}
+	}
+	f.proto, f.dstPort = s.proto, s.port
+	if s.proto != protoICMP {
+		f.srcPort = uint16(32768 + rand.Intn(28232)) // Linux ephemeral range
+	}
+	if s.proto == protoTCP {
+		f.tcpFlags = tcpFlagsSession
+	}
+
+	f.src = randomAddr(10)
+	if ip, ok := e.Fields["ip_address"].(string); ok {
+		if v4 := net.ParseIP(ip).To4(); v4 != nil {
+			copy(f.src[:], v4)
+		}
+	}
+	f.dst = randomAddr(0)
+
+	// Log-normal sizes: a median around 2KB with a tail of bulk transfers
+	f.bytes = uint64(math.Min(math.Max(math.Exp(rand.NormFloat64()*2+7.6), 40), 4e9))
+	packetSize := 64 + rand.Float64()*1436
+	f.packets = uint64(math.Max(1, math.Ceil(float64(f.bytes)/packetSize)))
+	if f.packets*40 > f.bytes {
+		f.bytes = f.packets * 40
+	}
+
+	duration := time.Duration(rand.ExpFloat64() * float64(2*time.Second))
+	switch ms := e.Fields["latency_ms"].(type) {
+	case int:
+		duration = time.Duration(ms) * time.Millisecond
+	case float64:
+		duration = time.Duration(ms * float64(time.Millisecond))
+	}
+	if duration < 0 {
+		duration = 0
+	}
+	if duration > 5*time.Minute {
+		duration = 5 * time.Minute
+	}
+	f.start = f.end.Add(-duration)
+	return f
+}
+
+// pickService picks a destination by the shares of the services
+func pickService() service {
+	r := rand.Float64() * totalWeight
+	for _, s := range services {
+		if r -= s.weight; r < 0 {
+			return s
+		}
+	}
+	return services[0]
+}
+
+// randomAddr returns a random IPv4 address, within 10.0.0.0/8 if first is
+// 10, otherwise a public-looking one
+func randomAddr(first byte) [4]byte {
+	addr := [4]byte{first, byte(rand.Intn(256)), byte(rand.Intn(256)), byte(1 + rand.Intn(254))}
+	if first == 0 {
+		// Skip the private, loopback and multicast first octets
+		addr[0] = byte(11 + rand.Intn(212))
+		if addr[0] == 127 || addr[0] == 172 || addr[0] == 192 {
+			addr[0]++
+		}
+	}
+	return addr
+}
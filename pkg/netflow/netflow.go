@@ -0,0 +1,148 @@
+package netflow
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// Export versions
+const (
+	// Version5 exports fixed NetFlow v5 records
+	Version5 = "5"
+	// Version9 exports NetFlow v9 records following a template
+	Version9 = "9"
+	// VersionIPFIX exports IPFIX (NetFlow v10) records following a template
+	VersionIPFIX = "ipfix"
+)
+
+const (
+	defaultPort     = "2055"
+	defaultInterval = time.Second
+	maxFlows        = 25 // flows per packet, keeping every version under 1500 bytes
+	templateEvery   = 20 // packets between repeats of the template, for collectors joining late
+	uptimeAtStart   = 24 * time.Hour
+)
+
+// ParseVersion validates an export version, 9 if empty
+func ParseVersion(version string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(version)); v {
+	case "":
+		return Version9, nil
+	case Version5, Version9, VersionIPFIX:
+		return v, nil
+	case "10":
+		return VersionIPFIX, nil
+	}
+	return "", fmt.Errorf("unknown NetFlow version %q (use %s, %s or %s)", version, Version5, Version9, VersionIPFIX)
+}
+
+// Config holds the configuration of the flow sink
+type Config struct {
+	Target   string        // host:port of the collector, port 2055 if omitted
+	Version  string        // Version5, Version9 or VersionIPFIX (default 9)
+	Interval time.Duration // Longest a flow waits to be exported (default 1s)
+}
+
+// Sink exports a flow record for every log to a flow collector over UDP.
+// Flows are batched into packets of up to 25, sent when full and every
+// interval, like an exporter's active timeout.
+type Sink struct {
+	conn     net.Conn
+	interval time.Duration
+	mutex    sync.Mutex
+	exporter exporter
+	pending  []flow
+	done     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// New creates a flow sink exporting to the collector
+func New(config Config) (*Sink, error) {
+	version, err := ParseVersion(config.Version)
+	if err != nil {
+		return nil, err
+	}
+	target := config.Target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultPort)
+	}
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach flow collector: %w", err)
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	s := &Sink{
+		conn:     conn,
+		interval: config.Interval,
+		exporter: exporter{version: version, boot: time.Now().Add(-uptimeAtStart), domain: rand.Uint32() & 0xff},
+		pending:  make([]flow, 0, maxFlows),
+		done:     make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Write queues the flow of a log, sending the packet it fills
+func (s *Sink) Write(e logger.Entry) error {
+	f := flowOf(e)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, f)
+	if len(s.pending) < maxFlows {
+		return nil
+	}
+	return s.send()
+}
+
+// Flush sends the flows queued so far
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.send()
+}
+
+// Close sends the queued flows and closes the socket
+func (s *Sink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	err := s.Flush()
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// run sends the queued flows every interval until closed
+func (s *Sink) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// send sends the queued flows as one packet; the mutex must be held
+func (s *Sink) send() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	packet := s.exporter.encode(s.pending, time.Now())
+	s.pending = s.pending[:0]
+	_, err := s.conn.Write(packet)
+	return err
+}
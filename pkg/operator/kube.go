@@ -0,0 +1,172 @@
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Files of the service account a pod runs as
+const (
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// errExpired is returned when a watch must start over from a new list
+var errExpired = errors.New("watch expired")
+
+// client talks to the Kubernetes API server over its REST API
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// newClient creates a client of the API server, the one of the cluster the
+// pod runs in if server is empty
+func newClient(server, token, caFile string) (*client, error) {
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("not running in a cluster: set the API server")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+		if token == "" {
+			data, err := os.ReadFile(serviceAccountToken)
+			if err != nil {
+				return nil, fmt.Errorf("reading the service account token: %w", err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		if caFile == "" {
+			caFile = serviceAccountCA
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading the CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &client{server: strings.TrimSuffix(server, "/"), token: token, http: &http.Client{Transport: transport}}, nil
+}
+
+// do sends a request with a JSON body, if any, and decodes the JSON
+// response into out, if not nil
+func (c *client) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := c.request(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// watch streams the events of a watch request to handle until the stream
+// ends, returning errExpired if the resource version is too old
+func (c *client) watch(ctx context.Context, path string, handle func(kind string, object json.RawMessage)) error {
+	req, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return errExpired
+	}
+	if resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("decoding watch event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			var status struct {
+				Code int `json:"code"`
+			}
+			if json.Unmarshal(event.Object, &status) == nil && status.Code == http.StatusGone {
+				return errExpired
+			}
+			return fmt.Errorf("watch failed: %s", event.Object)
+		}
+		handle(event.Type, event.Object)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// request creates an authenticated request of the API server
+func (c *client) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// apiError describes a failed request by the message of the API server
+func apiError(resp *http.Response) error {
+	var status struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(data, &status) == nil && status.Message != "" {
+		return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, status.Message)
+	}
+	return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+}
@@ -0,0 +1,476 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"github.com/rjonczy/log-genie/pkg/scenario"
+	"gopkg.in/yaml.v3"
+)
+
+// API of the LogLoad custom resource
+const (
+	Group   = "log-genie.rjonczy.github.io"
+	Version = "v1alpha1"
+	Kind    = "LogLoad"
+)
+
+const (
+	resource      = "logloads"
+	fieldManager  = "log-genie-operator"
+	defaultImage  = "log-genie:latest"
+	defaultResync = 30 * time.Second
+	retryDelay    = 5 * time.Second
+
+	// Scenarios are mounted into the generators from a ConfigMap
+	scenarioDir        = "/etc/log-genie/scenario"
+	defaultScenarioKey = "scenario.yaml"
+	scenarioHash       = Group + "/scenario-hash"
+)
+
+// Phases of a LogLoad
+const (
+	// PhaseRunning means every generator is ready
+	PhaseRunning = "Running"
+	// PhaseProgressing means generators are starting or stopping
+	PhaseProgressing = "Progressing"
+	// PhasePaused means the generators are scaled to zero
+	PhasePaused = "Paused"
+	// PhaseInvalid means the spec cannot be turned into generators
+	PhaseInvalid = "Invalid"
+	// PhaseFailed means the generators could not be deployed
+	PhaseFailed = "Failed"
+)
+
+// settingsInSpec are the flags a LogLoad sets through its spec, not its
+// settings
+var settingsInSpec = []string{"rate", "telemetry", "telemetry-endpoint", "scenario"}
+
+// LogLoad describes synthetic log load generated in a namespace
+type LogLoad struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     Spec     `json:"spec"`
+	Status   Status   `json:"status"`
+}
+
+// Metadata identifies a LogLoad
+type Metadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	UID             string `json:"uid"`
+	Generation      int64  `json:"generation"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// Spec is the load a LogLoad asks for
+type Spec struct {
+	Rate     string            `json:"rate,omitempty"`     // total rate shared by the replicas, e.g. 2k/s
+	Replicas *int              `json:"replicas,omitempty"` // generators sharing the rate, 1 if unset
+	Target   string            `json:"target,omitempty"`   // OTLP/HTTP endpoint the logs are exported to, stdout if empty
+	Paused   bool              `json:"paused,omitempty"`   // scales the generators to zero
+	Image    string            `json:"image,omitempty"`    // image of the generators, the operator's default if empty
+	Settings map[string]string `json:"settings,omitempty"` // further flags of the generators by name, e.g. preset: web
+	Scenario *Scenario         `json:"scenario,omitempty"` // streams every generator runs
+}
+
+// Scenario gives the generators of a LogLoad the streams of a scenario file,
+// inline or from a ConfigMap of the LogLoad's namespace
+type Scenario struct {
+	Streams   []map[string]interface{} `json:"streams,omitempty"`   // as in a scenario file
+	ConfigMap *ConfigMapKey            `json:"configMap,omitempty"` // holding a scenario file instead
+}
+
+// ConfigMapKey is a key of a ConfigMap
+type ConfigMapKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"` // scenario.yaml if empty
+}
+
+// Status is what the operator last made of a LogLoad
+type Status struct {
+	Phase              string `json:"phase,omitempty"`
+	Message            string `json:"message"` // empty once the LogLoad reconciles, clearing an earlier error
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Replicas           int    `json:"replicas"`
+	ReadyReplicas      int    `json:"readyReplicas"`
+	RatePerReplica     string `json:"ratePerReplica"`
+}
+
+// Config holds the configuration of the operator
+type Config struct {
+	Server    string        // URL of the API server, the cluster's own when running in a pod
+	Token     string        // Bearer token, the service account's when running in a pod
+	CAFile    string        // CA certificate of the API server, the service account's when running in a pod
+	Namespace string        // Namespace to watch, all if empty
+	Image     string        // Image of the generators unless a LogLoad names one
+	Resync    time.Duration // How often every LogLoad is reconciled again, refreshing its status (default 30s)
+}
+
+// Operator reconciles LogLoads into Deployments of generators
+type Operator struct {
+	client *client
+	config Config
+}
+
+// New creates an operator
+func New(config Config) (*Operator, error) {
+	c, err := newClient(config.Server, config.Token, config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if config.Image == "" {
+		config.Image = defaultImage
+	}
+	if config.Resync <= 0 {
+		config.Resync = defaultResync
+	}
+	return &Operator{client: c, config: config}, nil
+}
+
+// Run reconciles LogLoads as they change, and all of them every resync
+// interval, until ctx is done
+func (o *Operator) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := o.sync(ctx); err != nil && ctx.Err() == nil {
+			diag.Warn("Failed to sync LogLoads, retrying", "error", err, "retry_in", retryDelay.String())
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// sync reconciles every LogLoad, then those that change until the resync
+// interval has passed
+func (o *Operator) sync(ctx context.Context) error {
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []LogLoad `json:"items"`
+	}
+	if err := o.client.do(ctx, http.MethodGet, o.path(), "", nil, &list); err != nil {
+		return err
+	}
+	for _, load := range list.Items {
+		o.reconcile(ctx, load)
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, o.config.Resync)
+	defer cancel()
+	query := url.Values{"watch": {"1"}, "resourceVersion": {list.Metadata.ResourceVersion}}
+	err := o.client.watch(watchCtx, o.path()+"?"+query.Encode(), func(kind string, object json.RawMessage) {
+		if kind != "ADDED" && kind != "MODIFIED" {
+			// Deleted LogLoads take their Deployments along, as their owner
+			return
+		}
+		var load LogLoad
+		if err := json.Unmarshal(object, &load); err != nil {
+			diag.Warn("Failed to decode LogLoad", "error", err)
+			return
+		}
+		// Status updates leave the generation alone; the next resync
+		// refreshes them
+		if kind == "MODIFIED" && load.Metadata.Generation == load.Status.ObservedGeneration {
+			return
+		}
+		o.reconcile(ctx, load)
+	})
+	if errors.Is(err, errExpired) || watchCtx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// reconcile applies the Deployment of a LogLoad and records the outcome in
+// its status
+func (o *Operator) reconcile(ctx context.Context, load LogLoad) {
+	status := o.apply(ctx, load)
+	status.ObservedGeneration = load.Metadata.Generation
+
+	changed := load.Status.ObservedGeneration != status.ObservedGeneration || load.Status.Phase != status.Phase
+	kv := []interface{}{"namespace", load.Metadata.Namespace, "name", load.Metadata.Name, "phase", status.Phase}
+	switch {
+	case status.Phase == PhaseInvalid || status.Phase == PhaseFailed:
+		if changed {
+			diag.Warn("Failed to reconcile LogLoad", append(kv, "error", status.Message)...)
+		}
+	case changed:
+		diag.Info("Reconciled LogLoad", append(kv, "replicas", status.Replicas, "rate_per_replica", status.RatePerReplica)...)
+	}
+
+	path := fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s/%s/status", Group, Version, load.Metadata.Namespace, resource, load.Metadata.Name)
+	patch := map[string]interface{}{"status": status}
+	if err := o.client.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil); err != nil {
+		diag.Warn("Failed to update LogLoad status", "namespace", load.Metadata.Namespace, "name", load.Metadata.Name, "error", err)
+	}
+}
+
+// apply applies the Deployment of a LogLoad, returning the status it leads to
+func (o *Operator) apply(ctx context.Context, load LogLoad) Status {
+	deployment, configMap, status, err := o.deployment(load)
+	if err != nil {
+		return Status{Phase: PhaseInvalid, Message: err.Error()}
+	}
+	if configMap != nil {
+		path := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s?fieldManager=%s&force=true", load.Metadata.Namespace, scenarioConfigMap(load), fieldManager)
+		if err := o.client.do(ctx, http.MethodPatch, path, "application/apply-patch+yaml", configMap, nil); err != nil {
+			return Status{Phase: PhaseFailed, Message: err.Error()}
+		}
+	}
+
+	var applied struct {
+		Status struct {
+			ReadyReplicas int `json:"readyReplicas"`
+		} `json:"status"`
+	}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s?fieldManager=%s&force=true", load.Metadata.Namespace, load.Metadata.Name, fieldManager)
+	if err := o.client.do(ctx, http.MethodPatch, path, "application/apply-patch+yaml", deployment, &applied); err != nil {
+		return Status{Phase: PhaseFailed, Message: err.Error()}
+	}
+	status.ReadyReplicas = applied.Status.ReadyReplicas
+	switch {
+	case load.Spec.Paused:
+		status.Phase = PhasePaused
+	case status.ReadyReplicas >= status.Replicas:
+		status.Phase = PhaseRunning
+	default:
+		status.Phase = PhaseProgressing
+	}
+	return status
+}
+
+// deployment returns the Deployment running the generators of a LogLoad,
+// owned by it, the ConfigMap of its inline scenario if any and the status
+// they lead to once ready
+func (o *Operator) deployment(load LogLoad) (map[string]interface{}, map[string]interface{}, Status, error) {
+	replicas := 1
+	if load.Spec.Replicas != nil {
+		replicas = *load.Spec.Replicas
+	}
+	if replicas < 0 {
+		return nil, nil, Status{}, fmt.Errorf("replicas %d is negative", replicas)
+	}
+	mount, err := scenarioOf(load)
+	if err != nil {
+		return nil, nil, Status{}, fmt.Errorf("invalid scenario: %v", err)
+	}
+	args, perReplica, err := generatorArgs(load, replicas, mount)
+	if err != nil {
+		return nil, nil, Status{}, err
+	}
+	if load.Spec.Paused {
+		replicas = 0
+	}
+	image := load.Spec.Image
+	if image == "" {
+		image = o.config.Image
+	}
+
+	selector := map[string]interface{}{
+		"app.kubernetes.io/name":     "log-genie",
+		"app.kubernetes.io/instance": load.Metadata.Name,
+	}
+	labels := map[string]interface{}{"app.kubernetes.io/managed-by": fieldManager}
+	for k, v := range selector {
+		labels[k] = v
+	}
+	container := map[string]interface{}{
+		"name":  "log-genie",
+		"image": image,
+		"args":  args,
+	}
+	podSpec := map[string]interface{}{"containers": []interface{}{container}}
+	podMetadata := map[string]interface{}{"labels": labels}
+	var configMap map[string]interface{}
+	if mount != nil {
+		container["volumeMounts"] = []interface{}{map[string]interface{}{
+			"name":      "scenario",
+			"mountPath": scenarioDir,
+			"readOnly":  true,
+		}}
+		podSpec["volumes"] = []interface{}{map[string]interface{}{
+			"name":      "scenario",
+			"configMap": map[string]interface{}{"name": mount.configMap},
+		}}
+		if mount.rendered != nil {
+			// The generators read their scenario once: a new one rolls them
+			podMetadata["annotations"] = map[string]interface{}{
+				scenarioHash: fmt.Sprintf("%x", sha256.Sum256(mount.rendered)),
+			}
+			configMap = map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name":            mount.configMap,
+					"namespace":       load.Metadata.Namespace,
+					"labels":          labels,
+					"ownerReferences": ownerReferences(load),
+				},
+				"data": map[string]interface{}{mount.key: string(mount.rendered)},
+			}
+		}
+	}
+	deployment := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            load.Metadata.Name,
+			"namespace":       load.Metadata.Namespace,
+			"labels":          labels,
+			"ownerReferences": ownerReferences(load),
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"selector": map[string]interface{}{"matchLabels": selector},
+			"template": map[string]interface{}{
+				"metadata": podMetadata,
+				"spec":     podSpec,
+			},
+		},
+	}
+	return deployment, configMap, Status{Replicas: replicas, RatePerReplica: perReplica}, nil
+}
+
+// ownerReferences makes an object owned by a LogLoad, deleted along with it
+func ownerReferences(load LogLoad) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"apiVersion":         Group + "/" + Version,
+		"kind":               Kind,
+		"name":               load.Metadata.Name,
+		"uid":                load.Metadata.UID,
+		"controller":         true,
+		"blockOwnerDeletion": true,
+	}}
+}
+
+// scenarioMount is the scenario file mounted into the generators of a LogLoad
+type scenarioMount struct {
+	configMap string
+	key       string
+	rendered  []byte  // of inline streams, nil for a ConfigMap of the user's
+	total     float64 // rate of inline streams, 0 if unknown
+}
+
+// scenarioOf returns the scenario of a LogLoad, nil if it has none. Inline
+// streams are validated and rendered into a scenario file.
+func scenarioOf(load LogLoad) (*scenarioMount, error) {
+	s := load.Spec.Scenario
+	switch {
+	case s == nil:
+		return nil, nil
+	case len(s.Streams) > 0 && s.ConfigMap != nil:
+		return nil, errors.New("give inline streams or a ConfigMap, not both")
+	case s.ConfigMap != nil:
+		if s.ConfigMap.Name == "" {
+			return nil, errors.New("no ConfigMap name")
+		}
+		key := s.ConfigMap.Key
+		if key == "" {
+			key = defaultScenarioKey
+		}
+		if strings.Contains(key, "/") {
+			return nil, fmt.Errorf("invalid ConfigMap key %q", key)
+		}
+		return &scenarioMount{configMap: s.ConfigMap.Name, key: key}, nil
+	case len(s.Streams) == 0:
+		return nil, errors.New("no streams or ConfigMap")
+	}
+	rendered, err := yaml.Marshal(map[string]interface{}{"streams": s.Streams})
+	if err != nil {
+		return nil, err
+	}
+	streams, err := scenario.Parse(rendered)
+	if err != nil {
+		return nil, err
+	}
+	return &scenarioMount{
+		configMap: scenarioConfigMap(load),
+		key:       defaultScenarioKey,
+		rendered:  rendered,
+		total:     scenario.Total(streams),
+	}, nil
+}
+
+// scenarioConfigMap returns the name of the ConfigMap of the inline scenario
+// of a LogLoad
+func scenarioConfigMap(load LogLoad) string {
+	return load.Metadata.Name + "-scenario"
+}
+
+// generatorArgs returns the flags of the generators of a LogLoad, each
+// taking its share of the rate, and that share. The generators of an inline
+// scenario without a rate share the sum of its streams' rates.
+func generatorArgs(load LogLoad, replicas int, mount *scenarioMount) ([]string, string, error) {
+	var args []string
+	perReplica := ""
+	rate, set := 0.0, load.Spec.Rate != ""
+	if set {
+		var err error
+		rate, err = ratelimit.Parse(load.Spec.Rate)
+		if err == nil {
+			err = ratelimit.Validate(rate)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid rate %q: %v", load.Spec.Rate, err)
+		}
+	} else if mount != nil {
+		rate, set = mount.total, true
+	}
+	if mount != nil && rate == ratelimit.Unlimited {
+		return nil, "", errors.New("a scenario cannot run at the max rate")
+	}
+	if set {
+		if rate != ratelimit.Unlimited && replicas > 1 {
+			rate /= float64(replicas)
+		}
+		perReplica = ratelimit.Format(rate)
+		args = append(args, "--rate="+perReplica)
+	}
+	if mount != nil {
+		args = append(args, "--scenario="+scenarioDir+"/"+mount.key)
+	}
+	if load.Spec.Target != "" {
+		args = append(args, "--telemetry", "--telemetry-endpoint="+load.Spec.Target)
+	}
+
+	names := make([]string, 0, len(load.Spec.Settings))
+	for name := range load.Spec.Settings {
+		for _, reserved := range settingsInSpec {
+			if name == reserved {
+				return nil, "", fmt.Errorf("setting %s is set through the spec", name)
+			}
+		}
+		if name == "" || strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+			return nil, "", fmt.Errorf("invalid setting name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--"+name+"="+load.Spec.Settings[name])
+	}
+	if _, ok := load.Spec.Settings["application-id"]; !ok {
+		args = append(args, "--application-id="+load.Metadata.Name)
+	}
+	return args, perReplica, nil
+}
+
+// path returns the path of the LogLoads watched
+func (o *Operator) path() string {
+	if o.config.Namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", Group, Version, resource)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, o.config.Namespace, resource)
+}
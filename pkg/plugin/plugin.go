@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"fmt"
+	goplugin "plugin"
+	"sort"
+
+	"github.com/rjonczy/log-genie/pkg/generator"
+)
+
+// RegisterSymbol is the function every plugin exports to register what it
+// provides:
+//
+//	func Register(r *plugin.Registry) error
+const RegisterSymbol = "Register"
+
+// SourceFactory creates a source of logs replacing the built-in generator.
+// The source is called concurrently when several workers generate.
+type SourceFactory func(options map[string]string) (func(errorLog bool) generator.Event, error)
+
+// SinkFactory creates a sink every log is sent to
+type SinkFactory func(options map[string]string) (generator.Sink, error)
+
+// Registry holds the sources and sinks registered by plugins, by name
+type Registry struct {
+	sources map[string]SourceFactory
+	sinks   map[string]SinkFactory
+	err     error // first registration conflict
+}
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{sources: map[string]SourceFactory{}, sinks: map[string]SinkFactory{}}
+}
+
+// Source registers a source of logs under name
+func (r *Registry) Source(name string, factory SourceFactory) {
+	if _, ok := r.sources[name]; ok && r.err == nil {
+		r.err = fmt.Errorf("source %q registered twice", name)
+	}
+	r.sources[name] = factory
+}
+
+// Sink registers a sink under name
+func (r *Registry) Sink(name string, factory SinkFactory) {
+	if _, ok := r.sinks[name]; ok && r.err == nil {
+		r.err = fmt.Errorf("sink %q registered twice", name)
+	}
+	r.sinks[name] = factory
+}
+
+// Load opens a plugin built with -buildmode=plugin against the same version
+// of log-genie and calls its Register function
+func (r *Registry) Load(path string) error {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return err
+	}
+	symbol, err := p.Lookup(RegisterSymbol)
+	if err != nil {
+		return err
+	}
+	register, ok := symbol.(func(*Registry) error)
+	if !ok {
+		return fmt.Errorf("%s has type %T, expected func(*plugin.Registry) error", RegisterSymbol, symbol)
+	}
+	if err := register(r); err != nil {
+		return err
+	}
+	return r.err
+}
+
+// NewSource creates the source registered under name
+func (r *Registry) NewSource(name string, options map[string]string) (func(errorLog bool) generator.Event, error) {
+	factory, ok := r.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q (registered: %v)", name, names(r.sources))
+	}
+	return factory(options)
+}
+
+// NewSink creates the sink registered under name
+func (r *Registry) NewSink(name string, options map[string]string) (generator.Sink, error) {
+	factory, ok := r.sinks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q (registered: %v)", name, names(r.sinks))
+	}
+	return factory(options)
+}
+
+// Sources returns the names of the registered sources, sorted
+func (r *Registry) Sources() []string {
+	return names(r.sources)
+}
+
+// Sinks returns the names of the registered sinks, sorted
+func (r *Registry) Sinks() []string {
+	return names(r.sinks)
+}
+
+// names returns the keys of a map, sorted
+func names[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
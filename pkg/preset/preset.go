@@ -0,0 +1,53 @@
+package preset
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/config"
+)
+
+//go:embed presets/*.yaml
+var files embed.FS
+
+// Names returns the names of the built-in presets
+func Names() []string {
+	entries, _ := files.ReadDir("presets")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the settings of a preset, keyed by flag name like a config file
+func Load(name string) (map[string]string, error) {
+	data, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	return config.Parse(data)
+}
+
+// Description returns the one-line description at the top of a preset
+func Description(name string) string {
+	data, err := read(name)
+	if err != nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(strings.TrimPrefix(first, "#"))
+}
+
+// read returns the contents of a preset file
+func read(name string) ([]byte, error) {
+	data, err := files.ReadFile(path.Join("presets", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return data, nil
+}
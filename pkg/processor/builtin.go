@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"math/rand"
+	"regexp"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// Redacted replaces the values hidden by Redact and Mask
+const Redacted = "[REDACTED]"
+
+// Redact replaces the values of the named fields, where present
+func Redact(fields ...string) Processor {
+	return Func(func(e *logger.Entry) bool {
+		for _, name := range fields {
+			if _, ok := e.Fields[name]; ok {
+				e.Fields[name] = Redacted
+			}
+		}
+		return true
+	})
+}
+
+// Mask replaces the matches of pattern in the message and in string fields
+func Mask(pattern *regexp.Regexp) Processor {
+	return Func(func(e *logger.Entry) bool {
+		e.Message = pattern.ReplaceAllString(e.Message, Redacted)
+		for name, value := range e.Fields {
+			if s, ok := value.(string); ok {
+				e.Fields[name] = pattern.ReplaceAllString(s, Redacted)
+			}
+		}
+		return true
+	})
+}
+
+// Sample keeps each log with probability ratio
+func Sample(ratio float64) Processor {
+	return Func(func(*logger.Entry) bool {
+		return rand.Float64() < ratio
+	})
+}
+
+// RateLimit keeps at most rate logs per second, dropping the rest
+func RateLimit(rate float64) (Processor, error) {
+	limiter, err := ratelimit.NewLimiter(rate)
+	if err != nil {
+		return nil, err
+	}
+	return Func(func(*logger.Entry) bool {
+		return limiter.Allow()
+	}), nil
+}
+
+// Enrich adds static fields, replacing generated ones of the same name
+func Enrich(fields map[string]string) Processor {
+	return Func(func(e *logger.Entry) bool {
+		for name, value := range fields {
+			e.Fields[name] = value
+		}
+		return true
+	})
+}
+
+// SpanEvents shapes logs like span events, for backends merging the two:
+// every log gets an event.name, and error logs become exception events
+// carrying exception.type, exception.message and exception.stacktrace (per
+// the semantic conventions) in place of error_type and stack_trace
+func SpanEvents() Processor {
+	return Func(func(e *logger.Entry) bool {
+		if e.Level == logger.Error {
+			e.Fields["event.name"] = "exception"
+			exceptionType, ok := e.Fields["error_type"]
+			if !ok {
+				exceptionType = "Error"
+			}
+			e.Fields["exception.type"] = exceptionType
+			e.Fields["exception.message"] = e.Message
+			if stack, ok := e.Fields["stack_trace"]; ok {
+				e.Fields["exception.stacktrace"] = stack
+			}
+			delete(e.Fields, "error_type")
+			delete(e.Fields, "stack_trace")
+			return true
+		}
+		if _, ok := e.Fields["http_method"]; ok {
+			e.Fields["event.name"] = "http.server.request"
+		} else {
+			e.Fields["event.name"] = "app.log"
+		}
+		return true
+	})
+}
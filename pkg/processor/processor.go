@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// Processor handles every log between generation and the sinks, like the
+// processors of a collector. It may change the log in place and reports
+// false to drop it from all sinks. Process is called concurrently when
+// several workers generate, and must not keep the fields once it returns:
+// the generator reuses them for later logs.
+type Processor interface {
+	Process(e *logger.Entry) bool
+}
+
+// Func adapts a function to a Processor
+type Func func(e *logger.Entry) bool
+
+// Process calls f
+func (f Func) Process(e *logger.Entry) bool {
+	return f(e)
+}
+
+// Chain runs processors in order, stopping at the first that drops the log
+type Chain []Processor
+
+// Process runs the chain on a log, reporting false if a processor dropped it
+func (c Chain) Process(e *logger.Entry) bool {
+	for _, p := range c {
+		if !p.Process(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Routes sends the logs of a level only to the named sinks (as counted in
+// the stats, e.g. stdout and otlp). Levels without a route go to every sink.
+type Routes map[logger.LogLevel][]string
+
+// Apply returns transforms filtering the logs of each sink by the routes
+// before running the sink's own transform from transforms
+func (r Routes) Apply(transforms logger.Transforms) logger.Transforms {
+	if len(r) == 0 {
+		return transforms
+	}
+	sinks := map[string]bool{logger.AllSinks: true}
+	for sink := range transforms {
+		sinks[sink] = true
+	}
+	for _, names := range r {
+		for _, sink := range names {
+			sinks[sink] = true
+		}
+	}
+
+	routed := make(logger.Transforms, len(sinks))
+	for sink := range sinks {
+		sink, next := sink, transforms.For(sink)
+		routed[sink] = func(e *logger.Entry) bool {
+			if !r.allows(sink, e.Level) {
+				return false
+			}
+			return next == nil || next(e)
+		}
+	}
+	return routed
+}
+
+// allows reports whether logs of a level go to a sink
+func (r Routes) allows(sink string, level logger.LogLevel) bool {
+	names, ok := r[level]
+	if !ok {
+		return true
+	}
+	for _, name := range names {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads processors given as stages separated by ';', each a name and
+// its arguments after a colon, in the order they run:
+//
+//	redact:password,token       replace the values of fields
+//	mask:\d{4}-\d{4}            replace matches in the message and string fields
+//	sample:0.1                  keep a share of logs
+//	sample:key=service,rate=10  head-sample by a field, see Sampler
+//	rate-limit:100/s            keep at most this rate of logs
+//	enrich:env=prod,region=eu   add fields
+//	route:error=otlp|stdout     send the logs of a level only to these sinks
+func Parse(spec string) (Chain, Routes, error) {
+	var chain Chain
+	var routes Routes
+	for _, stage := range strings.Split(spec, ";") {
+		stage = strings.TrimSpace(stage)
+		if stage == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(stage, ":")
+		args = strings.TrimSpace(args)
+		if args == "" {
+			return nil, nil, fmt.Errorf("processor %q needs arguments", name)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "redact":
+			chain = append(chain, Redact(splitList(args)...))
+		case "mask":
+			pattern, err := regexp.Compile(args)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid mask pattern: %w", err)
+			}
+			chain = append(chain, Mask(pattern))
+		case "sample":
+			sampler, err := parseSampler(args)
+			if err != nil {
+				return nil, nil, err
+			}
+			chain = append(chain, sampler)
+		case "rate-limit":
+			rate, err := ratelimit.Parse(args)
+			if err != nil {
+				return nil, nil, err
+			}
+			limit, err := RateLimit(rate)
+			if err != nil {
+				return nil, nil, err
+			}
+			chain = append(chain, limit)
+		case "enrich":
+			attributes, err := logger.ParseAttributes(args)
+			if err != nil {
+				return nil, nil, err
+			}
+			chain = append(chain, Enrich(attributes))
+		case "route":
+			pairs, err := logger.ParseAttributes(args)
+			if err != nil {
+				return nil, nil, err
+			}
+			if routes == nil {
+				routes = Routes{}
+			}
+			for levelName, sinks := range pairs {
+				level, ok := logger.ParseLevel(levelName)
+				if !ok {
+					return nil, nil, fmt.Errorf("unknown level %q in route", levelName)
+				}
+				routes[level] = append(routes[level], strings.Split(sinks, "|")...)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown processor %q (use redact, mask, sample, rate-limit, enrich or route)", name)
+		}
+	}
+	return chain, routes, nil
+}
+
+// splitList splits comma-separated names, dropping empty ones
+func splitList(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+)
+
+// maxSampledKeys bounds the rate limiters of a sampler; further values of
+// its key share one
+const maxSampledKeys = 10000
+
+// Sampler head-samples logs the way a client-side sampler would, so its
+// effect on downstream analytics can be observed. Logs are kept with
+// probability Ratio, then at most Rate per second are kept. With a Key the
+// decision is made per value of that field: all logs sharing a value (e.g.
+// a trace ID) are kept or dropped together, and every value (e.g. each
+// service) gets its own rate.
+type Sampler struct {
+	Key   string  // Field (or level) to sample by, empty to sample every log alike
+	Ratio float64 // Share of logs (or of key values) kept, 0 to 1
+	Rate  float64 // Maximum logs per second kept for each key value, 0 for no limit
+
+	mutex    sync.Mutex
+	limiters map[string]*ratelimit.Limiter
+	overflow *ratelimit.Limiter // shared by values beyond maxSampledKeys
+}
+
+// NewSampler creates a sampler, checking its settings
+func NewSampler(key string, ratio, rate float64) (*Sampler, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid sample ratio %g: must be between 0 and 1", ratio)
+	}
+	if rate != 0 {
+		if err := ratelimit.Validate(rate); err != nil {
+			return nil, err
+		}
+	}
+	return &Sampler{Key: key, Ratio: ratio, Rate: rate}, nil
+}
+
+// Process reports whether the sampler keeps a log
+func (s *Sampler) Process(e *logger.Entry) bool {
+	value, keyed := s.value(e)
+
+	if s.Ratio < 1 {
+		if keyed {
+			if hashFraction(value) >= s.Ratio {
+				return false
+			}
+		} else if rand.Float64() >= s.Ratio {
+			return false
+		}
+	}
+
+	if s.Rate > 0 {
+		return s.limiter(value).Allow()
+	}
+	return true
+}
+
+// value returns the value of the key in a log, the level if no field is
+// named level
+func (s *Sampler) value(e *logger.Entry) (string, bool) {
+	if s.Key == "" {
+		return "", false
+	}
+	if v, ok := e.Fields[s.Key]; ok {
+		return fmt.Sprint(v), true
+	}
+	if s.Key == "level" {
+		return string(e.Level), true
+	}
+	return "", false
+}
+
+// limiter returns the rate limiter of a key value, creating it on first use
+func (s *Sampler) limiter(value string) *ratelimit.Limiter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if limiter, ok := s.limiters[value]; ok {
+		return limiter
+	}
+	if s.limiters == nil {
+		s.limiters = map[string]*ratelimit.Limiter{}
+	}
+	if len(s.limiters) >= maxSampledKeys {
+		if s.overflow == nil {
+			s.overflow, _ = ratelimit.NewLimiter(s.Rate)
+		}
+		return s.overflow
+	}
+	limiter, _ := ratelimit.NewLimiter(s.Rate)
+	s.limiters[value] = limiter
+	return limiter
+}
+
+// hashFraction maps a value to [0, 1) consistently
+func hashFraction(value string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// parseSampler reads the arguments of the sample processor: a ratio alone,
+// or key, ratio and rate settings, e.g. key=service,rate=10/s
+func parseSampler(args string) (Processor, error) {
+	if ratio, err := strconv.ParseFloat(args, 64); err == nil {
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid sample ratio %q: must be between 0 and 1", args)
+		}
+		return Sample(ratio), nil
+	}
+
+	settings, err := logger.ParseAttributes(args)
+	if err != nil {
+		return nil, err
+	}
+	key, ratio, rate := "", 1.0, 0.0
+	for name, value := range settings {
+		switch name {
+		case "key":
+			key = value
+		case "ratio":
+			if ratio, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid sample ratio %q", value)
+			}
+		case "rate":
+			if rate, err = ratelimit.Parse(value); err != nil {
+				return nil, err
+			}
+			if math.IsInf(rate, 1) {
+				rate = 0
+			}
+		default:
+			return nil, fmt.Errorf("unknown sample setting %q (use key, ratio or rate)", name)
+		}
+	}
+	return NewSampler(key, ratio, rate)
+}
@@ -0,0 +1,37 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Arrival processes
+const (
+	ArrivalFixed   = "fixed"
+	ArrivalPoisson = "poisson"
+	ArrivalUniform = "uniform"
+)
+
+// Arrival draws the token cost of the next event. Costs average 1, so the
+// mean rate is preserved while the inter-arrival times follow the process.
+type Arrival func() float64
+
+// NewArrival creates an arrival process. jitter is the relative spread of
+// the uniform process, between 0 and 1.
+func NewArrival(kind string, jitter float64) (Arrival, error) {
+	switch kind {
+	case ArrivalFixed, "":
+		return func() float64 { return 1 }, nil
+	case ArrivalPoisson:
+		// Exponentially distributed gaps with mean 1 give a Poisson process
+		return func() float64 { return -math.Log(1 - gofakeit.Float64Range(0, 1)) }, nil
+	case ArrivalUniform:
+		if jitter < 0 || jitter > 1 {
+			return nil, fmt.Errorf("jitter %g is out of range [0, 1]", jitter)
+		}
+		return func() float64 { return 1 + jitter*(2*gofakeit.Float64Range(0, 1)-1) }, nil
+	}
+	return nil, fmt.Errorf("unknown arrival process %q (use fixed, poisson or uniform)", kind)
+}
@@ -0,0 +1,82 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backpressure policies, for when sinks cannot keep up with the rate
+const (
+	// BackpressureHold keeps the rate, the sinks dropping the logs they
+	// cannot take
+	BackpressureHold = "hold"
+	// BackpressureAdapt slows the rate down until the sinks keep up
+	BackpressureAdapt = "adapt"
+)
+
+// ParseBackpressure validates a backpressure policy, hold if empty
+func ParseBackpressure(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return BackpressureHold, nil
+	case BackpressureHold, BackpressureAdapt:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown backpressure policy %q (use %s or %s)", policy, BackpressureHold, BackpressureAdapt)
+}
+
+// Thresholds of Backpressure: the rate is cut while the pressure is at or
+// above pressureHigh, again every backoffInterval as long as the pressure
+// has not fallen since the last cut, and recovers while it is below
+// pressureLow
+const (
+	pressureHigh    = 0.8
+	pressureLow     = 0.5
+	backoffInterval = 500 * time.Millisecond
+	backoffFactor   = 0.7  // share of the rate kept by a cut
+	recoveryStep    = 0.02 // share of the base rate regained per evaluation
+	minRateShare    = 0.01
+)
+
+// Backpressure slows the rate down while the sinks push back, by
+// multiplicative decrease, and brings it back up to the base rate once they
+// have caught up, by additive increase
+type Backpressure struct {
+	Signal func() float64 // how hard the sinks push back, from 0 to 1
+
+	mutex    sync.Mutex
+	slowed   float64 // share of the base rate taken off
+	cut      float64 // pressure at the last cut
+	deepest  float64
+	cutAt    time.Duration
+	backoffs int64
+}
+
+// Rate returns the base rate less the share taken off
+func (b *Backpressure) Rate(base float64, elapsed time.Duration) float64 {
+	pressure := b.Signal()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch {
+	case pressure >= pressureHigh && (b.backoffs == 0 || elapsed-b.cutAt >= backoffInterval && pressure >= b.cut):
+		b.slowed = math.Min(1-minRateShare, 1-(1-b.slowed)*backoffFactor)
+		b.deepest = math.Max(b.deepest, b.slowed)
+		b.cut, b.cutAt = pressure, elapsed
+		b.backoffs++
+	case pressure < pressureLow:
+		b.slowed = math.Max(0, b.slowed-recoveryStep)
+	}
+	return base * (1 - b.slowed)
+}
+
+// Backoffs returns how often the rate was cut, and the lowest share of the
+// base rate it was cut to
+func (b *Backpressure) Backoffs() (int64, float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.backoffs, 1 - b.deepest
+}
@@ -0,0 +1,103 @@
+package rate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteUnits maps size units to their value in bytes. Decimal units follow SI,
+// binary units (KiB, MiB...) powers of 1024.
+var byteUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// ParseBytes converts a throughput expression such as "5MB/s", "512KiB/s" or
+// "1GB/m" into bytes per second. A missing period means per second.
+func ParseBytes(expr string) (float64, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, fmt.Errorf("empty throughput")
+	}
+
+	sizePart, periodPart, hasPeriod := strings.Cut(expr, "/")
+	size, err := ParseSize(sizePart)
+	if err != nil {
+		return 0, fmt.Errorf("invalid throughput %q: %w", expr, err)
+	}
+
+	period := 1.0
+	if hasPeriod {
+		var ok bool
+		if period, ok = periods[strings.ToLower(strings.TrimSpace(periodPart))]; !ok {
+			return 0, fmt.Errorf("invalid throughput %q: unknown period %q (use s, m, h or d)", expr, periodPart)
+		}
+	}
+
+	return size / period, nil
+}
+
+// ParseSize converts a size such as "512", "4KB" or "1.5MiB" into bytes
+func ParseSize(expr string) (float64, error) {
+	expr = strings.TrimSpace(expr)
+	i := len(expr)
+	for i > 0 && (expr[i-1] < '0' || expr[i-1] > '9') && expr[i-1] != '.' {
+		i--
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(expr[:i]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size", expr)
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(expr[i:]))
+	if unit == "" {
+		return n, nil
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q (use B, KB, MB, GB, KiB, MiB or GiB)", expr[i:])
+	}
+	return n * multiplier, nil
+}
+
+// FormatBytes renders a byte rate using decimal units
+func FormatBytes(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1e9:
+		return strconv.FormatFloat(bytesPerSecond/1e9, 'g', 4, 64) + "GB/s"
+	case bytesPerSecond >= 1e6:
+		return strconv.FormatFloat(bytesPerSecond/1e6, 'g', 4, 64) + "MB/s"
+	case bytesPerSecond >= 1e3:
+		return strconv.FormatFloat(bytesPerSecond/1e3, 'g', 4, 64) + "KB/s"
+	}
+	return strconv.FormatFloat(bytesPerSecond, 'g', 4, 64) + "B/s"
+}
+
+// ByteFlag is a flag.Value accepting throughput expressions understood by
+// ParseBytes; zero means throughput pacing is disabled
+type ByteFlag float64
+
+// String returns the formatted throughput
+func (f *ByteFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return FormatBytes(float64(*f))
+}
+
+// Set parses a throughput expression
+func (f *ByteFlag) Set(expr string) error {
+	b, err := ParseBytes(expr)
+	if err != nil {
+		return err
+	}
+	*f = ByteFlag(b)
+	return nil
+}
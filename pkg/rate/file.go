@@ -0,0 +1,204 @@
+package rate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point is a single step of a load profile file
+type Point struct {
+	At         time.Duration // Elapsed time, or time of day for wall-clock profiles
+	Rate       float64       // Absolute rate, used unless Multiplier is set
+	Multiplier float64       // Factor applied to the base rate (0 if unset)
+	ErrorRatio float64       // Share of error logs, negative if unset
+}
+
+// FileProfile replays a time series of rates and error ratios. Each point
+// holds until the next one; after the last point its values stay in effect
+// (elapsed profiles) or wrap around to the next day (wall-clock profiles).
+type FileProfile struct {
+	points    []Point
+	wallClock bool
+}
+
+// LoadProfile reads a profile file. Each non-empty, non-comment line holds a
+// time, a rate and an optional error ratio separated by whitespace:
+//
+//	# elapsed  rate    error_ratio
+//	0s         100/s   0.05
+//	5m         1k/s    0.2
+//	20m        x0.5
+//
+// Times are either durations since start (0s, 5m, 1h30m) or wall-clock times
+// of day (09:00, 17:30:00); a file must use one kind only. Rates use the
+// --rate syntax, or "xN" to multiply the base rate.
+func LoadProfile(path string) (*FileProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile: %w", err)
+	}
+	defer file.Close()
+
+	p := &FileProfile{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		point, wallClock, err := parsePoint(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if len(p.points) > 0 && wallClock != p.wallClock {
+			return nil, fmt.Errorf("%s:%d: cannot mix elapsed durations and times of day", path, lineNo)
+		}
+		p.wallClock = wallClock
+		p.points = append(p.points, point)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	if len(p.points) == 0 {
+		return nil, fmt.Errorf("profile %s has no points", path)
+	}
+
+	sort.SliceStable(p.points, func(i, j int) bool { return p.points[i].At < p.points[j].At })
+	return p, nil
+}
+
+// Points returns the points of the profile ordered by time
+func (p *FileProfile) Points() []Point {
+	return p.points
+}
+
+// WallClock reports whether point times are times of day
+func (p *FileProfile) WallClock() bool {
+	return p.wallClock
+}
+
+// Rate returns the rate of the point in effect
+func (p *FileProfile) Rate(base float64, elapsed time.Duration) float64 {
+	point := p.current(elapsed)
+	if point.Multiplier > 0 {
+		return base * point.Multiplier
+	}
+	return point.Rate
+}
+
+// ErrorRatio returns the error ratio of the point in effect, if the profile
+// defines one at or before it
+func (p *FileProfile) ErrorRatio(elapsed time.Duration) (float64, bool) {
+	at := p.position(elapsed)
+	ratio := -1.0
+	for _, point := range p.points {
+		if point.At > at {
+			break
+		}
+		if point.ErrorRatio >= 0 {
+			ratio = point.ErrorRatio
+		}
+	}
+	// Wall-clock profiles carry the last ratio of the previous day over midnight
+	if ratio < 0 && p.wallClock {
+		for _, point := range p.points {
+			if point.ErrorRatio >= 0 {
+				ratio = point.ErrorRatio
+			}
+		}
+	}
+	return ratio, ratio >= 0
+}
+
+// current returns the point in effect after elapsed time
+func (p *FileProfile) current(elapsed time.Duration) Point {
+	at := p.position(elapsed)
+	i := sort.Search(len(p.points), func(i int) bool { return p.points[i].At > at }) - 1
+	if i < 0 {
+		if p.wallClock {
+			// Before the first time of day: yesterday's last point is in effect
+			return p.points[len(p.points)-1]
+		}
+		return p.points[0]
+	}
+	return p.points[i]
+}
+
+// position maps elapsed time to the profile's time axis
+func (p *FileProfile) position(elapsed time.Duration) time.Duration {
+	if !p.wallClock {
+		return elapsed
+	}
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return now.Sub(midnight)
+}
+
+// parsePoint parses a single profile line
+func parsePoint(line string) (Point, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Point{}, false, fmt.Errorf("expected <time> <rate> [error_ratio], got %q", line)
+	}
+
+	point := Point{ErrorRatio: -1}
+	wallClock := strings.Contains(fields[0], ":")
+	if wallClock {
+		at, err := parseTimeOfDay(fields[0])
+		if err != nil {
+			return Point{}, false, err
+		}
+		point.At = at
+	} else {
+		at, err := time.ParseDuration(fields[0])
+		if err != nil || at < 0 {
+			return Point{}, false, fmt.Errorf("invalid time %q", fields[0])
+		}
+		point.At = at
+	}
+
+	if multiplier, ok := strings.CutPrefix(fields[1], "x"); ok {
+		m, err := strconv.ParseFloat(multiplier, 64)
+		if err != nil || m <= 0 {
+			return Point{}, false, fmt.Errorf("invalid multiplier %q", fields[1])
+		}
+		point.Multiplier = m
+	} else {
+		r, err := Parse(fields[1])
+		if err != nil {
+			return Point{}, false, err
+		}
+		point.Rate = r
+	}
+
+	if len(fields) == 3 {
+		ratio, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return Point{}, false, fmt.Errorf("invalid error ratio %q: must be between 0 and 1", fields[2])
+		}
+		point.ErrorRatio = ratio
+	}
+
+	return point, wallClock, nil
+}
+
+// parseTimeOfDay parses HH:MM or HH:MM:SS into an offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q", s)
+}
@@ -0,0 +1,334 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// burstWindow is how much time worth of tokens the bucket may hold. It lets
+// high rates absorb timer and scheduling granularity (a sleep rarely lasts
+// less than ~50µs) without losing throughput.
+const burstWindow = 10 * time.Millisecond
+
+// maxWait bounds the time a waiter sleeps, as long as it waits for a rate
+// while paused
+const maxWait = 100 * 365 * 24 * time.Hour
+
+// MinRate and MaxRate bound the event rates the limiter is designed for,
+// besides 0, which pauses it until the rate is raised. MinRate is a bit
+// under one event per day.
+const (
+	MinRate = 0.00001
+	MaxRate = 10_000_000
+)
+
+// Unlimited is the rate of a limiter that never waits, for measuring the
+// maximum throughput of a pipeline
+var Unlimited = math.Inf(1)
+
+// MinByteRate and MaxByteRate bound the byte rates of throughput limiters
+const (
+	MinByteRate = 1
+	MaxByteRate = 100_000_000_000
+)
+
+// Pacing policies for the events a limiter falls behind on, e.g. while a GC
+// pause or a slow sink stalls its caller
+const (
+	// PacingCatchUp lets the missed events through as fast as the caller
+	// takes them, up to a maximum lag
+	PacingCatchUp = "catch-up"
+	// PacingSkip forgoes the missed events, resuming at the rate
+	PacingSkip = "skip"
+)
+
+// ParsePacing validates a pacing policy, catch-up if empty
+func ParsePacing(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return PacingCatchUp, nil
+	case PacingCatchUp, PacingSkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown pacing policy %q (use %s or %s)", policy, PacingCatchUp, PacingSkip)
+}
+
+// Limiter paces events with a token bucket. It supports fractional rates
+// (0.5/s) as well as very high ones (1M/s) since tokens accrue continuously
+// instead of being derived from a fixed integer interval.
+//
+// The bucket holds the events due but not taken yet, so a caller stalled for
+// a while catches up on the events it missed, as far as the maximum lag
+// allows (see SetCatchUp); the ones beyond are skipped. Progress compares
+// the events due with those taken and skipped.
+type Limiter struct {
+	mutex    sync.Mutex
+	rate     float64 // tokens per second
+	burst    float64 // bucket capacity while keeping up
+	maxLag   time.Duration
+	tokens   float64
+	last     time.Time
+	min      float64
+	max      float64
+	progress Progress
+	skipped  float64          // events skipped as of the last take, see Resync
+	accrued  float64          // tokens accrued since creation, which waiters count down to
+	changed  chan struct{}    // closed when the rate changes, waking the waiters
+	now      func() time.Time // the clock tokens accrue by
+}
+
+// Progress compares the events a limiter was due to let through since it
+// was created with the events it let through
+type Progress struct {
+	Due     float64 // events the rate called for
+	Taken   float64 // events let through, or reserved by waiters
+	Skipped float64 // events forgone while falling behind further than the maximum lag
+}
+
+// Behind returns the due events neither taken nor skipped yet, which a
+// limiter catching up still lets through
+func (p Progress) Behind() float64 {
+	return math.Max(0, p.Due-p.Taken-p.Skipped)
+}
+
+// add sums the progress of two limiters
+func (p Progress) add(o Progress) Progress {
+	return Progress{Due: p.Due + o.Due, Taken: p.Taken + o.Taken, Skipped: p.Skipped + o.Skipped}
+}
+
+// NewLimiter creates a limiter emitting rate events per second. The first
+// event is allowed immediately, unless the rate is 0.
+func NewLimiter(rate float64) (*Limiter, error) {
+	return newLimiter(rate, MinRate, MaxRate)
+}
+
+// NewByteLimiter creates a limiter emitting bytesPerSecond bytes per second.
+// Callers wait for the size of each event with WaitN.
+func NewByteLimiter(bytesPerSecond float64) (*Limiter, error) {
+	return newLimiter(bytesPerSecond, MinByteRate, MaxByteRate)
+}
+
+// newLimiter creates a limiter accepting rates within [min, max]
+func newLimiter(rate, min, max float64) (*Limiter, error) {
+	if err := validate(rate, min, max); err != nil {
+		return nil, err
+	}
+
+	l := &Limiter{now: time.Now, last: time.Now(), tokens: 1, min: min, max: max, progress: Progress{Due: 1}, changed: make(chan struct{})}
+	if rate == 0 {
+		// Paused from the start
+		l.tokens, l.progress.Due = 0, 0
+	}
+	l.setRate(rate)
+	return l, nil
+}
+
+// Validate checks that an event rate can be handled by the limiter
+func Validate(rate float64) error {
+	return validate(rate, MinRate, MaxRate)
+}
+
+// ValidateBytes checks that a byte rate can be handled by a throughput limiter
+func ValidateBytes(bytesPerSecond float64) error {
+	return validate(bytesPerSecond, MinByteRate, MaxByteRate)
+}
+
+// validate checks that a rate lies within [min, max], or is 0 or Unlimited
+func validate(rate, min, max float64) error {
+	switch {
+	case rate == 0, rate == Unlimited:
+		return nil
+	case math.IsNaN(rate):
+		return fmt.Errorf("rate is not a number")
+	case rate < 0:
+		return fmt.Errorf("rate %g is negative (0 pauses)", rate)
+	case rate < min || rate > max:
+		return fmt.Errorf("rate %g is out of range [%g, %g] per second (0 pauses)", rate, min, max)
+	}
+	return nil
+}
+
+// Rate returns the current rate in events per second
+func (l *Limiter) Rate() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rate
+}
+
+// SetRate changes the rate, waking the waiters to wait for the new one;
+// tokens accrued so far are kept
+func (l *Limiter) SetRate(rate float64) error {
+	if err := validate(rate, l.min, l.max); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(l.now())
+	l.setRate(rate)
+	return nil
+}
+
+// SetCatchUp lets the limiter catch up on up to lag worth of events it fell
+// behind on, letting them through without waiting, and skip the ones
+// beyond. With 0 it only absorbs timer granularity and skips the rest.
+func (l *Limiter) SetCatchUp(lag time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(l.now())
+	l.maxLag = max(lag, 0)
+	l.limit()
+}
+
+// Resync forgoes the events that fell due since the last take while the
+// caller held back on purpose, e.g. paused, so it does not catch up on them
+// once resumed. They no longer count as due, nor as skipped.
+func (l *Limiter) Resync() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(l.now())
+	l.progress.Due -= l.progress.Skipped - l.skipped
+	l.progress.Skipped = l.skipped
+	if excess := l.tokens - l.burst; excess > 0 {
+		l.tokens = l.burst
+		l.progress.Due -= excess
+	}
+}
+
+// Progress returns the events due, taken and skipped so far
+func (l *Limiter) Progress() Progress {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.rate != Unlimited {
+		l.refill(l.now())
+	}
+	return l.progress
+}
+
+// Wait blocks until one event may be emitted or the context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// Allow takes a token if one is available, reporting whether it did, for
+// callers dropping events beyond the rate instead of waiting for them
+func (l *Limiter) Allow() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.rate == Unlimited {
+		return true
+	}
+	l.refill(l.now())
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	l.progress.Taken++
+	l.skipped = l.progress.Skipped
+	return true
+}
+
+// WaitN blocks until n tokens are available or the context is done. n may be
+// fractional, which lets callers shape inter-arrival times.
+func (l *Limiter) WaitN(ctx context.Context, n float64) error {
+	if !(n > 0) {
+		n = 0
+	}
+
+	l.mutex.Lock()
+	if l.rate == Unlimited {
+		l.mutex.Unlock()
+		return ctx.Err()
+	}
+	l.refill(l.now())
+
+	// Reserve the tokens even if they are not there yet, so concurrent
+	// waiters queue up behind each other instead of racing
+	l.tokens -= n
+	l.progress.Taken += n
+	l.skipped = l.progress.Skipped
+	if l.tokens >= 0 {
+		l.mutex.Unlock()
+		return ctx.Err()
+	}
+	until := l.accrued - l.tokens
+
+	for {
+		// Wait for the reservation at the current rate, or for a new rate
+		// if paused, until the rate changes
+		wait := maxWait
+		if l.rate > 0 {
+			if seconds := (until - l.accrued) / l.rate; seconds < maxWait.Seconds() {
+				wait = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		timer := time.NewTimer(wait)
+		changed := l.changed
+		l.mutex.Unlock()
+
+		select {
+		case <-timer.C:
+			return nil
+		case <-changed:
+			timer.Stop()
+			l.mutex.Lock()
+			if l.rate == Unlimited {
+				l.mutex.Unlock()
+				return ctx.Err()
+			}
+			l.refill(l.now())
+			if l.accrued >= until {
+				l.mutex.Unlock()
+				return ctx.Err()
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			// Give back the reservation so the rate is not depressed
+			l.mutex.Lock()
+			l.tokens += n
+			l.progress.Taken -= n
+			l.mutex.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// refill adds the tokens accrued since the last refill; callers hold the mutex
+func (l *Limiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+	if elapsed <= 0 {
+		return
+	}
+
+	due := elapsed * l.rate
+	l.tokens += due
+	l.accrued += due
+	l.progress.Due += due
+	l.limit()
+}
+
+// limit skips the tokens beyond what the limiter may catch up on; callers
+// hold the mutex
+func (l *Limiter) limit() {
+	capacity := math.Max(l.burst, l.rate*l.maxLag.Seconds())
+	if excess := l.tokens - capacity; excess > 0 {
+		l.tokens = capacity
+		l.progress.Skipped += excess
+	}
+}
+
+// setRate updates rate and burst capacity; callers hold the mutex
+func (l *Limiter) setRate(rate float64) {
+	if rate != l.rate && l.changed != nil {
+		close(l.changed)
+		l.changed = make(chan struct{})
+	}
+	l.rate = rate
+	l.burst = math.Max(1, rate*burstWindow.Seconds())
+	l.limit()
+}
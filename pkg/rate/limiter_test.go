@@ -0,0 +1,152 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewLimiterValidatesRate(t *testing.T) {
+	tests := []struct {
+		rate    float64
+		wantErr bool
+	}{
+		{0, false},
+		{MinRate, false},
+		{MaxRate, false},
+		{Unlimited, false},
+		{-1, true},
+		{MinRate / 2, true},
+		{MaxRate * 2, true},
+		{math.NaN(), true},
+	}
+	for _, tt := range tests {
+		if _, err := NewLimiter(tt.rate); (err != nil) != tt.wantErr {
+			t.Errorf("NewLimiter(%g) error %v, want error %v", tt.rate, err, tt.wantErr)
+		}
+	}
+}
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// newTestLimiter creates a limiter on a fake clock
+func newTestLimiter(t *testing.T, rate float64) (*Limiter, *fakeClock) {
+	t.Helper()
+	l, err := NewLimiter(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l.now, l.last = clock.Now, clock.now
+	return l, clock
+}
+
+// take lets through the events a limiter allows over elapsed, advancing its
+// clock by step at a time
+func take(l *Limiter, clock *fakeClock, elapsed, step time.Duration) int {
+	taken := 0
+	for {
+		for l.Allow() {
+			taken++
+		}
+		if elapsed <= 0 {
+			return taken
+		}
+		clock.now = clock.now.Add(step)
+		elapsed -= step
+	}
+}
+
+func TestLimiterPaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		elapsed time.Duration
+		want    int
+	}{
+		{"first event immediately", 1, 0, 1},
+		{"fractional", 0.5, 4 * time.Second, 3},
+		{"steady", 200, 200 * time.Millisecond, 41},
+		{"high", 100000, 200 * time.Millisecond, 20001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(t, tt.rate)
+			taken := take(l, clock, tt.elapsed, time.Millisecond)
+			// Tokens accrue in floating point, so the last may fall short
+			if taken < tt.want-1 || taken > tt.want {
+				t.Errorf("%s over %s let %d events through, want %d", Format(tt.rate), tt.elapsed, taken, tt.want)
+			}
+			if p := l.Progress(); p.Taken != float64(taken) || p.Skipped > 0.01 {
+				t.Errorf("progress %+v after %d events", p, taken)
+			}
+		})
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l, _ := newTestLimiter(t, Unlimited)
+	for i := 0; i < 10000; i++ {
+		if !l.Allow() {
+			t.Fatalf("an unlimited limiter refused event %d", i)
+		}
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLimiterCatchesUpWithinLag(t *testing.T) {
+	l, clock := newTestLimiter(t, 1000)
+	l.SetCatchUp(50 * time.Millisecond)
+	take(l, clock, 0, 0)
+
+	// A stall of 200ms leaves 200 events due, of which 50 are caught up on
+	clock.now = clock.now.Add(200 * time.Millisecond)
+	taken := take(l, clock, 0, 0)
+	p := l.Progress()
+	if math.Abs(float64(taken)-50) > 1 || math.Abs(p.Skipped-150) > 1 {
+		t.Errorf("caught up on %d events, progress %+v; want 50 taken and 150 skipped", taken, p)
+	}
+	if p.Behind() >= 1 {
+		t.Errorf("still %g events behind after catching up", p.Behind())
+	}
+}
+
+func TestLimiterPausedAndAllow(t *testing.T) {
+	l, clock := newTestLimiter(t, 0)
+	if l.Allow() {
+		t.Error("a paused limiter allowed an event")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Error("a paused limiter let a waiter through")
+	}
+	if p := l.Progress(); p.Taken != 0 {
+		t.Errorf("a cancelled waiter kept its reservation: %+v", p)
+	}
+
+	if err := l.SetRate(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(5 * time.Millisecond)
+	if l.Allow() {
+		t.Error("a limiter resumed at 1/s allowed an event within 5ms")
+	}
+	if err := l.SetRate(1000); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(5 * time.Millisecond)
+	if !l.Allow() {
+		t.Error("a limiter at 1k/s allowed no event after 5ms")
+	}
+}
@@ -0,0 +1,113 @@
+package rate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Mux paces streams independently, each with its own pool of workers. A
+// stream takes a fixed share of the total rate, in proportion to its own
+// rate, shaped by profiles of its own timed from its start. Until its start
+// delay has passed its rate is 0.
+type Mux struct {
+	streams []*muxStream
+	total   float64 // sum of the rates of the streams
+	workers int
+	lag     time.Duration
+
+	mutex sync.Mutex
+	start time.Time
+}
+
+// muxStream is a stream of a mux
+type muxStream struct {
+	pool    *Pool
+	rate    float64
+	profile Profile
+	delay   time.Duration
+}
+
+// NewMux creates a mux without streams, splitting each stream added between
+// workers catching up on at most lag worth of events (see Limiter.SetCatchUp)
+func NewMux(workers int, lag time.Duration) *Mux {
+	return &Mux{workers: workers, lag: lag, start: time.Now()}
+}
+
+// Add adds a stream of rate events per second, shaped by profile if not nil
+// once it starts after delay, and returns the pool pacing its workers.
+// Streams must be added before the mux is started.
+func (m *Mux) Add(rate float64, profile Profile, delay time.Duration) (*Pool, error) {
+	if rate == Unlimited {
+		return nil, fmt.Errorf("a stream cannot generate at the maximum rate")
+	}
+	initial := rate
+	if delay > 0 {
+		// A stream waiting for its start does not let the first event through
+		initial = 0
+	}
+	limiter, err := NewLimiter(initial)
+	if err == nil {
+		err = Validate(rate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	limiter.SetCatchUp(m.lag)
+	pool := limiter.Split(m.workers)
+	m.streams = append(m.streams, &muxStream{pool: pool, rate: rate, profile: profile, delay: delay})
+	m.total += rate
+	return pool, nil
+}
+
+// Total returns the sum of the rates of the streams
+func (m *Mux) Total() float64 {
+	return m.total
+}
+
+// Start restarts the clock the start delays and profiles of the streams
+// count from
+func (m *Mux) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.start = time.Now()
+}
+
+// SetRate changes the total rate, dividing it between the streams by their
+// shares
+func (m *Mux) SetRate(rate float64) error {
+	if err := Validate(rate); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	elapsed := time.Since(m.start)
+	m.mutex.Unlock()
+
+	for _, s := range m.streams {
+		share := 0.0
+		if elapsed >= s.delay && m.total > 0 {
+			share = rate * s.rate / m.total
+			if s.profile != nil {
+				share = s.profile.Rate(share, elapsed-s.delay)
+			}
+		}
+		if err := s.pool.SetRate(s.pool.clamp(share)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Progress returns the events due, taken and skipped by all streams
+func (m *Mux) Progress() Progress {
+	var total Progress
+	for _, s := range m.streams {
+		total = total.add(s.pool.Progress())
+	}
+	return total
+}
+
+// clamp bounds a total rate to what the mux accepts
+func (m *Mux) clamp(rate float64) float64 {
+	return clamp(rate, MinRate, MaxRate)
+}
@@ -0,0 +1,114 @@
+package rate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// periods maps time unit names to their length in seconds
+var periods = map[string]float64{
+	"s": 1, "sec": 1, "secs": 1, "second": 1, "seconds": 1,
+	"m": 60, "min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"h": 3600, "hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"d": 86400, "day": 86400, "days": 86400,
+}
+
+// multipliers maps count suffixes to their value
+var multipliers = map[byte]float64{
+	'k': 1e3,
+	'K': 1e3,
+	'M': 1e6,
+	'G': 1e9,
+}
+
+// Parse converts a rate expression into events per second. Accepted forms are
+// a plain number ("10", per second), or a count and period separated by a
+// slash ("500/m", "10k/s", "2/h", "1.5M/min"). Counts may carry a k, M or G
+// suffix. "max" (or "unlimited") yields Unlimited.
+func Parse(expr string) (float64, error) {
+	expr = strings.TrimSpace(expr)
+	switch strings.ToLower(expr) {
+	case "":
+		return 0, fmt.Errorf("empty rate")
+	case "max", "unlimited":
+		return Unlimited, nil
+	}
+
+	countPart, periodPart, hasPeriod := strings.Cut(expr, "/")
+	count, err := parseCount(strings.TrimSpace(countPart))
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", expr, err)
+	}
+
+	period := 1.0
+	if hasPeriod {
+		unit := strings.ToLower(strings.TrimSpace(periodPart))
+		var ok bool
+		if period, ok = periods[unit]; !ok {
+			return 0, fmt.Errorf("invalid rate %q: unknown period %q (use s, m, h or d)", expr, periodPart)
+		}
+	}
+
+	return count / period, nil
+}
+
+// parseCount parses a number with an optional k, M or G suffix
+func parseCount(s string) (float64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing count")
+	}
+
+	multiplier := 1.0
+	if m, ok := multipliers[s[len(s)-1]]; ok {
+		multiplier = m
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return n * multiplier, nil
+}
+
+// Format renders a rate in events per second using the shortest natural unit
+func Format(rate float64) string {
+	switch {
+	case rate == Unlimited:
+		return "max"
+	case rate >= 1e6:
+		return strconv.FormatFloat(rate/1e6, 'g', 4, 64) + "M/s"
+	case rate >= 1e3:
+		return strconv.FormatFloat(rate/1e3, 'g', 4, 64) + "k/s"
+	case rate >= 1 || rate == 0:
+		return strconv.FormatFloat(rate, 'g', 4, 64) + "/s"
+	case rate*60 >= 1:
+		return strconv.FormatFloat(rate*60, 'g', 4, 64) + "/m"
+	case rate*3600 >= 1:
+		return strconv.FormatFloat(rate*3600, 'g', 4, 64) + "/h"
+	default:
+		return strconv.FormatFloat(rate*86400, 'g', 4, 64) + "/d"
+	}
+}
+
+// Flag is a flag.Value accepting rate expressions understood by Parse
+type Flag float64
+
+// String returns the formatted rate
+func (f *Flag) String() string {
+	return Format(float64(*f))
+}
+
+// Set parses a rate expression, which must not be negative
+func (f *Flag) Set(expr string) error {
+	r, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	if r < 0 {
+		return fmt.Errorf("rate %q is negative (0 pauses)", expr)
+	}
+	*f = Flag(r)
+	return nil
+}
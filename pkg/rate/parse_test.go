@@ -0,0 +1,30 @@
+package rate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    float64
+		wantErr bool
+	}{
+		{"10", 10, false},
+		{"500/m", 500.0 / 60, false},
+		{"10k/s", 10000, false},
+		{"1.5M/min", 1.5e6 / 60, false},
+		{"2/h", 2.0 / 3600, false},
+		{"max", Unlimited, false},
+		{"", 0, true},
+		{"10/fortnight", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.expr)
+		if (err != nil) != tt.wantErr || (err == nil && math.Abs(got-tt.want) > 1e-9*math.Max(1, tt.want)) {
+			t.Errorf("Parse(%q) = %g, %v; want %g, error %v", tt.expr, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
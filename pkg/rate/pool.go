@@ -0,0 +1,85 @@
+package rate
+
+// Pool paces independent workers, each with its own limiter pacing an equal
+// share of the total rate. Workers never contend for a shared bucket, which
+// lets very high rates scale across cores.
+type Pool struct {
+	limiters []*Limiter
+	min      float64
+	max      float64
+}
+
+// Split divides the rate of the limiter between n workers. With a single
+// worker the pool paces with the limiter itself.
+func (l *Limiter) Split(n int) *Pool {
+	if n < 1 {
+		n = 1
+	}
+
+	l.mutex.Lock()
+	rate, min, max, lag := l.rate, l.min, l.max, l.maxLag
+	l.mutex.Unlock()
+
+	p := &Pool{min: min, max: max}
+	if n == 1 {
+		p.limiters = []*Limiter{l}
+		return p
+	}
+
+	share := float64(n)
+	for i := 0; i < n; i++ {
+		// Share bounds scale with the rate so every valid total stays valid
+		worker, _ := newLimiter(rate/share, min/share, max/share)
+		worker.maxLag = lag
+		p.limiters = append(p.limiters, worker)
+	}
+	return p
+}
+
+// Workers returns the number of workers sharing the rate
+func (p *Pool) Workers() int {
+	return len(p.limiters)
+}
+
+// Worker returns the limiter pacing worker i
+func (p *Pool) Worker(i int) *Limiter {
+	return p.limiters[i]
+}
+
+// Rate returns the total rate of all workers
+func (p *Pool) Rate() float64 {
+	total := 0.0
+	for _, l := range p.limiters {
+		total += l.Rate()
+	}
+	return total
+}
+
+// Progress returns the events due, taken and skipped by all workers
+func (p *Pool) Progress() Progress {
+	var total Progress
+	for _, l := range p.limiters {
+		total = total.add(l.Progress())
+	}
+	return total
+}
+
+// SetRate changes the total rate, dividing it evenly between the workers
+func (p *Pool) SetRate(rate float64) error {
+	if err := validate(rate, p.min, p.max); err != nil {
+		return err
+	}
+
+	share := rate / float64(len(p.limiters))
+	for _, l := range p.limiters {
+		if err := l.SetRate(share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clamp bounds a total rate to what the pool accepts
+func (p *Pool) clamp(rate float64) float64 {
+	return clamp(rate, p.min, p.max)
+}
@@ -0,0 +1,256 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// shapeInterval is how often profiles re-evaluate the target rate
+const shapeInterval = 100 * time.Millisecond
+
+// Profile modulates the configured base rate over the course of a run
+type Profile interface {
+	// Rate returns the target rate after elapsed time given the rate
+	// produced by the preceding profiles
+	Rate(base float64, elapsed time.Duration) float64
+}
+
+// Chain applies profiles in order, each one modulating the output of the previous
+type Chain []Profile
+
+// Rate returns the rate after applying every profile of the chain
+func (c Chain) Rate(base float64, elapsed time.Duration) float64 {
+	for _, p := range c {
+		base = p.Rate(base, elapsed)
+	}
+	return base
+}
+
+// Pacer is a limiter or pool whose rate profiles can steer
+type Pacer interface {
+	SetRate(rate float64) error
+	clamp(rate float64) float64
+}
+
+// Target is a base rate that may change while a run is in progress, e.g.
+// when a coordinator reassigns rate shares
+type Target struct {
+	bits atomic.Uint64
+}
+
+// NewTarget creates a target starting at rate
+func NewTarget(rate float64) *Target {
+	t := &Target{}
+	t.Set(rate)
+	return t
+}
+
+// Get returns the current base rate
+func (t *Target) Get() float64 {
+	return math.Float64frombits(t.bits.Load())
+}
+
+// Set changes the base rate
+func (t *Target) Set(rate float64) {
+	t.bits.Store(math.Float64bits(rate))
+}
+
+// Apply periodically sets the pacer to the rate the profile derives from the
+// target base rate until the context is done
+func Apply(ctx context.Context, limiter Pacer, base *Target, profile Profile) {
+	start := time.Now()
+	ticker := time.NewTicker(shapeInterval)
+	defer ticker.Stop()
+
+	for {
+		_ = limiter.SetRate(limiter.clamp(profile.Rate(base.Get(), time.Since(start))))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clamp bounds a rate to what the limiter accepts
+func (l *Limiter) clamp(rate float64) float64 {
+	return clamp(rate, l.min, l.max)
+}
+
+// clamp bounds a rate to [min, max], letting Unlimited through and pausing
+// at rates down to 0
+func clamp(rate, min, max float64) float64 {
+	if rate == Unlimited {
+		return rate
+	}
+	if math.IsNaN(rate) {
+		return min
+	}
+	if rate <= 0 {
+		return 0
+	}
+	return math.Max(min, math.Min(max, rate))
+}
+
+// Burst multiplies the rate for Duration every Every, e.g. 10x for 30
+// seconds every 5 minutes. The first burst starts after Offset.
+type Burst struct {
+	Every      time.Duration
+	Duration   time.Duration
+	Multiplier float64
+	Offset     time.Duration
+}
+
+// Rate returns the base rate, multiplied while a burst is active
+func (b Burst) Rate(base float64, elapsed time.Duration) float64 {
+	if b.Every <= 0 || elapsed < b.Offset {
+		return base
+	}
+	if (elapsed-b.Offset)%b.Every < b.Duration {
+		return base * b.Multiplier
+	}
+	return base
+}
+
+// Ramp shapes
+const (
+	RampLinear      = "linear"
+	RampExponential = "exponential"
+)
+
+// Ramp moves the rate from From to To over Duration, holds it for Hold and,
+// if Down is set, moves it back to From over the same duration. A zero To
+// ramps towards the base rate.
+type Ramp struct {
+	From     float64
+	To       float64
+	Duration time.Duration
+	Hold     time.Duration
+	Down     bool
+	Shape    string
+}
+
+// Rate returns the rate at the given point of the ramp
+func (r Ramp) Rate(base float64, elapsed time.Duration) float64 {
+	to := r.To
+	if to <= 0 {
+		to = base
+	}
+	if r.Duration <= 0 {
+		return to
+	}
+
+	switch {
+	case elapsed < r.Duration:
+		return r.interpolate(r.From, to, float64(elapsed)/float64(r.Duration))
+	case !r.Down || elapsed < r.Duration+r.Hold:
+		return to
+	case elapsed < 2*r.Duration+r.Hold:
+		return r.interpolate(to, r.From, float64(elapsed-r.Duration-r.Hold)/float64(r.Duration))
+	}
+	return r.From
+}
+
+// interpolate returns the rate at fraction f of the way from a to b
+func (r Ramp) interpolate(a, b, f float64) float64 {
+	if r.Shape == RampExponential {
+		// Exponential curves need a positive starting point
+		a, b = math.Max(a, MinRate), math.Max(b, MinRate)
+		return a * math.Pow(b/a, f)
+	}
+	return a + (b-a)*f
+}
+
+// Wave shapes
+const (
+	WaveSine    = "sine"
+	WaveDiurnal = "diurnal"
+)
+
+// diurnalTrough is the time of day with the least traffic
+const diurnalTrough = 4 * time.Hour
+
+// Wave modulates the rate between base*(1-Amplitude) and base*(1+Amplitude).
+// A sine wave starts at the base rate when the run starts; a diurnal wave
+// follows the wall clock with its trough at 04:00 and its peak at 16:00,
+// with flatter nights than a sine. Periods other than 24h compress or
+// stretch the "day".
+type Wave struct {
+	Shape     string
+	Period    time.Duration
+	Amplitude float64
+}
+
+// Rate returns the modulated rate
+func (w Wave) Rate(base float64, elapsed time.Duration) float64 {
+	if w.Period <= 0 {
+		return base
+	}
+	amplitude := math.Max(0, math.Min(1, w.Amplitude))
+
+	if w.Shape == WaveDiurnal {
+		now := time.Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		// Fraction of the (possibly compressed) day, shifted so 0 is the trough
+		day := math.Mod(float64(now.Sub(midnight)), float64(w.Period)) / float64(w.Period)
+		phase := day - float64(diurnalTrough)/float64(24*time.Hour)
+		level := math.Pow((1-math.Cos(2*math.Pi*phase))/2, 1.5)
+		return base * (1 - amplitude + 2*amplitude*level)
+	}
+
+	return base * (1 + amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(w.Period)))
+}
+
+// RandomWalk lets the rate drift organically: every Interval the rate moves
+// by a random relative step of up to Step (in log space) and is reflected
+// back into [Min, Max]. Zero bounds default to half and double the base rate.
+type RandomWalk struct {
+	Min      float64
+	Max      float64
+	Step     float64
+	Interval time.Duration
+
+	mutex  sync.Mutex
+	rate   float64
+	stepAt time.Duration
+}
+
+// Rate returns the current position of the walk
+func (w *RandomWalk) Rate(base float64, elapsed time.Duration) float64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	lo, hi := w.Min, w.Max
+	if lo <= 0 {
+		lo = base / 2
+	}
+	if hi <= 0 {
+		hi = base * 2
+	}
+	if w.rate == 0 {
+		w.rate = math.Max(lo, math.Min(hi, base))
+	}
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for w.stepAt+interval <= elapsed {
+		w.stepAt += interval
+		w.rate *= math.Exp(gofakeit.Float64Range(-w.Step, w.Step))
+		// Reflect off the bounds so the walk does not stick to them
+		if w.rate > hi {
+			w.rate = math.Max(lo, hi*hi/w.rate)
+		}
+		if w.rate < lo {
+			w.rate = math.Min(hi, lo*lo/w.rate)
+		}
+	}
+	return w.rate
+}
@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Anonymization methods
+const (
+	// AnonymizeHash replaces a value with a keyed hash of it
+	AnonymizeHash = "hash"
+	// AnonymizeFake replaces a value with a fake one of the same kind: an IP
+	// address, email, UUID, number or name
+	AnonymizeFake = "fake"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	digitPattern = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Anonymizer replaces the values of configured fields consistently: a value
+// is always replaced by the same hash or fake, so correlations between
+// records survive
+type Anonymizer struct {
+	rules map[string]string
+	key   []byte
+}
+
+// ParseAnonymize parses anonymization rules such as
+// "ip_address=fake,email=fake,user_id=hash"; nested fields are named by
+// their dotted path
+func ParseAnonymize(spec string) (map[string]string, error) {
+	rules := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return rules, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		field, method, ok := strings.Cut(strings.TrimSpace(part), "=")
+		field, method = strings.TrimSpace(field), strings.ToLower(strings.TrimSpace(method))
+		if !ok || field == "" {
+			return nil, fmt.Errorf("invalid rule %q: expected field=%s or field=%s", part, AnonymizeHash, AnonymizeFake)
+		}
+		if method != AnonymizeHash && method != AnonymizeFake {
+			return nil, fmt.Errorf("unknown method %q for %s: use %s or %s", method, field, AnonymizeHash, AnonymizeFake)
+		}
+		rules[field] = method
+	}
+	return rules, nil
+}
+
+// NewAnonymizer creates an anonymizer applying the rules. Replacements are
+// derived from the salt, so the same salt gives the same replacements in
+// every run; an empty salt picks a random one.
+func NewAnonymizer(rules map[string]string, salt string) (*Anonymizer, error) {
+	key := []byte(salt)
+	if salt == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Anonymizer{rules: rules, key: key}, nil
+}
+
+// Fields returns the names of the anonymized fields, sorted
+func (a *Anonymizer) Fields() []string {
+	fields := make([]string, 0, len(a.rules))
+	for field := range a.rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Apply replaces the values of the configured fields of a record in place
+func (a *Anonymizer) Apply(fields map[string]interface{}) {
+	for field, method := range a.rules {
+		parent, key := lookup(fields, field)
+		value, ok := parent[key]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		parent[key] = a.replace(method, value)
+	}
+}
+
+// replace returns the replacement of a value
+func (a *Anonymizer) replace(method string, value interface{}) interface{} {
+	original := fmt.Sprint(value)
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(original))
+	sum := mac.Sum(nil)
+
+	if method == AnonymizeHash {
+		return hex.EncodeToString(sum[:8])
+	}
+
+	// Seed the fake from the hash so a value always gets the same fake
+	faker := gofakeit.NewUnlocked(int64(binary.BigEndian.Uint64(sum)))
+	switch {
+	case net.ParseIP(original) != nil && strings.Contains(original, ":"):
+		return faker.IPv6Address()
+	case net.ParseIP(original) != nil:
+		return faker.IPv4Address()
+	case emailPattern.MatchString(original):
+		return faker.Email()
+	case uuidPattern.MatchString(original):
+		return faker.UUID()
+	case digitPattern.MatchString(original):
+		digits := faker.DigitN(uint(len(original)))
+		if _, isNumber := value.(json.Number); isNumber {
+			// Keep numbers numbers, without a leading zero
+			if digits[0] == '0' {
+				digits = "1" + digits[1:]
+			}
+			return json.Number(digits)
+		}
+		return digits
+	default:
+		return faker.Username()
+	}
+}
+
+// lookup finds the map holding a field, following a dotted path into nested
+// objects unless the record has the dotted name itself
+func lookup(fields map[string]interface{}, name string) (map[string]interface{}, string) {
+	if _, ok := fields[name]; ok {
+		return fields, name
+	}
+	parent := fields
+	parts := strings.Split(name, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := parent[part].(map[string]interface{})
+		if !ok {
+			return fields, name
+		}
+		parent = child
+	}
+	return parent, parts[len(parts)-1]
+}
@@ -0,0 +1,261 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/schema"
+)
+
+var (
+	// messageKeys, levelKeys and timestampKeys are checked in order to find
+	// the message, level and timestamp of a record
+	messageKeys   = []string{"msg", "message", "body", "log"}
+	levelKeys     = []string{"level", "severity", "lvl", "loglevel", "log.level"}
+	timestampKeys = []string{"timestamp", "@timestamp", "time", "ts", "date"}
+
+	// timestampLayouts are the layouts tried when parsing timestamp strings
+	timestampLayouts = []string{time.RFC3339Nano, time.RFC3339, "2006-01-02 15:04:05.000", "2006-01-02 15:04:05"}
+)
+
+// Config holds the configuration of a replay
+type Config struct {
+	File           string      // NDJSON or logfmt file to replay
+	Speed          float64     // Factor the original timing is sped up by, 0 for as fast as possible
+	TimestampField string      // Extra field to read timestamps from, checked first
+	Loop           bool        // Start over at the end of the file, until stopped
+	Anonymizer     *Anonymizer // Replaces sensitive fields before records are emitted, if set
+}
+
+// Record is a log read back from a file
+type Record struct {
+	Time    time.Time // Original timestamp, zero if the record had none
+	Level   string
+	Message string
+	Fields  map[string]interface{} // Remaining fields
+}
+
+// Player re-emits the records of a log file, keeping their relative timing
+type Player struct {
+	config  Config
+	skipped int64
+	passes  int64
+}
+
+// New creates a player, checking that the file can be read
+func New(config Config) (*Player, error) {
+	if config.File == "" {
+		return nil, fmt.Errorf("no file to replay")
+	}
+	if config.Speed < 0 || math.IsInf(config.Speed, 0) || math.IsNaN(config.Speed) {
+		return nil, fmt.Errorf("invalid speed %v", config.Speed)
+	}
+	file, err := os.Open(config.File)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return &Player{config: config}, nil
+}
+
+// ParseSpeed parses a speed factor such as 2x, 0.5x or 10; max replays as
+// fast as possible and returns 0
+func ParseSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 || math.IsInf(speed, 0) {
+		return 0, fmt.Errorf("invalid speed %q: use a positive factor like 2x or 0.5x, or max", s)
+	}
+	return speed, nil
+}
+
+// Run reads the file and passes every record to emit at its original offset
+// from the first record, divided by the speed. wait is called before each
+// record and may block, e.g. while paused; the time it blocks shifts the
+// rest of the replay. Run returns when the file ends (unless looping), wait
+// returns false or ctx is cancelled.
+func (p *Player) Run(ctx context.Context, wait func() bool, emit func(Record)) error {
+	for {
+		emitted, err := p.pass(ctx, wait, emit)
+		if err != nil || emitted < 0 {
+			return err
+		}
+		p.passes++
+		// Without a record to replay, looping would spin
+		if !p.config.Loop || emitted == 0 {
+			return nil
+		}
+	}
+}
+
+// pass replays the file once, returning the number of records emitted; it
+// returns -1 if it was stopped before the end
+func (p *Player) pass(ctx context.Context, wait func() bool, emit func(Record)) (int, error) {
+	file, err := os.Open(p.config.File)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	emitted := 0
+	var first, origin time.Time
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		record, err := ParseRecord(line, p.config.TimestampField, p.config.Anonymizer)
+		if err != nil {
+			p.skip()
+			continue
+		}
+
+		// Records without a timestamp, or older than the first, go out
+		// right after the previous one
+		if p.config.Speed > 0 && !record.Time.IsZero() {
+			if first.IsZero() {
+				first, origin = record.Time, time.Now()
+			} else if offset := record.Time.Sub(first); offset > 0 {
+				due := origin.Add(time.Duration(float64(offset) / p.config.Speed))
+				if delay := time.Until(due); delay > 0 {
+					timer := time.NewTimer(delay)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+						return -1, nil
+					}
+				}
+			}
+		}
+
+		blocked := time.Now()
+		if !wait() || ctx.Err() != nil {
+			return -1, nil
+		}
+		if !origin.IsZero() {
+			origin = origin.Add(time.Since(blocked))
+		}
+		emit(record)
+		emitted++
+	}
+	if err := scanner.Err(); err != nil {
+		return emitted, fmt.Errorf("line %d: %w", lineNo+1, err)
+	}
+	return emitted, nil
+}
+
+// skip counts a line that could not be parsed, once however often it is
+// replayed
+func (p *Player) skip() {
+	if p.passes == 0 {
+		p.skipped++
+	}
+}
+
+// Skipped returns the number of lines of the file that could not be parsed
+func (p *Player) Skipped() int64 {
+	return p.skipped
+}
+
+// Passes returns the number of times the file was replayed to the end
+func (p *Player) Passes() int64 {
+	return p.passes
+}
+
+// ParseRecord parses an NDJSON or logfmt line into a record, reading the
+// timestamp from timestampField first if given. The anonymizer, if any, is
+// applied before the timestamp, level and message are taken out.
+func ParseRecord(line string, timestampField string, anonymizer *Anonymizer) (Record, error) {
+	// Free text would parse as logfmt flags
+	if !strings.HasPrefix(line, "{") && !strings.Contains(line, "=") {
+		return Record{}, fmt.Errorf("not a JSON or logfmt record")
+	}
+	fields, err := schema.ParseRecord(line)
+	if err != nil {
+		return Record{}, err
+	}
+	if anonymizer != nil {
+		anonymizer.Apply(fields)
+	}
+
+	record := Record{Fields: fields}
+	keys := timestampKeys
+	if timestampField != "" {
+		keys = append([]string{timestampField}, timestampKeys...)
+	}
+	for _, key := range keys {
+		if t, ok := parseTimestamp(fields[key]); ok {
+			if record.Time.IsZero() {
+				record.Time = t
+			}
+			delete(fields, key)
+		}
+	}
+	for _, key := range levelKeys {
+		if level, ok := fields[key].(string); ok {
+			record.Level = level
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range messageKeys {
+		if message, ok := fields[key].(string); ok {
+			record.Message = message
+			delete(fields, key)
+			break
+		}
+	}
+	return record, nil
+}
+
+// parseTimestamp parses a timestamp written as a string in a common layout
+// or as seconds, milliseconds, microseconds or nanoseconds since the epoch
+func parseTimestamp(value interface{}) (time.Time, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	default:
+		return time.Time{}, false
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	epoch, err := strconv.ParseFloat(s, 64)
+	if err != nil || epoch <= 0 {
+		return time.Time{}, false
+	}
+	// Tell the unit from the magnitude
+	switch {
+	case epoch >= 1e17:
+		return time.Unix(0, int64(epoch)), true
+	case epoch >= 1e14:
+		return time.UnixMicro(int64(epoch)), true
+	case epoch >= 1e11:
+		return time.UnixMilli(int64(epoch)), true
+	default:
+		sec, frac := math.Modf(epoch)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+}
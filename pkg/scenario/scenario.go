@@ -0,0 +1,199 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/clock"
+	"github.com/rjonczy/log-genie/pkg/generator"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	ratelimit "github.com/rjonczy/log-genie/pkg/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// file is the YAML document of a scenario
+type file struct {
+	Streams []streamSpec `yaml:"streams"`
+}
+
+// streamSpec is a stream as written in a scenario file
+type streamSpec struct {
+	Name         string             `yaml:"name"`
+	Rate         string             `yaml:"rate"`
+	ErrorRatio   *float64           `yaml:"error-ratio"`
+	LevelWeights map[string]float64 `yaml:"level-weights"`
+	Attributes   map[string]string  `yaml:"attributes"`
+	StartDelay   string             `yaml:"start-delay"`
+	Timezone     string             `yaml:"timezone"`
+	Bursts       []burstSpec        `yaml:"bursts"`
+}
+
+// burstSpec is a burst of a stream as written in a scenario file
+type burstSpec struct {
+	Every      string  `yaml:"every"`
+	Duration   string  `yaml:"duration"`
+	Multiplier float64 `yaml:"multiplier"`
+	Offset     string  `yaml:"offset"`
+}
+
+// Load reads the streams of a scenario file:
+//
+//	streams:
+//	  - name: inventory
+//	    rate: 200/s
+//	    level-weights: {debug: 8, info: 2}
+//	    error-ratio: 0
+//	    attributes: {service: inventory}
+//	  - name: payments
+//	    rate: 2/s
+//	    error-ratio: 0.4
+//	    start-delay: 30s
+//	    attributes: {service: payments}
+//	    timezone: Asia/Tokyo
+//	    bursts:
+//	      - every: 5m
+//	        duration: 30s
+//	        multiplier: 10
+//
+// Rates use the --rate syntax; streams without an error ratio or level
+// weights use the generator's, and streams without a timezone the
+// generator's --timezone
+func Load(path string) ([]generator.Stream, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return streams, nil
+}
+
+// Parse parses the contents of a scenario file, see Load
+func Parse(data []byte) ([]generator.Stream, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var doc file
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	if len(doc.Streams) == 0 {
+		return nil, fmt.Errorf("invalid scenario: no streams")
+	}
+
+	streams := make([]generator.Stream, 0, len(doc.Streams))
+	names := map[string]bool{}
+	for i, spec := range doc.Streams {
+		if spec.Name == "" {
+			spec.Name = "stream-" + strconv.Itoa(i+1)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("invalid scenario: duplicate stream %s", spec.Name)
+		}
+		names[spec.Name] = true
+		stream, err := spec.stream()
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream %s: %w", spec.Name, err)
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// Total returns the sum of the rates of the streams, the rate at which each
+// generates at its own
+func Total(streams []generator.Stream) float64 {
+	total := 0.0
+	for _, s := range streams {
+		total += s.Rate
+	}
+	return total
+}
+
+// stream converts a stream as written in the file
+func (spec streamSpec) stream() (generator.Stream, error) {
+	s := generator.Stream{Name: spec.Name, ErrorRatio: -1, Attributes: spec.Attributes}
+	if spec.Rate == "" {
+		return s, fmt.Errorf("no rate")
+	}
+	rate, err := ratelimit.Parse(spec.Rate)
+	if err == nil {
+		err = ratelimit.Validate(rate)
+	}
+	if err == nil && rate == ratelimit.Unlimited {
+		err = fmt.Errorf("max is not a stream rate")
+	}
+	if err != nil {
+		return s, fmt.Errorf("invalid rate %q: %w", spec.Rate, err)
+	}
+	s.Rate = rate
+
+	if spec.ErrorRatio != nil {
+		if *spec.ErrorRatio < 0 || *spec.ErrorRatio > 1 {
+			return s, fmt.Errorf("invalid error ratio %g: must be between 0 and 1", *spec.ErrorRatio)
+		}
+		s.ErrorRatio = *spec.ErrorRatio
+	}
+	if len(spec.LevelWeights) > 0 {
+		s.LevelWeights = map[logger.LogLevel]float64{}
+		total := 0.0
+		for name, weight := range spec.LevelWeights {
+			level, ok := logger.ParseLevel(name)
+			if !ok {
+				return s, fmt.Errorf("invalid level weight: unknown level %q", name)
+			}
+			if weight < 0 {
+				return s, fmt.Errorf("invalid level weight of %s: must not be negative", name)
+			}
+			s.LevelWeights[level] += weight
+			total += weight
+		}
+		if total <= 0 {
+			return s, fmt.Errorf("invalid level weights: at least one weight must be positive")
+		}
+	}
+	if s.StartDelay, err = duration("start delay", spec.StartDelay); err != nil {
+		return s, err
+	}
+	if spec.Timezone != "" {
+		if s.Location, err = clock.ParseLocation(spec.Timezone); err != nil {
+			return s, err
+		}
+	}
+
+	for _, b := range spec.Bursts {
+		burst := ratelimit.Burst{Multiplier: b.Multiplier}
+		if burst.Every, err = duration("burst interval", b.Every); err != nil {
+			return s, err
+		}
+		if burst.Duration, err = duration("burst duration", b.Duration); err != nil {
+			return s, err
+		}
+		if burst.Offset, err = duration("burst offset", b.Offset); err != nil {
+			return s, err
+		}
+		if burst.Every <= 0 || burst.Duration <= 0 || burst.Multiplier <= 0 {
+			return s, fmt.Errorf("invalid burst: every, duration and multiplier must be positive")
+		}
+		s.Profile = append(s.Profile, burst)
+	}
+	return s, nil
+}
+
+// duration parses an optional, non-negative duration, 0 if empty
+func duration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
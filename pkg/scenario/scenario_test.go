@@ -0,0 +1,41 @@
+package scenario
+
+import (
+	"testing"
+)
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		want     string // location name, empty for none
+		wantErr  bool
+	}{
+		{"generator's", "", "", false},
+		{"IANA name", "Asia/Tokyo", "Asia/Tokyo", false},
+		{"offset", "+05:30", "+05:30", false},
+		{"unknown", "Mars/Olympus", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := "streams:\n  - name: a\n    rate: 5/s\n"
+			if tt.timezone != "" {
+				doc += "    timezone: " + tt.timezone + "\n"
+			}
+			streams, err := Parse([]byte(doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := ""
+			if streams[0].Location != nil {
+				got = streams[0].Location.String()
+			}
+			if got != tt.want {
+				t.Errorf("location %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search for the next active minute
+const maxSearch = 366 * 24 * time.Hour
+
+// Schedule is a set of cron expressions describing when generation is
+// active. A minute is active if it matches any of the expressions, so
+// "* 9-17 * * 1-5" means business hours and "0-14 2 * * *" a nightly
+// 15-minute window.
+type Schedule struct {
+	expressions []expression
+}
+
+// expression is a parsed five-field cron expression stored as bitsets
+type expression struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// field describes the allowed range of a cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses one or more cron expressions separated by semicolons. Each
+// expression has five fields (minute, hour, day of month, month, day of
+// week) supporting *, lists (1,3), ranges (9-17) and steps (*/15, 0-30/5).
+// Sunday is 0 or 7.
+func Parse(spec string) (*Schedule, error) {
+	s := &Schedule{}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		e, err := parseExpression(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", part, err)
+		}
+		s.expressions = append(s.expressions, e)
+	}
+	if len(s.expressions) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	return s, nil
+}
+
+// Active reports whether generation is active at t
+func (s *Schedule) Active(t time.Time) bool {
+	for _, e := range s.expressions {
+		if e.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the start of the next active minute after t, or t itself if
+// it is active. The zero time is returned if nothing matches within a year.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.Active(t) {
+		return t
+	}
+	candidate := t.Truncate(time.Minute)
+	for end := t.Add(maxSearch); candidate.Before(end); {
+		candidate = candidate.Add(time.Minute)
+		if s.Active(candidate) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// matches reports whether t falls into a minute matched by the expression
+func (e expression) matches(t time.Time) bool {
+	if e.minute&(1<<uint(t.Minute())) == 0 ||
+		e.hour&(1<<uint(t.Hour())) == 0 ||
+		e.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := e.dom&(1<<uint(t.Day())) != 0
+	dowMatch := e.dow&(1<<uint(t.Weekday())) != 0
+	// Standard cron semantics: when both day fields are restricted, either may match
+	switch {
+	case e.domAny && e.dowAny:
+		return true
+	case e.domAny:
+		return dowMatch
+	case e.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parseExpression parses a single five-field expression
+func parseExpression(spec string) (expression, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return expression{}, fmt.Errorf("expected 5 fields, got %d", len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return expression{}, err
+		}
+		bits[i] = b
+	}
+
+	// Fold Sunday=7 onto Sunday=0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return expression{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseField parses a comma separated cron field into a bitset
+func parseField(spec string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(loPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", loPart, f.name)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiPart); err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s field", hiPart, f.name)
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+		}
+
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s field value %q out of range %d-%d", f.name, item, f.min, f.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// jsonSchemaDialect is the JSON Schema version documents are written in
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonTypes maps inferred field types to JSON Schema types
+var jsonTypes = map[string]string{
+	TypeString:    "string",
+	TypeInt:       "integer",
+	TypeFloat:     "number",
+	TypeBool:      "boolean",
+	TypeTimestamp: "string",
+	TypeObject:    "object",
+}
+
+// shapeFormats maps string shapes to JSON Schema formats
+var shapeFormats = map[string]string{
+	ShapeUUID:  "uuid",
+	ShapeIPv4:  "ipv4",
+	ShapeEmail: "email",
+	ShapeURL:   "uri",
+}
+
+// JSONSchema returns a JSON Schema document describing the records the
+// schema was inferred from. Fields present in every record are required,
+// and string fields with few distinct values are enums.
+func (s *Schema) JSONSchema(title string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, f := range s.Fields {
+		properties[f.Name] = f.jsonSchema(s)
+		if f.Presence >= 1 {
+			required = append(required, f.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":     jsonSchemaDialect,
+		"title":       title,
+		"description": fmt.Sprintf("Inferred from %d generated records", s.Samples),
+		"type":        "object",
+		"properties":  properties,
+		"required":    required,
+	}
+}
+
+// jsonSchema describes a single field
+func (f *Field) jsonSchema(s *Schema) map[string]interface{} {
+	property := map[string]interface{}{
+		"description": fmt.Sprintf("Present in %.1f%% of records", f.Presence*100),
+	}
+
+	// Fields seen with several types (e.g. ints and floats) accept them all
+	types := []string{}
+	seen := map[string]bool{}
+	for typ := range f.typeHits {
+		if t := jsonTypes[typ]; !seen[t] {
+			types = append(types, t)
+			seen[t] = true
+		}
+	}
+	if len(types) == 0 {
+		types = append(types, jsonTypes[f.Type])
+	}
+	sort.Strings(types)
+	if seen["integer"] && seen["number"] {
+		types = removeType(types, "integer")
+	}
+	if len(types) == 1 {
+		property["type"] = types[0]
+	} else {
+		property["type"] = types
+	}
+
+	switch {
+	case f.Type == TypeTimestamp && (f.Layout == time.RFC3339 || f.Layout == time.RFC3339Nano):
+		property["format"] = "date-time"
+	case f.Type == TypeString && shapeFormats[f.Shape] != "":
+		property["format"] = shapeFormats[f.Shape]
+	case f.Type == TypeString && f.Shape == ShapeHex:
+		property["pattern"] = "^[0-9a-fA-F]+$"
+	}
+
+	if f.Name == s.LevelField && len(s.Levels) > 0 {
+		property["enum"] = sortedKeys(s.Levels)
+	} else if f.Type == TypeString && len(f.Values) > 0 && len(types) == 1 {
+		property["enum"] = sortedKeys(f.Values)
+	}
+	return property
+}
+
+// removeType removes a type from a list of types
+func removeType(types []string, remove string) []string {
+	kept := types[:0]
+	for _, t := range types {
+		if t != remove {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
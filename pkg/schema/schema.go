@@ -0,0 +1,518 @@
+package schema
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rjonczy/log-genie/pkg/catalog"
+)
+
+// Field types recognised during inference
+const (
+	TypeString    = "string"
+	TypeInt       = "int"
+	TypeFloat     = "float"
+	TypeBool      = "bool"
+	TypeTimestamp = "timestamp"
+	TypeObject    = "object"
+)
+
+// String shapes recognised during inference
+const (
+	ShapeText  = "text"
+	ShapeUUID  = "uuid"
+	ShapeIPv4  = "ipv4"
+	ShapeEmail = "email"
+	ShapeURL   = "url"
+	ShapeHex   = "hex"
+)
+
+// maxCategories is the cardinality up to which values are kept as an enum
+const maxCategories = 20
+
+var (
+	// messageKeys and levelKeys are checked in order to find the message and level fields
+	messageKeys = []string{"msg", "message", "body", "log"}
+	levelKeys   = []string{"level", "severity", "lvl", "loglevel", "log.level"}
+
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	ipv4Pattern  = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	urlPattern   = regexp.MustCompile(`^https?://`)
+	hexPattern   = regexp.MustCompile(`^[0-9a-fA-F]{8,}$`)
+)
+
+// Schema describes the shape of a sample log file
+type Schema struct {
+	Samples      int            `json:"samples"`
+	MessageField string         `json:"message_field,omitempty"`
+	LevelField   string         `json:"level_field,omitempty"`
+	Levels       map[string]int `json:"levels,omitempty"`
+	Fields       []*Field       `json:"fields"`
+	byName       map[string]*Field
+}
+
+// Field describes a single inferred field and its value distribution
+type Field struct {
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	Shape    string         `json:"shape,omitempty"`
+	Presence float64        `json:"presence"`
+	Min      float64        `json:"min,omitempty"`
+	Max      float64        `json:"max,omitempty"`
+	AvgLen   float64        `json:"avg_len,omitempty"`
+	Layout   string         `json:"layout,omitempty"`
+	Values   map[string]int `json:"values,omitempty"`
+	Fake     string         `json:"fake,omitempty"` // catalog placeholder generating the values, set by hand
+
+	count    int
+	typeHits map[string]int
+	shapes   map[string]int
+	lenTotal int
+	overflow bool
+	numeric  bool
+}
+
+// timestampLayouts are the layouts tried when detecting timestamp strings
+var timestampLayouts = []string{time.RFC3339Nano, time.RFC3339, "2006-01-02 15:04:05.000", "2006-01-02 15:04:05"}
+
+// InferFile infers a schema from an NDJSON or logfmt file
+func InferFile(path string) (*Schema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sample: %w", err)
+	}
+	defer file.Close()
+	return Infer(file)
+}
+
+// Infer infers a schema from NDJSON or logfmt records, one per line
+func Infer(r io.Reader) (*Schema, error) {
+	s := &Schema{byName: make(map[string]*Field), Levels: make(map[string]int)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		record, err := ParseRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		s.observe(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sample: %w", err)
+	}
+	if s.Samples == 0 {
+		return nil, fmt.Errorf("sample contains no records")
+	}
+
+	s.finalize()
+	return s, nil
+}
+
+// ParseRecord parses a single NDJSON or logfmt line
+func ParseRecord(line string) (map[string]interface{}, error) {
+	if strings.HasPrefix(line, "{") {
+		record := make(map[string]interface{})
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return record, nil
+	}
+	return parseLogfmt(line)
+}
+
+// Load reads a schema previously written by Save
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	s := &Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	s.byName = make(map[string]*Field, len(s.Fields))
+	for _, f := range s.Fields {
+		s.byName[f.Name] = f
+	}
+	return s, nil
+}
+
+// Save writes the schema as indented JSON
+func (s *Schema) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// RandomLevel returns a level value drawn from the observed distribution,
+// or an empty string if the sample had no level field
+func (s *Schema) RandomLevel() string {
+	return weightedPick(s.Levels)
+}
+
+// TimestampFields returns the names of fields holding timestamps
+func (s *Schema) TimestampFields() []string {
+	var names []string
+	for _, f := range s.Fields {
+		if f.Type == TypeTimestamp {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// Generate produces field values following the inferred schema. The message
+// and level fields are left to the caller.
+func (s *Schema) Generate() map[string]interface{} {
+	fields := make(map[string]interface{}, len(s.Fields))
+	for _, f := range s.Fields {
+		if f.Name == s.MessageField || f.Name == s.LevelField {
+			continue
+		}
+		if f.Presence < 1 && gofakeit.Float64Range(0, 1) >= f.Presence {
+			continue
+		}
+		fields[f.Name] = f.generate()
+	}
+	return fields
+}
+
+// observe records a single parsed record
+func (s *Schema) observe(record map[string]interface{}) {
+	s.Samples++
+	for name, value := range record {
+		f, ok := s.byName[name]
+		if !ok {
+			f = &Field{Name: name, typeHits: make(map[string]int), shapes: make(map[string]int), Values: make(map[string]int)}
+			s.byName[name] = f
+			s.Fields = append(s.Fields, f)
+		}
+		f.observe(value)
+	}
+
+	for _, key := range levelKeys {
+		if level, ok := record[key].(string); ok {
+			s.Levels[strings.ToLower(level)]++
+			break
+		}
+	}
+}
+
+// finalize resolves field types and detects the message and level fields
+func (s *Schema) finalize() {
+	sort.Slice(s.Fields, func(i, j int) bool { return s.Fields[i].Name < s.Fields[j].Name })
+	for _, f := range s.Fields {
+		f.finalize(s.Samples)
+	}
+
+	for _, key := range messageKeys {
+		if f, ok := s.byName[key]; ok && f.Type == TypeString {
+			s.MessageField = key
+			// Never persist real messages
+			f.Values = nil
+			break
+		}
+	}
+	for _, key := range levelKeys {
+		if f, ok := s.byName[key]; ok && f.Type == TypeString {
+			s.LevelField = key
+			break
+		}
+	}
+	if len(s.Levels) == 0 {
+		s.Levels = nil
+	}
+}
+
+// observe records a single value of the field
+func (f *Field) observe(value interface{}) {
+	f.count++
+
+	var typ, text string
+	switch v := value.(type) {
+	case bool:
+		typ, text = TypeBool, strconv.FormatBool(v)
+	case json.Number:
+		text = v.String()
+		if n, err := v.Int64(); err == nil {
+			typ = TypeInt
+			f.updateRange(float64(n))
+		} else if n, err := v.Float64(); err == nil {
+			typ = TypeFloat
+			f.updateRange(n)
+		}
+	case string:
+		typ, text = classifyString(v)
+		switch typ {
+		case TypeInt, TypeFloat:
+			n, _ := strconv.ParseFloat(v, 64)
+			f.updateRange(n)
+		case TypeTimestamp:
+			f.Layout = timestampLayout(v)
+		default:
+			f.shapes[stringShape(v)]++
+			f.lenTotal += len(v)
+		}
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		typ, text = TypeObject, string(encoded)
+	default:
+		typ, text = TypeString, fmt.Sprintf("%v", v)
+	}
+	f.typeHits[typ]++
+
+	if !f.overflow {
+		f.Values[text]++
+		if len(f.Values) > maxCategories {
+			f.overflow = true
+		}
+	}
+}
+
+// updateRange widens the numeric range of the field
+func (f *Field) updateRange(n float64) {
+	if !f.numeric || n < f.Min {
+		f.Min = n
+	}
+	if !f.numeric || n > f.Max {
+		f.Max = n
+	}
+	f.numeric = true
+}
+
+// finalize picks the dominant type and decides whether to keep an enum
+func (f *Field) finalize(samples int) {
+	f.Presence = math.Round(float64(f.count)/float64(samples)*1000) / 1000
+
+	best := 0
+	for typ, hits := range f.typeHits {
+		if hits > best || (hits == best && typ < f.Type) {
+			f.Type, best = typ, hits
+		}
+	}
+
+	if f.Type == TypeString {
+		best = 0
+		for shape, hits := range f.shapes {
+			if hits > best {
+				f.Shape, best = shape, hits
+			}
+		}
+		f.AvgLen = math.Round(float64(f.lenTotal)/float64(f.count)*10) / 10
+	}
+
+	// High-cardinality and free-form values are regenerated, not replayed
+	if f.overflow || f.Type == TypeTimestamp || (f.Type == TypeString && f.Shape != ShapeText) {
+		f.Values = nil
+	}
+}
+
+// generate produces a new value for the field
+func (f *Field) generate() interface{} {
+	if f.Fake != "" {
+		if generate, ok := catalog.Fake(f.Fake); ok {
+			return generate()
+		}
+	}
+
+	if len(f.Values) > 0 {
+		value := weightedPick(f.Values)
+		switch f.Type {
+		case TypeInt:
+			n, _ := strconv.ParseInt(value, 10, 64)
+			return n
+		case TypeFloat:
+			n, _ := strconv.ParseFloat(value, 64)
+			return n
+		case TypeBool:
+			return value == "true"
+		case TypeObject:
+			var v interface{}
+			_ = json.Unmarshal([]byte(value), &v)
+			return v
+		}
+		return value
+	}
+
+	switch f.Type {
+	case TypeInt:
+		return int64(gofakeit.Number(int(f.Min), int(f.Max)))
+	case TypeFloat:
+		return math.Round(gofakeit.Float64Range(f.Min, f.Max)*1000) / 1000
+	case TypeBool:
+		return gofakeit.Bool()
+	case TypeTimestamp:
+		layout := f.Layout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return time.Now().Format(layout)
+	case TypeObject:
+		return map[string]interface{}{}
+	}
+
+	switch f.Shape {
+	case ShapeUUID:
+		return gofakeit.UUID()
+	case ShapeIPv4:
+		return gofakeit.IPv4Address()
+	case ShapeEmail:
+		return gofakeit.Email()
+	case ShapeURL:
+		return gofakeit.URL()
+	case ShapeHex:
+		return gofakeit.HexUint64()[2:]
+	}
+
+	words := int(math.Max(1, f.AvgLen/6))
+	return strings.TrimSuffix(gofakeit.Sentence(words), ".")
+}
+
+// classifyString detects numbers and timestamps encoded as strings (as in logfmt)
+func classifyString(v string) (typ, text string) {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return TypeInt, v
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return TypeFloat, v
+	}
+	if v == "true" || v == "false" {
+		return TypeBool, v
+	}
+	if timestampLayout(v) != "" {
+		return TypeTimestamp, v
+	}
+	return TypeString, v
+}
+
+// timestampLayout returns the layout a timestamp string was written in
+func timestampLayout(v string) string {
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return layout
+		}
+	}
+	return ""
+}
+
+// stringShape classifies free-form strings so fresh values of the same kind can be generated
+func stringShape(v string) string {
+	switch {
+	case uuidPattern.MatchString(v):
+		return ShapeUUID
+	case ipv4Pattern.MatchString(v):
+		return ShapeIPv4
+	case emailPattern.MatchString(v):
+		return ShapeEmail
+	case urlPattern.MatchString(v):
+		return ShapeURL
+	case hexPattern.MatchString(v):
+		return ShapeHex
+	}
+	return ShapeText
+}
+
+// weightedPick draws a key with probability proportional to its count
+func weightedPick(weights map[string]int) string {
+	total := 0
+	keys := make([]string, 0, len(weights))
+	for k, w := range weights {
+		total += w
+		keys = append(keys, k)
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	n := gofakeit.Number(1, total)
+	for _, k := range keys {
+		n -= weights[k]
+		if n <= 0 {
+			return k
+		}
+	}
+	return keys[len(keys)-1]
+}
+
+// parseLogfmt parses key=value pairs; values may be double quoted
+func parseLogfmt(line string) (map[string]interface{}, error) {
+	record := make(map[string]interface{})
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("invalid logfmt at column %d", i+1)
+		}
+		if i >= len(line) || line[i] != '=' {
+			// Bare keys are boolean flags
+			record[key] = "true"
+			continue
+		}
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quote at column %d", i+1)
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value at column %d: %w", i+1, err)
+			}
+			record[key] = value
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		record[key] = line[start:i]
+	}
+
+	if len(record) == 0 {
+		return nil, fmt.Errorf("no key=value pairs found")
+	}
+	return record, nil
+}
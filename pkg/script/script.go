@@ -0,0 +1,248 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Script is a compiled Lua transform, safe for concurrent use. It runs once
+// per log with the global table event holding its level, message, time
+// (RFC 3339, read-only) and fields, which it may change in place; returning
+// false drops the log.
+type Script struct {
+	name   string
+	proto  *lua.FunctionProto
+	states sync.Pool
+	failed sync.Once // reports the first runtime error
+}
+
+// Load compiles the Lua script in a file
+func Load(path string) (*Script, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(path, string(source))
+}
+
+// Compile compiles a Lua script, named in errors
+func Compile(name, source string) (*Script, error) {
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{name: name, proto: proto}
+	s.states.New = func() interface{} {
+		return newState()
+	}
+	return s, nil
+}
+
+// newState creates an interpreter with the libraries a transform may use,
+// without access to files or processes
+func newState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, unsafe := range []string{"dofile", "loadfile", "load", "loadstring", "require"} {
+		L.SetGlobal(unsafe, lua.LNil)
+	}
+	return L
+}
+
+// Transform runs the script on a log, reporting false if it dropped the log.
+// A script failing at runtime leaves the log as it was.
+func (s *Script) Transform(e *logger.Entry) bool {
+	L := s.states.Get().(*lua.LState)
+	defer s.states.Put(L)
+
+	original := make(map[string]lua.LValue, len(e.Fields))
+	fields := L.NewTable()
+	for key, value := range e.Fields {
+		lv := toLua(L, value)
+		original[key] = lv
+		fields.RawSetString(key, lv)
+	}
+	event := L.NewTable()
+	event.RawSetString("level", lua.LString(e.Level))
+	event.RawSetString("message", lua.LString(e.Message))
+	event.RawSetString("time", lua.LString(e.Time.Format(time.RFC3339Nano)))
+	event.RawSetString("fields", fields)
+	L.SetGlobal("event", event)
+
+	L.Push(L.NewFunctionFromProto(s.proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		s.failed.Do(func() {
+			diag.Warn("Transform script failed, logs are left as they were", "script", s.name, "error", err)
+		})
+		L.SetTop(0)
+		return true
+	}
+	result := L.Get(-1)
+	L.Pop(1)
+	if result == lua.LFalse {
+		return false
+	}
+
+	if level, ok := event.RawGetString("level").(lua.LString); ok {
+		e.Level = logger.LogLevel(level)
+	}
+	if message, ok := event.RawGetString("message").(lua.LString); ok {
+		e.Message = string(message)
+	}
+	// The script may have replaced the table of fields
+	fields, ok := event.RawGetString("fields").(*lua.LTable)
+	if !ok {
+		fields = L.NewTable()
+	}
+	for key := range e.Fields {
+		if fields.RawGetString(key) == lua.LNil && original[key] != lua.LNil {
+			delete(e.Fields, key)
+		}
+	}
+	fields.ForEach(func(key, value lua.LValue) {
+		name := key.String()
+		// Keep unchanged values as they were, e.g. integers beyond the
+		// precision of Lua's numbers
+		if lv, ok := original[name]; ok && lv.Type() != lua.LTTable && lv == value {
+			return
+		}
+		e.Fields[name] = fromLua(value)
+	})
+	return true
+}
+
+// toLua converts a field value to Lua
+func toLua(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case string:
+		return lua.LString(v)
+	case bool:
+		return lua.LBool(v)
+	case int:
+		return lua.LNumber(v)
+	case int32:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case uint64:
+		return lua.LNumber(v)
+	case float32:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return lua.LString(v)
+		}
+		return lua.LNumber(f)
+	case time.Time:
+		return lua.LString(v.Format(time.RFC3339Nano))
+	case map[string]interface{}:
+		t := L.NewTable()
+		for key, nested := range v {
+			t.RawSetString(key, toLua(L, nested))
+		}
+		return t
+	case []interface{}:
+		t := L.NewTable()
+		for _, item := range v {
+			t.Append(toLua(L, item))
+		}
+		return t
+	default:
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
+// fromLua converts a Lua value back to a field value: integral numbers
+// become integers and tables with only positive integer keys become arrays
+func fromLua(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LString:
+		return string(v)
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		f := float64(v)
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f)
+		}
+		return f
+	case *lua.LTable:
+		if n := v.MaxN(); n > 0 && n == countKeys(v) {
+			items := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				items = append(items, fromLua(v.RawGetInt(i)))
+			}
+			return items
+		}
+		m := map[string]interface{}{}
+		v.ForEach(func(key, nested lua.LValue) {
+			m[key.String()] = fromLua(nested)
+		})
+		return m
+	default:
+		return nil
+	}
+}
+
+// countKeys returns the number of keys of a table
+func countKeys(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
+
+// Parse reads transform scripts given as sink=file.lua pairs, separated by
+// commas, into the transforms of their sinks
+func Parse(spec string) (logger.Transforms, error) {
+	pairs, err := logger.ParseAttributes(spec)
+	if err != nil {
+		return nil, err
+	}
+	sinks := make([]string, 0, len(pairs))
+	for sink := range pairs {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+
+	transforms := logger.Transforms{}
+	for _, sink := range sinks {
+		s, err := Load(pairs[sink])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sink, err)
+		}
+		transforms[sink] = s.Transform
+	}
+	return transforms, nil
+}
@@ -0,0 +1,105 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the SNMP types used in traps
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagOID         = 0x06
+	tagSequence    = 0x30
+	tagTimeTicks   = 0x43
+	tagTrapV2      = 0xa7
+)
+
+// tlv encodes a value of a tag
+func tlv(tag byte, content []byte) []byte {
+	out := append([]byte{tag}, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+// encodeLength encodes a length in the short form up to 127, the long form
+// beyond
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var digits []byte
+	for ; n > 0; n >>= 8 {
+		digits = append([]byte{byte(n)}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+// integer encodes a signed integer in the fewest two's complement bytes
+func integer(v int64) []byte {
+	content := []byte{byte(v)}
+	for v >>= 8; !(v == 0 && content[0] < 0x80) && !(v == -1 && content[0] >= 0x80); v >>= 8 {
+		content = append([]byte{byte(v)}, content...)
+	}
+	return tlv(tagInteger, content)
+}
+
+// unsigned encodes an unsigned application type such as TimeTicks
+func unsigned(tag byte, v uint32) []byte {
+	content := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		content = append([]byte{byte(v)}, content...)
+	}
+	if content[0] >= 0x80 {
+		content = append([]byte{0}, content...)
+	}
+	return tlv(tag, content)
+}
+
+// octetString encodes a string
+func octetString(s string) []byte {
+	return tlv(tagOctetString, []byte(s))
+}
+
+// oid encodes an object identifier in dotted notation
+func oid(dotted string) ([]byte, error) {
+	parts := strings.Split(dotted, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", dotted)
+	}
+	arcs := make([]uint64, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", dotted)
+		}
+		arcs[i] = arc
+	}
+	if arcs[0] > 2 || (arcs[0] < 2 && arcs[1] > 39) {
+		return nil, fmt.Errorf("invalid OID %q", dotted)
+	}
+	content := base128(arcs[0]*40 + arcs[1])
+	for _, arc := range arcs[2:] {
+		content = append(content, base128(arc)...)
+	}
+	return tlv(tagOID, content), nil
+}
+
+// base128 encodes an arc of an OID, seven bits per byte with the high bit
+// set on all but the last
+func base128(v uint64) []byte {
+	out := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		out = append([]byte{byte(v&0x7f) | 0x80}, out...)
+	}
+	return out
+}
+
+// mustOID encodes an OID known to be valid
+func mustOID(dotted string) []byte {
+	encoded, err := oid(dotted)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
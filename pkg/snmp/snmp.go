@@ -0,0 +1,179 @@
+package snmp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+const (
+	defaultPort      = "162"
+	defaultCommunity = "public"
+	interfaces       = 48 // interfaces the link traps are spread over
+)
+
+// OIDs of the standard traps (SNMPv2-MIB, IF-MIB) and their variables
+const (
+	OIDColdStart     = "1.3.6.1.6.3.1.1.5.1"
+	OIDWarmStart     = "1.3.6.1.6.3.1.1.5.2"
+	OIDLinkDown      = "1.3.6.1.6.3.1.1.5.3"
+	OIDLinkUp        = "1.3.6.1.6.3.1.1.5.4"
+	OIDAuthFailure   = "1.3.6.1.6.3.1.1.5.5"
+	oidSysUpTime     = "1.3.6.1.2.1.1.3.0"
+	oidSnmpTrapOID   = "1.3.6.1.6.3.1.1.4.1.0"
+	oidIfIndex       = "1.3.6.1.2.1.2.2.1.1"
+	oidIfDescr       = "1.3.6.1.2.1.2.2.1.2"
+	oidIfAdminStatus = "1.3.6.1.2.1.2.2.1.7"
+	oidIfOperStatus  = "1.3.6.1.2.1.2.2.1.8"
+)
+
+// Variables carrying the log a trap stands for, under the enterprise number
+// reserved for documentation (RFC 5612)
+const (
+	OIDLogMessage = "1.3.6.1.4.1.32473.1.1"
+	OIDLogLevel   = "1.3.6.1.4.1.32473.1.2"
+	OIDLogService = "1.3.6.1.4.1.32473.1.3"
+)
+
+// ifStatus values of IF-MIB
+const (
+	ifUp   = 1
+	ifDown = 2
+)
+
+// Config holds the configuration of the trap sink
+type Config struct {
+	Target    string // host:port of the trap receiver, port 162 if omitted
+	Community string // community string (default public)
+}
+
+// Sink sends every log as an SNMPv2c trap over UDP, picking a standard trap
+// by its level: linkDown for errors, authenticationFailure for warnings,
+// linkUp for info and warmStart for debug logs. A coldStart trap announces
+// the sink. Every trap carries the message, level and service of its log.
+type Sink struct {
+	conn      net.Conn
+	community string
+	start     time.Time
+	requestID atomic.Int32
+}
+
+// New creates a trap sink and sends its coldStart trap
+func New(config Config) (*Sink, error) {
+	target := config.Target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultPort)
+	}
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach trap receiver: %w", err)
+	}
+	if config.Community == "" {
+		config.Community = defaultCommunity
+	}
+	s := &Sink{conn: conn, community: config.Community, start: time.Now()}
+	if err := s.send(OIDColdStart, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write sends the trap of a log
+func (s *Sink) Write(e logger.Entry) error {
+	trap, varbinds := TrapOf(e.Level), [][]byte{
+		varbind(OIDLogMessage, octetString(e.Message)),
+		varbind(OIDLogLevel, octetString(string(e.Level))),
+	}
+	if service, ok := e.Fields["service"].(string); ok {
+		varbinds = append(varbinds, varbind(OIDLogService, octetString(service)))
+	}
+	if trap == OIDLinkDown || trap == OIDLinkUp {
+		varbinds = append(linkVarbinds(e, trap == OIDLinkUp), varbinds...)
+	}
+	return s.send(trap, varbinds)
+}
+
+// Close closes the socket
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+// TrapOf returns the OID of the trap a log of a level is sent as
+func TrapOf(level logger.LogLevel) string {
+	switch level {
+	case logger.Error:
+		return OIDLinkDown
+	case logger.Warn:
+		return OIDAuthFailure
+	case logger.Debug:
+		return OIDWarmStart
+	}
+	return OIDLinkUp
+}
+
+// linkVarbinds returns the interface variables of a link trap, the
+// interface derived from the service of the log so each service flaps its
+// own link
+func linkVarbinds(e logger.Entry, up bool) [][]byte {
+	h := fnv.New32a()
+	service, _ := e.Fields["service"].(string)
+	h.Write([]byte(service))
+	index := int64(h.Sum32()%interfaces) + 1
+	status := int64(ifDown)
+	if up {
+		status = ifUp
+	}
+	suffix := fmt.Sprintf(".%d", index)
+	return [][]byte{
+		varbind(oidIfIndex+suffix, integer(index)),
+		varbind(oidIfDescr+suffix, octetString(fmt.Sprintf("GigabitEthernet0/%d", index))),
+		varbind(oidIfAdminStatus+suffix, integer(ifUp)),
+		varbind(oidIfOperStatus+suffix, integer(status)),
+	}
+}
+
+// send sends a trap with sysUpTime and snmpTrapOID ahead of its variables
+func (s *Sink) send(trap string, varbinds [][]byte) error {
+	uptime := uint32(time.Since(s.start) / (10 * time.Millisecond))
+	list := append([][]byte{
+		varbind(oidSysUpTime, unsigned(tagTimeTicks, uptime)),
+		varbind(oidSnmpTrapOID, mustOID(trap)),
+	}, varbinds...)
+
+	pdu := concat(
+		integer(int64(s.requestID.Add(1))),
+		integer(0), // error-status
+		integer(0), // error-index
+		tlv(tagSequence, concat(list...)),
+	)
+	message := tlv(tagSequence, concat(
+		integer(1), // version: SNMPv2c
+		octetString(s.community),
+		tlv(tagTrapV2, pdu),
+	))
+	_, err := s.conn.Write(message)
+	return err
+}
+
+// varbind encodes a variable binding
+func varbind(name string, value []byte) []byte {
+	return tlv(tagSequence, append(mustOID(name), value...))
+}
+
+// concat joins encoded values
+func concat(parts ...[]byte) []byte {
+	var n int
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]byte, 0, n)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
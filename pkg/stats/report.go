@@ -0,0 +1,149 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// Report formats
+const (
+	// ReportText reports through the diagnostics
+	ReportText = "text"
+	// ReportJSON writes a JSON object per report
+	ReportJSON = "json"
+)
+
+// ParseReportFormat validates a report format, text if empty
+func ParseReportFormat(format string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case "":
+		return ReportText, nil
+	case ReportText, ReportJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown report format %q (use %s or %s)", format, ReportText, ReportJSON)
+}
+
+// Snapshot is a point-in-time copy of the counts of a registry
+type Snapshot struct {
+	Time   time.Time
+	Levels map[string]int64
+	Sinks  []SinkStats
+}
+
+// Snapshot copies the counts of the registry
+func (r *Registry) Snapshot() Snapshot {
+	return Snapshot{Time: time.Now(), Levels: r.Levels(), Sinks: r.Sinks()}
+}
+
+// Report describes what happened between two snapshots
+type Report struct {
+	Time       time.Time        `json:"time"`
+	Seconds    float64          `json:"seconds"`
+	Logs       int64            `json:"logs"` // generated, at any level
+	LogsPerSec float64          `json:"logs_per_sec"`
+	Levels     map[string]int64 `json:"levels"`
+	Sinks      []SinkReport     `json:"sinks"`
+}
+
+// SinkReport describes what a sink did between two snapshots
+type SinkReport struct {
+	Name       string  `json:"name"`
+	Sent       int64   `json:"sent"`
+	Failed     int64   `json:"failed"`
+	Dropped    int64   `json:"dropped"` // change of the logs counted as dropped, see SinkStats
+	SentPerSec float64 `json:"sent_per_sec"`
+}
+
+// Since reports what changed since an earlier snapshot. Sinks registered
+// meanwhile count from zero.
+func (s Snapshot) Since(earlier Snapshot) Report {
+	seconds := s.Time.Sub(earlier.Time).Seconds()
+	report := Report{Time: s.Time, Seconds: round(seconds), Levels: make(map[string]int64, len(s.Levels))}
+	for level, n := range s.Levels {
+		report.Levels[level] = n - earlier.Levels[level]
+		report.Logs += report.Levels[level]
+	}
+	before := make(map[string]SinkStats, len(earlier.Sinks))
+	for _, sink := range earlier.Sinks {
+		before[sink.Name] = sink
+	}
+	for _, sink := range s.Sinks {
+		b := before[sink.Name]
+		report.Sinks = append(report.Sinks, SinkReport{
+			Name:    sink.Name,
+			Sent:    sink.Sent - b.Sent,
+			Failed:  sink.Failed - b.Failed,
+			Dropped: sink.Dropped - b.Dropped,
+		})
+	}
+	if seconds > 0 {
+		report.LogsPerSec = round(float64(report.Logs) / seconds)
+		for i := range report.Sinks {
+			report.Sinks[i].SentPerSec = round(float64(report.Sinks[i].Sent) / seconds)
+		}
+	}
+	return report
+}
+
+// round rounds to a tenth, enough for reporting rates
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+// Reporter reports the counts of a registry every interval, each report
+// comparing a snapshot with the one before, so counters are never reset
+type Reporter struct {
+	Registry *Registry
+	Interval time.Duration
+	Format   string    // ReportText or ReportJSON
+	Output   io.Writer // where JSON reports are written, one per line
+}
+
+// Run reports every interval until ctx is done
+func (r *Reporter) Run(ctx context.Context) {
+	if r.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	last := r.Registry.Snapshot()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		snapshot := r.Registry.Snapshot()
+		if err := r.write(snapshot.Since(last)); err != nil {
+			diag.Warn("Failed to write stats report", "error", err)
+		}
+		last = snapshot
+	}
+}
+
+// write writes a report in the format of the reporter
+func (r *Reporter) write(report Report) error {
+	if r.Format == ReportJSON {
+		data, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		_, err = r.Output.Write(append(data, '\n'))
+		return err
+	}
+
+	diag.Info("Stats report", "seconds", report.Seconds, "logs", report.Logs, "logs_per_sec", report.LogsPerSec)
+	for _, sink := range report.Sinks {
+		diag.Info("Sink report", "sink", sink.Name, "sent", sink.Sent, "failed", sink.Failed, "dropped", sink.Dropped, "sent_per_sec", sink.SentPerSec)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing count, safe for concurrent use
+type Counter struct {
+	value atomic.Int64
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) {
+	c.value.Add(n)
+}
+
+// Load returns the current count
+func (c *Counter) Load() int64 {
+	return c.value.Load()
+}
+
+// Sink counts the logs handed to a sink and the outcome of delivering them
+type Sink struct {
+	name     string
+	queued   Counter
+	sent     Counter
+	failed   Counter
+	dropped  Counter      // queued logs known to be dropped
+	failing  atomic.Bool  // the last delivery failed
+	capacity atomic.Int64 // logs the sink holds queued before dropping, 0 if unknown
+}
+
+// Queue counts logs handed to the sink whose delivery completes later
+func (s *Sink) Queue(n int64) {
+	s.queued.Add(n)
+}
+
+// Done counts queued logs whose delivery completed, as sent or as failed
+// depending on err
+func (s *Sink) Done(n int64, err error) {
+	if err != nil {
+		s.failed.Add(n)
+	} else {
+		s.sent.Add(n)
+	}
+	s.failing.Store(err != nil)
+}
+
+// Drop counts queued logs the sink dropped instead of delivering. Stats
+// counts them as dropped either way, but they no longer count as backlog.
+func (s *Sink) Drop(n int64) {
+	s.dropped.Add(n)
+}
+
+// Deliver counts logs delivered synchronously, see Queue and Done
+func (s *Sink) Deliver(n int64, err error) {
+	s.Queue(n)
+	s.Done(n, err)
+}
+
+// SetCapacity records how many logs the sink can hold queued before it drops
+// them, which its pressure is measured against
+func (s *Sink) SetCapacity(n int64) {
+	s.capacity.Store(n)
+}
+
+// Backlog returns the logs queued and neither delivered, failed nor dropped
+// yet
+func (s *Sink) Backlog() int64 {
+	return max(0, s.queued.Load()-s.sent.Load()-s.failed.Load()-s.dropped.Load())
+}
+
+// Pressure returns how hard the sink pushes back, from 0 to 1: its backlog
+// as a share of its capacity, or 1 while its deliveries fail
+func (s *Sink) Pressure() float64 {
+	if s.failing.Load() {
+		return 1
+	}
+	capacity := s.capacity.Load()
+	if capacity <= 0 {
+		return 0
+	}
+	return min(1, float64(s.Backlog())/float64(capacity))
+}
+
+// Stats returns the counts of the sink so far
+func (s *Sink) Stats() SinkStats {
+	stats := SinkStats{
+		Name:    s.name,
+		Sent:    s.sent.Load(),
+		Failed:  s.failed.Load(),
+		Failing: s.failing.Load(),
+	}
+	if pending := s.queued.Load() - stats.Sent - stats.Failed; pending > 0 {
+		stats.Dropped = pending
+	}
+	return stats
+}
+
+// SinkStats counts the logs a sink delivered and failed to deliver
+type SinkStats struct {
+	Name    string `json:"name"`
+	Sent    int64  `json:"sent"`
+	Failed  int64  `json:"failed"`
+	Dropped int64  `json:"dropped,omitempty"` // queued but neither delivered nor failed (yet)
+	Failing bool   `json:"failing,omitempty"` // the last delivery failed
+}
+
+// Lost returns the share of logs the sink failed to deliver or dropped
+func (s SinkStats) Lost() float64 {
+	total := s.Sent + s.Failed + s.Dropped
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Failed+s.Dropped) / float64(total)
+}
+
+// Registry is the central place generators and sinks report their counts
+// into. Counters are created on first use and live as long as the registry.
+type Registry struct {
+	mutex  sync.Mutex
+	levels map[string]*Counter
+	sinks  []*Sink
+}
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{levels: make(map[string]*Counter)}
+}
+
+// Level returns the counter of logs generated at a level, creating it on
+// first use. Callers on a hot path should keep the counter.
+func (r *Registry) Level(name string) *Counter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	counter, ok := r.levels[name]
+	if !ok {
+		counter = &Counter{}
+		r.levels[name] = counter
+	}
+	return counter
+}
+
+// Levels returns the number of logs generated at each level
+func (r *Registry) Levels() map[string]int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	counts := make(map[string]int64, len(r.levels))
+	for name, counter := range r.levels {
+		counts[name] = counter.Load()
+	}
+	return counts
+}
+
+// Sink returns the counts of the named sink, registering it on first use
+func (r *Registry) Sink(name string) *Sink {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, sink := range r.sinks {
+		if sink.name == name {
+			return sink
+		}
+	}
+	sink := &Sink{name: name}
+	r.sinks = append(r.sinks, sink)
+	return sink
+}
+
+// Pressure returns the pressure of the sink pushing back the hardest, see
+// Sink.Pressure
+func (r *Registry) Pressure() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	pressure := 0.0
+	for _, sink := range r.sinks {
+		pressure = max(pressure, sink.Pressure())
+	}
+	return pressure
+}
+
+// Sinks returns the counts of every registered sink, in the order they were
+// registered
+func (r *Registry) Sinks() []SinkStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	stats := make([]SinkStats, 0, len(r.sinks))
+	for _, sink := range r.sinks {
+		stats = append(stats, sink.Stats())
+	}
+	return stats
+}
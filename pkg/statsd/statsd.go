@@ -0,0 +1,210 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// Flavors of the statsd line protocol
+const (
+	// FlavorStatsd folds tags into the metric name, for plain statsd
+	FlavorStatsd = "statsd"
+	// FlavorDogStatsD appends tags to the line, for DogStatsD and the
+	// collector's statsd receiver
+	FlavorDogStatsD = "dogstatsd"
+)
+
+const (
+	defaultPort     = "8125"
+	defaultPrefix   = "log_genie"
+	defaultInterval = time.Second
+	maxPacket       = 1432 // bytes per datagram, safe over Ethernet
+)
+
+// ParseFlavor validates a flavor, dogstatsd if empty
+func ParseFlavor(flavor string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(flavor)); f {
+	case "":
+		return FlavorDogStatsD, nil
+	case FlavorStatsd, FlavorDogStatsD:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown statsd flavor %q (use %s or %s)", flavor, FlavorStatsd, FlavorDogStatsD)
+}
+
+// Config holds the configuration of statsd emission
+type Config struct {
+	Target   string        // host:port of the statsd server, port 8125 if omitted
+	Flavor   string        // FlavorStatsd or FlavorDogStatsD (default dogstatsd)
+	Prefix   string        // prefix of the metric names (default log_genie)
+	Interval time.Duration // longest a metric waits in a datagram (default 1s)
+}
+
+// Emitter emits statsd metrics derived from the logs, like an application
+// instrumented with statsd would next to its logs: a counter of logs by
+// level, and for logs describing a request, a counter of requests by method
+// and status code, a counter of errors and a timer of the latency. As a
+// processor it sees every log before sampling, as the request metrics do.
+// Lines are packed into datagrams, sent when full and every interval.
+type Emitter struct {
+	conn    net.Conn
+	flavor  string
+	prefix  string
+	mutex   sync.Mutex
+	packet  []byte
+	done    chan struct{}
+	stopped sync.WaitGroup
+	failed  sync.Once
+}
+
+// New creates an emitter sending to the statsd server
+func New(config Config) (*Emitter, error) {
+	flavor, err := ParseFlavor(config.Flavor)
+	if err != nil {
+		return nil, err
+	}
+	target := config.Target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultPort)
+	}
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach statsd server: %w", err)
+	}
+	if config.Prefix == "" {
+		config.Prefix = defaultPrefix
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	e := &Emitter{
+		conn:   conn,
+		flavor: flavor,
+		prefix: strings.TrimSuffix(config.Prefix, "."),
+		packet: make([]byte, 0, maxPacket),
+		done:   make(chan struct{}),
+	}
+	e.stopped.Add(1)
+	go e.run(config.Interval)
+	return e, nil
+}
+
+// Process emits the metrics of a log
+func (e *Emitter) Process(entry *logger.Entry) bool {
+	e.emit("log.records", "1|c", "level", string(entry.Level))
+
+	method, ok := entry.Fields["http_method"].(string)
+	if !ok {
+		return true
+	}
+	status, hasStatus := logger.IntField(entry.Fields, "status_code")
+	tags := []string{"method", method}
+	if hasStatus {
+		tags = append(tags, "status_code", strconv.Itoa(status))
+	}
+	e.emit("http.server.requests", "1|c", tags...)
+	if entry.Level == logger.Error || (hasStatus && status >= 500) {
+		e.emit("http.server.errors", "1|c", "method", method)
+	}
+	if ms, ok := logger.IntField(entry.Fields, "latency_ms"); ok && ms >= 0 {
+		e.emit("http.server.request.duration", strconv.Itoa(ms)+"|ms", tags...)
+	}
+	return true
+}
+
+// Close sends the pending metrics and closes the socket
+func (e *Emitter) Close() error {
+	close(e.done)
+	e.stopped.Wait()
+	e.mutex.Lock()
+	e.send()
+	e.mutex.Unlock()
+	return e.conn.Close()
+}
+
+// emit adds a line of a metric, its value and type, with tags as name,
+// value pairs
+func (e *Emitter) emit(name, value string, tags ...string) {
+	var line strings.Builder
+	line.WriteString(e.prefix)
+	line.WriteByte('.')
+	line.WriteString(name)
+	if e.flavor == FlavorStatsd {
+		for i := 1; i < len(tags); i += 2 {
+			line.WriteByte('.')
+			line.WriteString(sanitize(tags[i]))
+		}
+	}
+	line.WriteByte(':')
+	line.WriteString(value)
+	if e.flavor == FlavorDogStatsD && len(tags) > 0 {
+		line.WriteString("|#")
+		for i := 0; i+1 < len(tags); i += 2 {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(tags[i])
+			line.WriteByte(':')
+			line.WriteString(sanitize(tags[i+1]))
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.packet) > 0 && len(e.packet)+1+line.Len() > maxPacket {
+		e.send()
+	}
+	if len(e.packet) > 0 {
+		e.packet = append(e.packet, '\n')
+	}
+	e.packet = append(e.packet, line.String()...)
+}
+
+// run sends the pending metrics every interval until closed
+func (e *Emitter) run(interval time.Duration) {
+	defer e.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.mutex.Lock()
+			e.send()
+			e.mutex.Unlock()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// send sends the pending lines as one datagram, reporting the first
+// failure; the mutex must be held
+func (e *Emitter) send() {
+	if len(e.packet) == 0 {
+		return
+	}
+	if _, err := e.conn.Write(e.packet); err != nil {
+		e.failed.Do(func() {
+			diag.Warn("Failed to send statsd metrics", "error", err)
+		})
+	}
+	e.packet = e.packet[:0]
+}
+
+// sanitize replaces the characters the line protocol reserves
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+)
+
+// defaultBuffer is the number of records buffered per client
+const defaultBuffer = 1024
+
+// Config holds the configuration for the stream hub
+type Config struct {
+	Buffer int // Records buffered per client before records are dropped for it
+}
+
+// Hub fans the generated logs out to the clients streaming them over
+// Server-Sent Events or WebSocket. It is an io.Writer taking one record per
+// write; a client that cannot keep up misses records rather than slowing
+// generation down.
+type Hub struct {
+	buffer  int
+	mutex   sync.Mutex
+	clients map[*client]struct{}
+}
+
+// client is a connected consumer of the stream
+type client struct {
+	records chan []byte
+	dropped atomic.Int64
+}
+
+// New creates a new stream hub with the given configuration
+func New(config Config) *Hub {
+	if config.Buffer <= 0 {
+		config.Buffer = defaultBuffer
+	}
+	return &Hub{buffer: config.Buffer, clients: make(map[*client]struct{})}
+}
+
+// Write sends a record to every connected client
+func (h *Hub) Write(p []byte) (int, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.clients) == 0 {
+		return len(p), nil
+	}
+
+	record := bytes.TrimRight(p, "\n")
+	record = append(make([]byte, 0, len(record)), record...)
+	for c := range h.clients {
+		select {
+		case c.records <- record:
+		default:
+			c.dropped.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+// Clients returns the number of connected clients
+func (h *Hub) Clients() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.clients)
+}
+
+// Handler returns the HTTP handler serving the stream as Server-Sent Events
+// on GET /events and as WebSocket text messages on /ws
+func (h *Hub) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", h.handleEvents)
+	// Accept any origin, so browser demos served from elsewhere can connect
+	mux.Handle("/ws", websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   h.handleWebSocket,
+	})
+	return mux
+}
+
+// subscribe registers a new client
+func (h *Hub) subscribe(remote string) *client {
+	c := &client{records: make(chan []byte, h.buffer)}
+	h.mutex.Lock()
+	h.clients[c] = struct{}{}
+	clients := len(h.clients)
+	h.mutex.Unlock()
+	diag.Info("Stream client connected", "remote", remote, "clients", clients)
+	return c
+}
+
+// unsubscribe removes a client
+func (h *Hub) unsubscribe(c *client, remote string) {
+	h.mutex.Lock()
+	delete(h.clients, c)
+	clients := len(h.clients)
+	h.mutex.Unlock()
+	diag.Info("Stream client disconnected", "remote", remote, "clients", clients, "dropped", c.dropped.Load())
+}
+
+// handleEvents streams the records as Server-Sent Events, one record per
+// event
+func (h *Hub) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c := h.subscribe(r.RemoteAddr)
+	defer h.unsubscribe(c, r.RemoteAddr)
+	for {
+		select {
+		case record := <-c.records:
+			if writeEvent(w, record) != nil {
+				return
+			}
+			// Send what is buffered in one flush
+			for n := len(c.records); n > 0; n-- {
+				if writeEvent(w, <-c.records) != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a record as an event, splitting records spanning lines
+// (e.g. plain logs with stack traces) into data lines
+func writeEvent(w io.Writer, record []byte) error {
+	_, err := fmt.Fprintf(w, "data: %s\n\n", bytes.ReplaceAll(record, []byte("\n"), []byte("\ndata: ")))
+	return err
+}
+
+// handleWebSocket streams the records as WebSocket text messages, one
+// record per message
+func (h *Hub) handleWebSocket(conn *websocket.Conn) {
+	remote := conn.Request().RemoteAddr
+	c := h.subscribe(remote)
+	defer h.unsubscribe(c, remote)
+
+	// Notice the client going away; it is not expected to send anything
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard []byte
+		for websocket.Message.Receive(conn, &discard) == nil {
+		}
+	}()
+
+	for {
+		select {
+		case record := <-c.records:
+			if err := websocket.Message.Send(conn, string(record)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-conn.Request().Context().Done():
+			return
+		}
+	}
+}
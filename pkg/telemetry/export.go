@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+
+	"github.com/rjonczy/log-genie/pkg/stats"
+)
+
+// countingExporter counts the records the wrapped exporter delivered or
+// failed to deliver, and records how long exports take
+type countingExporter struct {
+	sdklog.Exporter
+	sink     *stats.Sink
+	duration metric.Float64Histogram
+}
+
+// newCountingExporter wraps an exporter. Export durations are recorded
+// through the global meter provider, which only exports them when self
+// metrics are enabled.
+func newCountingExporter(exporter sdklog.Exporter, sink *stats.Sink) *countingExporter {
+	duration, _ := otel.Meter("log-genie").Float64Histogram("loggenie.export.duration",
+		metric.WithDescription("Duration of OTLP log exports"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5))
+	return &countingExporter{Exporter: exporter, sink: sink, duration: duration}
+}
+
+// Export exports the records and counts them
+func (e *countingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.duration.Record(ctx, time.Since(start).Seconds(),
+		metric.WithAttributes(attribute.Bool("error", err != nil)))
+	e.sink.Done(int64(len(records)), err)
+	return err
+}
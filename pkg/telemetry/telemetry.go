@@ -10,12 +10,18 @@ import (
 	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/log"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/version"
 )
 
 // Provider is a wrapper for OpenTelemetry log provider
@@ -24,13 +30,16 @@ type Provider struct {
 	endpoint      string
 	hostPort      string // Just the host:port part
 	path          string // The path part
+	insecure      bool   // Plaintext unless the endpoint is https://
 	logProvider   *sdklog.LoggerProvider
 	logger        log.Logger
 	ctx           context.Context
 	cancel        context.CancelFunc
-	logCount      atomic.Int64
-	mutex         sync.Mutex
-	lastReport    time.Time
+	sink          *stats.Sink // counts records emitted and their export outcome
+	shutdown      sync.Once
+	closed        atomic.Bool   // refuses logs once shutting down
+	full          sync.Once     // reports the first time the export queue is full
+	drainTimeout  time.Duration // bounds the export of queued logs on shutdown
 	httpClient    *http.Client
 	showResponses bool   // Flag to control response display
 	applicationID string // Application ID for resource attributes
@@ -40,10 +49,30 @@ type Provider struct {
 type Config struct {
 	Enabled       bool
 	Endpoint      string
-	ShowResponses bool   // Control response display
-	ApplicationID string // Application ID for OTEL resource attributes
+	ShowResponses bool          // Control response display
+	ApplicationID string        // Application ID for OTEL resource attributes
+	Stats         *stats.Sink   // Sink to count records into (a private one if nil)
+	DrainTimeout  time.Duration // How long Shutdown waits for queued records to be exported (default 5s)
 }
 
+// defaultDrainTimeout bounds the export of queued records on shutdown
+const defaultDrainTimeout = 5 * time.Second
+
+// maxQueueSize is how many records the SDK queues for export, and
+// maxExportBatchSize how many it exports at once; it drops the oldest
+// records beyond both
+const (
+	maxQueueSize       = 2048
+	maxExportBatchSize = 10
+)
+
+// Fields holding the IDs of the span a log belongs to, which exported logs
+// are correlated with
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
 // LogLevel represents the level of logging
 type LogLevel string
 
@@ -60,42 +89,57 @@ const (
 
 // This section intentionally left empty after refactoring to use direct POST testing
 
-// parseEndpoint separates host:port from path in an endpoint string
-func parseEndpoint(endpoint string) (hostPort, path string) {
-	// Handle case where the endpoint might already have a scheme
-	if strings.HasPrefix(endpoint, "http://") {
+// ParseEndpoint separates host:port from the path of an endpoint, which
+// may start with a scheme. Only https:// endpoints are sent over TLS;
+// http:// and bare hosts are insecure.
+func ParseEndpoint(endpoint string) (hostPort, path string, insecure bool) {
+	insecure = true
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		endpoint, insecure = rest, false
+	} else {
 		endpoint = strings.TrimPrefix(endpoint, "http://")
-	} else if strings.HasPrefix(endpoint, "https://") {
-		endpoint = strings.TrimPrefix(endpoint, "https://")
 	}
-
-	// Split at first slash to separate host:port from path
-	parts := strings.SplitN(endpoint, "/", 2)
-	hostPort = parts[0]
-
-	if len(parts) > 1 {
-		path = "/" + parts[1]
-	} else {
-		path = ""
+	hostPort, path, found := strings.Cut(endpoint, "/")
+	if found {
+		path = "/" + path
 	}
+	return hostPort, path, insecure
+}
 
-	return hostPort, path
+// Collector returns the endpoint without its path, keeping the scheme, so
+// other signals can be sent to the same collector
+func Collector(endpoint string) string {
+	hostPort, _, insecure := ParseEndpoint(endpoint)
+	if !insecure {
+		return "https://" + hostPort
+	}
+	return hostPort
 }
 
 // New creates a new telemetry provider
 func New(config Config) (*Provider, error) {
-	hostPort, path := parseEndpoint(config.Endpoint)
+	hostPort, path, insecure := ParseEndpoint(config.Endpoint)
 
 	p := &Provider{
 		enabled:       config.Enabled,
 		endpoint:      config.Endpoint,
 		hostPort:      hostPort,
+		insecure:      insecure,
 		path:          path,
-		lastReport:    time.Now(), // Initialize to current time instead of zero time
+		sink:          config.Stats,
 		httpClient:    &http.Client{Timeout: 5 * time.Second},
 		showResponses: config.ShowResponses,
 		applicationID: config.ApplicationID,
+		drainTimeout:  config.DrainTimeout,
 	}
+	if p.drainTimeout <= 0 {
+		p.drainTimeout = defaultDrainTimeout
+	}
+
+	if p.sink == nil {
+		p.sink = stats.New().Sink("otlp")
+	}
+	p.sink.SetCapacity(maxQueueSize)
 
 	if !p.enabled {
 		return p, nil
@@ -104,22 +148,30 @@ func New(config Config) (*Provider, error) {
 	var err error
 	p.ctx, p.cancel = context.WithCancel(context.Background())
 
+	// Report export errors as diagnostics rather than through the standard
+	// library logger
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		diag.Warn("OpenTelemetry export error", "error", err)
+	}))
+
 	// If show responses is enabled, print configuration information
 	if p.showResponses {
-		fmt.Println("OTEL COLLECTOR CONFIG:")
-		fmt.Printf("  - Endpoint: %s\n", p.endpoint)
-		fmt.Printf("  - Host:Port: %s\n", p.hostPort)
-		fmt.Printf("  - Path: %s\n", p.path)
-		fmt.Printf("  - Application ID: %s\n", p.applicationID)
+		diag.Info("OTEL collector config",
+			"endpoint", p.endpoint,
+			"host_port", p.hostPort,
+			"path", p.path,
+			"application_id", p.applicationID)
 
 		// Test direct POST to the collector
 		go p.testDirectPost()
 	}
 
-	// Create resource with service.name and application_id attributes
+	// Create resource with service.name, service.version and application_id
+	// attributes
 	resource, err := sdkresource.New(context.Background(),
 		sdkresource.WithAttributes(
 			semconv.ServiceName("log-genie"),
+			semconv.ServiceVersion(version.Get().Version),
 			attribute.String("application_id", p.applicationID),
 		),
 	)
@@ -131,7 +183,6 @@ func New(config Config) (*Provider, error) {
 	var exporter sdklog.Exporter
 
 	// For OTLP exporter, we need just the host:port part
-	insecure := true // Default to insecure for easier testing
 	options := []otlploghttp.Option{
 		otlploghttp.WithEndpoint(p.hostPort),
 	}
@@ -139,7 +190,7 @@ func New(config Config) (*Provider, error) {
 	// The WithHTTPClient option might not be available in this version
 	// Instead, we'll rely on the custom transport to capture responses
 
-	if insecure {
+	if p.insecure {
 		options = append(options, otlploghttp.WithInsecure())
 	}
 
@@ -153,14 +204,14 @@ func New(config Config) (*Provider, error) {
 		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
 	}
 
-	// Create batch processor with exporter
+	// Create batch processor with exporter, counting exported records
 	batchProcessor := sdklog.NewBatchProcessor(
-		exporter,
+		newCountingExporter(exporter, p.sink),
 		// Configure batch settings
 		sdklog.WithExportTimeout(5*time.Second),
-		sdklog.WithMaxQueueSize(2048),
+		sdklog.WithMaxQueueSize(maxQueueSize),
 		// Use smaller batch size for more frequent POST operations
-		sdklog.WithExportMaxBatchSize(10),
+		sdklog.WithExportMaxBatchSize(maxExportBatchSize),
 	)
 
 	// Create log provider with BatchProcessor and resource
@@ -172,12 +223,6 @@ func New(config Config) (*Provider, error) {
 	// Get a logger instance
 	p.logger = p.logProvider.Logger("log-genie")
 
-	// Reset the log counter
-	p.logCount.Store(0)
-
-	// Report logs sent every minute
-	go p.reportLogsSent()
-
 	// Set up periodic POST test if responses should be shown
 	if p.showResponses {
 		// Start a goroutine to periodically test direct POST
@@ -211,9 +256,13 @@ func (p *Provider) testDirectPost() {
 	if p.path != "" {
 		pathToUse = p.path
 	}
-	logsUrl := fmt.Sprintf("http://%s%s", p.hostPort, pathToUse)
+	scheme := "https"
+	if p.insecure {
+		scheme = "http"
+	}
+	logsUrl := fmt.Sprintf("%s://%s%s", scheme, p.hostPort, pathToUse)
 
-	fmt.Printf("DEBUG: Testing direct POST to %s\n", logsUrl)
+	diag.Debug("Testing direct POST", "url", logsUrl)
 
 	// Create a test log payload similar to what the OTLP exporter would send
 	// Include application_id in the resource attributes
@@ -257,81 +306,58 @@ func (p *Provider) testDirectPost() {
 			defer resp.Body.Close()
 			body, _ := io.ReadAll(resp.Body)
 			if len(body) > 0 {
-				fmt.Printf("OTEL COLLECTOR DIRECT POST RESPONSE: %s\n", string(body))
+				diag.Info("OTEL collector direct POST response", "status", resp.StatusCode, "body", string(body))
 			} else {
-				fmt.Printf("DEBUG: OTLP collector returned empty response with status: %d\n",
-					resp.StatusCode)
+				diag.Debug("OTLP collector returned empty response", "status", resp.StatusCode)
 			}
 		} else {
-			fmt.Printf("DEBUG: Error sending test POST request: %v\n", err)
+			diag.Warn("Error sending test POST request", "url", logsUrl, "error", err)
 		}
 	} else {
-		fmt.Printf("DEBUG: Error creating test POST request: %v\n", err)
+		diag.Warn("Error creating test POST request", "url", logsUrl, "error", err)
 	}
 }
 
-// reportLogsSent reports the number of logs sent periodically
-func (p *Provider) reportLogsSent() {
-	if !p.enabled {
-		return
-	}
-
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			count := p.logCount.Load()
-			now := time.Now()
-
-			// Check if lastReport is zero time or unreasonably old (>10 minutes ago)
-			if p.lastReport.IsZero() || now.Sub(p.lastReport) > 10*time.Minute {
-				p.lastReport = now
-				p.logCount.Store(0)
-				continue
-			}
-
-			elapsed := now.Sub(p.lastReport).Seconds()
-			if elapsed > 0 {
-				rate := float64(count) / elapsed
-				fmt.Printf("TELEMETRY: Sent %d logs in the last %.1f seconds (%.1f logs/sec)\n",
-					count, elapsed, rate)
-
-				// Reset counter and update last report time
-				p.logCount.Store(0)
-				p.lastReport = now
+// Shutdown refuses further logs, exports the queued ones and waits for the
+// exports in flight, for at most the drain timeout, then shuts down the
+// telemetry provider; calls after the first do nothing
+func (p *Provider) Shutdown() {
+	p.shutdown.Do(func() {
+		p.closed.Store(true)
+		if p.logProvider != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), p.drainTimeout)
+			defer cancel()
+			if err := p.logProvider.ForceFlush(ctx); err != nil {
+				diag.Warn("Failed to export queued logs before the drain timeout", "timeout", p.drainTimeout.String(), "error", err)
 			}
-		case <-p.ctx.Done():
-			return
+			_ = p.logProvider.Shutdown(ctx)
 		}
-	}
-}
-
-// Shutdown shuts down the telemetry provider
-func (p *Provider) Shutdown() {
-	if p.cancel != nil {
-		p.cancel()
-	}
-
-	if p.logProvider != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = p.logProvider.Shutdown(ctx)
-	}
+		if p.cancel != nil {
+			p.cancel()
+		}
+	})
 }
 
 // SendLog sends a log to the telemetry provider
 func (p *Provider) SendLog(level LogLevel, message string, fields map[string]interface{}) error {
+	return p.SendLogAt(time.Now(), level, message, fields)
+}
+
+// SendLogAt sends a log with an explicit event timestamp to the telemetry provider
+func (p *Provider) SendLogAt(timestamp time.Time, level LogLevel, message string, fields map[string]interface{}) error {
 	if !p.enabled || p.logger == nil {
 		return fmt.Errorf("telemetry is not enabled or logger is not initialized")
 	}
+	if p.closed.Load() {
+		return fmt.Errorf("telemetry provider is shut down")
+	}
 
 	// Create a new record
-	record := &log.Record{}
+	var record log.Record
 
-	// Set the timestamp
-	record.SetTimestamp(time.Now())
+	// Set the event timestamp (possibly skewed) and the real observation time
+	record.SetTimestamp(timestamp)
+	record.SetObservedTimestamp(time.Now())
 
 	// Set severity based on log level
 	var severity log.Severity
@@ -351,8 +377,10 @@ func (p *Provider) SendLog(level LogLevel, message string, fields map[string]int
 	// Set the message body
 	record.SetBody(log.StringValue(message))
 
-	// Add attributes from fields
-	attributes := make([]log.KeyValue, 0, len(fields))
+	// Add attributes from fields; the record copies them, so the slice is
+	// reused
+	pooled := attributesPool.Get().(*[]log.KeyValue)
+	attributes := (*pooled)[:0]
 	for k, v := range fields {
 		switch val := v.(type) {
 		case string:
@@ -370,14 +398,60 @@ func (p *Provider) SendLog(level LogLevel, message string, fields map[string]int
 		}
 	}
 	record.AddAttributes(attributes...)
+	clear(attributes)
+	*pooled = attributes
+	attributesPool.Put(pooled)
+
+	// Emit the log record, within the span whose IDs it carries
+	ctx := p.ctx
+	if sc, ok := SpanContext(fields); ok {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+	p.logger.Emit(ctx, record)
+
+	// Count the record as queued for export
+	p.sink.Queue(1)
+	if over := p.sink.Backlog() - maxQueueSize - maxExportBatchSize; over > 0 {
+		// The SDK dropped as many, silently
+		p.sink.Drop(over)
+		p.full.Do(func() {
+			diag.Warn("OTLP export cannot keep up, its queue is full", "queue", maxQueueSize, "consequence", "the oldest queued logs are dropped")
+		})
+	}
 
-	// Emit the log record
-	p.logger.Emit(p.ctx, *record)
+	return nil
+}
 
-	// Increment counter
-	p.logCount.Add(1)
+// attributesPool holds the attribute slices records are built from
+var attributesPool = sync.Pool{
+	New: func() any {
+		attributes := make([]log.KeyValue, 0, 16)
+		return &attributes
+	},
+}
 
-	return nil
+// SpanContext returns the span a log belongs to, from its trace and span ID
+// fields
+func SpanContext(fields map[string]interface{}) (trace.SpanContext, bool) {
+	traceHex, _ := fields[TraceIDField].(string)
+	spanHex, _ := fields[SpanIDField].(string)
+	if traceHex == "" || spanHex == "" {
+		return trace.SpanContext{}, false
+	}
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(spanHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}), true
 }
 
 // IsEnabled returns whether telemetry is enabled
@@ -385,7 +459,7 @@ func (p *Provider) IsEnabled() bool {
 	return p.enabled
 }
 
-// GetLogCount returns the current log count
-func (p *Provider) GetLogCount() int64 {
-	return p.logCount.Load()
+// Stats returns the export counts so far; after Shutdown they are final
+func (p *Provider) Stats() stats.SinkStats {
+	return p.sink.Stats()
 }
@@ -0,0 +1,25 @@
+package telemetry
+
+import "testing"
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, hostPort, path string
+		insecure                 bool
+		collector                string
+	}{
+		{"collector:4318", "collector:4318", "", true, "collector:4318"},
+		{"http://collector:4318/v1/logs", "collector:4318", "/v1/logs", true, "collector:4318"},
+		{"https://otel.example.com/otlp/v1/logs", "otel.example.com", "/otlp/v1/logs", false, "https://otel.example.com"},
+		{"https://otel.example.com:443", "otel.example.com:443", "", false, "https://otel.example.com:443"},
+	}
+	for _, tt := range tests {
+		hostPort, path, insecure := ParseEndpoint(tt.endpoint)
+		if hostPort != tt.hostPort || path != tt.path || insecure != tt.insecure {
+			t.Errorf("ParseEndpoint(%q) = %q, %q, %v; want %q, %q, %v", tt.endpoint, hostPort, path, insecure, tt.hostPort, tt.path, tt.insecure)
+		}
+		if got := Collector(tt.endpoint); got != tt.collector {
+			t.Errorf("Collector(%q) = %q, want %q", tt.endpoint, got, tt.collector)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package tenant
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Field is the field carrying the tenant of a log
+const Field = "tenant_id"
+
+// maxTenants bounds the tenants of a pool given by their number
+const maxTenants = 100000
+
+// Pool is a weighted set of tenants logs are tagged with
+type Pool struct {
+	names   []string
+	weights []float64
+	total   float64
+}
+
+// Parse parses a pool of tenants: either their number, naming them
+// tenant-1, tenant-2..., or their names with optional relative weights, such
+// as "acme=5,globex=2,initech" (weight 1 unless given)
+func Parse(spec string) (*Pool, error) {
+	spec = strings.TrimSpace(spec)
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 1 || n > maxTenants {
+			return nil, fmt.Errorf("invalid number of tenants %d: must be between 1 and %d", n, maxTenants)
+		}
+		p := &Pool{}
+		for i := 1; i <= n; i++ {
+			p.add("tenant-"+strconv.Itoa(i), 1)
+		}
+		return p, nil
+	}
+
+	p := &Pool{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, weighted := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid tenant %q: empty name", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid tenants: duplicate tenant %s", name)
+		}
+		seen[name] = true
+		weight := 1.0
+		if weighted {
+			w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid tenant %q: weight must be a non-negative number", entry)
+			}
+			weight = w
+		}
+		p.add(name, weight)
+	}
+	if p.total <= 0 {
+		return nil, fmt.Errorf("invalid tenants %q: at least one tenant must have a positive weight", spec)
+	}
+	return p, nil
+}
+
+// add adds a tenant of a weight
+func (p *Pool) add(name string, weight float64) {
+	p.names = append(p.names, name)
+	p.weights = append(p.weights, weight)
+	p.total += weight
+}
+
+// Pick returns a tenant at random, by weight
+func (p *Pool) Pick() string {
+	pick := gofakeit.Float64Range(0, p.total)
+	for i, weight := range p.weights {
+		if pick < weight {
+			return p.names[i]
+		}
+		pick -= weight
+	}
+	// Rounding left nothing to pick from: use the last weighted tenant
+	for i := len(p.weights) - 1; i >= 0; i-- {
+		if p.weights[i] > 0 {
+			return p.names[i]
+		}
+	}
+	return p.names[0]
+}
+
+// Names returns the tenants of the pool, in the order given
+func (p *Pool) Names() []string {
+	return p.names
+}
+
+// Of returns the tenant a log's fields are tagged with, empty if none
+func Of(fields map[string]interface{}) string {
+	name, _ := fields[Field].(string)
+	return name
+}
+
+// Without returns the pool without a tenant, nil if it was the only one,
+// and the share of the logs the tenant took
+func (p *Pool) Without(name string) (*Pool, float64, error) {
+	rest := &Pool{}
+	share := -1.0
+	for i, n := range p.names {
+		if n == name {
+			share = p.weights[i] / p.total
+			continue
+		}
+		rest.add(n, p.weights[i])
+	}
+	switch {
+	case share < 0:
+		return nil, 0, fmt.Errorf("unknown tenant %s", name)
+	case share == 0:
+		return nil, 0, fmt.Errorf("tenant %s has a weight of 0", name)
+	case rest.total <= 0:
+		return nil, 1, nil
+	}
+	return rest, share, nil
+}
@@ -0,0 +1,116 @@
+package tenant
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		names   string
+		wantErr bool
+	}{
+		{"3", "tenant-1,tenant-2,tenant-3", false},
+		{" acme=5, globex=2 ,initech ", "acme,globex,initech", false},
+		{"acme=0,globex", "acme,globex", false},
+		{"0", "", true},
+		{"100001", "", true},
+		{"acme,acme", "", true},
+		{"=2", "", true},
+		{"acme=-1", "", true},
+		{"acme=x", "", true},
+		{"acme=0", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && strings.Join(p.Names(), ",") != tt.names {
+			t.Errorf("Parse(%q) = %v, want %s", tt.spec, p.Names(), tt.names)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		spec  string
+		share map[string]float64
+	}{
+		{"acme=5,globex=2,initech=1", map[string]float64{"acme": 5.0 / 8, "globex": 2.0 / 8, "initech": 1.0 / 8}},
+		{"acme=0,globex", map[string]float64{"globex": 1}},
+		{"2", map[string]float64{"tenant-1": 0.5, "tenant-2": 0.5}},
+	}
+	const picks = 20000
+	for _, tt := range tests {
+		p, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		for i := 0; i < picks; i++ {
+			counts[p.Pick()]++
+		}
+		for name, count := range counts {
+			if _, ok := tt.share[name]; !ok {
+				t.Errorf("%s: picked %s %d times", tt.spec, name, count)
+			}
+		}
+		for name, share := range tt.share {
+			if got := float64(counts[name]) / picks; math.Abs(got-share) > 0.02 {
+				t.Errorf("%s: picked %s %.3f of the time, want %.3f", tt.spec, name, got, share)
+			}
+		}
+	}
+}
+
+func TestWithout(t *testing.T) {
+	tests := []struct {
+		spec    string
+		name    string
+		rest    string // names left, empty if none
+		share   float64
+		wantErr bool
+	}{
+		{"acme=3,globex=1", "acme", "globex", 0.75, false},
+		{"acme=3,globex=1", "globex", "acme", 0.25, false},
+		{"acme", "acme", "", 1, false},
+		{"acme,globex=0", "acme", "", 1, false},
+		{"acme,globex=0", "globex", "", 0, true},
+		{"acme", "initech", "", 0, true},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest, share, err := p.Without(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s without %s: error %v, want error %v", tt.spec, tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		names := ""
+		if rest != nil {
+			names = strings.Join(rest.Names(), ",")
+		}
+		if names != tt.rest || math.Abs(share-tt.share) > 1e-9 {
+			t.Errorf("%s without %s = %q, %g; want %q, %g", tt.spec, tt.name, names, share, tt.rest, tt.share)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	if got := Of(map[string]interface{}{Field: "acme"}); got != "acme" {
+		t.Errorf("Of = %q, want acme", got)
+	}
+	if got := Of(map[string]interface{}{Field: 1}); got != "" {
+		t.Errorf("Of a number = %q, want none", got)
+	}
+}
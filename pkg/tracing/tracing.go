@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/stats"
+	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+// Config holds the configuration of trace generation
+type Config struct {
+	Endpoint      string      // Collector endpoint, host:port with an optional path (default /v1/traces), over TLS with https://
+	ApplicationID string      // Application ID for resource attributes
+	Stats         *stats.Sink // Sink to count spans into (a private one if nil)
+}
+
+// Provider records a trace for every simulated request, exporting its spans
+// over OTLP. As a processor it stamps each log with the IDs of its span, so
+// logs and traces can be correlated.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	sink     *stats.Sink
+}
+
+// New creates a provider exporting spans to the collector
+func New(ctx context.Context, config Config) (*Provider, error) {
+	hostPort, path, insecure := telemetry.ParseEndpoint(config.Endpoint)
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(hostPort)}
+	if insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if path != "" {
+		options = append(options, otlptracehttp.WithURLPath(path))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// Same resource as the logs, so backends link them to one service
+	resource, err := sdkresource.New(ctx,
+		sdkresource.WithAttributes(
+			semconv.ServiceName("log-genie"),
+			semconv.ServiceVersion(version.Get().Version),
+			attribute.String("application_id", config.ApplicationID),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	sink := config.Stats
+	if sink == nil {
+		sink = stats.New().Sink("traces")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(&countingExporter{SpanExporter: exporter, sink: sink}),
+		sdktrace.WithResource(resource),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	return &Provider{provider: provider, tracer: provider.Tracer("log-genie"), sink: sink}, nil
+}
+
+// Process records the spans of the request a log describes, ending when the
+// log is written: a server span taking the latency of the log and, if the log
+// names a service, a client span calling it, which service maps show as a
+// dependency. The log gets the trace and span IDs of the server span.
+func (p *Provider) Process(e *logger.Entry) bool {
+	end := e.Time
+	if end.IsZero() {
+		end = time.Now()
+	}
+	latency := latencyOf(e.Fields)
+	start := end.Add(-latency)
+
+	method, _ := e.Fields["http_method"].(string)
+	if method == "" {
+		method = "GET"
+	}
+	attributes := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(method)}
+	status, hasStatus := logger.IntField(e.Fields, "status_code")
+	if hasStatus {
+		attributes = append(attributes, semconv.HTTPResponseStatusCode(status))
+	}
+	if ip, ok := e.Fields["ip_address"].(string); ok {
+		attributes = append(attributes, semconv.ClientAddress(ip))
+	}
+	if user, ok := e.Fields["user_id"].(string); ok {
+		attributes = append(attributes, semconv.EnduserID(user))
+	}
+
+	ctx, server := p.tracer.Start(context.Background(), method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...))
+	spans := int64(1)
+
+	if service, ok := e.Fields["service"].(string); ok && service != "" {
+		offset, duration := latency/10, latency*7/10
+		_, client := p.tracer.Start(ctx, method+" "+service,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(start.Add(offset)),
+			trace.WithAttributes(semconv.PeerService(service), semconv.HTTPRequestMethodKey.String(method)))
+		if hasStatus && status >= 500 {
+			client.SetStatus(codes.Error, fmt.Sprintf("%s responded %d", service, status))
+		}
+		client.End(trace.WithTimestamp(start.Add(offset + duration)))
+		spans++
+	}
+
+	if e.Level == logger.Error || (hasStatus && status >= 500) {
+		server.SetStatus(codes.Error, e.Message)
+	}
+	// The exception an error log reports, as the span event a backend may
+	// merge with it
+	if e.Level == logger.Error {
+		exception := []attribute.KeyValue{semconv.ExceptionMessage(e.Message)}
+		if exceptionType, ok := e.Fields["error_type"].(string); ok {
+			exception = append(exception, semconv.ExceptionType(exceptionType))
+		}
+		if stack, ok := e.Fields["stack_trace"].(string); ok {
+			exception = append(exception, semconv.ExceptionStacktrace(stack))
+		}
+		server.AddEvent(semconv.ExceptionEventName, trace.WithTimestamp(end), trace.WithAttributes(exception...))
+	}
+	server.End(trace.WithTimestamp(end))
+	p.sink.Queue(spans)
+
+	sc := server.SpanContext()
+	e.Fields[telemetry.TraceIDField] = sc.TraceID().String()
+	e.Fields[telemetry.SpanIDField] = sc.SpanID().String()
+	return true
+}
+
+// Close flushes the spans not exported yet and stops exporting
+func (p *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.provider.Shutdown(ctx)
+}
+
+// latencyOf returns the latency a log reports, or a plausible one
+func latencyOf(fields map[string]interface{}) time.Duration {
+	if ms, ok := logger.IntField(fields, "latency_ms"); ok && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return time.Duration(1+rand.Intn(500)) * time.Millisecond
+}
+
+// countingExporter counts the spans the wrapped exporter delivered or failed
+// to deliver
+type countingExporter struct {
+	sdktrace.SpanExporter
+	sink *stats.Sink
+}
+
+// ExportSpans exports the spans and counts them
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.sink.Done(int64(len(spans)), err)
+	return err
+}
@@ -0,0 +1,142 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/telemetry"
+	"github.com/rjonczy/log-genie/pkg/version"
+)
+
+// Config holds the configuration of request metrics
+type Config struct {
+	Endpoint      string        // Collector endpoint, host:port with an optional path (default /v1/metrics)
+	Interval      time.Duration // How often metrics are exported
+	ApplicationID string        // Application ID for resource attributes
+}
+
+// Provider derives the metrics of the simulated application from the
+// requests its logs describe, exporting them over OTLP. As a processor it
+// sees every log, so the metrics agree with the logs in dashboards. Logs
+// carrying trace and span IDs (see the tracing package) are recorded within
+// their span, so the metrics get exemplars linking to their traces.
+type Provider struct {
+	provider *sdkmetric.MeterProvider
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+	records  metric.Int64Counter
+}
+
+// New creates a provider exporting metrics to the collector periodically
+func New(ctx context.Context, config Config) (*Provider, error) {
+	hostPort, path, insecure := telemetry.ParseEndpoint(config.Endpoint)
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(hostPort)}
+	if insecure {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+	if path != "" {
+		options = append(options, otlpmetrichttp.WithURLPath(path))
+	}
+	exporter, err := otlpmetrichttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	// Same resource as the logs, so backends link them to one service
+	resource, err := sdkresource.New(ctx,
+		sdkresource.WithAttributes(
+			semconv.ServiceName("log-genie"),
+			semconv.ServiceVersion(version.Get().Version),
+			attribute.String("application_id", config.ApplicationID),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	p := &Provider{provider: sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(config.Interval))),
+		sdkmetric.WithResource(resource),
+		// Exemplars for the measurements made within a sampled span
+		sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+	)}
+	if err := p.register(p.provider.Meter("log-genie")); err != nil {
+		_ = p.provider.Shutdown(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+// register creates the instruments
+func (p *Provider) register(meter metric.Meter) error {
+	var err error
+	if p.requests, err = meter.Int64Counter("http.server.requests",
+		metric.WithDescription("Requests handled, by method and status code")); err != nil {
+		return err
+	}
+	if p.errors, err = meter.Int64Counter("http.server.errors",
+		metric.WithDescription("Requests failed with a 5xx status or logged as errors, by method")); err != nil {
+		return err
+	}
+	if p.duration, err = meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of requests, by method and status code"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10)); err != nil {
+		return err
+	}
+	if p.records, err = meter.Int64Counter("app.log.records",
+		metric.WithDescription("Logs written, by level")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Process records the request a log describes, if it describes one: a log
+// with an HTTP method counts as a request, with the status code and latency
+// it reports. The measurements of a log within a span reference it.
+func (p *Provider) Process(e *logger.Entry) bool {
+	ctx := context.Background()
+	if sc, ok := telemetry.SpanContext(e.Fields); ok {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+	p.records.Add(ctx, 1, metric.WithAttributes(attribute.String("level", string(e.Level))))
+
+	method, ok := e.Fields["http_method"].(string)
+	if !ok {
+		return true
+	}
+	attributes := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(method)}
+	status, hasStatus := logger.IntField(e.Fields, "status_code")
+	if hasStatus {
+		attributes = append(attributes, semconv.HTTPResponseStatusCode(status))
+	}
+	set := metric.WithAttributeSet(attribute.NewSet(attributes...))
+
+	p.requests.Add(ctx, 1, set)
+	if e.Level == logger.Error || (hasStatus && status >= 500) {
+		p.errors.Add(ctx, 1, metric.WithAttributes(semconv.HTTPRequestMethodKey.String(method)))
+	}
+	if ms, ok := logger.IntField(e.Fields, "latency_ms"); ok && ms >= 0 {
+		p.duration.Record(ctx, float64(ms)/1000, set)
+	}
+	return true
+}
+
+// Close exports the last values and stops exporting
+func (p *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.provider.Shutdown(ctx)
+}
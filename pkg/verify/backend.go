@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backends verification can query after a run
+const (
+	BackendLoki          = "loki"
+	BackendElasticsearch = "elasticsearch"
+)
+
+// queryPageSize is the number of logs fetched per request
+const queryPageSize = 5000
+
+// QueryConfig describes where to read the logs of a run back from
+type QueryConfig struct {
+	URL     string        // Base URL of the backend; credentials may be given as user:password@
+	Query   string        // LogQL selector for Loki, query string for Elasticsearch
+	Index   string        // Index pattern, for Elasticsearch
+	Start   time.Time     // Start of the run, for Loki
+	End     time.Time     // End of the time range, for Loki
+	Timeout time.Duration // Bound on each request (default 30s)
+}
+
+// Query reads the logs matching the query from a backend into the verifier,
+// returning the number of logs read
+func (v *Verifier) Query(ctx context.Context, backend string, config QueryConfig) (int, error) {
+	if config.URL == "" {
+		return 0, fmt.Errorf("no %s URL", backend)
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	client := &http.Client{Timeout: config.Timeout}
+
+	switch backend {
+	case BackendLoki:
+		return v.queryLoki(ctx, client, config)
+	case BackendElasticsearch:
+		return v.queryElasticsearch(ctx, client, config)
+	default:
+		return 0, fmt.Errorf("unknown backend %q: use %s or %s", backend, BackendLoki, BackendElasticsearch)
+	}
+}
+
+// fetch sends a request with an optional JSON body and decodes the JSON
+// response into out
+func fetch(ctx context.Context, client *http.Client, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
@@ -0,0 +1,136 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/integrity"
+)
+
+// messageKeys are checked in order to find the message of a document
+var messageKeys = []string{"message", "msg", "body", "log"}
+
+// scrollKeepAlive is how long Elasticsearch keeps a scroll between pages
+const scrollKeepAlive = "1m"
+
+// elasticsearchResponse is the part of a search response verification reads
+type elasticsearchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			Source map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// queryElasticsearch scrolls through the documents of the index matching
+// the query string
+func (v *Verifier) queryElasticsearch(ctx context.Context, client *http.Client, config QueryConfig) (int, error) {
+	query := config.Query
+	if query == "" {
+		query = "*"
+	}
+	search := map[string]interface{}{
+		"size":  queryPageSize,
+		"sort":  []string{"_doc"},
+		"query": map[string]interface{}{"query_string": map[string]interface{}{"query": query}},
+	}
+
+	var resp elasticsearchResponse
+	err := fetch(ctx, client, http.MethodPost, config.URL+"/"+url.PathEscape(config.Index)+"/_search?scroll="+scrollKeepAlive, search, &resp)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if resp.ScrollID != "" {
+			_ = fetch(ctx, client, http.MethodDelete, config.URL+"/_search/scroll", map[string]interface{}{"scroll_id": []string{resp.ScrollID}}, nil)
+		}
+	}()
+
+	read := 0
+	for len(resp.Hits.Hits) > 0 {
+		for _, hit := range resp.Hits.Hits {
+			v.Observe(documentRecord(hit.Source))
+			read++
+		}
+		scrollID := resp.ScrollID
+		resp = elasticsearchResponse{}
+		err := fetch(ctx, client, http.MethodPost, config.URL+"/_search/scroll", map[string]interface{}{"scroll": scrollKeepAlive, "scroll_id": scrollID}, &resp)
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+// documentRecord reads a document: the integrity fields may sit at any
+// depth, e.g. under attributes, as exporters nest them differently. A
+// document without them may hold the whole log as its message.
+func documentRecord(doc map[string]interface{}) Record {
+	var r Record
+	seq, ok := findField(doc, integrity.SequenceField)
+	if !ok {
+		if message, ok := findMessage(doc); ok {
+			r, _ = parseLine(message)
+		}
+		r.Sent = time.Time{}
+		return r
+	}
+
+	r.Seq, _ = strconv.ParseUint(fmt.Sprint(seq), 10, 64)
+	if stream, ok := findField(doc, integrity.StreamField); ok {
+		r.Stream = fmt.Sprint(stream)
+	}
+	// Without the message the checksum cannot be checked
+	if message, ok := findMessage(doc); ok {
+		r.Message = message
+		if checksum, ok := findField(doc, integrity.ChecksumField); ok {
+			r.Checksum = fmt.Sprint(checksum)
+		}
+	}
+	return r
+}
+
+// findMessage returns the message of a document
+func findMessage(doc map[string]interface{}) (string, bool) {
+	for _, key := range messageKeys {
+		value, ok := findField(doc, key)
+		if !ok {
+			continue
+		}
+		// Some exporters nest the body, e.g. body.text
+		if nested, isMap := value.(map[string]interface{}); isMap {
+			value, ok = nested["text"]
+			if !ok {
+				continue
+			}
+		}
+		if message, isString := value.(string); isString {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// findField returns the value of a field by name, case-insensitively, at
+// the top of the document, as the last part of a dotted key, or nested
+func findField(doc map[string]interface{}, name string) (interface{}, bool) {
+	for key, value := range doc {
+		if strings.EqualFold(key, name) || strings.HasSuffix(strings.ToLower(key), "."+name) {
+			return value, true
+		}
+	}
+	for _, value := range doc {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if found, ok := findField(nested, name); ok {
+				return found, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/integrity"
+)
+
+// maxRequestSize bounds the body of an export request
+const maxRequestSize = 64 << 20
+
+// OTLPHandler returns an OTLP/HTTP logs receiver (POST /v1/logs, protobuf or
+// JSON, optionally gzipped) feeding the verifier
+func (v *Verifier) OTLPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body io.Reader = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			body = gz
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		jsonEncoded := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+		request := &collogs.ExportLogsServiceRequest{}
+		if jsonEncoded {
+			err = protojson.Unmarshal(data, request)
+		} else {
+			err = proto.Unmarshal(data, request)
+		}
+		if err != nil {
+			diag.Warn("Verify receiver got an invalid export request", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		v.observeOTLP(request)
+
+		response := &collogs.ExportLogsServiceResponse{}
+		var out []byte
+		if jsonEncoded {
+			w.Header().Set("Content-Type", "application/json")
+			out, _ = protojson.Marshal(response)
+		} else {
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			out, _ = proto.Marshal(response)
+		}
+		_, _ = w.Write(out)
+	})
+	return mux
+}
+
+// observeOTLP records the logs of an export request
+func (v *Verifier) observeOTLP(request *collogs.ExportLogsServiceRequest) {
+	for _, resourceLogs := range request.GetResourceLogs() {
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				r := Record{Message: record.GetBody().GetStringValue()}
+				for _, kv := range record.GetAttributes() {
+					switch kv.GetKey() {
+					case integrity.StreamField:
+						r.Stream = anyString(kv.GetValue())
+					case integrity.SequenceField:
+						r.Seq, _ = strconv.ParseUint(anyString(kv.GetValue()), 10, 64)
+					case integrity.ChecksumField:
+						r.Checksum = anyString(kv.GetValue())
+					}
+				}
+				// log-genie sets the observed time when it emits a log; the
+				// timestamp may be skewed on purpose
+				if t := record.GetObservedTimeUnixNano(); t > 0 {
+					r.Sent = time.Unix(0, int64(t))
+				}
+				v.Observe(r)
+			}
+		}
+	}
+}
+
+// anyString returns an attribute value as a string, as pipelines may turn
+// numbers into strings
+func anyString(value *common.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
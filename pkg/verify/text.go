@@ -0,0 +1,185 @@
+package verify
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/integrity"
+	"github.com/rjonczy/log-genie/pkg/replay"
+)
+
+// maxSyslogMessage bounds a syslog message, the largest UDP datagram
+const maxSyslogMessage = 64 * 1024
+
+// HTTPHandler returns a receiver for logs POSTed to any path as NDJSON, a
+// JSON array or logfmt lines, optionally gzipped
+func (v *Verifier) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body io.Reader = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			body = gz
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		text := strings.TrimSpace(string(data))
+		if strings.HasPrefix(text, "[") {
+			var records []json.RawMessage
+			if err := json.Unmarshal([]byte(text), &records); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, record := range records {
+				v.observeLine(string(record))
+			}
+		} else {
+			for _, line := range strings.Split(text, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					v.observeLine(line)
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListenSyslog receives syslog messages (RFC 5424 or 3164) over both UDP and
+// TCP on the address, returning the address listened on. TCP takes
+// newline-delimited or octet-counted framing. The message is expected to
+// hold the log as JSON or logfmt.
+func (v *Verifier) ListenSyslog(address string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// Share the port picked for TCP, should the address leave it open
+	packets, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	go func() {
+		buf := make([]byte, maxSyslogMessage)
+		for {
+			n, _, err := packets.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			v.observeSyslog(string(buf[:n]))
+		}
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go v.serveSyslog(conn)
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// serveSyslog reads the syslog messages of a TCP connection
+func (v *Verifier) serveSyslog(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReaderSize(conn, maxSyslogMessage)
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return
+		}
+
+		var message string
+		if first[0] >= '0' && first[0] <= '9' {
+			// Octet counting: the length, a space and the message
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil || n <= 0 || n > maxSyslogMessage {
+				diag.Warn("Verify receiver got an invalid syslog frame", "remote", conn.RemoteAddr().String(), "length", length)
+				return
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(reader, buf); err != nil {
+				return
+			}
+			message = string(buf)
+		} else {
+			message, err = reader.ReadString('\n')
+			if err != nil && message == "" {
+				return
+			}
+		}
+		v.observeSyslog(message)
+	}
+}
+
+// observeSyslog records a log received as a syslog message
+func (v *Verifier) observeSyslog(message string) {
+	message = strings.TrimSpace(message)
+	// Drop the priority; the rest of the header parses as bare logfmt keys
+	if strings.HasPrefix(message, "<") {
+		if end := strings.IndexByte(message, '>'); end > 0 {
+			message = message[end+1:]
+		}
+	}
+	if start := strings.IndexByte(message, '{'); start >= 0 {
+		message = message[start:]
+	}
+	v.observeLine(message)
+}
+
+// observeLine records a log received as a JSON or logfmt line; the latency
+// is measured from its timestamp
+func (v *Verifier) observeLine(line string) {
+	// Unparseable lines are counted as foreign
+	r, _ := parseLine(line)
+	v.Observe(r)
+}
+
+// parseLine reads the integrity fields, message and timestamp of a JSON or
+// logfmt line
+func parseLine(line string) (Record, bool) {
+	parsed, err := replay.ParseRecord(line, "", nil)
+	if err != nil {
+		return Record{}, false
+	}
+	r := Record{Message: parsed.Message, Sent: parsed.Time}
+	if stream, ok := parsed.Fields[integrity.StreamField]; ok {
+		r.Stream = fmt.Sprint(stream)
+	}
+	if seq, ok := parsed.Fields[integrity.SequenceField]; ok {
+		r.Seq, _ = strconv.ParseUint(fmt.Sprint(seq), 10, 64)
+	}
+	if checksum, ok := parsed.Fields[integrity.ChecksumField]; ok {
+		r.Checksum = fmt.Sprint(checksum)
+	}
+	return r, true
+}
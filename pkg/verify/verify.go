@@ -0,0 +1,218 @@
+package verify
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/integrity"
+)
+
+// maxLatencySamples bounds the latencies kept for percentiles; beyond it a
+// uniform sample is kept
+const maxLatencySamples = 10000
+
+// sequenceSlack is how far past the logs counted as sent a sequence number is
+// still tracked, covering logs sequenced before they are counted
+const sequenceSlack = 1 << 16
+
+// Record is a log received back at the end of the pipeline
+type Record struct {
+	Stream   string
+	Seq      uint64 // 0 if the log carried no sequence number
+	Checksum string // empty if the log carried no checksum
+	Message  string
+	Sent     time.Time // when log-genie emitted the log, zero if unknown
+}
+
+// Verifier compares the logs received back against the sequence numbers of
+// the stream that was sent, the ground truth of a pipeline test
+type Verifier struct {
+	stream string
+
+	mutex      sync.Mutex
+	sent       func() uint64 // logs sent so far, bounding the sequence numbers tracked
+	seen       []uint64      // bitset of received sequence numbers
+	highest    uint64
+	received   uint64
+	beyond     uint64 // logs with sequence numbers past those tracked
+	duplicated uint64
+	reordered  uint64
+	corrupted  uint64
+	foreign    uint64
+	latencies  []float64 // milliseconds
+	latencyN   int64
+	latencySum float64
+	latencyMax float64
+}
+
+// New creates a verifier for the stream logs are sent on. sent returns the
+// logs sent so far: the bitset of received sequence numbers grows no further
+// than them, so a corrupted number cannot exhaust memory, and logs numbered
+// beyond are counted as unexpected.
+func New(stream string, sent func() uint64) *Verifier {
+	return &Verifier{stream: stream, sent: sent}
+}
+
+// Stream returns the stream the verifier checks
+func (v *Verifier) Stream() string {
+	return v.stream
+}
+
+// Observe records a log received back
+func (v *Verifier) Observe(r Record) {
+	now := time.Now()
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if r.Stream != v.stream || r.Seq == 0 {
+		v.foreign++
+		return
+	}
+	if r.Seq > v.sent()+sequenceSlack {
+		v.beyond++
+		return
+	}
+
+	word, bit := r.Seq/64, uint64(1)<<(r.Seq%64)
+	for uint64(len(v.seen)) <= word {
+		v.seen = append(v.seen, 0)
+	}
+	if v.seen[word]&bit != 0 {
+		v.duplicated++
+		return
+	}
+	v.seen[word] |= bit
+	v.received++
+
+	if r.Seq < v.highest {
+		v.reordered++
+	} else {
+		v.highest = r.Seq
+	}
+	if r.Checksum != "" && r.Checksum != integrity.Checksum(r.Stream, r.Seq, r.Message) {
+		v.corrupted++
+	}
+
+	if !r.Sent.IsZero() {
+		latency := float64(now.Sub(r.Sent)) / float64(time.Millisecond)
+		if latency < 0 {
+			latency = 0
+		}
+		v.latencyN++
+		v.latencySum += latency
+		v.latencyMax = math.Max(v.latencyMax, latency)
+		if len(v.latencies) < maxLatencySamples {
+			v.latencies = append(v.latencies, latency)
+		} else if i := rand.Int64N(v.latencyN); i < maxLatencySamples {
+			v.latencies[i] = latency
+		}
+	}
+}
+
+// Wait blocks until every one of the sent logs was received, the settle
+// time passed or ctx is cancelled, and reports whether all arrived
+func (v *Verifier) Wait(ctx context.Context, sent uint64, settle time.Duration) bool {
+	deadline := time.NewTimer(settle)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if v.Report(sent).Lost == 0 {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Latency summarizes end-to-end latencies in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Report is the outcome of a verification
+type Report struct {
+	Source     string   `json:"source"` // receivers, or the backend queried
+	Stream     string   `json:"stream"`
+	Sent       uint64   `json:"sent"`
+	Received   uint64   `json:"received"`   // distinct logs of the stream received
+	Lost       uint64   `json:"lost"`       // logs sent but not received
+	Duplicated uint64   `json:"duplicated"` // logs received more than once
+	Reordered  uint64   `json:"reordered"`  // logs received after a later one
+	Corrupted  uint64   `json:"corrupted"`  // logs whose checksum does not match
+	Unexpected uint64   `json:"unexpected"` // logs with sequence numbers beyond those sent
+	Foreign    uint64   `json:"foreign"`    // logs of other streams or without sequence numbers
+	Latency    *Latency `json:"latency_ms,omitempty"`
+	Passed     bool     `json:"passed"` // nothing lost, duplicated, corrupted or unexpected
+}
+
+// Report compares what was received against the sent sequence numbers 1 to
+// sent
+func (v *Verifier) Report(sent uint64) Report {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	report := Report{
+		Stream:     v.stream,
+		Sent:       sent,
+		Received:   v.received,
+		Duplicated: v.duplicated,
+		Reordered:  v.reordered,
+		Corrupted:  v.corrupted,
+		Foreign:    v.foreign,
+	}
+	expected := uint64(0)
+	for word, bits := range v.seen {
+		for bit := uint64(0); bits != 0 && bit < 64; bit++ {
+			if bits&(1<<bit) == 0 {
+				continue
+			}
+			if uint64(word)*64+bit <= sent {
+				expected++
+			}
+		}
+	}
+	report.Unexpected = v.received - expected + v.beyond
+	report.Lost = sent - expected
+
+	if v.latencyN > 0 {
+		sorted := append([]float64(nil), v.latencies...)
+		sort.Float64s(sorted)
+		report.Latency = &Latency{
+			Mean: round(v.latencySum / float64(v.latencyN)),
+			P50:  round(percentile(sorted, 0.5)),
+			P90:  round(percentile(sorted, 0.9)),
+			P99:  round(percentile(sorted, 0.99)),
+			Max:  round(v.latencyMax),
+		}
+	}
+	report.Passed = report.Lost == 0 && report.Duplicated == 0 && report.Corrupted == 0 && report.Unexpected == 0
+	return report
+}
+
+// percentile returns the q quantile of sorted values
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+}
+
+// round rounds to 3 decimals, microseconds of milliseconds
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
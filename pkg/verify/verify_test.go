@@ -0,0 +1,79 @@
+package verify
+
+import (
+	"testing"
+
+	"github.com/rjonczy/log-genie/pkg/integrity"
+)
+
+func TestReport(t *testing.T) {
+	const stream = "s1"
+	record := func(seq uint64) Record {
+		return Record{Stream: stream, Seq: seq, Message: "m", Checksum: integrity.Checksum(stream, seq, "m")}
+	}
+	tests := []struct {
+		name    string
+		sent    uint64
+		records []Record
+		want    Report
+	}{
+		{
+			name:    "all received in order",
+			sent:    3,
+			records: []Record{record(1), record(2), record(3)},
+			want:    Report{Sent: 3, Received: 3, Passed: true},
+		},
+		{
+			name:    "lost and reordered",
+			sent:    4,
+			records: []Record{record(3), record(1)},
+			want:    Report{Sent: 4, Received: 2, Lost: 2, Reordered: 1},
+		},
+		{
+			name:    "duplicated",
+			sent:    2,
+			records: []Record{record(1), record(2), record(2)},
+			want:    Report{Sent: 2, Received: 2, Duplicated: 1},
+		},
+		{
+			name:    "corrupted",
+			sent:    1,
+			records: []Record{{Stream: stream, Seq: 1, Message: "m", Checksum: "bad"}},
+			want:    Report{Sent: 1, Received: 1, Corrupted: 1},
+		},
+		{
+			name:    "foreign",
+			sent:    1,
+			records: []Record{record(1), {Stream: "other", Seq: 1}, {Stream: stream}},
+			want:    Report{Sent: 1, Received: 1, Foreign: 2, Passed: true},
+		},
+		{
+			name:    "unexpected within the tracked window",
+			sent:    2,
+			records: []Record{record(1), record(2), record(5)},
+			want:    Report{Sent: 2, Received: 3, Unexpected: 1},
+		},
+		{
+			name:    "unexpected beyond the tracked window",
+			sent:    1,
+			records: []Record{record(1), record(1 << 40), record(1 << 40)},
+			want:    Report{Sent: 1, Received: 1, Unexpected: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(stream, func() uint64 { return tt.sent })
+			for _, r := range tt.records {
+				v.Observe(r)
+			}
+			got := v.Report(tt.sent)
+			tt.want.Stream = stream
+			if got != tt.want {
+				t.Errorf("Report(%d) = %+v, want %+v", tt.sent, got, tt.want)
+			}
+			if words := len(v.seen); words > int((tt.sent+sequenceSlack)/64+1) {
+				t.Errorf("bitset grew to %d words", words)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X github.com/rjonczy/log-genie/pkg/version.Version=v1.2.3 \
+//	  -X github.com/rjonczy/log-genie/pkg/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/rjonczy/log-genie/pkg/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Values left unset are taken from the build info Go embeds, if any.
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build metadata, filling in what the linker flags left out
+// from the embedded build info
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				if setting.Value == "true" && info.Commit != "" && Commit == "" {
+					info.Commit += "-dirty"
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// String returns the build metadata on one line
+func (i Info) String() string {
+	return fmt.Sprintf("log-genie %s (commit %s, built %s, %s, %s)", i.Version, i.Commit, i.Date, i.GoVersion, i.Platform)
+}
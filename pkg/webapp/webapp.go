@@ -0,0 +1,112 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/logger"
+)
+
+// Config holds the configuration of the fake application
+type Config struct {
+	Logger         *logger.Logger
+	ErrorRatio     func() float64 // share of requests failing with 500, none if nil
+	LogsPerRequest int            // application logs generated per request besides its access log
+	Latency        time.Duration  // requests take a random time up to this long
+}
+
+// App is a fake HTTP application logging every request it serves, so the
+// log volume follows the traffic a load tool sends it. A request shapes its
+// own response with the query parameters status (e.g. ?status=503) and
+// delay (e.g. ?delay=250ms).
+type App struct {
+	config Config
+}
+
+// New creates a fake application
+func New(config Config) *App {
+	if config.ErrorRatio == nil {
+		config.ErrorRatio = func() float64 { return 0 }
+	}
+	return &App{config: config}
+}
+
+// ServeHTTP answers a request with a small JSON body after its latency,
+// then logs it
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := http.StatusOK
+	if rand.Float64() < a.config.ErrorRatio() {
+		status = http.StatusInternalServerError
+	}
+	if s := r.URL.Query().Get("status"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 100 && n <= 599 {
+			status = n
+		}
+	}
+	var delay time.Duration
+	if a.config.Latency > 0 {
+		delay = time.Duration(rand.Int63n(int64(a.config.Latency)))
+	}
+	if d := r.URL.Query().Get("delay"); d != "" {
+		if parsed, err := time.ParseDuration(d); err == nil && parsed >= 0 {
+			delay = parsed
+		}
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+		}
+	}
+
+	for i := 0; i < a.config.LogsPerRequest; i++ {
+		if status >= http.StatusInternalServerError {
+			a.config.Logger.GenerateRandomErrorLog()
+		} else {
+			a.config.Logger.GenerateRandomLog()
+		}
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"status": status, "path": r.URL.Path})
+	body = append(body, '\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+
+	a.logRequest(r, status, len(body), time.Since(start))
+}
+
+// logRequest emits the access log of a request, at warn for client errors
+// and error for server errors, with the fields request metrics and traces
+// are derived from
+func (a *App) logRequest(r *http.Request, status, size int, elapsed time.Duration) {
+	level := logger.Info
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = logger.Error
+	case status >= http.StatusBadRequest:
+		level = logger.Warn
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	fields := map[string]interface{}{
+		"http_method":   r.Method,
+		"path":          r.URL.Path,
+		"status_code":   status,
+		"latency_ms":    int(elapsed.Milliseconds()),
+		"ip_address":    ip,
+		"user_agent":    r.UserAgent(),
+		"response_size": size,
+	}
+	a.config.Logger.Replay(time.Time{}, string(level), fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status), fields)
+}
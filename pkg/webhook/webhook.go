@@ -0,0 +1,318 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjonczy/log-genie/pkg/diag"
+	"github.com/rjonczy/log-genie/pkg/logger"
+	"github.com/rjonczy/log-genie/pkg/tenant"
+)
+
+// Body formats
+const (
+	// FormatNDJSON posts one JSON object per line
+	FormatNDJSON = "ndjson"
+	// FormatJSONArray posts a JSON array of objects
+	FormatJSONArray = "json-array"
+)
+
+const (
+	defaultBatch    = 500
+	defaultInterval = time.Second
+	defaultTimeout  = 30 * time.Second
+)
+
+// Preset configures the sink for a common agent's HTTP input
+type Preset struct {
+	URL         string // where the agent listens by default
+	Format      string
+	ContentType string
+	Batch       int
+}
+
+// presets are the agents the sink is preconfigured for: Vector's
+// http_server source decoding newline-delimited JSON, and Fluent Bit's http
+// input, which tags the records by the path
+var presets = map[string]Preset{
+	"vector": {
+		URL:         "http://localhost:8080/",
+		Format:      FormatNDJSON,
+		ContentType: "application/x-ndjson",
+		Batch:       1000,
+	},
+	"fluent-bit": {
+		URL:         "http://localhost:9880/log-genie",
+		Format:      FormatJSONArray,
+		ContentType: "application/json",
+		Batch:       500,
+	},
+}
+
+// Presets returns the names of the presets
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupPreset returns a preset by name
+func LookupPreset(name string) (Preset, error) {
+	p, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown HTTP preset %q (use %s)", name, strings.Join(Presets(), " or "))
+	}
+	return p, nil
+}
+
+// ParseFormat validates a body format, ndjson if empty
+func ParseFormat(format string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case "":
+		return FormatNDJSON, nil
+	case FormatNDJSON, FormatJSONArray:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown HTTP format %q (use %s or %s)", format, FormatNDJSON, FormatJSONArray)
+}
+
+// Config holds the configuration of the HTTP sink. Fields left empty are
+// taken from the preset, if any, then from the defaults.
+type Config struct {
+	Preset       string        // agent the sink is preconfigured for, see Presets
+	URL          string        // where the batches are posted; {tenant} is replaced by the tenant of the batch
+	Format       string        // FormatNDJSON or FormatJSONArray (default ndjson)
+	Batch        int           // logs per request, sent once full (default 500)
+	Interval     time.Duration // longest a log waits to be sent (default 1s)
+	Timeout      time.Duration // bound on a request (default 30s)
+	TenantHeader string        // header naming the tenant of each request, e.g. X-Scope-OrgID
+}
+
+// TenantPlaceholder is replaced in the URL by the tenant of a batch
+const TenantPlaceholder = "{tenant}"
+
+// Sink posts the logs as JSON objects in batches: the fields of a log with
+// its timestamp, level and message. A batch fills up in Write, which posts it
+// and so paces generation by the receiver; the rest is posted every
+// interval. With a tenant header or a URL naming the tenant, the logs of
+// each tenant are batched and posted apart.
+type Sink struct {
+	url          string
+	format       string
+	contentType  string
+	batch        int
+	tenantHeader string
+	routed       bool
+	client       *http.Client
+	mutex        sync.Mutex
+	batches      map[string]*batch // by tenant, empty unless routed
+	done         chan struct{}
+	stopped      sync.WaitGroup
+}
+
+// batch holds the logs of a request
+type batch struct {
+	body    bytes.Buffer
+	pending int
+}
+
+// New creates an HTTP sink
+func New(config Config) (*Sink, error) {
+	contentType := ""
+	if config.Preset != "" {
+		p, err := LookupPreset(config.Preset)
+		if err != nil {
+			return nil, err
+		}
+		if config.URL == "" {
+			config.URL = p.URL
+		}
+		if config.Format == "" {
+			config.Format, contentType = p.Format, p.ContentType
+		}
+		if config.Batch <= 0 {
+			config.Batch = p.Batch
+		}
+	}
+	format, err := ParseFormat(config.Format)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = "application/x-ndjson"
+		if format == FormatJSONArray {
+			contentType = "application/json"
+		}
+	}
+	u, err := url.Parse(strings.ReplaceAll(config.URL, TenantPlaceholder, "tenant"))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid HTTP sink URL %q: use http(s)://host/path", config.URL)
+	}
+	if config.Batch <= 0 {
+		config.Batch = defaultBatch
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	s := &Sink{
+		url:          config.URL,
+		format:       format,
+		contentType:  contentType,
+		batch:        config.Batch,
+		tenantHeader: config.TenantHeader,
+		routed:       config.TenantHeader != "" || strings.Contains(config.URL, TenantPlaceholder),
+		client:       &http.Client{Timeout: config.Timeout},
+		batches:      map[string]*batch{},
+		done:         make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go s.run(config.Interval)
+	return s, nil
+}
+
+// URL returns where the batches are posted
+func (s *Sink) URL() string {
+	return s.url
+}
+
+// Write adds a log to the batch of its tenant, posting the batch it fills
+func (s *Sink) Write(e logger.Entry) error {
+	record, err := json.Marshal(document(e))
+	if err != nil {
+		return err
+	}
+	name := ""
+	if s.routed {
+		name = tenant.Of(e.Fields)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b := s.batches[name]
+	if b == nil {
+		b = &batch{}
+		s.batches[name] = b
+	}
+	switch {
+	case s.format == FormatNDJSON:
+		b.body.Write(record)
+		b.body.WriteByte('\n')
+	case b.pending == 0:
+		b.body.WriteByte('[')
+		b.body.Write(record)
+	default:
+		b.body.WriteByte(',')
+		b.body.Write(record)
+	}
+	b.pending++
+	if b.pending < s.batch {
+		return nil
+	}
+	return s.post(name, b)
+}
+
+// Flush posts the logs batched so far
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var errs []error
+	for name, b := range s.batches {
+		if err := s.post(name, b); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close posts the batched logs
+func (s *Sink) Close() error {
+	close(s.done)
+	s.stopped.Wait()
+	return s.Flush()
+}
+
+// run posts the batched logs every interval until closed
+func (s *Sink) run(interval time.Duration) {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				diag.Warn("Failed to post logs", "url", s.url, "error", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// post posts the batch of a tenant; the mutex must be held
+func (s *Sink) post(name string, b *batch) error {
+	if b.pending == 0 {
+		return nil
+	}
+	count := b.pending
+	defer func() {
+		b.body.Reset()
+		b.pending = 0
+	}()
+	if s.format == FormatJSONArray {
+		b.body.WriteByte(']')
+	}
+
+	target := strings.ReplaceAll(s.url, TenantPlaceholder, url.PathEscape(name))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, target, bytes.NewReader(b.body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	if s.tenantHeader != "" && name != "" {
+		req.Header.Set(s.tenantHeader, name)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("batch of %d logs: %w", count, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("batch of %d logs: %s answered %s", count, target, resp.Status)
+	}
+	return nil
+}
+
+// document returns the JSON object of a log, keeping a generated timestamp
+// field
+func document(e logger.Entry) map[string]interface{} {
+	doc := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		doc[k] = v
+	}
+	timestamp := e.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if _, ok := doc["timestamp"]; !ok {
+		doc["timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	doc["level"] = string(e.Level)
+	doc["message"] = e.Message
+	return doc
+}